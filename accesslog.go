@@ -0,0 +1,172 @@
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Fields of the entries of HTTPMiddleware.
+const (
+	HTTPMethodKey  = "http_method"
+	HTTPPathKey    = "http_path"
+	HTTPStatusKey  = "http_status"
+	HTTPLatencyKey = "http_latency"
+	HTTPBytesKey   = "http_bytes"
+	RemoteAddrKey  = "remote_addr"
+	RequestIDKey   = "request_id"
+)
+
+// RequestIDHeader is the HTTP header carrying the ID of a request, see
+// HTTPMiddleware.
+const RequestIDHeader = "X-Request-ID"
+
+// AccessLog configures the access log of HTTP requests.
+type AccessLog struct {
+	// Fields lists the fields of the entries, among http_method,
+	// http_path, http_status, http_latency, http_bytes, remote_addr and
+	// request_id. nil means all of them.
+	Fields []string
+	// Levels maps a status class, 4 for 4xx, to the level of the entries,
+	// overriding the default: ERROR for 5xx, WARNING for 4xx and INFO
+	// for the others.
+	Levels map[int]string
+}
+
+// HTTPMiddleware logs an access entry per request served by next, with the
+// default AccessLog.
+//
+//	http.ListenAndServe(":8080", log.HTTPMiddleware(mux))
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return (&AccessLog{}).Middleware(next)
+}
+
+// Middleware logs an entry "<method> <path> <status>" per request served
+// by next, once the handler returns, with the fields http_method,
+// http_path, http_status, http_latency, the time the handler took,
+// http_bytes, the size of the response body, remote_addr and request_id,
+// and those of the request context, see NewContext. The request ID is the
+// correlation ID of CorrelationMiddleware if it wraps Middleware, that of
+// the X-Request-ID header if valid, a new one from the ID generator
+// otherwise; it is echoed in the response header and stored in the request
+// context, so that the entries logged while serving the request carry it
+// too. Requests are logged at the level of their status class, see Levels.
+// An invalid level in Levels is reported and the default kept.
+func (a *AccessLog) Middleware(next http.Handler) http.Handler {
+	levels := [6]log.Level{log.InfoLevel, log.InfoLevel, log.InfoLevel, log.InfoLevel, log.WarnLevel, log.ErrorLevel}
+	for class, name := range a.Levels {
+		lvl, err := parseLevel(name)
+		switch {
+		case class < 1 || class >= len(levels):
+			reportError(fmt.Errorf("access log: no status class %dxx", class))
+		case err != nil:
+			reportError(fmt.Errorf("access log: %v", err))
+		default:
+			levels[class] = lvl
+		}
+	}
+	var keep map[string]bool
+	if a.Fields != nil {
+		keep = make(map[string]bool, len(a.Fields))
+		for _, k := range a.Fields {
+			keep[k] = true
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id, _ := ContextFields(r.Context())[CorrelationIDKey].(string)
+		if id == "" {
+			if id = r.Header.Get(RequestIDHeader); !validCorrelationID(id) {
+				id = NewID()
+			}
+		}
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(NewContext(r.Context(), Fields{RequestIDKey: id}))
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		level := log.InfoLevel
+		if class := sw.status / 100; class >= 1 && class < len(levels) {
+			level = levels[class]
+		}
+		if !enabled(level) {
+			return
+		}
+		fields := ctxData(r.Context())
+		for k, v := range map[string]interface{}{
+			HTTPMethodKey:  r.Method,
+			HTTPPathKey:    r.URL.Path,
+			HTTPStatusKey:  sw.status,
+			HTTPLatencyKey: time.Since(start),
+			HTTPBytesKey:   sw.bytes,
+			RemoteAddrKey:  r.RemoteAddr,
+			RequestIDKey:   id,
+		} {
+			if keep == nil || keep[k] {
+				fields[k] = v
+			} else {
+				delete(fields, k)
+			}
+		}
+		// The caller of the handler is net/http, the entry is attributed to
+		// the middleware instead.
+		emit(level, captureCaller(level, 0), fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, sw.status), fields)
+	})
+}
+
+// statusWriter records the status and the body size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the wrapped writer does.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the wrapped writer does, e.g. for
+// WebSocket upgrades.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not support hijacking", w.ResponseWriter)
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
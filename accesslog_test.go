@@ -0,0 +1,85 @@
+package log
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	h := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		InfoCtx(r.Context(), "serving")
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest("GET", "/flows?id=7", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get(RequestIDHeader); got != "req-1" {
+		t.Errorf("response %s = %q, want req-1", RequestIDHeader, got)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "serving") || !strings.Contains(lines[0], "request_id=req-1") {
+		t.Fatalf("output = %q, want the handler entry with the request ID, then the access entry", buf.String())
+	}
+	for _, want := range []string{"INFO", "GET /flows 200", "http_method=GET", "http_path=/flows", "http_status=200", "http_bytes=5", "http_latency=", "remote_addr=192.0.2.1:1234", "request_id=req-1"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("access entry is missing %q: %s", want, lines[1])
+		}
+	}
+
+	buf.Reset()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	if out := buf.String(); !strings.Contains(out, "WARNING") || !strings.Contains(out, "http_status=404") || strings.Contains(out, "request_id=req-1") {
+		t.Errorf("output = %q, want a warning with a new request ID", out)
+	}
+}
+
+func TestAccessLogConfig(t *testing.T) {
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetLevel("debug")
+	defer SetLevel("info")
+
+	a := &AccessLog{
+		Fields: []string{HTTPStatusKey},
+		Levels: map[int]string{2: "debug", 5: "warning"},
+	}
+	h := CorrelationMiddleware(a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(CorrelationHeader, "corr-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get(RequestIDHeader); got != "corr-1" {
+		t.Errorf("request ID = %q, want the correlation ID", got)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "DEBUG") || !strings.Contains(lines[1], "WARNING") || !strings.Contains(lines[1], "http_status=503") {
+		t.Fatalf("output = %q, want a debug and a warning entry", buf.String())
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "http_method") || strings.Contains(line, "request_id") {
+			t.Errorf("entry has fields left out: %s", line)
+		}
+	}
+}
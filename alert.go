@@ -0,0 +1,182 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// alertWebhookTimeout bounds a request of AlertWebhook.
+const alertWebhookTimeout = 10 * time.Second
+
+// AlertRule declares an alert on the entries of the process, for hosts
+// without an alerting stack: it fires when more than Threshold entries
+// selected by the rule are logged within Window, e.g. more than 10 errors
+// of db.go:42 in a minute:
+//
+//	log.AddAlertRule(log.AlertRule{
+//		Name:        "db-refused",
+//		Level:       "error",
+//		Fingerprint: "db.go:42",
+//		Threshold:   10,
+//		Window:      time.Minute,
+//	}, log.AlertWebhook("http://alerts.local/hook"))
+type AlertRule struct {
+	Name string
+	// Level is the least severe level of the entries counted.
+	Level string
+	// Fingerprint, if set, restricts the rule to the entries logged from
+	// file:line, the base name of the file sufficing, or with this
+	// message if their caller is not recorded.
+	Fingerprint string
+	// Match, if set, restricts the rule to the entries it selects.
+	Match     Matcher
+	Threshold int
+	Window    time.Duration
+}
+
+// Alert is a firing of an AlertRule.
+type Alert struct {
+	Rule string `json:"rule"`
+	// Count is the number of entries within the window, Threshold+1.
+	Count  int           `json:"count"`
+	Window time.Duration `json:"window"`
+	// Time, Level and Message are those of the entry firing the alert.
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+// alertRule is an AlertRule being evaluated.
+type alertRule struct {
+	AlertRule
+	level log.Level
+	fn    func(Alert)
+
+	// hits holds the times of the last Threshold+1 entries counted,
+	// entry i at i%len(hits).
+	hits []time.Time
+	n    int
+	// quiet is when the rule may fire again.
+	quiet time.Time
+}
+
+var alerts = struct {
+	mu     sync.Mutex
+	rules  []*alertRule
+	active int32
+	now    func() time.Time
+}{now: time.Now}
+
+// AddAlertRule evaluates r on every entry passing the level, before
+// sampling, deduplication and the rate limit so that suppressed repeats
+// count, and calls fn in a goroutine of its own when it fires. Once fired,
+// the rule is silent for a window.
+func AddAlertRule(r AlertRule, fn func(Alert)) error {
+	lvl, err := parseLevel(r.Level)
+	if err != nil {
+		return err
+	}
+	if r.Threshold < 0 || r.Window <= 0 {
+		return fmt.Errorf("alert rule %s: threshold must not be negative and window positive", r.Name)
+	}
+
+	alerts.mu.Lock()
+	alerts.rules = append(alerts.rules, &alertRule{
+		AlertRule: r,
+		level:     lvl,
+		fn:        fn,
+		hits:      make([]time.Time, r.Threshold+1),
+	})
+	atomic.StoreInt32(&alerts.active, 1)
+	alerts.mu.Unlock()
+	return nil
+}
+
+// ClearAlertRules removes every alert rule.
+func ClearAlertRules() {
+	alerts.mu.Lock()
+	alerts.rules = nil
+	atomic.StoreInt32(&alerts.active, 0)
+	alerts.mu.Unlock()
+}
+
+// AlertWebhook returns an alert callback posting every alert as a JSON
+// object to url. Failures are reported to the self-log.
+func AlertWebhook(url string) func(Alert) {
+	client := &http.Client{Timeout: alertWebhookTimeout}
+	return func(a Alert) {
+		b, err := json.Marshal(a)
+		if err != nil {
+			reportError(fmt.Errorf("alert webhook %s: %v", url, err))
+			return
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(b))
+		if err != nil {
+			reportError(fmt.Errorf("alert webhook %s: %v", url, err))
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			reportError(fmt.Errorf("alert webhook %s: %s", url, resp.Status))
+		}
+	}
+}
+
+// evaluateAlerts counts an entry for the alert rules selecting it.
+func evaluateAlerts(level log.Level, site callSite, msg string, fields log.Fields) {
+	if atomic.LoadInt32(&alerts.active) == 0 {
+		return
+	}
+	alerts.mu.Lock()
+	defer alerts.mu.Unlock()
+
+	now := alerts.now()
+	where := fingerprint(site, msg)
+	for _, r := range alerts.rules {
+		if level > r.level || !matchFingerprint(r.Fingerprint, where) {
+			continue
+		}
+		if r.Match != nil && !r.Match(msg, Fields(fields)) {
+			continue
+		}
+		r.hits[r.n%len(r.hits)] = now
+		r.n++
+		oldest := r.hits[r.n%len(r.hits)]
+		if r.n < len(r.hits) || now.Sub(oldest) > r.Window || now.Before(r.quiet) {
+			continue
+		}
+		r.quiet = now.Add(r.Window)
+		go r.fn(Alert{
+			Rule:    r.Name,
+			Count:   len(r.hits),
+			Window:  r.Window,
+			Time:    now,
+			Level:   severityName(level),
+			Message: msg,
+		})
+	}
+}
+
+// fingerprint identifies the place an entry was logged from: the base name
+// of the file and the line, or the message if the caller is not recorded.
+func fingerprint(site callSite, msg string) string {
+	if site.file == "" {
+		return msg
+	}
+	return filepath.Base(site.file) + ":" + strconv.Itoa(site.line)
+}
+
+// matchFingerprint reports whether the fingerprint of an entry matches the
+// one of a rule, the empty one matching every entry.
+func matchFingerprint(rule, where string) bool {
+	return rule == "" || where == rule || where == filepath.Base(rule)
+}
@@ -0,0 +1,124 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAlertRule(t *testing.T) {
+	SetOutputs(io.Discard)
+	defer SetOutputs(os.Stderr)
+	// Without callers, the message is the fingerprint.
+	SetReportCaller(false)
+	defer SetReportCaller(true)
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	alerts.now = func() time.Time { return now }
+	defer func() { alerts.now = time.Now }()
+	defer ClearAlertRules()
+
+	fired := make(chan Alert, 10)
+	err := AddAlertRule(AlertRule{
+		Name:        "refused",
+		Level:       "error",
+		Fingerprint: "connection refused",
+		Threshold:   2,
+		Window:      time.Minute,
+	}, func(a Alert) { fired <- a })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step := func(d time.Duration, level, msg string) {
+		now = now.Add(d)
+		switch level {
+		case "error":
+			Error(msg)
+		case "warning":
+			Warning(msg)
+		}
+	}
+	step(0, "error", "connection refused")
+	step(10*time.Second, "warning", "connection refused")
+	step(10*time.Second, "error", "connection reset")
+	step(10*time.Second, "error", "connection refused")
+	// The first entry left the window.
+	step(45*time.Second, "error", "connection refused")
+	select {
+	case a := <-fired:
+		t.Fatalf("alert fired early: %+v", a)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	step(time.Second, "error", "connection refused")
+	select {
+	case a := <-fired:
+		if a.Rule != "refused" || a.Count != 3 || a.Message != "connection refused" || !a.Time.Equal(now) {
+			t.Errorf("alert = %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert did not fire")
+	}
+
+	// Silent for a window after firing.
+	step(time.Second, "error", "connection refused")
+	step(time.Second, "error", "connection refused")
+	select {
+	case a := <-fired:
+		t.Fatalf("alert fired again: %+v", a)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAddAlertRuleInvalid(t *testing.T) {
+	defer ClearAlertRules()
+	for _, r := range []AlertRule{
+		{Level: "loud", Threshold: 1, Window: time.Minute},
+		{Level: "error", Threshold: -1, Window: time.Minute},
+		{Level: "error", Threshold: 1},
+	} {
+		if err := AddAlertRule(r, func(Alert) {}); err == nil {
+			t.Errorf("AddAlertRule(%+v) succeeded", r)
+		}
+	}
+}
+
+func TestMatchFingerprint(t *testing.T) {
+	for _, tt := range []struct {
+		rule string
+		want bool
+	}{
+		{"", true},
+		{"db.go:42", true},
+		{"/src/db.go:42", true},
+		{"db.go:43", false},
+	} {
+		if got := matchFingerprint(tt.rule, "db.go:42"); got != tt.want {
+			t.Errorf("matchFingerprint(%q) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestAlertWebhook(t *testing.T) {
+	got := make(chan map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		got <- body
+	}))
+	defer srv.Close()
+
+	AlertWebhook(srv.URL)(Alert{Rule: "refused", Count: 11, Window: time.Minute, Level: "error", Message: "connection refused"})
+	select {
+	case body := <-got:
+		if body["rule"] != "refused" || body["count"] != float64(11) || body["message"] != "connection refused" {
+			t.Errorf("posted %v", body)
+		}
+	default:
+		t.Fatal("nothing posted")
+	}
+}
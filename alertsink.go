@@ -0,0 +1,493 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Defaults of an AlertSink.
+const (
+	DefaultAlertWindow  = time.Minute
+	DefaultAlertEntries = 20
+	// alertSummarySize is the length of AlertBatch.Summary, the limit of
+	// a PagerDuty summary.
+	alertSummarySize = 1024
+)
+
+// Payload templates of an AlertSink for common destinations.
+const (
+	// SlackTemplate posts the batch to a Slack incoming webhook.
+	SlackTemplate = `{"text":{{json .Summary}},"blocks":[{"type":"section","text":{"type":"mrkdwn","text":{{json (printf "*%s*\n` + "```" + `%s` + "```" + `" .Summary .Text)}}}}]}`
+	// EmailTemplate is the message sent by EmailAlert, the summary as
+	// the subject and an entry per line as the body.
+	EmailTemplate = "Subject: {{.Summary}}\nContent-Type: text/plain; charset=utf-8\n\n{{.Text}}{{if .Omitted}}... and {{.Omitted}} more\n{{end}}"
+)
+
+// PagerDutyTemplate returns the payload template triggering a PagerDuty
+// Events API v2 incident for the service of routingKey, FATAL and PANIC
+// batches being critical. The incidents of a process are deduplicated
+// until resolved.
+func PagerDutyTemplate(routingKey string) string {
+	key, _ := json.Marshal(routingKey)
+	return `{"routing_key":` + string(key) + `,"event_action":"trigger","dedup_key":{{json (printf "%s@%s" .Tag .Host)}},` +
+		`"payload":{"summary":{{json .Summary}},"source":{{json .Host}},"component":{{json .Tag}},` +
+		`"severity":{{if .Fatal}}"critical"{{else}}"error"{{end}},"custom_details":{"entries":{{json .Text}},"omitted":{{.Omitted}}}}}`
+}
+
+// AlertSender delivers the payload rendered for a batch of alerts, see
+// WebhookAlert and EmailAlert. It should give up when ctx is done.
+type AlertSender func(ctx context.Context, payload []byte) error
+
+// AlertSinkConfig configures an AlertSink.
+type AlertSinkConfig struct {
+	// Level is the least severe level sent, error if empty.
+	Level string
+	// Window is how long the first entry of a batch waits for others,
+	// DefaultAlertWindow if zero. FATAL and PANIC entries are sent at
+	// once, before the process exits.
+	Window time.Duration
+	// Interval is the least time between two batches, the rate limit:
+	// the entries logged meanwhile wait for the next one. Window if zero.
+	Interval time.Duration
+	// MaxEntries is the number of entries of a batch, DefaultAlertEntries
+	// if zero; the others are counted in AlertBatch.Omitted.
+	MaxEntries int
+	// Template is a text/template rendering the payload from an
+	// AlertBatch, the batch as JSON if empty. The function json encodes a
+	// value as JSON, e.g. {"text":{{json .Summary}}}.
+	Template string
+	Send     AlertSender
+}
+
+// AlertBatch is what the template of an AlertSink is executed with.
+type AlertBatch struct {
+	Host string `json:"host"`
+	Tag  string `json:"tag"`
+	// Level is the most severe level of the entries and Fatal is set if
+	// it is FATAL or PANIC.
+	Level   string  `json:"level"`
+	Fatal   bool    `json:"fatal"`
+	Entries []Entry `json:"entries"`
+	// Omitted is the number of entries beyond MaxEntries.
+	Omitted int `json:"omitted"`
+}
+
+// MarshalJSON encodes the batch with the entries in the format of
+// JSONFormatter.
+func (b AlertBatch) MarshalJSON() ([]byte, error) {
+	type batch AlertBatch
+	entries := make([]json.RawMessage, len(b.Entries))
+	for i := range b.Entries {
+		e, err := entryJSON(&b.Entries[i])
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = e
+	}
+	return json.Marshal(struct {
+		batch
+		Entries []json.RawMessage `json:"entries"`
+	}{batch(b), entries})
+}
+
+// Summary returns a line describing the batch, such as
+//
+//	probe@sensor-3: 3 error entries: capture failed
+//
+// with the message of the first entry, at most 1024 bytes.
+func (b *AlertBatch) Summary() string {
+	n := len(b.Entries) + b.Omitted
+	s := fmt.Sprintf("%s@%s: %d %s entries", b.Tag, b.Host, n, b.Level)
+	if n == 1 {
+		s = fmt.Sprintf("%s@%s: %s", b.Tag, b.Host, b.Level)
+	}
+	if len(b.Entries) > 0 {
+		s += ": " + strings.Join(strings.Fields(b.Entries[0].Message), " ")
+	}
+	if len(s) > alertSummarySize {
+		cut := alertSummarySize
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	return s
+}
+
+// Text returns the entries a line each, with the time, the level, the
+// caller, the message and the fields sorted by key.
+func (b *AlertBatch) Text() string {
+	var t []byte
+	for _, e := range b.Entries {
+		t = append(t, e.Time.Format(time.RFC3339)...)
+		t = append(t, ' ')
+		t = append(t, e.Level...)
+		if e.File != "" {
+			t = append(t, fmt.Sprintf(" %s:%d", e.File, e.Line)...)
+		}
+		t = append(t, ' ')
+		t = append(t, e.Message...)
+		keys := make([]string, 0, len(e.Fields))
+		for k := range e.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			t = append(t, ' ')
+			t = append(t, k...)
+			t = append(t, '=')
+			t = appendValue(t, e.Fields[k])
+		}
+		t = append(t, '\n')
+	}
+	return string(t)
+}
+
+// AlertSink sends the ERROR and more severe entries to an alerting
+// destination, for small deployments without a log aggregator:
+//
+//	s, err := log.NewAlertSink(log.AlertSinkConfig{
+//		Template: log.SlackTemplate,
+//		Send:     log.WebhookAlert("https://hooks.slack.com/services/...", "application/json"),
+//		Interval: 10 * time.Minute,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	log.AddAlertSink(s)
+//
+// Entries are batched for a window and sent at most once per interval.
+// Failed sends are reported to the self-log and in the delivery reports,
+// they are not retried.
+type AlertSink struct {
+	cfg   AlertSinkConfig
+	level log.Level
+	tmpl  *template.Template
+
+	mu      sync.Mutex
+	batch   []Entry
+	omitted int
+	first   time.Time
+	pending int64
+	closed  bool
+
+	// wake is signalled by the first entry of a batch, now by FATAL and
+	// PANIC entries and Flush.
+	wake, now chan struct{}
+
+	// ctx is cancelled by Close, aborting the send in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewAlertSink returns an AlertSink sending with cfg.Send.
+func NewAlertSink(cfg AlertSinkConfig) (*AlertSink, error) {
+	if cfg.Send == nil {
+		return nil, errors.New("alert sink: no sender")
+	}
+	if cfg.Level == "" {
+		cfg.Level = "error"
+	}
+	lvl, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("alert sink: %v", err)
+	}
+	if cfg.Window < 0 || cfg.Interval < 0 || cfg.MaxEntries < 0 {
+		return nil, errors.New("alert sink: window, interval and max entries must not be negative")
+	}
+	if cfg.Window == 0 {
+		cfg.Window = DefaultAlertWindow
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = cfg.Window
+	}
+	if cfg.MaxEntries == 0 {
+		cfg.MaxEntries = DefaultAlertEntries
+	}
+	s := &AlertSink{
+		cfg:   cfg,
+		level: lvl,
+		wake:  make(chan struct{}, 1),
+		now:   make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if cfg.Template != "" {
+		funcs := template.FuncMap{"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		}}
+		if s.tmpl, err = template.New("alert").Funcs(funcs).Parse(cfg.Template); err != nil {
+			return nil, fmt.Errorf("alert sink: %v", err)
+		}
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	return s, nil
+}
+
+// AddAlertSink registers s with the package logger.
+func AddAlertSink(s *AlertSink) {
+	addHook(s)
+	addFlusher(s)
+}
+
+// Name returns the name of the sink in the delivery reports.
+func (s *AlertSink) Name() string {
+	return "alert:" + s.cfg.Level
+}
+
+// Levels implements logrus.Hook.
+func (s *AlertSink) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook. It never blocks.
+func (s *AlertSink) Fire(entry *log.Entry) error {
+	if entry.Level > s.level {
+		return nil
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	if len(s.batch) == 0 && s.omitted == 0 {
+		s.first = time.Now()
+	}
+	if len(s.batch) < s.cfg.MaxEntries {
+		s.batch = append(s.batch, toEntry(entry))
+	} else {
+		s.omitted++
+	}
+	atomic.AddInt64(&s.pending, 1)
+	s.mu.Unlock()
+
+	wakeUp(s.wake)
+	if entry.Level <= log.FatalLevel {
+		wakeUp(s.now)
+	}
+	return nil
+}
+
+// wakeUp wakes up the goroutine waiting on c, unless it is woken up
+// already.
+func wakeUp(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Flush sends the entries waiting for their batch at once and waits until
+// they are sent.
+func (s *AlertSink) Flush() error {
+	return s.FlushContext(context.Background())
+}
+
+// FlushContext is Flush giving up when ctx is done.
+func (s *AlertSink) FlushContext(ctx context.Context) error {
+	if atomic.LoadInt64(&s.pending) > 0 {
+		wakeUp(s.now)
+	}
+	return waitPending(ctx, &s.pending, s.done, "alert sink closed with entries pending")
+}
+
+// Close stops the sink, aborting a send in progress, and removes it from
+// the package logger. Entries waiting for their batch are discarded; call
+// Flush first to send them.
+func (s *AlertSink) Close() error {
+	s.closeOnce.Do(func() {
+		removeHook(s)
+		removeFlusher(s)
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		s.cancel()
+		close(s.stop)
+	})
+	<-s.done
+	s.mu.Lock()
+	atomic.AddInt64(&s.pending, -int64(len(s.batch)+s.omitted))
+	s.batch, s.omitted = nil, 0
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *AlertSink) run() {
+	defer close(s.done)
+
+	var last time.Time
+	for {
+		now := false
+		select {
+		case <-s.wake:
+		case <-s.now:
+			now = true
+		case <-s.stop:
+			return
+		}
+		if !now {
+			s.mu.Lock()
+			due := s.first.Add(s.cfg.Window)
+			s.mu.Unlock()
+			if next := last.Add(s.cfg.Interval); next.After(due) {
+				due = next
+			}
+			timer := time.NewTimer(time.Until(due))
+			select {
+			case <-timer.C:
+			case <-s.now:
+				timer.Stop()
+			case <-s.stop:
+				timer.Stop()
+				return
+			}
+		}
+
+		s.mu.Lock()
+		b := AlertBatch{Host: entryHost(nil), Tag: tag, Entries: s.batch, Omitted: s.omitted}
+		n := len(s.batch) + s.omitted
+		s.batch, s.omitted = nil, 0
+		s.mu.Unlock()
+		if n == 0 {
+			continue
+		}
+		last = time.Now()
+		s.send(&b, n)
+		atomic.AddInt64(&s.pending, -int64(n))
+	}
+}
+
+// send renders and sends b, of n entries, and reports the outcome.
+func (s *AlertSink) send(b *AlertBatch, n int) {
+	level := log.DebugLevel
+	for _, e := range b.Entries {
+		if l, err := parseLevel(e.Level); err == nil && l < level {
+			level = l
+		}
+	}
+	b.Level, b.Fatal = severityName(level), level <= log.FatalLevel
+
+	payload, err := s.render(b)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(s.ctx, alertWebhookTimeout)
+		err = s.cfg.Send(ctx, payload)
+		cancel()
+	}
+	if err != nil {
+		reportError(fmt.Errorf("output %s: %v", s.Name(), err))
+		reportDelivery(DeliveryReport{Output: s.Name(), Failed: n, Err: err})
+		return
+	}
+	reportDelivery(DeliveryReport{Output: s.Name(), Delivered: n})
+}
+
+// render returns the payload of b.
+func (s *AlertSink) render(b *AlertBatch) ([]byte, error) {
+	if s.tmpl == nil {
+		return json.Marshal(b)
+	}
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, b); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WebhookAlert returns an AlertSender posting the payload to url with the
+// content type, e.g. application/json for Slack and PagerDuty.
+func WebhookAlert(url, contentType string) AlertSender {
+	client := &http.Client{}
+	return func(ctx context.Context, payload []byte) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
+
+// EmailAlert returns an AlertSender mailing the payload, a message such as
+// the one of EmailTemplate, from from to the recipients through the SMTP
+// server at addr, host:port. The session is upgraded with STARTTLS when
+// the server offers it; auth may be nil.
+func EmailAlert(addr string, auth smtp.Auth, from string, to ...string) AlertSender {
+	return func(ctx context.Context, payload []byte) error {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer interruptible(ctx, conn)()
+		c, err := smtp.NewClient(conn, host)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		defer c.Close()
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				return err
+			}
+		}
+		if auth != nil {
+			if err := c.Auth(auth); err != nil {
+				return err
+			}
+		}
+		if err := c.Mail(from); err != nil {
+			return err
+		}
+		for _, rcpt := range to {
+			if err := c.Rcpt(rcpt); err != nil {
+				return err
+			}
+		}
+		w, err := c.Data()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "From: %s\nTo: %s\nDate: %s\n", from, strings.Join(to, ", "), time.Now().Format(time.RFC1123Z))
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return c.Quit()
+	}
+}
@@ -0,0 +1,239 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// alertEntry returns an entry of level with msg.
+func alertEntry(level log.Level, msg string) *log.Entry {
+	entry := log.WithFields(log.Fields{"iface": "eth1"})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = level
+	entry.Message = msg
+	return entry
+}
+
+func TestAlertSinkWebhook(t *testing.T) {
+	posts := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type %q", r.Header.Get("Content-Type"))
+		}
+		b, _ := io.ReadAll(r.Body)
+		posts <- b
+	}))
+	defer srv.Close()
+
+	s, err := NewAlertSink(AlertSinkConfig{
+		Template:   SlackTemplate,
+		Send:       WebhookAlert(srv.URL, "application/json"),
+		Window:     20 * time.Millisecond,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Fire(alertEntry(log.ErrorLevel, "capture failed"))
+	s.Fire(alertEntry(log.WarnLevel, "link flap"))
+	s.Fire(alertEntry(log.ErrorLevel, "ring full"))
+	s.Fire(alertEntry(log.ErrorLevel, "ring full"))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg struct {
+		Text string `json:"text"`
+	}
+	b := <-posts
+	if err := json.Unmarshal(b, &msg); err != nil {
+		t.Fatalf("payload %q is not JSON: %v", b, err)
+	}
+	if !strings.HasSuffix(msg.Text, ": 3 error entries: capture failed") {
+		t.Errorf("text = %q", msg.Text)
+	}
+	if strings.Contains(string(b), "link flap") || !strings.Contains(string(b), "ring full iface=eth1") {
+		t.Errorf("payload = %s, want the errors only", b)
+	}
+	select {
+	case b := <-posts:
+		t.Errorf("second post %s", b)
+	default:
+	}
+}
+
+func TestAlertSinkBatching(t *testing.T) {
+	sent := make(chan AlertBatch, 10)
+	s, err := NewAlertSink(AlertSinkConfig{
+		Window:   time.Hour,
+		Interval: time.Hour,
+		Send: func(ctx context.Context, payload []byte) error {
+			var b AlertBatch
+			err := json.Unmarshal(payload, &b)
+			sent <- b
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Fire(alertEntry(log.ErrorLevel, "capture failed"))
+	select {
+	case b := <-sent:
+		t.Fatalf("batch sent before the window: %+v", b)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// FATAL entries do not wait.
+	s.Fire(alertEntry(log.FatalLevel, "out of memory"))
+	select {
+	case b := <-sent:
+		if len(b.Entries) != 2 || b.Level != "fatal" || !b.Fatal {
+			t.Errorf("batch = %+v, want both entries as FATAL", b)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("FATAL entry not sent")
+	}
+}
+
+func TestAlertSinkInvalid(t *testing.T) {
+	send := func(context.Context, []byte) error { return nil }
+	for _, cfg := range []AlertSinkConfig{
+		{},
+		{Send: send, Level: "loud"},
+		{Send: send, Window: -time.Second},
+		{Send: send, Template: "{{.Summary"},
+	} {
+		if _, err := NewAlertSink(cfg); err == nil {
+			t.Errorf("NewAlertSink(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestAlertBatchSummary(t *testing.T) {
+	b := AlertBatch{Host: "sensor-3", Tag: "probe", Level: "error", Entries: []Entry{{Message: "capture\nfailed"}}}
+	if s := b.Summary(); s != "probe@sensor-3: error: capture failed" {
+		t.Errorf("Summary() = %q", s)
+	}
+	b.Entries[0].Message = strings.Repeat("é", 1000)
+	if s := b.Summary(); len(s) > alertSummarySize || !strings.HasSuffix(s, "é") {
+		t.Errorf("Summary() of a long message has %d bytes, ends with %q", len(s), s[len(s)-2:])
+	}
+	var pd map[string]interface{}
+	tmpl, err := NewAlertSink(AlertSinkConfig{Template: PagerDutyTemplate("key\"1"), Send: func(context.Context, []byte) error { return nil }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpl.Close()
+	payload, err := tmpl.render(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(payload, &pd); err != nil || pd["routing_key"] != "key\"1" {
+		t.Errorf("PagerDuty payload %s, %v", payload, err)
+	}
+}
+
+func TestAlertSinkEmail(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	mail := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ESMTP\r\n"))
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				mail <- data.String()
+				conn.Write([]byte("250 OK\r\n"))
+			case inData:
+				data.WriteString(line)
+			case strings.HasPrefix(line, "EHLO"):
+				conn.Write([]byte("250 localhost\r\n"))
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				conn.Write([]byte("354 go ahead\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+
+	s, err := NewAlertSink(AlertSinkConfig{
+		Template: EmailTemplate,
+		Send:     EmailAlert(l.Addr().String(), nil, "probe@example.com", "ops@example.com"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Fire(alertEntry(log.ErrorLevel, "capture failed"))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mail:
+		for _, want := range []string{"From: probe@example.com\r\n", "To: ops@example.com\r\n", "Subject: ", ": error: capture failed\r\n", "\r\n\r\n2017-03-01T12:00:00Z error capture failed iface=eth1\r\n"} {
+			if !strings.Contains(m, want) {
+				t.Errorf("mail %q does not contain %q", m, want)
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no mail")
+	}
+}
+
+func TestAlertSinkClose(t *testing.T) {
+	SetOutputs(io.Discard)
+	defer SetOutputs(os.Stderr)
+	s, err := NewAlertSink(AlertSinkConfig{
+		Window: time.Hour,
+		Send:   func(context.Context, []byte) error { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddAlertSink(s)
+	Error("waits for its batch")
+	s.Close()
+	if err := Flush(); err != nil {
+		t.Errorf("Flush after closing an alert sink with a batch: %v", err)
+	}
+	if n := atomic.LoadInt64(&s.pending); n != 0 {
+		t.Errorf("pending = %d after Close", n)
+	}
+}
@@ -0,0 +1,262 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// archiveMagic starts an encrypted archive. It is followed by the length
+// of the key ID, the key ID, and chunks of up to archiveChunk bytes, each
+// a last flag, the length of the sealed chunk, the nonce and the chunk
+// sealed with AES-GCM. The header, the chunk number and the flag are
+// authenticated with every chunk, so reordered, truncated or foreign
+// chunks are detected.
+const archiveMagic = "GOLOGENC1"
+
+const archiveChunk = 64 << 10
+
+var archiveKey struct {
+	sync.Mutex
+	id   string
+	aead cipher.AEAD
+}
+
+// SetArchiveKey makes the rotation encrypt the files it archives, after
+// compressing them, with the AES key of 16, 24 or 32 bytes, adding .enc to
+// their names. The key ID is recorded in every archive so OpenArchive picks
+// the key of each file: rotating keys only needs the new key set here and
+// the old ones kept for reading. A nil key stops encrypting.
+func SetArchiveKey(id string, key []byte) error {
+	if key == nil {
+		archiveKey.Lock()
+		archiveKey.id, archiveKey.aead = "", nil
+		archiveKey.Unlock()
+		return nil
+	}
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("archive key ID must be 1 to 255 bytes, got %d", len(id))
+	}
+	aead, err := newArchiveAEAD(key)
+	if err != nil {
+		return err
+	}
+	archiveKey.Lock()
+	archiveKey.id, archiveKey.aead = id, aead
+	archiveKey.Unlock()
+	return nil
+}
+
+func newArchiveAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// currentArchiveKey returns the key set with SetArchiveKey, nil if none.
+func currentArchiveKey() (string, cipher.AEAD) {
+	archiveKey.Lock()
+	defer archiveKey.Unlock()
+	return archiveKey.id, archiveKey.aead
+}
+
+// archiveAAD returns the data authenticated with chunk n.
+func archiveAAD(header []byte, n uint64, last bool) []byte {
+	aad := make([]byte, len(header)+9)
+	copy(aad, header)
+	binary.BigEndian.PutUint64(aad[len(header):], n)
+	if last {
+		aad[len(aad)-1] = 1
+	}
+	return aad
+}
+
+// encryptFile replaces name with a copy encrypted with the key id, named
+// name.enc.
+func encryptFile(name, id string, aead cipher.AEAD) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := name + ".enc.tmp"
+	out, err := createLogFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	err = encryptArchive(out, in, id, aead)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name+".enc"); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(name)
+}
+
+// encryptArchive writes the contents of r to w in the archive format.
+func encryptArchive(w io.Writer, r io.Reader, id string, aead cipher.AEAD) error {
+	header := append([]byte(archiveMagic), byte(len(id)))
+	header = append(header, id...)
+	bw := bufio.NewWriter(w)
+	bw.Write(header)
+
+	// Reading one byte ahead tells whether a chunk is the last one.
+	br := bufio.NewReaderSize(r, archiveChunk+1)
+	buf := make([]byte, archiveChunk)
+	nonce := make([]byte, aead.NonceSize())
+	var sealed []byte
+	for n := uint64(0); ; n++ {
+		k, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		_, perr := br.Peek(1)
+		last := perr != nil
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		sealed = aead.Seal(sealed[:0], nonce, buf[:k], archiveAAD(header, n, last))
+
+		var prefix [5]byte
+		if last {
+			prefix[0] = 1
+		}
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(nonce)+len(sealed)))
+		bw.Write(prefix[:])
+		bw.Write(nonce)
+		if _, err := bw.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return bw.Flush()
+		}
+	}
+}
+
+// OpenArchive opens a file archived by the rotation for reading: it is
+// decrypted with the key of keys, by key ID, it was encrypted with and
+// decompressed if it is gzipped. Unencrypted files are read as they are.
+func OpenArchive(name string, keys map[string][]byte) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(len(archiveMagic)); string(magic) == archiveMagic {
+		d, err := newArchiveReader(br, keys)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		r = bufio.NewReader(d)
+	}
+	if gz, _ := r.(*bufio.Reader).Peek(2); bytes.Equal(gz, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		r = zr
+	}
+	return &archiveFile{Reader: r, f: f}, nil
+}
+
+type archiveFile struct {
+	io.Reader
+	f *os.File
+}
+
+func (a *archiveFile) Close() error {
+	return a.f.Close()
+}
+
+// archiveReader decrypts the chunks of an archive.
+type archiveReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	n      uint64
+	buf    []byte
+	done   bool
+}
+
+func newArchiveReader(r io.Reader, keys map[string][]byte) (*archiveReader, error) {
+	header := make([]byte, len(archiveMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	id := make([]byte, header[len(header)-1])
+	if _, err := io.ReadFull(r, id); err != nil {
+		return nil, err
+	}
+	key, ok := keys[string(id)]
+	if !ok {
+		return nil, fmt.Errorf("no key for archive key ID %q", id)
+	}
+	aead, err := newArchiveAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveReader{r: r, aead: aead, header: append(header, id...)}, nil
+}
+
+func (a *archiveReader) Read(p []byte) (int, error) {
+	for len(a.buf) == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+		if err := a.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, a.buf)
+	a.buf = a.buf[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk.
+func (a *archiveReader) next() error {
+	var prefix [5]byte
+	if _, err := io.ReadFull(a.r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return errors.New("archive truncated")
+		}
+		return err
+	}
+	last := prefix[0] == 1
+	size := binary.BigEndian.Uint32(prefix[1:])
+	ns := a.aead.NonceSize()
+	if size < uint32(ns+a.aead.Overhead()) || size > uint32(ns+archiveChunk+a.aead.Overhead()) {
+		return fmt.Errorf("invalid archive chunk of %d bytes", size)
+	}
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(a.r, chunk); err != nil {
+		return err
+	}
+	plain, err := a.aead.Open(chunk[ns:ns], chunk[:ns], chunk[ns:], archiveAAD(a.header, a.n, last))
+	if err != nil {
+		return fmt.Errorf("archive chunk %d: %v", a.n, err)
+	}
+	a.n++
+	a.buf, a.done = plain, last
+	return nil
+}
@@ -0,0 +1,99 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveKeyRotation(t *testing.T) {
+	k1, k2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	name := filepath.Join(t.TempDir(), "probe.log")
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := newRotatingFile(name, RotationConfig{MaxSize: 20, Compress: true})
+	r.now = func() time.Time { return now }
+	defer r.Close()
+	defer SetArchiveKey("", nil)
+
+	if err := SetArchiveKey("2017-q1", k1); err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("entry of 15 b.\n"))
+	now = now.Add(time.Second)
+	r.Write([]byte("second entry.\n"))
+	r.Flush()
+	if err := SetArchiveKey("2017-q2", k2); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	r.Write([]byte("third entry..\n"))
+	r.Flush()
+
+	backups, err := r.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || !strings.HasSuffix(backups[0], ".gz.enc") || !strings.HasSuffix(backups[1], ".gz.enc") {
+		t.Fatalf("backups = %q, want two encrypted archives", backups)
+	}
+	keys := map[string][]byte{"2017-q1": k1, "2017-q2": k2}
+	for i, want := range []string{"entry of 15 b.\n", "second entry.\n"} {
+		f, err := OpenArchive(backups[i], keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(b) != want {
+			t.Errorf("archive %d = %q, %v, want %q", i, b, err, want)
+		}
+	}
+
+	if _, err := OpenArchive(backups[1], map[string][]byte{"2017-q1": k1}); err == nil || !strings.Contains(err.Error(), "2017-q2") {
+		t.Errorf("OpenArchive without the key = %v, want an error naming the key ID", err)
+	}
+	if err := SetArchiveKey("bad", []byte("short")); err == nil {
+		t.Error("SetArchiveKey accepted an invalid AES key")
+	}
+}
+
+func TestArchiveTampering(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	aead, _ := newArchiveAEAD(key)
+	var buf bytes.Buffer
+	plain := strings.Repeat("x", archiveChunk+10)
+	if err := encryptArchive(&buf, strings.NewReader(plain), "k", aead); err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string][]byte{"k": key}
+	read := func(b []byte) (string, error) {
+		name := filepath.Join(t.TempDir(), "a.enc")
+		os.WriteFile(name, b, 0600)
+		f, err := OpenArchive(name, keys)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		out, err := io.ReadAll(f)
+		return string(out), err
+	}
+
+	if got, err := read(buf.Bytes()); err != nil || got != plain {
+		t.Fatalf("round trip = %d bytes, %v", len(got), err)
+	}
+	tampered := append([]byte(nil), buf.Bytes()...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := read(tampered); err == nil {
+		t.Error("tampered archive was read")
+	}
+	// Cut after the first chunk: 9+1+1 header bytes, the prefix, the nonce
+	// and the sealed chunk.
+	first := 11 + 5 + aead.NonceSize() + archiveChunk + aead.Overhead()
+	if _, err := read(buf.Bytes()[:first]); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("truncated archive: %v, want an error", err)
+	}
+}
@@ -0,0 +1,291 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Defaults of NewAsyncWriter.
+const (
+	DefaultAsyncQueue         = 10000
+	DefaultAsyncFlushInterval = 100 * time.Millisecond
+)
+
+// asyncPriorityQueue is the capacity of the lane of the severe entries
+// that find the queue of an AsyncWriter full.
+const asyncPriorityQueue = 1000
+
+// asyncBatchBytes is the size at which an AsyncWriter writes its batch
+// without waiting for the flush interval.
+const asyncBatchBytes = 64 << 10
+
+// FullPolicy selects what an AsyncWriter does with an entry while its
+// queue is full.
+type FullPolicy int
+
+const (
+	// DropWhenFull discards the entry, counting it in Dropped, unless it
+	// is a WARNING, ERROR, FATAL or PANIC entry in the text or JSON
+	// format: those go to a lane of their own and wait for room there if
+	// it is full as well. Logging only blocks for severe entries, at the
+	// price of losing the others under overload.
+	DropWhenFull FullPolicy = iota
+	// BlockWhenFull makes the logging goroutine wait for room in the
+	// queue. No entry is lost, but a stalled output stalls logging.
+	BlockWhenFull
+)
+
+// AsyncWriter decouples logging from a slow output: Write queues the entry
+// and returns, and a background goroutine writes the queued entries in
+// batches, once per flush interval or as soon as 64 KiB are pending:
+//
+//	log.SetOutputs(log.NewAsyncWriter(f, 0, 0, log.DropWhenFull))
+//
+// Flush and Close drain the queue; Fatal flushes it before exiting, see
+// SetFatalFlushTimeout. Write errors of the output are reported to the
+// self-log as they can no longer be returned to the logger.
+type AsyncWriter struct {
+	w        io.Writer
+	policy   FullPolicy
+	interval time.Duration
+
+	queue chan asyncItem
+	// priority holds the severe entries that found queue full.
+	priority chan asyncItem
+	flush    chan chan error
+	pending  int64
+	dropped  uint64
+	latency  int64
+	started  int64
+	failing  bool
+
+	mu        sync.RWMutex
+	closed    bool
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type asyncItem struct {
+	p        []byte
+	enqueued time.Time
+}
+
+// NewAsyncWriter returns an AsyncWriter queueing up to size entries, or
+// DefaultAsyncQueue if size is not positive, for w and writing them every
+// interval, or DefaultAsyncFlushInterval if interval is not positive.
+func NewAsyncWriter(w io.Writer, size int, interval time.Duration, policy FullPolicy) *AsyncWriter {
+	if size <= 0 {
+		size = DefaultAsyncQueue
+	}
+	if interval <= 0 {
+		interval = DefaultAsyncFlushInterval
+	}
+	a := &AsyncWriter{
+		w:        w,
+		policy:   policy,
+		interval: interval,
+		queue:    make(chan asyncItem, size),
+		priority: make(chan asyncItem, asyncPriorityQueue),
+		flush:    make(chan chan error),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if synchronous() {
+		// Entries are written directly, as after Close.
+		a.closed = true
+		close(a.done)
+		return a
+	}
+	go a.run()
+	return a
+}
+
+// Name describes the wrapped output.
+func (a *AsyncWriter) Name() string {
+	return "async:" + outputName(a.w)
+}
+
+// Write queues a copy of p. Once the writer is closed, entries are written
+// to the output directly.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	// The read lock keeps Close from stopping the goroutine while the
+	// entry is being queued.
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return a.w.Write(p)
+	}
+
+	item := asyncItem{append([]byte(nil), p...), time.Now()}
+	atomic.AddInt64(&a.pending, 1)
+	if a.policy == BlockWhenFull {
+		a.queue <- item
+		return len(p), nil
+	}
+	select {
+	case a.queue <- item:
+	default:
+		if severeLine(p) {
+			a.priority <- item
+			break
+		}
+		atomic.AddInt64(&a.pending, -1)
+		atomic.AddUint64(&a.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// severeLine reports whether the entry p is a WARNING or more severe one.
+// Entries in formats parseLine does not understand are not.
+func severeLine(p []byte) bool {
+	_, level, _, _ := parseLine(string(bytes.TrimSuffix(p, []byte("\n"))))
+	lvl, err := parseLevel(level)
+	return err == nil && lvl <= log.WarnLevel
+}
+
+// QueueDepth implements QueueReporter.
+func (a *AsyncWriter) QueueDepth() int {
+	return int(atomic.LoadInt64(&a.pending))
+}
+
+// QueueLatency implements QueueReporter.
+func (a *AsyncWriter) QueueLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&a.latency))
+}
+
+// busySince implements busyReporter.
+func (a *AsyncWriter) busySince() time.Time {
+	return unixNano(atomic.LoadInt64(&a.started))
+}
+
+// Dropped returns the number of entries dropped because the queue was full.
+func (a *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Flush writes every queued entry and flushes the output if it buffers
+// entries itself.
+func (a *AsyncWriter) Flush() error {
+	ack := make(chan error, 1)
+	select {
+	case a.flush <- ack:
+		if err := <-ack; err != nil {
+			return err
+		}
+	case <-a.done:
+	}
+	if f, ok := a.w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close writes every queued entry and stops the background goroutine.
+// The output itself is not closed.
+func (a *AsyncWriter) Close() error {
+	a.closeOnce.Do(func() {
+		a.mu.Lock()
+		a.closed = true
+		a.mu.Unlock()
+		close(a.stop)
+	})
+	<-a.done
+	if f, ok := a.w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	var buf []byte
+	var n int
+	var oldest time.Time
+	add := func(item asyncItem) {
+		if n == 0 {
+			oldest = item.enqueued
+		}
+		buf = append(buf, item.p...)
+		n++
+	}
+	drain := func() {
+		for {
+			select {
+			case item := <-a.priority:
+				add(item)
+			case item := <-a.queue:
+				add(item)
+			default:
+				return
+			}
+		}
+	}
+	write := func() error {
+		if n == 0 {
+			return nil
+		}
+		err := a.write(buf, n, oldest)
+		buf, n = buf[:0], 0
+		return err
+	}
+
+	for {
+		select {
+		case item := <-a.priority:
+			add(item)
+			if len(buf) >= asyncBatchBytes {
+				write()
+			}
+		case item := <-a.queue:
+			add(item)
+			if len(buf) >= asyncBatchBytes {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		case ack := <-a.flush:
+			drain()
+			ack <- write()
+		case <-a.stop:
+			drain()
+			write()
+			return
+		}
+	}
+}
+
+// write writes a batch of n entries to the output.
+func (a *AsyncWriter) write(buf []byte, n int, oldest time.Time) error {
+	start := time.Now()
+	atomic.StoreInt64(&a.started, start.UnixNano())
+	_, err := a.w.Write(buf)
+	atomic.StoreInt64(&a.started, 0)
+	atomic.StoreInt64(&a.latency, int64(start.Sub(oldest)))
+	atomic.AddInt64(&a.pending, -int64(n))
+
+	if err != nil {
+		atomic.AddUint64(&stats.WriteErrors, 1)
+		fallback(a.w, buf)
+		if !a.failing {
+			a.failing = true
+			reportError(fmt.Errorf("write to output %s: %v", a.Name(), err))
+		}
+		return err
+	}
+	if a.failing {
+		a.failing = false
+		reportError(fmt.Errorf("output %s recovered", a.Name()))
+	}
+	return nil
+}
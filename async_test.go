@@ -0,0 +1,162 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	var out syncBuffer
+	a := NewAsyncWriter(&out, 0, time.Hour, BlockWhenFull)
+	defer a.Close()
+	SetOutputs(a)
+	defer SetOutputs(os.Stderr)
+
+	Info("queued")
+	if strings.Contains(out.String(), "queued") {
+		t.Fatal("entry written before the flush interval")
+	}
+	if a.QueueDepth() != 1 {
+		t.Errorf("QueueDepth() = %d, want 1", a.QueueDepth())
+	}
+	Flush()
+	if !strings.Contains(out.String(), "queued") {
+		t.Fatalf("output after Flush = %q, want the entry", out.String())
+	}
+	if a.QueueDepth() != 0 {
+		t.Errorf("QueueDepth() after Flush = %d, want 0", a.QueueDepth())
+	}
+	if a.Name() != "async:*log.syncBuffer" {
+		t.Errorf("Name() = %q", a.Name())
+	}
+}
+
+func TestAsyncWriterInterval(t *testing.T) {
+	var out syncBuffer
+	a := NewAsyncWriter(&out, 0, 10*time.Millisecond, DropWhenFull)
+	defer a.Close()
+
+	a.Write([]byte("one\n"))
+	a.Write([]byte("two\n"))
+	deadline := time.Now().Add(2 * time.Second)
+	for out.String() != "one\ntwo\n" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if out.String() != "one\ntwo\n" {
+		t.Errorf("output = %q, want both entries in order", out.String())
+	}
+}
+
+func TestAsyncWriterBatches(t *testing.T) {
+	w := &writesRecorder{}
+	a := NewAsyncWriter(w, 100, time.Hour, BlockWhenFull)
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(a, "entry %d\n", i)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if len(w.writes) != 1 {
+		t.Fatalf("%d writes, want the entries written in one batch", len(w.writes))
+	}
+	if !strings.HasSuffix(w.writes[0], "entry 49\n") {
+		t.Errorf("output = %q, want every entry", strings.Join(w.writes, ""))
+	}
+	// Entries written after Close go straight to the output.
+	a.Write([]byte("late\n"))
+	if !strings.HasSuffix(strings.Join(w.writes, ""), "late\n") {
+		t.Errorf("entry written after Close was lost")
+	}
+}
+
+func TestAsyncWriterDropWhenFull(t *testing.T) {
+	b := &blockingWriter{release: make(chan struct{})}
+	a := NewAsyncWriter(b, 2, time.Millisecond, DropWhenFull)
+
+	// The first entry is being written and blocks, two wait in the queue
+	// and the rest is dropped.
+	a.Write([]byte("first\n"))
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		if _, err := a.Write([]byte("entry\n")); err != nil {
+			t.Fatalf("Write() = %v, want dropping to be silent", err)
+		}
+	}
+	if a.Dropped() != 8 {
+		t.Errorf("Dropped() = %d, want 8", a.Dropped())
+	}
+	if a.busySince().IsZero() {
+		t.Error("busySince() is zero during a blocked write")
+	}
+	close(b.release)
+	a.Close()
+	if a.QueueDepth() != 0 {
+		t.Errorf("QueueDepth() after Close = %d, want 0", a.QueueDepth())
+	}
+}
+
+// gatedWriter records the writes made once release is closed.
+type gatedWriter struct {
+	release chan struct{}
+	syncBuffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.syncBuffer.Write(p)
+}
+
+func TestAsyncWriterKeepsSevereEntries(t *testing.T) {
+	b := &gatedWriter{release: make(chan struct{})}
+	a := NewAsyncWriter(b, 2, time.Millisecond, DropWhenFull)
+
+	a.Write([]byte("first\n"))
+	time.Sleep(20 * time.Millisecond)
+	ts := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		a.Write(formatLine(ts, log.DebugLevel, "", "flood", nil))
+	}
+	a.Write(formatLine(ts, log.ErrorLevel, "", "disk failed", nil))
+	a.Write([]byte(`{"time":"2017-03-01T12:00:00Z","level":"fatal","msg":"out of memory"}` + "\n"))
+	if a.Dropped() != 8 {
+		t.Errorf("Dropped() = %d, want the 8 DEBUG entries beyond the queue", a.Dropped())
+	}
+	close(b.release)
+	a.Close()
+	for _, want := range []string{"disk failed", "out of memory"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("severe entry %q dropped behind a flood: %q", want, b.String())
+		}
+	}
+}
+
+func TestAsyncWriterBlockWhenFull(t *testing.T) {
+	b := &blockingWriter{release: make(chan struct{})}
+	a := NewAsyncWriter(b, 1, time.Millisecond, BlockWhenFull)
+
+	a.Write([]byte("first\n"))
+	time.Sleep(20 * time.Millisecond)
+	a.Write([]byte("queued\n"))
+
+	done := make(chan struct{})
+	go func() {
+		a.Write([]byte("blocked\n"))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Write() returned while the queue was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(b.release)
+	<-done
+	a.Close()
+	if a.Dropped() != 0 {
+		t.Errorf("Dropped() = %d, want 0", a.Dropped())
+	}
+}
@@ -0,0 +1,334 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// AuditFile is an append-only file of audit entries, one per line.
+// When sealing is enabled, every file closed by Rotate is made read-only
+// (0400) and a sealing entry holding its SHA-256 and entry count is
+// recorded as the first entry of the next file, so a closed period can be
+// verified later. See also SetAuditFile for hash chained records.
+type AuditFile struct {
+	mu      sync.Mutex
+	path    string
+	seal    bool
+	f       *os.File
+	hash    hash.Hash
+	entries int
+	// last is the hash of the last record written by Audit and seq its
+	// number, carried over to the files started by Rotate.
+	last string
+	seq  uint64
+}
+
+// OpenAuditFile opens or creates the audit file at path. If seal is true,
+// Rotate seals the files it closes.
+func OpenAuditFile(path string, seal bool) (*AuditFile, error) {
+	a := &AuditFile{path: path, seal: seal}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// open opens a.path for appending and accounts for entries already in it.
+func (a *AuditFile) open() error {
+	f, err := os.OpenFile(a.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	entries, last, err := scanAuditFile(io.TeeReader(f, h))
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	a.f, a.hash, a.entries = f, h, entries
+	if last != nil {
+		end := len(auditHashPrefix) + sha256.Size*2
+		if len(last) < end+2 || string(last[end:end+2]) != `",` {
+			f.Close()
+			return fmt.Errorf("last audit record of %s is malformed", a.path)
+		}
+		var r auditRecord
+		if err := json.Unmarshal(last, &r); err != nil {
+			f.Close()
+			return fmt.Errorf("last audit record of %s: %v", a.path, err)
+		}
+		a.last, a.seq = string(last[len(auditHashPrefix):end]), r.Seq
+	}
+	return nil
+}
+
+// Write appends p, which may hold several entries, e.g. from an
+// AsyncWriter or a batch; every newline ends one.
+func (a *AuditFile) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return 0, os.ErrClosed
+	}
+	n, err := a.f.Write(p)
+	a.hash.Write(p[:n])
+	a.entries += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+// Rotate closes the current file, renames it with a timestamp suffix and
+// starts a new one. It returns the name of the closed file.
+func (a *AuditFile) Rotate() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return "", os.ErrClosed
+	}
+	if err := a.f.Sync(); err != nil {
+		return "", err
+	}
+	if err := a.f.Close(); err != nil {
+		return "", err
+	}
+	a.f = nil
+
+	sum := hex.EncodeToString(a.hash.Sum(nil))
+	entries := a.entries
+	closed := a.path + "." + time.Now().UTC().Format("20060102T150405.000000000Z")
+	if err := os.Rename(a.path, closed); err != nil {
+		return "", err
+	}
+	if a.seal {
+		if err := os.Chmod(closed, 0400); err != nil {
+			return closed, err
+		}
+	}
+
+	if err := a.open(); err != nil {
+		return closed, err
+	}
+	if a.seal {
+		_, file, line, _ := runtime.Caller(1)
+		seal := formatLine(time.Now(), log.InfoLevel, file+":"+strconv.Itoa(line), "audit file sealed", log.Fields{
+			"sealed_file": closed,
+			"sha256":      sum,
+			"entries":     entries,
+		})
+		n, err := a.f.Write(seal)
+		a.hash.Write(seal[:n])
+		a.entries++
+		if err != nil {
+			return closed, fmt.Errorf("record seal of %s: %v", closed, err)
+		}
+	}
+	return closed, nil
+}
+
+// Close closes the audit file without sealing it.
+func (a *AuditFile) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return os.ErrClosed
+	}
+	err := a.f.Close()
+	a.f = nil
+	return err
+}
+
+// auditHashPrefix starts the lines of the records written by Audit, the
+// hash being followed by the record it covers.
+const auditHashPrefix = `{"hash":"`
+
+// auditRecord is a record written by Audit.
+type auditRecord struct {
+	Time   string `json:"time"`
+	Seq    uint64 `json:"seq"`
+	Event  string `json:"event"`
+	Host   string `json:"hostname"`
+	Tag    string `json:"tag"`
+	Pid    int    `json:"pid"`
+	Fields Fields `json:"fields,omitempty"`
+	// Prev is the hash of the previous record, empty for the first one.
+	Prev string `json:"prev_hash"`
+}
+
+var audit struct {
+	sync.Mutex
+	f *AuditFile
+}
+
+// SetAuditFile makes a the destination of Audit, nil to stop recording.
+func SetAuditFile(a *AuditFile) {
+	audit.Lock()
+	audit.f = a
+	audit.Unlock()
+}
+
+// Audit records event, e.g. an administrative action, with fields in the
+// file set with SetAuditFile, apart from the log:
+//
+//	log.Audit("user.delete", log.Fields{"admin": admin, "user": name})
+//
+// Records are JSON objects numbered by seq, each one carrying the SHA-256
+// of the previous record as prev_hash and its own as hash, so that
+// VerifyAuditFile detects a record modified, removed or inserted. The
+// chain continues across Rotate. Secret values are recorded hashed, as
+// with HashSecrets, whatever the mode set with SetSecretMode, so records
+// can be correlated without revealing them. Audit returns an error if no
+// file is set or the record could not be written, for callers that must
+// not proceed with an unrecorded action.
+func Audit(event string, fields Fields) error {
+	audit.Lock()
+	a := audit.f
+	audit.Unlock()
+	if a == nil {
+		return errors.New("no audit file, see SetAuditFile")
+	}
+	return a.record(event, fields)
+}
+
+// record appends a record of event to a.
+func (a *AuditFile) record(event string, fields Fields) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return os.ErrClosed
+	}
+	body, err := json.Marshal(auditRecord{
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Seq:    a.seq + 1,
+		Event:  event,
+		Host:   entryHost(nil),
+		Tag:    tag,
+		Pid:    pid,
+		Fields: hashSecrets(fields),
+		Prev:   a.last,
+	})
+	if err != nil {
+		return fmt.Errorf("audit record %s: %v", event, err)
+	}
+	sum := sha256.Sum256(body)
+	line := auditLine(hex.EncodeToString(sum[:]), body)
+
+	n, err := a.f.Write(line)
+	a.hash.Write(line[:n])
+	if n > 0 {
+		a.entries++
+	}
+	if err != nil {
+		return err
+	}
+	a.last = hex.EncodeToString(sum[:])
+	a.seq++
+	return nil
+}
+
+// auditLine returns the line of a record: body with its hash first.
+func auditLine(hash string, body []byte) []byte {
+	line := make([]byte, 0, len(auditHashPrefix)+len(hash)+2+len(body))
+	line = append(line, auditHashPrefix...)
+	line = append(line, hash...)
+	line = append(line, `",`...)
+	line = append(line, body[1:]...)
+	return append(line, '\n')
+}
+
+// VerifyAuditFile checks the chain of the records written by Audit to the
+// audit file at path and returns the hash of the last one. The first
+// record must follow the record whose hash is prev, e.g. the last one of
+// the file before in the rotation, or any record if prev is empty. Only
+// the sealing entry of Rotate may precede the records. The error locates
+// the first line breaking the chain.
+func VerifyAuditFile(path, prev string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	last, records := prev, 0
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			return last, nil
+		}
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if line[len(line)-1] != '\n' {
+			return "", fmt.Errorf("%s:%d: truncated record", path, n)
+		}
+		line = line[:len(line)-1]
+		if !bytes.HasPrefix(line, []byte(auditHashPrefix)) {
+			if records > 0 || n > 1 {
+				return "", fmt.Errorf("%s:%d: not an audit record", path, n)
+			}
+			continue
+		}
+
+		end := len(auditHashPrefix) + sha256.Size*2
+		if len(line) < end+2 || string(line[end:end+2]) != `",` {
+			return "", fmt.Errorf("%s:%d: malformed audit record", path, n)
+		}
+		hash := string(line[len(auditHashPrefix):end])
+		body := append([]byte{'{'}, line[end+2:]...)
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != hash {
+			return "", fmt.Errorf("%s:%d: record does not match its hash", path, n)
+		}
+		var r auditRecord
+		if err := json.Unmarshal(body, &r); err != nil {
+			return "", fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if (records > 0 || prev != "") && r.Prev != last {
+			return "", fmt.Errorf("%s:%d: record %d does not follow the previous record", path, n, r.Seq)
+		}
+		last = hash
+		records++
+	}
+}
+
+// scanAuditFile counts the lines read from r and returns the last record
+// written by Audit among them, if any.
+func scanAuditFile(r io.Reader) (int, []byte, error) {
+	br := bufio.NewReader(r)
+	n := 0
+	var last []byte
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			n++
+			if bytes.HasPrefix(line, []byte(auditHashPrefix)) {
+				last = line
+			}
+		}
+		if err == io.EOF {
+			return n, last, nil
+		}
+		if err != nil {
+			return n, last, err
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package log
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditFileSealsOnRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAuditFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	for _, e := range []string{"user=alice action=login\n", "user=alice action=logout\n"} {
+		if _, err := a.Write([]byte(e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	closed, err := a.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(closed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0400 {
+		t.Errorf("sealed file mode = %o, want 400", mode)
+	}
+
+	content, _ := os.ReadFile(closed)
+	sum := sha256.Sum256(content)
+	current, _ := os.ReadFile(path)
+	for _, want := range []string{"audit file sealed", "sha256=" + hex.EncodeToString(sum[:]), "entries=2"} {
+		if !strings.Contains(string(current), want) {
+			t.Errorf("new audit file is missing %q:\n%s", want, current)
+		}
+	}
+}
+
+func TestAuditFileCountsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAuditFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	// A write of an AsyncWriter or a batch holds several entries.
+	if _, err := a.Write([]byte("user=alice action=login\nuser=bob action=login\nuser=alice action=logout\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Write([]byte("user=bob action=logout\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := os.ReadFile(path); !strings.Contains(string(current), "entries=4") {
+		t.Errorf("seal does not count 4 entries:\n%s", current)
+	}
+}
+
+func TestOpenAuditFileShortRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for _, last := range []string{`{"hash":"ab"}`, `{"hash":"ab`} {
+		os.WriteFile(path, []byte(last+"\n"), 0600)
+		if a, err := OpenAuditFile(path, false); err == nil {
+			a.Close()
+			t.Errorf("OpenAuditFile accepted the last record %s", last)
+		}
+	}
+}
+
+func TestAuditChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := Audit("user.login", nil); err == nil {
+		t.Error("Audit without a file = nil error")
+	}
+	a, err := OpenAuditFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	SetAuditFile(a)
+	defer SetAuditFile(nil)
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		if err := Audit("user.delete", Fields{"admin": "root", "user": user}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := VerifyAuditFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The chain continues in the file started by Rotate, after the seal.
+	closed, err := a.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Audit("config.reload", Fields{"admin": "root"})
+	if _, err := VerifyAuditFile(path, first); err != nil {
+		t.Errorf("rotated chain: %v", err)
+	}
+	if _, err := VerifyAuditFile(path, strings.Repeat("0", 64)); err == nil {
+		t.Error("rotated file verified against another previous record")
+	}
+
+	// A reopened file continues the chain.
+	a.Close()
+	if a, err = OpenAuditFile(path, true); err != nil {
+		t.Fatal(err)
+	}
+	SetAuditFile(a)
+	Audit("user.login", Fields{"user": "dave"})
+	if _, err := VerifyAuditFile(path, first); err != nil {
+		t.Errorf("reopened chain: %v", err)
+	}
+	b, _ := os.ReadFile(path)
+	if !strings.Contains(string(b), `"seq":5`) {
+		t.Errorf("records not numbered across files:\n%s", b)
+	}
+
+	os.Chmod(closed, 0600)
+	content, _ := os.ReadFile(closed)
+	lines := strings.SplitAfter(string(content), "\n")
+	for name, tampered := range map[string]string{
+		"modified":  strings.Replace(string(content), "bob", "eve", 1),
+		"removed":   lines[0] + lines[2],
+		"inserted":  lines[0] + "user=eve action=login\n" + lines[1],
+		"reordered": lines[1] + lines[0],
+	} {
+		os.WriteFile(closed, []byte(tampered), 0600)
+		if _, err := VerifyAuditFile(closed, ""); err == nil {
+			t.Errorf("%s record not detected", name)
+		}
+	}
+}
+
+func TestAuditHashesSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAuditFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	SetAuditFile(a)
+	defer SetAuditFile(nil)
+
+	key := Secret("api_key", "hunter2")
+	WithTyped(key).Info("user.login")
+	if err := Audit("user.login", Fields{"api_key": key.Value()}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := secretValue{"hunter2"}.hash()
+	if !strings.Contains(buf.String(), "api_key=****") || strings.Contains(buf.String(), hashed) {
+		t.Errorf("log %q does not mask the secret", buf.String())
+	}
+	if !strings.Contains(string(b), `"api_key":"`+hashed+`"`) || strings.Contains(string(b), "hunter2") {
+		t.Errorf("audit record %q does not hash the secret", b)
+	}
+}
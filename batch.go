@@ -0,0 +1,134 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// EntryBatch accumulates related entries and writes them contiguously, so
+// that a multi-line report is never interleaved with the entries of other
+// goroutines in a shared file. It is obtained from Batch.
+type EntryBatch struct {
+	l *Logger
+
+	mu      sync.Mutex
+	entries []batchEntry
+}
+
+type batchEntry struct {
+	level  log.Level
+	msg    string
+	fields log.Fields
+	site   callSite
+}
+
+// Batch returns an empty EntryBatch:
+//
+//	b := log.Batch()
+//	for _, q := range queues {
+//		b.Infof("queue %s: %d packets", q.Name, q.Len())
+//	}
+//	b.Commit()
+//
+// Nothing is written until Commit.
+func Batch() *EntryBatch {
+	return &EntryBatch{}
+}
+
+// Batch returns an empty EntryBatch whose entries carry the fields of l.
+func (l *Logger) Batch() *EntryBatch {
+	return &EntryBatch{l: l}
+}
+
+// Commit writes the accumulated entries, in order and in a single write to
+// the outputs, and empties the batch. Every entry is subject to the level,
+// sampling and rate limit on its own.
+func (b *EntryBatch) Commit() {
+	b.mu.Lock()
+	entries := b.entries
+	b.entries = nil
+	b.mu.Unlock()
+
+	var buf []byte
+	for _, e := range entries {
+		level, msg, fields, ok := prepare(e.level, e.site, e.msg, e.fields)
+		if !ok {
+			continue
+		}
+		countWritten(level)
+		parts := shapeMessage(level, msg, fields)
+		if parts == nil {
+			parts = []messagePart{{msg, fields}}
+		}
+		for _, p := range parts {
+			if out, ok := renderEntry(level, e.site, p.msg, p.fields); ok {
+				buf = append(buf, out...)
+			}
+		}
+	}
+	if len(buf) > 0 {
+		writeOutput(b.l.instance(), buf)
+	}
+}
+
+// Len returns the number of entries waiting for Commit.
+func (b *EntryBatch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// add records an entry, capturing the caller of the exported method.
+func (b *EntryBatch) add(level log.Level, msg string) {
+	e := batchEntry{level: level, msg: msg}
+	e.site = captureCaller(level, 2)
+	if b.l != nil {
+		e.fields = b.l.data()
+	}
+
+	b.mu.Lock()
+	b.entries = append(b.entries, e)
+	b.mu.Unlock()
+}
+
+// Debug adds a message with severity DEBUG.
+func (b *EntryBatch) Debug(v ...interface{}) {
+	b.add(log.DebugLevel, fmt.Sprint(v...))
+}
+
+// Error adds a message with severity ERROR.
+func (b *EntryBatch) Error(v ...interface{}) {
+	b.add(log.ErrorLevel, fmt.Sprint(v...))
+}
+
+// Info adds a message with severity INFO.
+func (b *EntryBatch) Info(v ...interface{}) {
+	b.add(log.InfoLevel, fmt.Sprint(v...))
+}
+
+// Warning adds a message with severity WARNING.
+func (b *EntryBatch) Warning(v ...interface{}) {
+	b.add(log.WarnLevel, fmt.Sprint(v...))
+}
+
+// Debugf adds a formatted message with severity DEBUG.
+func (b *EntryBatch) Debugf(format string, v ...interface{}) {
+	b.add(log.DebugLevel, fmt.Sprintf(format, v...))
+}
+
+// Errorf adds a formatted message with severity ERROR.
+func (b *EntryBatch) Errorf(format string, v ...interface{}) {
+	b.add(log.ErrorLevel, fmt.Sprintf(format, v...))
+}
+
+// Infof adds a formatted message with severity INFO.
+func (b *EntryBatch) Infof(format string, v ...interface{}) {
+	b.add(log.InfoLevel, fmt.Sprintf(format, v...))
+}
+
+// Warningf adds a formatted message with severity WARNING.
+func (b *EntryBatch) Warningf(format string, v ...interface{}) {
+	b.add(log.WarnLevel, fmt.Sprintf(format, v...))
+}
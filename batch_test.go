@@ -0,0 +1,88 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writesRecorder records every write separately.
+type writesRecorder struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *writesRecorder) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestBatch(t *testing.T) {
+	w := &writesRecorder{}
+	SetOutputs(w)
+	defer SetOutputs(os.Stderr)
+	SetLevel("debug")
+
+	b := With("report", "queues").Batch()
+	b.Info("queue rx: 10 packets")
+	b.Debugf("queue %s: %d packets", "tx", 3)
+	b.Warning("queue err: 1 packet")
+	if b.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", b.Len())
+	}
+	if len(w.writes) != 0 {
+		t.Fatalf("entries written before Commit: %q", w.writes)
+	}
+	b.Commit()
+
+	if len(w.writes) != 1 {
+		t.Fatalf("batch written in %d writes, want 1: %q", len(w.writes), w.writes)
+	}
+	lines := strings.Split(strings.TrimSuffix(w.writes[0], "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %q", len(lines), w.writes[0])
+	}
+	for i, want := range []string{"rx: 10", "tx: 3", "err: 1"} {
+		if !strings.Contains(lines[i], want) || !strings.Contains(lines[i], "report=queues") || !strings.Contains(lines[i], "batch_test.go:") {
+			t.Errorf("line %d = %q, want %q with fields and caller", i, lines[i], want)
+		}
+	}
+	if b.Len() != 0 {
+		t.Errorf("Len() after Commit = %d", b.Len())
+	}
+}
+
+func TestBatchLevel(t *testing.T) {
+	w := &writesRecorder{}
+	SetOutputs(w)
+	defer SetOutputs(os.Stderr)
+	SetLevel("warning")
+	defer SetLevel("debug")
+
+	b := Batch()
+	b.Info("filtered")
+	b.Commit()
+	if len(w.writes) != 0 {
+		t.Errorf("filtered batch wrote %q", w.writes)
+	}
+}
+
+func TestBatchStats(t *testing.T) {
+	SetOutputs(&writesRecorder{})
+	defer SetOutputs(os.Stderr)
+	defer SetLevel(getLevel().String())
+	SetLevel("info")
+
+	before := Stats().Entries["info"]
+	b := New().Batch()
+	b.Info("rx")
+	b.Info("tx")
+	b.Debug("dropped by the level")
+	b.Commit()
+	if n := Stats().Entries["info"] - before; n != 2 {
+		t.Errorf("Entries[info] grew by %d, want 2", n)
+	}
+}
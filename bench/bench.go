@@ -0,0 +1,182 @@
+/*
+Package bench provides reproducible throughput and allocation benchmarks for
+the formatters and outputs of package log.
+
+The benchmarks can be run with go test:
+
+	go test -bench . -benchmem github.com/net-sniper/go-log/bench
+
+or programmatically, e.g. from a diagnostics command on the target hardware:
+
+	for _, r := range bench.Run("") {
+		fmt.Println(r)
+	}
+
+Output benchmarks reconfigure the package logger, so they should not be run
+in a process that is logging for real.
+*/
+package bench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	golog "github.com/net-sniper/go-log"
+)
+
+// Case is a single benchmark.
+type Case struct {
+	Name string
+	F    func(b *testing.B)
+}
+
+// Result is the outcome of running a Case.
+type Result struct {
+	Name string
+	testing.BenchmarkResult
+}
+
+// String formats r like the go test -benchmem output.
+func (r Result) String() string {
+	return fmt.Sprintf("%-24s %s\t%s", r.Name, r.BenchmarkResult.String(), r.MemString())
+}
+
+// Cases returns every benchmark, named formatter/..., logger/... or
+// output/....
+func Cases() []Case {
+	return []Case{
+		{"formatter/text", benchFormatter(nil)},
+		{"formatter/text-fields", benchFormatter(log.Fields{"user_id": 42, "path": "/api/v1/flows", "status": 200})},
+		{"formatter/json", benchJSON(log.Fields{"user_id": 42, "path": "/api/v1/flows", "status": 200})},
+		{"logger/debug-disabled", benchDisabled},
+		{"logger/fields", benchFields},
+		{"output/discard", benchOutput(discard(1))},
+		{"output/multi", benchOutput(discard(3))},
+		{"output/file", benchOutput(tempFile)},
+	}
+}
+
+// Run runs every case whose name contains filter and returns the results in
+// order. An empty filter runs all cases.
+func Run(filter string) []Result {
+	var results []Result
+	for _, c := range Cases() {
+		if !strings.Contains(c.Name, filter) {
+			continue
+		}
+		results = append(results, Result{c.Name, testing.Benchmark(c.F)})
+	}
+	return results
+}
+
+func benchFormatter(fields log.Fields) func(b *testing.B) {
+	return func(b *testing.B) {
+		f := &golog.Formatter{}
+		entry := log.NewEntry(log.StandardLogger()).WithFields(fields)
+		entry.Time = time.Now()
+		entry.Level = log.InfoLevel
+		entry.Message = "flow table rebuilt"
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := f.Format(entry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchJSON(fields log.Fields) func(b *testing.B) {
+	return func(b *testing.B) {
+		f := &golog.JSONFormatter{}
+		entry := log.NewEntry(log.StandardLogger()).WithFields(fields)
+		entry.Time = time.Now()
+		entry.Level = log.InfoLevel
+		entry.Message = "flow table rebuilt"
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := f.Format(entry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// benchDisabled measures the debug entries of a Logger at info, the cost
+// of leaving debug logging in hot paths.
+func benchDisabled(b *testing.B) {
+	golog.SetLevel("info")
+	l := golog.WithFields(golog.Fields{"iface": "eth1"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Debugf("rx frame %d", i)
+	}
+}
+
+// benchFields measures entries with fields written to a discarded output.
+func benchFields(b *testing.B) {
+	golog.SetLevel("info")
+	golog.SetOutputs(io.Discard)
+	defer golog.SetOutputs(os.Stderr)
+	l := golog.WithFields(golog.Fields{"iface": "eth1", "proto": "tcp"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Infow("flow closed", "bytes", 1500, "packets", 3)
+	}
+}
+
+// opener returns the outputs for a benchmark and a function releasing them.
+type opener func() ([]io.Writer, func(), error)
+
+func benchOutput(open opener) func(b *testing.B) {
+	return func(b *testing.B) {
+		outputs, closeFn, err := open()
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer closeFn()
+
+		golog.SetLevel("info")
+		golog.SetOutputs(outputs...)
+		defer golog.SetOutputs(os.Stderr)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			golog.Info("flow table rebuilt")
+		}
+	}
+}
+
+func discard(n int) opener {
+	return func() ([]io.Writer, func(), error) {
+		outputs := make([]io.Writer, n)
+		for i := range outputs {
+			outputs[i] = io.Discard
+		}
+		return outputs, func() {}, nil
+	}
+}
+
+func tempFile() ([]io.Writer, func(), error) {
+	f, err := os.CreateTemp("", "go-log-bench-*.log")
+	if err != nil {
+		return nil, nil, err
+	}
+	return []io.Writer{f}, func() {
+		f.Close()
+		os.Remove(f.Name())
+	}, nil
+}
@@ -0,0 +1,57 @@
+package bench
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	golog "github.com/net-sniper/go-log"
+)
+
+// textEntryAllocs is the allocation budget of a text entry without fields
+// written to a discarded output.
+const textEntryAllocs = 16
+
+func BenchmarkCases(b *testing.B) {
+	for _, c := range Cases() {
+		b.Run(c.Name, c.F)
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs benchmarks")
+	}
+	results := Run("formatter/text-fields")
+	if len(results) != 1 || results[0].Name != "formatter/text-fields" {
+		t.Fatalf("Run() = %v, want only formatter/text-fields", results)
+	}
+	if results[0].N == 0 {
+		t.Error("benchmark did not run")
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	golog.SetLevel("info")
+	defer golog.SetLevel("debug")
+	golog.SetOutputs(io.Discard)
+	defer golog.SetOutputs(os.Stderr)
+	// A Fields literal is a map and is allocated by the caller; hot paths
+	// build their Logger once, like benchDisabled.
+	l := golog.WithFields(golog.Fields{"iface": "eth1"})
+
+	for _, c := range []struct {
+		name string
+		max  float64
+		f    func()
+	}{
+		{"disabled Debug", 0, func() { golog.Debug("rx frame") }},
+		{"disabled WithFields Debug", 0, func() { l.Debug("rx frame") }},
+		{"disabled WithTyped Debug", 0, func() { golog.WithTyped(golog.String("iface", "eth1")).Debug("rx frame") }},
+		{"text entry", textEntryAllocs, func() { golog.Info("flow table rebuilt") }},
+	} {
+		if n := testing.AllocsPerRun(100, c.f); n > c.max {
+			t.Errorf("%s allocates %v times, want at most %v", c.name, n, c.max)
+		}
+	}
+}
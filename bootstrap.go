@@ -0,0 +1,102 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// BootstrapBuffer is the number of entries logged before Init that are
+// kept for the outputs of Init.
+const BootstrapBuffer = 1000
+
+// bootstrap keeps the entries written before the package logger is
+// configured. Until then, logrus writes them to stderr in the text format.
+var bootstrap = struct {
+	sync.Mutex
+	active  int32
+	entries []*log.Entry
+	dropped int
+}{active: 1}
+
+func init() {
+	addHook(bootstrapHook{})
+}
+
+// bootstrapHook keeps the entries written before Init.
+type bootstrapHook struct{}
+
+func (bootstrapHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (bootstrapHook) Fire(entry *log.Entry) error {
+	if atomic.LoadInt32(&bootstrap.active) == 0 {
+		return nil
+	}
+	bootstrap.Lock()
+	defer bootstrap.Unlock()
+	if bootstrap.active == 0 {
+		return nil
+	}
+	if len(bootstrap.entries) >= BootstrapBuffer {
+		bootstrap.dropped++
+		return nil
+	}
+	data := make(log.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	bootstrap.entries = append(bootstrap.entries, &log.Entry{
+		Logger:  entry.Logger,
+		Data:    data,
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Message: entry.Message,
+	})
+	return nil
+}
+
+// endBootstrap ends the bootstrap phase. With replay, the kept entries are
+// written to the outputs of the package logger in its format, unless those
+// are stderr, which already has them.
+func endBootstrap(replay bool) {
+	bootstrap.Lock()
+	if bootstrap.active == 0 {
+		bootstrap.Unlock()
+		return
+	}
+	atomic.StoreInt32(&bootstrap.active, 0)
+	entries, dropped := bootstrap.entries, bootstrap.dropped
+	bootstrap.entries = nil
+	bootstrap.Unlock()
+
+	current.Lock()
+	mw := current.output
+	current.Unlock()
+	if !replay || mw == nil || onlyStderr(mw) {
+		return
+	}
+	f := log.StandardLogger().Formatter
+	for _, e := range entries {
+		b, err := f.Format(e)
+		if err != nil {
+			reportError(fmt.Errorf("replay entry logged before Init: %v", err))
+			continue
+		}
+		mw.Write(b)
+	}
+	if dropped > 0 {
+		emit(log.WarnLevel, callSite{}, "entries logged before Init dropped", log.Fields{"dropped": dropped, "kept": len(entries)})
+	}
+}
+
+// onlyStderr reports whether stderr is the only output of mw.
+func onlyStderr(mw *multiWriter) bool {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	return len(mw.outputs) == 1 && mw.outputs[0] == os.Stderr
+}
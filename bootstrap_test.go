@@ -0,0 +1,74 @@
+package log
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// restartBootstrap makes the package logger keep entries as before Init.
+func restartBootstrap(t *testing.T) {
+	SetOutputs(io.Discard)
+	bootstrap.Lock()
+	atomic.StoreInt32(&bootstrap.active, 1)
+	bootstrap.entries, bootstrap.dropped = nil, 0
+	bootstrap.Unlock()
+	t.Cleanup(func() { endBootstrap(false) })
+}
+
+func TestBootstrapReplay(t *testing.T) {
+	resetInit(t)
+	restartBootstrap(t)
+	WithFields(Fields{"config": "/etc/sniper.conf"}).Warning("config not found, using defaults")
+
+	name := filepath.Join(t.TempDir(), "probe.log")
+	InitProduction(name)
+	Info("started")
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"msg":"config not found, using defaults"`) || !strings.Contains(lines[0], `"config":"/etc/sniper.conf"`) {
+		t.Fatalf("log file = %q, want the entry logged before Init first, as JSON", lines)
+	}
+	if !strings.Contains(lines[1], `"msg":"started"`) {
+		t.Errorf("second entry = %q", lines[1])
+	}
+}
+
+func TestBootstrapDropped(t *testing.T) {
+	resetInit(t)
+	restartBootstrap(t)
+	for i := 0; i < BootstrapBuffer+5; i++ {
+		Infof("probe %d", i)
+	}
+
+	name := filepath.Join(t.TempDir(), "probe.log")
+	Init(name, "info")
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	if n := strings.Count(got, "probe "); n != BootstrapBuffer {
+		t.Errorf("replayed %d entries, want %d", n, BootstrapBuffer)
+	}
+	if !strings.Contains(got, "entries logged before Init dropped dropped=5") {
+		t.Errorf("no report of the dropped entries in %q", got[len(got)-200:])
+	}
+}
+
+func TestBootstrapSetOutputs(t *testing.T) {
+	restartBootstrap(t)
+	Info("before the outputs")
+	SetOutputs(io.Discard)
+	bootstrap.Lock()
+	defer bootstrap.Unlock()
+	if bootstrap.active != 0 || bootstrap.entries != nil {
+		t.Error("SetOutputs did not end the bootstrap phase")
+	}
+}
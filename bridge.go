@@ -0,0 +1,198 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	stdlog "log"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxWriterLine is the length from which a Writer logs a line without
+// waiting for its newline.
+const maxWriterLine = 64 << 10
+
+// Writer returns a writer logging every line written to it as an entry at
+// level, for code that only knows how to write to an io.Writer, e.g. the
+// output of a command:
+//
+//	w, _ := log.Writer("warning")
+//	cmd.Stderr = w
+//	err := cmd.Run()
+//	w.Close()
+//
+// The entries have no caller. Close logs the last line if it lacks its
+// newline; lines longer than 64 KiB are split.
+func Writer(level string) (io.WriteCloser, error) {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return &levelWriter{level: lvl}, nil
+}
+
+// StdLogger returns a logger of the standard library logging every message
+// as an entry at level, for libraries that take one, e.g.:
+//
+//	errLog, _ := log.StdLogger("warning")
+//	srv := &http.Server{ErrorLog: errLog}
+func StdLogger(level string) (*stdlog.Logger, error) {
+	w, err := Writer(level)
+	if err != nil {
+		return nil, err
+	}
+	return stdlog.New(w, "", 0), nil
+}
+
+// levelWriter is the writer returned by Writer.
+type levelWriter struct {
+	level log.Level
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	line := w.buf
+	for {
+		i := bytes.IndexByte(line, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(line[:i])
+		line = line[i+1:]
+	}
+	for len(line) >= maxWriterLine {
+		w.log(line[:maxWriterLine])
+		line = line[maxWriterLine:]
+	}
+	w.buf = w.buf[:copy(w.buf, line)]
+	return len(p), nil
+}
+
+// Close logs the incomplete line left, if any.
+func (w *levelWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.log(w.buf)
+	w.buf = nil
+	return nil
+}
+
+func (w *levelWriter) log(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) > 0 && enabled(w.level) {
+		emit(w.level, callSite{}, string(line), nil)
+	}
+}
+
+// SlogHandler is a slog.Handler writing the records of a slog.Logger
+// through the package logger, so that libraries using log/slog get the
+// same format, outputs and filters as the application:
+//
+//	slog.SetDefault(slog.New(log.NewSlogHandler()))
+//
+// Levels below INFO map to DEBUG and those above ERROR to ERROR. Attributes
+// become fields, those of groups with dotted keys such as http.status, and
+// the fields and trace of the context are added as with InfoCtx.
+type SlogHandler struct {
+	fields log.Fields
+	// group is the prefix of the keys, the open groups with a trailing
+	// dot each.
+	group string
+}
+
+// NewSlogHandler returns a SlogHandler.
+func NewSlogHandler() *SlogHandler {
+	return &SlogHandler{}
+}
+
+// Enabled implements slog.Handler.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return enabled(slogLevel(level))
+}
+
+// Handle implements slog.Handler.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	level := slogLevel(r.Level)
+	fields := ctxData(ctx)
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(fields, h.group, a)
+		return true
+	})
+
+	var site callSite
+	if r.PC != 0 && atomic.LoadInt32(&reportCaller) != 0 && uint32(level) <= atomic.LoadUint32(&callerLevel) {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		site = callSite{pc: r.PC, file: frame.File, line: frame.Line}
+	}
+	emit(level, site, r.Message, fields)
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(log.Fields, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	for _, a := range attrs {
+		addAttr(fields, h.group, a)
+	}
+	return &SlogHandler{fields: fields, group: h.group}
+}
+
+// WithGroup implements slog.Handler.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{fields: h.fields, group: h.group + name + "."}
+}
+
+// addAttr adds a to fields with the key prefix, the attributes of a group
+// with the key of the group and a dot.
+func addAttr(fields log.Fields, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		fields[prefix+a.Key] = a.Value.Any()
+		return
+	}
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	for _, ga := range a.Value.Group() {
+		addAttr(fields, prefix, ga)
+	}
+}
+
+// slogLevel returns the level of entries logged at the slog level l.
+func slogLevel(l slog.Level) log.Level {
+	switch {
+	case l < slog.LevelInfo:
+		return log.DebugLevel
+	case l < slog.LevelWarn:
+		return log.InfoLevel
+	case l < slog.LevelError:
+		return log.WarnLevel
+	}
+	return log.ErrorLevel
+}
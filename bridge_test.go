@@ -0,0 +1,95 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	w, err := Writer("warning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(w, "disk almost full\r\nretrying")
+	fmt.Fprint(w, " in 5s\n\nlast words")
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("wrote %d entries before Close, want 2:\n%s", n, buf.String())
+	}
+	w.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for i, want := range []string{"disk almost full", "retrying in 5s", "last words"} {
+		if i >= len(lines) || !strings.Contains(lines[i], ": WARNING\t[") || !strings.HasSuffix(lines[i], "] "+want) {
+			t.Errorf("entries = %q, want %q at %d", lines, want, i)
+		}
+	}
+
+	if _, err := Writer("loud"); err == nil {
+		t.Error("Writer accepted an invalid level")
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	l, err := StdLogger("error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Printf("http: TLS handshake error from %s", "10.0.0.1:4711")
+	if got := buf.String(); !strings.Contains(got, ": ERROR\t") || !strings.HasSuffix(got, "http: TLS handshake error from 10.0.0.1:4711\n") {
+		t.Errorf("entry = %q", got)
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetCallerStyle(CallerShort, false)
+	defer SetCallerStyle(CallerFull, false)
+
+	l := slog.New(NewSlogHandler()).With("iface", "eth1").WithGroup("http")
+	ctx := NewContext(context.Background(), Fields{"request_id": "r-1"})
+	l.WarnContext(ctx, "upstream failed", "status", 503, slog.Group("upstream", "host", "db"), "err", errors.New("refused"))
+
+	got := buf.String()
+	for _, want := range []string{
+		": WARNING\tbridge_test.go:",
+		"upstream failed",
+		"http.err=refused",
+		"http.status=503",
+		"http.upstream.host=db",
+		"iface=eth1",
+		"request_id=r-1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("entry = %q, want %q", got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug - 4, "debug"},
+		{slog.LevelInfo + 2, "info"},
+		{slog.LevelError + 4, "error"},
+	} {
+		if got := slogLevel(tt.level).String(); got != tt.want {
+			t.Errorf("slogLevel(%v) = %s, want %s", tt.level, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ComponentBudget is the share of the log volume of a component, see
+// SetComponentBudget.
+type ComponentBudget struct {
+	// Entries and Bytes cap the entries and the size of their messages and
+	// fields per Interval. Zero means no cap.
+	Entries int
+	Bytes   int64
+	// Interval is the period of the budget, a second if zero.
+	Interval time.Duration
+	// Sample keeps one in Sample of the entries over budget rather than
+	// dropping them all.
+	Sample int
+}
+
+// budgetState is the use of a budget in the current interval.
+type budgetState struct {
+	ComponentBudget
+	start   time.Time
+	entries int
+	bytes   int64
+	// over counts the entries over budget in the interval, dropped the
+	// entries dropped since the last report and total since the budget
+	// was set.
+	over    int
+	dropped uint64
+	total   uint64
+}
+
+var budgets = struct {
+	sync.Mutex
+	active int32
+	states map[string]*budgetState
+	stop   chan struct{}
+}{}
+
+// SetComponentBudget caps the entries of c, see WithComponent, so that a
+// chatty subsystem cannot starve the others of the bandwidth of shared
+// outputs:
+//
+//	log.SetComponentBudget(log.Decode, log.ComponentBudget{Entries: 500, Bytes: 64 << 10})
+//
+// Entries over budget are dropped, or sampled, and counted in Stats; the
+// count is logged with severity WARNING as "log budget exceeded" once per
+// second. FATAL entries are never dropped. A budget without Entries and
+// Bytes removes the budget of c.
+func SetComponentBudget(c Component, b ComponentBudget) {
+	if b.Interval <= 0 {
+		b.Interval = time.Second
+	}
+
+	budgets.Lock()
+	defer budgets.Unlock()
+	if budgets.states == nil {
+		budgets.states = make(map[string]*budgetState)
+	}
+	if b.Entries <= 0 && b.Bytes <= 0 {
+		delete(budgets.states, string(c))
+	} else {
+		budgets.states[string(c)] = &budgetState{ComponentBudget: b, start: time.Now()}
+	}
+
+	if len(budgets.states) == 0 {
+		atomic.StoreInt32(&budgets.active, 0)
+		if budgets.stop != nil {
+			close(budgets.stop)
+			budgets.stop = nil
+		}
+		return
+	}
+	atomic.StoreInt32(&budgets.active, 1)
+	if budgets.stop == nil {
+		budgets.stop = make(chan struct{})
+		go reportBudgets(budgets.stop)
+	}
+}
+
+// allowBudget reports whether an entry fits in the budget of its
+// component.
+func allowBudget(level log.Level, msg string, fields log.Fields) bool {
+	if atomic.LoadInt32(&budgets.active) == 0 || level <= log.FatalLevel {
+		return true
+	}
+	c, _ := fields[ComponentKey].(string)
+
+	budgets.Lock()
+	defer budgets.Unlock()
+	b, ok := budgets.states[c]
+	if !ok {
+		return true
+	}
+	if now := time.Now(); now.Sub(b.start) >= b.Interval {
+		b.start, b.entries, b.bytes, b.over = now, 0, 0, 0
+	}
+	var size int64
+	if b.Bytes > 0 {
+		size = entrySize(msg, fields)
+	}
+	if (b.Entries <= 0 || b.entries < b.Entries) && (b.Bytes <= 0 || b.bytes+size <= b.Bytes) {
+		b.entries++
+		b.bytes += size
+		return true
+	}
+	b.over++
+	if b.Sample > 0 && (b.over-1)%b.Sample == 0 {
+		return true
+	}
+	b.dropped++
+	b.total++
+	atomic.AddUint64(&stats.OverBudget, 1)
+	return false
+}
+
+// entrySize estimates the size of an entry from its message and fields.
+func entrySize(msg string, fields log.Fields) int64 {
+	n := len(msg)
+	for k, v := range fields {
+		if !isReserved(k) {
+			n += len(k) + len(fmt.Sprint(v)) + 2
+		}
+	}
+	return int64(n)
+}
+
+// reportBudgets logs the entries dropped by each budget every second until
+// stop is closed.
+func reportBudgets(stop chan struct{}) {
+	ticker := time.NewTicker(rateLimitReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			budgets.Lock()
+			dropped := make(map[string]uint64)
+			for c, b := range budgets.states {
+				if b.dropped > 0 {
+					dropped[c] = b.dropped
+					b.dropped = 0
+				}
+			}
+			budgets.Unlock()
+
+			for c, n := range dropped {
+				// Like the rate limit summary, the report bypasses the
+				// budget it is about.
+				countWritten(log.WarnLevel)
+				dispatch(log.WarnLevel, captureCaller(log.WarnLevel, 0), "log budget exceeded", log.Fields{
+					ComponentKey: c,
+					"dropped":    n,
+				})
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// budgetDropped returns the entries of c dropped by its budget.
+func budgetDropped(c string) uint64 {
+	budgets.Lock()
+	defer budgets.Unlock()
+	if b, ok := budgets.states[c]; ok {
+		return b.total
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestComponentBudget(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetComponentBudget(Decode, ComponentBudget{Entries: 3, Interval: time.Hour})
+	defer SetComponentBudget(Decode, ComponentBudget{})
+	before := Stats().OverBudget
+
+	for i := 0; i < 10; i++ {
+		WithComponent(Decode).Infof("decoded frame %d", i)
+		WithComponent(Storage).Infof("stored frame %d", i)
+	}
+	if n := strings.Count(out.String(), "decoded frame"); n != 3 {
+		t.Errorf("wrote %d decode entries, want the budget of 3", n)
+	}
+	if n := strings.Count(out.String(), "stored frame"); n != 10 {
+		t.Errorf("wrote %d storage entries, want all 10", n)
+	}
+	if n := Stats().OverBudget - before; n != 7 {
+		t.Errorf("OverBudget increased by %d, want 7", n)
+	}
+	for _, c := range Stats().Components {
+		if c.Component == string(Decode) && c.OverBudget < 7 {
+			t.Errorf("decode dropped %d entries, want at least 7", c.OverBudget)
+		}
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !strings.Contains(out.String(), "log budget exceeded component=decode dropped=7") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no budget report in %q", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestComponentBudgetBytesAndSampling(t *testing.T) {
+	SetComponentBudget(Capture, ComponentBudget{Bytes: 100, Interval: time.Hour, Sample: 4})
+	defer SetComponentBudget(Capture, ComponentBudget{})
+
+	fields := log.Fields{ComponentKey: string(Capture), "len": 1500}
+	kept := 0
+	for i := 0; i < 12; i++ {
+		if allowBudget(log.InfoLevel, "rx frame of 40 bytes.", fields) {
+			kept++
+		}
+	}
+	// 2 entries of 48 bytes fit, then one in 4 of the other 10.
+	if kept != 5 {
+		t.Errorf("kept %d entries, want 5", kept)
+	}
+	if !allowBudget(log.FatalLevel, "capture died", fields) {
+		t.Error("FATAL entry dropped by the budget")
+	}
+}
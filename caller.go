@@ -0,0 +1,184 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// CallerStyle selects how the file of the caller is printed.
+type CallerStyle int
+
+const (
+	// CallerFull prints the absolute path reported by the runtime, e.g.
+	// /home/build/go/src/github.com/net-sniper/sniffer/capture/ring.go:42.
+	// This is the default.
+	CallerFull CallerStyle = iota
+	// CallerModule trims the GOPATH or module cache prefix and prints the
+	// import path of the package, e.g.
+	// github.com/net-sniper/sniffer/capture/ring.go:42.
+	CallerModule
+	// CallerPackage prints the directory and file name, e.g.
+	// capture/ring.go:42.
+	CallerPackage
+	// CallerShort prints the file name only, e.g. ring.go:42.
+	CallerShort
+)
+
+// String returns the name of the style: full, module, package or short.
+func (s CallerStyle) String() string {
+	switch s {
+	case CallerFull:
+		return "full"
+	case CallerModule:
+		return "module"
+	case CallerPackage:
+		return "package"
+	case CallerShort:
+		return "short"
+	}
+	return "unknown"
+}
+
+// parseCallerStyle returns the style named by s, the default for "".
+func parseCallerStyle(s string) (CallerStyle, error) {
+	for _, style := range []CallerStyle{CallerFull, CallerModule, CallerPackage, CallerShort} {
+		if s == style.String() {
+			return style, nil
+		}
+	}
+	if s == "" {
+		return CallerFull, nil
+	}
+	return CallerFull, fmt.Errorf("not a valid caller style: %q", s)
+}
+
+// SetCallerStyle configures how the package formatter prints the caller and
+// whether the function name is included.
+func SetCallerStyle(style CallerStyle, function bool) {
+	updateFormatter(func(f *Formatter) {
+		f.CallerStyle = style
+		f.CallerFunction = function
+	})
+}
+
+// callSite is the place an entry was logged from. It travels with the
+// entry in the reserved key _caller.
+type callSite struct {
+	pc   uintptr
+	file string
+	line int
+}
+
+// callerLevel is the least severe level whose entries record their caller,
+// and reportCaller is zero if no entry does.
+var (
+	callerLevel  = uint32(log.DebugLevel)
+	reportCaller = int32(1)
+)
+
+// SetReportCaller enables or disables recording the caller of entries. The
+// runtime.Caller lookup is the most expensive step of logging an entry. It
+// is enabled by default; SetCallerLevel restricts it to severe entries.
+func SetReportCaller(report bool) {
+	var v int32
+	if report {
+		v = 1
+	}
+	atomic.StoreInt32(&reportCaller, v)
+}
+
+// SetCallerLevel records the caller only for entries at level or more
+// severe, e.g. "warning", sparing the hot path of less severe levels the
+// cost of runtime.Caller. Their entries are written without the caller.
+// The default is debug, i.e. every entry records its caller. An entry
+// escalated by an upgrade rule keeps the decision of its original level.
+func SetCallerLevel(level string) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&callerLevel, uint32(lvl))
+	return nil
+}
+
+// captureCaller returns the caller skip frames above the caller of
+// captureCaller, or nothing if entries at level do not record their caller.
+func captureCaller(level log.Level, skip int) callSite {
+	if level > log.DebugLevel {
+		// Trace entries record their caller like debug entries.
+		level = log.DebugLevel
+	}
+	if atomic.LoadInt32(&reportCaller) == 0 || uint32(level) > atomic.LoadUint32(&callerLevel) {
+		return callSite{}
+	}
+	var c callSite
+	c.pc, c.file, c.line, _ = runtime.Caller(skip + 1)
+	return c
+}
+
+// withCaller adds c to the entry data fields, allocating them if needed.
+func withCaller(fields log.Fields, c callSite) log.Fields {
+	if c.file == "" {
+		return fields
+	}
+	if fields == nil {
+		fields = make(log.Fields, 1)
+	}
+	fields[callerKey] = c
+	return fields
+}
+
+// entryCaller returns the call site carried by entry data.
+func entryCaller(data log.Fields) callSite {
+	c, _ := data[callerKey].(callSite)
+	return c
+}
+
+// caller renders the call site according to the formatter options, empty
+// if it was not recorded.
+func (c *Formatter) caller(site callSite) string {
+	pc, file, line := site.pc, site.file, site.line
+	if file == "" {
+		return ""
+	}
+	var fn string
+	if c.CallerFunction || c.CallerStyle == CallerModule {
+		if f := runtime.FuncForPC(pc); f != nil {
+			fn = f.Name()
+		}
+	}
+
+	switch c.CallerStyle {
+	case CallerModule:
+		if pkg := packagePath(fn); pkg != "" {
+			file = pkg + "/" + filepath.Base(file)
+		}
+	case CallerPackage:
+		file = filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file))
+	case CallerShort:
+		file = filepath.Base(file)
+	}
+
+	s := file + ":" + strconv.Itoa(line)
+	if c.CallerFunction && fn != "" {
+		s += ":" + fn[strings.LastIndex(fn, "/")+1:]
+	}
+	return s
+}
+
+// packagePath returns the import path of the package of a fully qualified
+// function name such as github.com/net-sniper/go-log.(*Logger).Info.
+func packagePath(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	dot := strings.Index(fn[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return fn[:slash+1+dot]
+}
@@ -0,0 +1,145 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFormatterCaller(t *testing.T) {
+	pc, file, line, _ := runtime.Caller(0)
+	l := strconv.Itoa(line)
+
+	tests := []struct {
+		style    CallerStyle
+		function bool
+		want     string
+	}{
+		{CallerFull, false, file + ":" + l},
+		{CallerModule, false, "github.com/net-sniper/go-log/caller_test.go:" + l},
+		{CallerShort, false, "caller_test.go:" + l},
+		{CallerShort, true, "caller_test.go:" + l + ":go-log.TestFormatterCaller"},
+	}
+	for _, tt := range tests {
+		c := &Formatter{CallerStyle: tt.style, CallerFunction: tt.function}
+		if got := c.caller(callSite{pc, file, line}); got != tt.want {
+			t.Errorf("caller(style %d, function %v) = %q, want %q", tt.style, tt.function, got, tt.want)
+		}
+	}
+}
+
+func TestPackagePath(t *testing.T) {
+	tests := map[string]string{
+		"github.com/net-sniper/go-log.(*Logger).Info": "github.com/net-sniper/go-log",
+		"main.main":                "main",
+		"net/http.(*Server).Serve": "net/http",
+	}
+	for fn, want := range tests {
+		if got := packagePath(fn); got != want {
+			t.Errorf("packagePath(%q) = %q, want %q", fn, got, want)
+		}
+	}
+}
+
+func TestSetCallerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	if err := SetCallerLevel("warning"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetCallerLevel("debug")
+
+	Info("hot path")
+	if strings.Contains(buf.String(), "caller_test.go") {
+		t.Errorf("INFO entry %q records its caller", buf.String())
+	}
+	buf.Reset()
+	Warning("problem")
+	if !strings.Contains(buf.String(), "caller_test.go:") {
+		t.Errorf("WARNING entry %q does not record its caller", buf.String())
+	}
+
+	if err := SetCallerLevel("loud"); err == nil {
+		t.Error("SetCallerLevel(loud) = nil, want error")
+	}
+}
+
+func TestSetReportCaller(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetReportCaller(false)
+	defer SetReportCaller(true)
+
+	Error("no caller")
+	if strings.Contains(buf.String(), "caller_test.go") {
+		t.Errorf("entry %q records its caller", buf.String())
+	}
+	if got := CurrentConfig().CallerLevel; got != "none" {
+		t.Errorf("Config.CallerLevel = %q, want none", got)
+	}
+}
+
+func TestCallerIsPerEntry(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_, _, line, _ := runtime.Caller(0)
+				Infof("line %d", line+1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		i := strings.LastIndex(l, "line ")
+		if !strings.Contains(l, "caller_test.go:"+l[i+len("line "):]+"[") {
+			t.Fatalf("entry %q is attributed to the wrong line", l)
+		}
+	}
+}
+
+func TestSetCallerStyleConcurrent(t *testing.T) {
+	SetOutputs(io.Discard)
+	defer SetOutputs(os.Stderr)
+	KeepRecent(time.Minute)
+	defer KeepRecent(0)
+	defer SetCallerStyle(CallerFull, false)
+	defer SetColor(false)
+	for i := 0; i < 10; i++ {
+		Info("filled")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			SetCallerStyle(CallerShort, i%2 == 0)
+			SetColor(i%2 == 1)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			DumpRecent(io.Discard)
+			Info("entry")
+			CurrentConfig()
+		}
+	}()
+	wg.Wait()
+}
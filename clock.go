@@ -0,0 +1,60 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of the time of the entries, see SetClock.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock.
+type ClockFunc func() time.Time
+
+// Now calls fn().
+func (fn ClockFunc) Now() time.Time {
+	return fn()
+}
+
+// clocks holds the Clock of SetClock, nil for the real time.
+var clocks atomic.Value // clockHolder
+
+type clockHolder struct {
+	c Clock
+}
+
+// SetClock makes c the source of the time of every entry and of the
+// durations measured by TimeTrack, e.g. a fake clock a test advances by
+// hand; entries with a time of their own, such as those of Ingest, keep it.
+// The clock of SetTestMode wins over c while the test mode is on. A nil c
+// restores the real time.
+func SetClock(c Clock) {
+	clocks.Store(clockHolder{c})
+}
+
+// activeClock returns the clock of the test mode or else of SetClock, nil
+// for the real time.
+func activeClock() func() time.Time {
+	if synchronous() {
+		testMode.mu.Lock()
+		c := testMode.clock
+		testMode.mu.Unlock()
+		if c != nil {
+			return c
+		}
+	}
+	if h, _ := clocks.Load().(clockHolder); h.c != nil {
+		return h.c.Now
+	}
+	return nil
+}
+
+// now returns the time of the active clock.
+func now() time.Time {
+	if c := activeClock(); c != nil {
+		return c()
+	}
+	return time.Now()
+}
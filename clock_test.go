@@ -0,0 +1,38 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetClock(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	now := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	SetClock(ClockFunc(func() time.Time { return now }))
+	defer SetClock(nil)
+	if err := SetFormat(JSONFormat); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFormat(TextFormat)
+
+	done := TimeTrack(context.Background(), "rebuild", time.Second)
+	now = now.Add(3 * time.Second)
+	done()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"time":"2017-03-01T12:00:03`) || !strings.Contains(lines[0], `"duration":3000000000`) {
+		t.Errorf("output = %q, want the time and the duration of the clock", buf.String())
+	}
+
+	SetClock(nil)
+	buf.Reset()
+	Info("real")
+	if strings.Contains(buf.String(), `"time":"2017-`) {
+		t.Errorf("output after SetClock(nil) = %q", buf.String())
+	}
+}
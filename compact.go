@@ -0,0 +1,261 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SummarySuffix is appended to the name of a compacted file.
+const SummarySuffix = ".summary"
+
+// HourSummary is a record of a compacted file: the entries of one hour
+// logged at one level from one place.
+type HourSummary struct {
+	Hour  time.Time `json:"hour"`
+	Level string    `json:"level"`
+	// Fingerprint is the caller of the entries, or their message if the
+	// line carries no caller.
+	Fingerprint string    `json:"fingerprint"`
+	Count       int       `json:"count"`
+	First       time.Time `json:"first"`
+	Last        time.Time `json:"last"`
+	// Sample is the message of the last entry.
+	Sample string `json:"sample"`
+}
+
+// Compact replaces the log files matching pattern, e.g.
+// "/var/log/sniper.log.*", that were last modified more than age ago with
+// summaries: every file is rewritten as name+SummarySuffix holding one JSON
+// HourSummary per line, counting its entries per hour, level and
+// fingerprint, and is then removed. This keeps the signal of old logs on
+// probes short of disk. Both the text and the JSON format are understood,
+// gzipped archives are read through OpenArchive and encrypted archives are
+// left alone. Lines that are neither are counted as unparsed; a file that
+// is mostly unparsed is kept as it is and reported to the self-log.
+// Compact returns the files it replaced.
+func Compact(pattern string, age time.Duration) ([]string, error) {
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var compacted []string
+	cutoff := time.Now().Add(-age)
+	for _, name := range names {
+		if strings.HasSuffix(name, SummarySuffix) || strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".enc") {
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			return compacted, err
+		}
+		if !fi.Mode().IsRegular() || fi.ModTime().After(cutoff) || name == CurrentConfig().File {
+			continue
+		}
+		ok, err := compactFile(name)
+		if err != nil {
+			return compacted, err
+		}
+		if ok {
+			compacted = append(compacted, name)
+		}
+	}
+	return compacted, nil
+}
+
+// StartCompaction runs Compact every interval until stop is called, e.g.
+// StartCompaction("/var/log/sniper.log.*", 7*24*time.Hour, time.Hour).
+// Errors are reported to the self-log.
+func StartCompaction(pattern string, age, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := Compact(pattern, age); err != nil {
+				reportError(fmt.Errorf("compact %s: %v", pattern, err))
+			}
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// compactFile writes the summary of name and removes it. It reports false,
+// leaving name alone, if name is encrypted or most of its lines are
+// unparsed.
+func compactFile(name string) (bool, error) {
+	f, err := OpenArchive(name, nil)
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return false, err
+	}
+	if err != nil {
+		// An encrypted archive despite its name, or a corrupt one.
+		reportError(fmt.Errorf("compact: %v", err))
+		return false, nil
+	}
+	defer f.Close()
+
+	type key struct {
+		hour        time.Time
+		level       string
+		fingerprint string
+	}
+	sums := make(map[key]*HourSummary)
+	var lines, unparsed int
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), DefaultMaxLineBytes)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		ts, level, fingerprint, msg := parseLine(s.Text())
+		lines++
+		if level == "unparsed" {
+			unparsed++
+		}
+		k := key{ts.UTC().Truncate(time.Hour), level, fingerprint}
+		sum, ok := sums[k]
+		if !ok {
+			sum = &HourSummary{Hour: k.hour, Level: level, Fingerprint: fingerprint, First: ts, Last: ts}
+			sums[k] = sum
+		}
+		sum.Count++
+		if ts.Before(sum.First) {
+			sum.First = ts
+		}
+		if !ts.Before(sum.Last) {
+			sum.Last = ts
+			sum.Sample = msg
+		}
+	}
+	if err := s.Err(); err != nil {
+		return false, fmt.Errorf("read %s: %v", name, err)
+	}
+	if unparsed*2 > lines {
+		reportError(fmt.Errorf("compact %s: %d of %d lines are not log entries, keeping the file", name, unparsed, lines))
+		return false, nil
+	}
+
+	list := make([]*HourSummary, 0, len(sums))
+	for _, sum := range sums {
+		list = append(list, sum)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+
+	out := name + SummarySuffix
+	tmp := out + ".tmp"
+	w, err := createLogFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return false, err
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, sum := range list {
+		enc.Encode(sum)
+	}
+	if err := bw.Flush(); err != nil {
+		w.Close()
+		os.Remove(tmp)
+		return false, err
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		return false, err
+	}
+	return true, os.Remove(name)
+}
+
+// parseLine extracts the time, level, fingerprint and message of a line in
+// the text or JSON format. Unparsed lines get the level "unparsed".
+func parseLine(s string) (ts time.Time, level, fingerprint, msg string) {
+	if strings.HasPrefix(s, "{") {
+		var e Entry
+		if err := json.Unmarshal([]byte(s), &e); err == nil {
+			fingerprint = e.Message
+			if e.File != "" {
+				fingerprint = fmt.Sprintf("%s:%d", e.File, e.Line)
+			}
+			return e.Time, e.Level, fingerprint, e.Message
+		}
+	}
+
+	// timestamp hostname : LEVEL\tcaller tag[pid] message fields
+	unparsed := func() (time.Time, string, string, string) {
+		return time.Time{}, "unparsed", "", s
+	}
+	ts, _, ok := parseTimestamp(s)
+	if !ok {
+		return unparsed()
+	}
+	colon := strings.Index(s, " : ")
+	tab := strings.IndexByte(s, '\t')
+	if colon < 0 || tab < colon {
+		return unparsed()
+	}
+	level = parseLabel(s[colon+3 : tab])
+	rest := s[tab+1:]
+	end := strings.Index(rest, "] ")
+	if end < 0 {
+		return unparsed()
+	}
+	caller := rest[:end]
+	if i := strings.LastIndexByte(caller, '['); i >= 0 {
+		caller = caller[:i]
+	}
+	return ts, level, caller, rest[end+2:]
+}
+
+// splitCallerTag splits the text in front of the PID of a text line into
+// the caller and the tag of WithTag, which holds no colon unlike callers.
+func splitCallerTag(s string) (caller, t string) {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	if strings.IndexByte(s, ':') < 0 {
+		return "", s
+	}
+	return s, ""
+}
+
+// parseLabel returns the level name of a severity label as printed by the
+// text format.
+func parseLabel(label string) string {
+	for _, lvl := range allLevels {
+		if strings.EqualFold(label, levelLabel(lvl)) {
+			return levelName(lvl)
+		}
+	}
+	if lvl, err := parseLevel(label); err == nil {
+		return levelName(lvl)
+	}
+	return strings.ToLower(label)
+}
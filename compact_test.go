@@ -0,0 +1,124 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "sniper.log.1")
+	lines := "" +
+		"2017-03-01T12:05:00Z probe-7 : ERROR\t/src/db.go:42[311] connection refused\n" +
+		"2017-03-01T12:40:00Z probe-7 : ERROR\t/src/db.go:42[311] connection reset retries=3\n" +
+		"2017-03-01T12:41:00Z probe-7 : INFO\t/src/main.go:10[311] started\n" +
+		`{"time":"2017-03-01T13:00:01Z","level":"warning","file":"/src/ring.go","line":7,"msg":"ring full"}` + "\n" +
+		"garbage\n"
+	if err := os.WriteFile(old, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(old, past, past)
+	recent := filepath.Join(dir, "sniper.log.2")
+	os.WriteFile(recent, []byte("2017-03-02T12:00:00Z probe-7 : INFO\t/src/main.go:10[311] started\n"), 0644)
+
+	compacted, err := Compact(filepath.Join(dir, "sniper.log.*"), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compacted) != 1 || compacted[0] != old {
+		t.Fatalf("Compact() = %v, want only %s", compacted, old)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("compacted file still exists: %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("recent file was touched: %v", err)
+	}
+
+	f, err := os.Open(old + SummarySuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []HourSummary
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var h HourSummary
+		if err := json.Unmarshal(s.Bytes(), &h); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, h)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d summaries, want 4: %+v", len(got), got)
+	}
+	e := got[1]
+	if e.Level != "error" || e.Fingerprint != "/src/db.go:42" || e.Count != 2 || e.Sample != "connection reset retries=3" {
+		t.Errorf("error summary = %+v", e)
+	}
+	if !e.First.Equal(time.Date(2017, 3, 1, 12, 5, 0, 0, time.UTC)) || !e.Last.Equal(time.Date(2017, 3, 1, 12, 40, 0, 0, time.UTC)) {
+		t.Errorf("error summary spans %s to %s", e.First, e.Last)
+	}
+	if got[0].Level != "unparsed" || got[3].Fingerprint != "/src/ring.go:7" || got[3].Level != "warning" {
+		t.Errorf("summaries = %+v", got)
+	}
+
+	// Summaries are never compacted again.
+	past = time.Now().Add(-48 * time.Hour)
+	os.Chtimes(old+SummarySuffix, past, past)
+	if compacted, _ := Compact(filepath.Join(dir, "sniper.log.*"), 24*time.Hour); len(compacted) != 0 {
+		t.Errorf("second Compact() = %v, want nothing", compacted)
+	}
+}
+
+func TestCompactArchives(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	io.WriteString(zw, "2017-03-01T12:05:00Z probe-7 : ERROR\t/src/db.go:42[311] connection refused\n")
+	zw.Close()
+	files := map[string][]byte{
+		"sniper.log.1.gz":  gz.Bytes(),
+		"sniper.log.2.enc": []byte(archiveMagic + "\x01kciphertext"),
+		"sniper.log.3":     {0x03, 0x00, 0x6e, 0x2c, 0xff, 0xfe, 0x44, '\n', 0x01, 0x02, '\n'},
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	for name, b := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, past, past)
+	}
+
+	compacted, err := Compact(filepath.Join(dir, "sniper.log.*"), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compacted) != 1 || filepath.Base(compacted[0]) != "sniper.log.1.gz" {
+		t.Fatalf("Compact() = %v, want only the gzipped backup", compacted)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "sniper.log.1.gz"+SummarySuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h HourSummary
+	if err := json.Unmarshal(b, &h); err != nil || h.Level != "error" || h.Sample != "connection refused" {
+		t.Errorf("summary of the gzipped backup = %s (%v)", b, err)
+	}
+	for _, name := range []string{"sniper.log.2.enc", "sniper.log.3"} {
+		if got, err := os.ReadFile(filepath.Join(dir, name)); err != nil || !bytes.Equal(got, files[name]) {
+			t.Errorf("%s was not left alone: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ComponentKey is the field naming the component an entry comes from.
+const ComponentKey = "component"
+
+// Component is a part of a net-sniper tool in the controlled vocabulary
+// that routing rules and dashboards key off. Components are predeclared
+// or registered with RegisterComponent; entries naming an unregistered one
+// are reported once to the self-log.
+type Component string
+
+// The components common to every net-sniper tool.
+const (
+	Capture Component = "capture"
+	Decode  Component = "decode"
+	Storage Component = "storage"
+	API     Component = "api"
+)
+
+var components = struct {
+	sync.Mutex
+	known    map[Component]bool
+	reported map[Component]bool
+}{
+	known:    map[Component]bool{Capture: true, Decode: true, Storage: true, API: true},
+	reported: make(map[Component]bool),
+}
+
+// RegisterComponent adds name to the components, typically in a package
+// variable declaration:
+//
+//	var Flows = log.RegisterComponent("flows")
+//
+// Names are lower case letters, digits, '-' and '_'; RegisterComponent
+// panics on other names. Registering a name twice is harmless.
+func RegisterComponent(name string) Component {
+	if name == "" || strings.TrimLeft(name, "abcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+		panic(fmt.Sprintf("log: invalid component name %q", name))
+	}
+
+	c := Component(name)
+	components.Lock()
+	components.known[c] = true
+	components.Unlock()
+	return c
+}
+
+// Components returns the registered components, sorted.
+func Components() []Component {
+	components.Lock()
+	defer components.Unlock()
+
+	list := make([]Component, 0, len(components.known))
+	for c := range components.known {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// check reports c to the self-log the first time it is used without being
+// registered.
+func (c Component) check() {
+	components.Lock()
+	defer components.Unlock()
+
+	if components.known[c] || components.reported[c] {
+		return
+	}
+	components.reported[c] = true
+	reportError(fmt.Errorf("component %q is not registered", string(c)))
+}
+
+// Field returns the component field for c, for use with With.
+func (c Component) Field() Field {
+	c.check()
+	return String(ComponentKey, string(c))
+}
+
+// WithComponent returns a Logger attributing its entries to c.
+func WithComponent(c Component) *Logger {
+	return &Logger{typed: []Field{c.Field()}}
+}
+
+// WithComponent returns a child of l attributing its entries to c.
+func (l *Logger) WithComponent(c Component) *Logger {
+	return &Logger{parent: l, typed: []Field{c.Field()}}
+}
+
+// componentLevels holds the levels set with SetComponentLevel, as a
+// map[string]log.Level replaced on every change.
+var componentLevels struct {
+	sync.Mutex
+	levels atomic.Value
+	// verbose is the most verbose of the levels, for the fast check of
+	// enabled.
+	verbose uint32
+}
+
+// SetComponentLevel sets the level of the entries attributed to c, see
+// WithComponent, e.g. to get the debug entries of one subsystem only:
+//
+//	log.SetComponentLevel(log.Storage, "debug")
+//
+// The entries of other components keep the level set with SetLevel. An
+// empty level removes the level of c. Component levels apply to the package
+// logger, not to Loggers created by New.
+func SetComponentLevel(c Component, level string) error {
+	var lvl log.Level
+	if level != "" {
+		var err error
+		if lvl, err = parseLevel(level); err != nil {
+			return err
+		}
+	}
+
+	componentLevels.Lock()
+	defer componentLevels.Unlock()
+
+	old, _ := componentLevels.levels.Load().(map[string]log.Level)
+	levels := make(map[string]log.Level, len(old)+1)
+	for k, v := range old {
+		levels[k] = v
+	}
+	if level == "" {
+		delete(levels, string(c))
+	} else {
+		levels[string(c)] = lvl
+	}
+	storeComponentLevels(levels)
+	return nil
+}
+
+// storeComponentLevels replaces the component levels, with componentLevels
+// locked.
+func storeComponentLevels(levels map[string]log.Level) {
+	var verbose log.Level
+	for _, v := range levels {
+		if v > verbose {
+			verbose = v
+		}
+	}
+	componentLevels.levels.Store(levels)
+	atomic.StoreUint32(&componentLevels.verbose, uint32(verbose))
+}
+
+// SetComponentLevels applies a spec such as "storage=debug,capture=warn,*=info"
+// listing component=level pairs, where * stands for the level set with
+// SetLevel. Components not in the spec lose their level. Nothing is changed
+// if the spec is invalid.
+func SetComponentLevels(spec string) error {
+	levels, def, err := parseComponentLevels(spec)
+	if err != nil {
+		return err
+	}
+	if def != "" {
+		SetLevel(def)
+	}
+	componentLevels.Lock()
+	storeComponentLevels(levels)
+	componentLevels.Unlock()
+	return nil
+}
+
+// parseComponentLevels parses a spec of SetComponentLevels into the levels
+// of the components and the level of *, empty if not in the spec.
+func parseComponentLevels(spec string) (map[string]log.Level, string, error) {
+	levels := make(map[string]log.Level)
+	def := ""
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(pair, "=")
+		name, level = strings.TrimSpace(name), strings.TrimSpace(level)
+		if !ok || name == "" {
+			return nil, "", fmt.Errorf("component level %q is not component=level", pair)
+		}
+		lvl, err := parseLevel(level)
+		if err != nil {
+			return nil, "", fmt.Errorf("component %s: %v", name, err)
+		}
+		if name == "*" {
+			def = level
+			continue
+		}
+		levels[name] = lvl
+	}
+	return levels, def, nil
+}
+
+// componentLevel returns the level set for the component of entry data.
+func componentLevel(data log.Fields) (log.Level, bool) {
+	levels, _ := componentLevels.levels.Load().(map[string]log.Level)
+	if len(levels) == 0 {
+		return 0, false
+	}
+	c, ok := data[ComponentKey].(string)
+	if !ok {
+		return 0, false
+	}
+	lvl, ok := levels[c]
+	return lvl, ok
+}
+
+// componentVerbose returns the most verbose component level, panic if none
+// is set.
+func componentVerbose() log.Level {
+	return log.Level(atomic.LoadUint32(&componentLevels.verbose))
+}
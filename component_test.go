@@ -0,0 +1,115 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestComponents(t *testing.T) {
+	var buf, self bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(&self)
+	defer SetSelfLog(os.Stderr)
+
+	flows := RegisterComponent("flows")
+	WithComponent(Capture).WithComponent(flows).Info("started")
+	if !strings.Contains(buf.String(), "started component=flows") {
+		t.Errorf("output %q does not carry the innermost component", buf.String())
+	}
+	found := false
+	for _, c := range Components() {
+		found = found || c == flows
+	}
+	if !found {
+		t.Errorf("Components() = %v, want flows included", Components())
+	}
+	if self.Len() != 0 {
+		t.Errorf("registered components were reported: %q", self.String())
+	}
+
+	With(Component("typo").Field()).Info("first")
+	With(Component("typo").Field()).Info("second")
+	if n := strings.Count(self.String(), `"typo" is not registered`); n != 1 {
+		t.Errorf("unregistered component reported %d times, want once: %q", n, self.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterComponent accepted an invalid name")
+		}
+	}()
+	RegisterComponent("Has Space")
+}
+
+func TestSetComponentLevel(t *testing.T) {
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetLevel("info")
+	defer SetLevel("debug")
+	defer SetComponentLevels("")
+
+	if err := SetComponentLevel(Storage, "debug"); err != nil {
+		t.Fatal(err)
+	}
+	WithComponent(Storage).Debug("storage detail")
+	WithComponent(Capture).Debug("capture detail")
+	Debug("plain detail")
+	out := buf.String()
+	if !strings.Contains(out, "storage detail") {
+		t.Errorf("output %q lacks the debug entry of storage", out)
+	}
+	if strings.Contains(out, "capture detail") || strings.Contains(out, "plain detail") {
+		t.Errorf("output %q has debug entries of other components", out)
+	}
+
+	SetComponentLevel(Storage, "")
+	WithComponent(Storage).Debug("storage again")
+	if strings.Contains(buf.String(), "storage again") {
+		t.Error("debug entry written after the component level was removed")
+	}
+	if err := SetComponentLevel(Storage, "loud"); err == nil {
+		t.Error("SetComponentLevel accepted an invalid level")
+	}
+}
+
+func TestSetComponentLevels(t *testing.T) {
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer SetLevel("debug")
+	defer SetComponentLevels("")
+
+	if err := SetComponentLevels("storage=debug, capture=warn,*=info"); err != nil {
+		t.Fatal(err)
+	}
+	WithComponent(Storage).Debug("storage detail")
+	WithComponent(Capture).Info("capture info")
+	WithComponent(Capture).Warning("capture warning")
+	Info("plain info")
+	Debug("plain detail")
+	out := buf.String()
+	for _, want := range []string{"storage detail", "capture warning", "plain info"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q lacks %q", out, want)
+		}
+	}
+	for _, unwanted := range []string{"capture info", "plain detail"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output %q has %q", out, unwanted)
+		}
+	}
+
+	for _, spec := range []string{"storage", "storage=loud", "=debug"} {
+		if err := SetComponentLevels(spec); err == nil {
+			t.Errorf("SetComponentLevels(%q) = nil, want an error", spec)
+		}
+	}
+	WithComponent(Storage).Debug("still storage")
+	if !strings.Contains(buf.String(), "still storage") {
+		t.Error("an invalid spec changed the component levels")
+	}
+}
@@ -0,0 +1,140 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultCompressFlushInterval is the flush interval of NewCompressedWriter
+// unless it is given another.
+const DefaultCompressFlushInterval = time.Second
+
+// Compressor is a compressed stream written to an output, such as a
+// *gzip.Writer, a *zlib.Writer or the encoder of klauspost/compress/zstd.
+// Flush writes a flush point, up to which a reader decompresses
+// everything written, and Close ends the stream.
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+var errCompressedClosed = errors.New("compressed output closed")
+
+// CompressedWriter compresses the entries written to an output as one
+// continuous stream, e.g. for trace captures so verbose that even the
+// active log file is too large uncompressed:
+//
+//	f, _ := os.Create("capture.log.gz")
+//	log.SetOutputs(log.NewCompressedWriter(f, nil, 0))
+//
+// A flush point is written at most one interval after an entry, so that
+// zcat or a crash recovery reads everything but the last interval even
+// if the stream is never closed; short intervals cost compression. The
+// output should be a plain file, as rotation would split the stream.
+// Close ends the stream; Flush writes a flush point right away.
+type CompressedWriter struct {
+	w        io.Writer
+	interval time.Duration
+
+	mu      sync.Mutex
+	c       Compressor
+	timer   *time.Timer
+	pending bool
+	closed  bool
+}
+
+// NewCompressedWriter returns a CompressedWriter writing to w through c,
+// or gzip if c is nil, with a flush point every interval, or
+// DefaultCompressFlushInterval if interval is not positive. c must write
+// to w.
+func NewCompressedWriter(w io.Writer, c Compressor, interval time.Duration) *CompressedWriter {
+	if c == nil {
+		c = gzip.NewWriter(w)
+	}
+	if interval <= 0 {
+		interval = DefaultCompressFlushInterval
+	}
+	return &CompressedWriter{w: w, c: c, interval: interval}
+}
+
+// Name describes the wrapped output.
+func (z *CompressedWriter) Name() string {
+	return "compressed:" + outputName(z.w)
+}
+
+// Write compresses p. Entries written after Close are refused.
+func (z *CompressedWriter) Write(p []byte) (int, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.closed {
+		return 0, errCompressedClosed
+	}
+	n, err := z.c.Write(p)
+	if err == nil && !z.pending {
+		z.pending = true
+		if z.timer == nil {
+			z.timer = time.AfterFunc(z.interval, z.flushPoint)
+		} else {
+			z.timer.Reset(z.interval)
+		}
+	}
+	return n, err
+}
+
+// flushPoint writes the flush point due since an entry was written.
+func (z *CompressedWriter) flushPoint() {
+	if err := z.flush(); err != nil {
+		reportError(fmt.Errorf("flush output %s: %v", z.Name(), err))
+	}
+}
+
+// flush writes a flush point if entries were written since the last one.
+func (z *CompressedWriter) flush() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if !z.pending || z.closed {
+		return nil
+	}
+	z.pending = false
+	z.timer.Stop()
+	return z.c.Flush()
+}
+
+// Flush writes a flush point and flushes the output if it buffers entries
+// itself.
+func (z *CompressedWriter) Flush() error {
+	if err := z.flush(); err != nil {
+		return err
+	}
+	if f, ok := z.w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close ends the compressed stream and flushes the output. The output
+// itself is not closed.
+func (z *CompressedWriter) Close() error {
+	z.mu.Lock()
+	if z.closed {
+		z.mu.Unlock()
+		return nil
+	}
+	z.closed = true
+	if z.timer != nil {
+		z.timer.Stop()
+	}
+	err := z.c.Close()
+	z.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if f, ok := z.w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
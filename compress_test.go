@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decompressed returns what a reader gets from a possibly unfinished
+// stream.
+func decompressed(t *testing.T, open func(io.Reader) (io.Reader, error), b string) string {
+	t.Helper()
+	r, err := open(strings.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := io.ReadAll(r)
+	return string(d)
+}
+
+func gunzip(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+func TestCompressedWriter(t *testing.T) {
+	var out syncBuffer
+	z := NewCompressedWriter(&out, nil, 10*time.Millisecond)
+	SetOutputs(z)
+	defer SetOutputs(os.Stderr)
+
+	Info("rx frame 1")
+	Info("rx frame 2")
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(decompressed(t, gunzip, out.String()), "rx frame 2") {
+		if time.Now().After(deadline) {
+			t.Fatal("no flush point written after the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	Info("rx frame 3")
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("closed stream: %v", err)
+	}
+	if n := strings.Count(string(b), "rx frame"); n != 3 {
+		t.Errorf("decompressed %q, want 3 entries", b)
+	}
+	if _, err := z.Write([]byte("late\n")); err == nil {
+		t.Error("Write after Close = nil error, want an error")
+	}
+}
+
+func TestCompressedWriterCompressor(t *testing.T) {
+	var out bytes.Buffer
+	z := NewCompressedWriter(&out, zlib.NewWriter(&out), time.Hour)
+	io.WriteString(z, "rx frame 1\n")
+	if err := z.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	open := func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }
+	if got := decompressed(t, open, out.String()); got != "rx frame 1\n" {
+		t.Errorf("decompressed %q after Flush", got)
+	}
+	if got := z.Name(); got != "compressed:*bytes.Buffer" {
+		t.Errorf("Name() = %q", got)
+	}
+	z.Close()
+}
@@ -0,0 +1,230 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Config is the effective configuration of the package logger.
+type Config struct {
+	// File is the path opened by Init, empty if SetOutputs replaced it.
+	File    string   `json:"file,omitempty"`
+	Level   string   `json:"level"`
+	Tag     string   `json:"tag"`
+	Outputs []string `json:"outputs"`
+	// Format is the format selected with SetFormat, or custom after
+	// SetFormatter.
+	Format string `json:"format"`
+	// TimeFormat and TimeUTC are the timestamp layout and zone set with
+	// SetTimeFormat, and Uptime whether SetUptime enabled the uptime
+	// field.
+	TimeFormat string `json:"time_format,omitempty"`
+	TimeUTC    bool   `json:"time_utc,omitempty"`
+	Uptime     bool   `json:"uptime,omitempty"`
+	// FloatPrecision, DurationUnit and ThousandsSep are the rendering of
+	// numbers set with SetNumberFormat.
+	FloatPrecision int    `json:"float_precision,omitempty"`
+	DurationUnit   string `json:"duration_unit,omitempty"`
+	ThousandsSep   string `json:"thousands_sep,omitempty"`
+
+	CallerStyle    string `json:"caller_style"`
+	CallerFunction bool   `json:"caller_function"`
+	// CallerLevel is the level set with SetCallerLevel, or none if
+	// SetReportCaller disabled recording the caller.
+	CallerLevel string `json:"caller_level"`
+
+	// RecentWindow is the window kept by KeepRecent, zero if disabled.
+	RecentWindow string `json:"recent_window,omitempty"`
+	// RateLimit and RateBurst are the limits set by SetRateLimit, zero if
+	// disabled.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	RateBurst int     `json:"rate_burst,omitempty"`
+
+	FatalFlushTimeout string `json:"fatal_flush_timeout"`
+}
+
+// CurrentConfig returns the configuration the package logger is actually
+// using.
+func CurrentConfig() Config {
+	f := packageFormatter()
+	c := Config{
+		Level:          levelName(getLevel()),
+		Tag:            tag,
+		Outputs:        []string{},
+		Format:         format,
+		CallerStyle:    f.CallerStyle.String(),
+		CallerFunction: f.CallerFunction,
+		CallerLevel:    levelName(log.Level(atomic.LoadUint32(&callerLevel))),
+		Uptime:         atomic.LoadInt32(&uptime) != 0,
+	}
+	tf := activeTimeFormat()
+	c.TimeFormat, c.TimeUTC = tf.layout, tf.utc
+	nf := activeNumberFormat()
+	c.FloatPrecision, c.ThousandsSep = nf.FloatPrecision, nf.ThousandsSep
+	if nf.DurationUnit > 0 {
+		c.DurationUnit = nf.DurationUnit.String()
+	}
+
+	if atomic.LoadInt32(&reportCaller) == 0 {
+		c.CallerLevel = "none"
+	}
+
+	current.Lock()
+	c.File = current.file
+	if current.output != nil {
+		for _, w := range current.output.outputs {
+			c.Outputs = append(c.Outputs, outputName(w))
+		}
+	}
+	c.FatalFlushTimeout = current.fatalFlushTimeout.String()
+	current.Unlock()
+
+	recent.mu.Lock()
+	if recent.window > 0 {
+		c.RecentWindow = recent.window.String()
+	}
+	recent.mu.Unlock()
+
+	limiter.mu.Lock()
+	if limiter.rate > 0 {
+		c.RateLimit = limiter.rate
+		c.RateBurst = int(limiter.burst)
+	}
+	limiter.mu.Unlock()
+
+	return c
+}
+
+// ConfigHandler returns an HTTP handler rendering CurrentConfig as JSON.
+func ConfigHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(CurrentConfig())
+	})
+}
+
+// ConfigChange is a setting that differs between two configurations.
+type ConfigChange struct {
+	// Key is the JSON name of the setting, e.g. rate_limit.
+	Key      string
+	Old, New interface{}
+}
+
+// DiffConfig returns the settings that differ between old and new, in the
+// order of the Config fields.
+func DiffConfig(old, new Config) []ConfigChange {
+	var changes []ConfigChange
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		o, n := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		changes = append(changes, ConfigChange{Key: key, Old: o, New: n})
+	}
+	return changes
+}
+
+// Reconfigure applies c to the package logger, e.g. after reloading a
+// configuration file, and logs the changed settings with severity INFO: the
+// changed field lists their keys and each one is recorded as <key>_old and
+// <key>_new, so audits can tell when verbosity or destinations changed.
+// Outputs cannot be rebuilt from their names and are ignored; a File
+// different from the current one is opened instead of the current output.
+// Nothing is changed if c is invalid. Combinations of settings that do not
+// work together are logged as with Init, see CheckConfig.
+func Reconfigure(c Config) error {
+	old := CurrentConfig()
+
+	lvl, err := parseLevel(c.Level)
+	if err != nil {
+		return err
+	}
+	style, err := parseCallerStyle(c.CallerStyle)
+	if err != nil {
+		return err
+	}
+	fmtName, err := parseFormat(c.Format)
+	if err != nil {
+		return err
+	}
+	callerLvl, report := log.DebugLevel, c.CallerLevel != "none"
+	if c.CallerLevel != "" && report {
+		if callerLvl, err = parseLevel(c.CallerLevel); err != nil {
+			return fmt.Errorf("caller_level: %v", err)
+		}
+	}
+	var window, flushTimeout time.Duration
+	if c.RecentWindow != "" {
+		if window, err = time.ParseDuration(c.RecentWindow); err != nil {
+			return fmt.Errorf("recent_window: %v", err)
+		}
+	}
+	if c.FatalFlushTimeout != "" {
+		if flushTimeout, err = time.ParseDuration(c.FatalFlushTimeout); err != nil {
+			return fmt.Errorf("fatal_flush_timeout: %v", err)
+		}
+	}
+	nf := NumberFormat{FloatPrecision: c.FloatPrecision, ThousandsSep: c.ThousandsSep}
+	if c.DurationUnit != "" {
+		if nf.DurationUnit, err = time.ParseDuration(c.DurationUnit); err != nil {
+			return fmt.Errorf("duration_unit: %v", err)
+		}
+	}
+	if err := nf.validate(); err != nil {
+		return err
+	}
+	if c.File != "" && c.File != old.File {
+		if err := createLogDir(c.File); err != nil {
+			return err
+		}
+		f, err := openLogFile(c.File)
+		if err != nil {
+			return err
+		}
+		setOutput(&lazyFile{name: c.File, f: f})
+		current.Lock()
+		current.file = c.File
+		current.Unlock()
+	}
+
+	setLevel(lvl)
+	tag = c.Tag
+	if f := packageFormatter(); style != f.CallerStyle || c.CallerFunction != f.CallerFunction {
+		SetCallerStyle(style, c.CallerFunction)
+	}
+	atomic.StoreUint32(&callerLevel, uint32(callerLvl))
+	SetReportCaller(report)
+	if fmtName != format {
+		SetFormat(fmtName)
+	}
+	SetTimeFormat(c.TimeFormat, c.TimeUTC)
+	SetUptime(c.Uptime)
+	SetNumberFormat(nf)
+	KeepRecent(window)
+	if c.RateLimit != old.RateLimit || c.RateBurst != old.RateBurst {
+		SetRateLimit(c.RateLimit, c.RateBurst)
+	}
+	if c.FatalFlushTimeout != "" {
+		SetFatalFlushTimeout(flushTimeout)
+	}
+
+	changes := DiffConfig(old, CurrentConfig())
+	if len(changes) == 0 {
+		return nil
+	}
+	defer reportConfigProblems(captureCaller(log.WarnLevel, 1))
+	output(log.InfoLevel, "configuration changed", changeFields(changes))
+	return nil
+}
@@ -0,0 +1,108 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigHandler(t *testing.T) {
+	SetOutputs(os.Stdout, os.Stderr)
+	defer SetOutputs(os.Stderr)
+	SetRateLimit(100, 10)
+	defer SetRateLimit(0, 0)
+	KeepRecent(30 * time.Second)
+	defer KeepRecent(0)
+
+	rec := httptest.NewRecorder()
+	ConfigHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/log/config", nil))
+
+	var c Config
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	if len(c.Outputs) != 2 || c.Outputs[0] != "/dev/stdout" {
+		t.Errorf("Outputs = %v, want stdout and stderr", c.Outputs)
+	}
+	if c.RateLimit != 100 || c.RateBurst != 10 {
+		t.Errorf("rate limit = %v/%v, want 100/10", c.RateLimit, c.RateBurst)
+	}
+	if c.RecentWindow != "30s" {
+		t.Errorf("RecentWindow = %q, want 30s", c.RecentWindow)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetLevel("debug")
+	defer SetLevel("debug")
+	defer SetRateLimit(0, 0)
+
+	c := CurrentConfig()
+	c.Level = "info"
+	c.RateLimit, c.RateBurst = 100, 10
+	if err := Reconfigure(c); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		"configuration changed",
+		"changed=level,rate_limit,rate_burst",
+		"level_new=info level_old=debug",
+		"rate_limit_new=100 rate_limit_old=0",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+	if CurrentConfig().Level != "info" {
+		t.Errorf("level = %s after Reconfigure, want info", CurrentConfig().Level)
+	}
+
+	buf.Reset()
+	if err := Reconfigure(CurrentConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unchanged configuration logged %q", buf.String())
+	}
+}
+
+func TestReconfigureInvalid(t *testing.T) {
+	c := CurrentConfig()
+	for _, mutate := range []func(*Config){
+		func(c *Config) { c.Level = "loud" },
+		func(c *Config) { c.CallerStyle = "tiny" },
+		func(c *Config) { c.Format = "xml" },
+		func(c *Config) { c.CallerLevel = "loud" },
+		func(c *Config) { c.RecentWindow = "forever" },
+		func(c *Config) { c.DurationUnit = "fortnight" },
+		func(c *Config) { c.ThousandsSep = "." },
+	} {
+		bad := c
+		mutate(&bad)
+		if err := Reconfigure(bad); err == nil {
+			t.Errorf("Reconfigure(%+v) = nil, want error", bad)
+		}
+	}
+	if !reflect.DeepEqual(CurrentConfig(), c) {
+		t.Errorf("invalid configuration was partially applied")
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	a := Config{Level: "info", Outputs: []string{"/dev/stderr"}}
+	b := Config{Level: "info", Outputs: []string{"/dev/stderr", "/var/log/a.log"}}
+	changes := DiffConfig(a, b)
+	if len(changes) != 1 || changes[0].Key != "outputs" {
+		t.Errorf("DiffConfig() = %+v, want outputs changed", changes)
+	}
+}
@@ -0,0 +1,265 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// FileConfig is the configuration read by InitFromConfig and InitFromEnv,
+// e.g. as YAML:
+//
+//	level: info
+//	format: json
+//	file: /var/log/sniper/sniper.log
+//	rotation:
+//	  max_size: 104857600
+//	  max_backups: 5
+//	  compress: true
+//	components: storage=debug,capture=warn
+//
+// The keys are the same in JSON and TOML.
+type FileConfig struct {
+	// Level is the level of Init, info if empty.
+	Level  string `json:"level" yaml:"level" toml:"level"`
+	Format string `json:"format,omitempty" yaml:"format" toml:"format"`
+	// File is the log file, stderr if empty.
+	File     string        `json:"file,omitempty" yaml:"file" toml:"file"`
+	Rotation *FileRotation `json:"rotation,omitempty" yaml:"rotation" toml:"rotation"`
+	// Components is a spec of SetComponentLevels.
+	Components string `json:"components,omitempty" yaml:"components" toml:"components"`
+}
+
+// FileRotation is the RotationConfig of a FileConfig, MaxAge and
+// MaxBackupAge being durations such as 24h.
+type FileRotation struct {
+	MaxSize      int64  `json:"max_size,omitempty" yaml:"max_size" toml:"max_size"`
+	MaxAge       string `json:"max_age,omitempty" yaml:"max_age" toml:"max_age"`
+	MaxBackups   int    `json:"max_backups,omitempty" yaml:"max_backups" toml:"max_backups"`
+	Compress     bool   `json:"compress,omitempty" yaml:"compress" toml:"compress"`
+	Shard        string `json:"shard,omitempty" yaml:"shard" toml:"shard"`
+	Schedule     string `json:"schedule,omitempty" yaml:"schedule" toml:"schedule"`
+	MaxBackupAge string `json:"max_backup_age,omitempty" yaml:"max_backup_age" toml:"max_backup_age"`
+	Symlink      string `json:"symlink,omitempty" yaml:"symlink" toml:"symlink"`
+}
+
+// InitFromConfig initializes the package logger like Init from the
+// configuration file path, see FileConfig, so that services share the glue
+// between their configuration and the logger. The file is YAML if its
+// extension is .yaml or .yml, TOML if it is .toml and JSON otherwise.
+// Unknown keys are refused. Nothing is initialized if the file is invalid.
+// WatchConfig applies later changes of the file.
+func InitFromConfig(path string) error {
+	c, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	return c.init("InitFromConfig")
+}
+
+// InitFromEnv initializes the package logger like Init from the environment
+// variables LOG_LEVEL, LOG_FORMAT, LOG_FILE and LOG_COMPONENTS, the
+// settings of FileConfig, e.g. for containers configured through their
+// environment. Nothing is initialized if a variable is invalid.
+func InitFromEnv() error {
+	return FileConfig{
+		Level:      os.Getenv("LOG_LEVEL"),
+		Format:     os.Getenv("LOG_FORMAT"),
+		File:       os.Getenv("LOG_FILE"),
+		Components: os.Getenv("LOG_COMPONENTS"),
+	}.init("InitFromEnv")
+}
+
+// WatchConfig watches the configuration file path of InitFromConfig with
+// fsnotify and applies its level, format, file and component levels when it
+// is written or replaced, without a restart. The changes are logged as by
+// Reconfigure; removing the file switches back to stderr and the rotation
+// only applies to files opened by Init. An
+// invalid file is reported to the self-log and leaves the configuration as
+// it is. The directory of path is watched, so that editors and deployment
+// tools replacing the file by a rename are noticed. stop ends the watch.
+func WatchConfig(path string) (stop func(), err error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		w.Close()
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != path || !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) {
+					continue
+				}
+				if err := reloadConfig(path); err != nil {
+					reportError(err)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				reportError(fmt.Errorf("config %s: %v", path, err))
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			w.Close()
+		})
+	}, nil
+}
+
+// readConfigFile reads and validates a FileConfig.
+func readConfigFile(path string) (FileConfig, error) {
+	var c FileConfig
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := decodeConfig(path, b, &c); err != nil {
+		return c, fmt.Errorf("config %s: %v", path, err)
+	}
+	if _, err := c.validate(); err != nil {
+		return c, fmt.Errorf("config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// decodeConfig decodes b into c in the format the extension of path names,
+// refusing unknown keys.
+func decodeConfig(path string, b []byte, c *FileConfig) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		return dec.Decode(c)
+	case ".toml":
+		md, err := toml.NewDecoder(bytes.NewReader(b)).Decode(c)
+		if err != nil {
+			return err
+		}
+		if keys := md.Undecoded(); len(keys) > 0 {
+			return fmt.Errorf("unknown key %s", keys[0])
+		}
+		return nil
+	default:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		return dec.Decode(c)
+	}
+}
+
+// validate checks c and returns its rotation.
+func (c FileConfig) validate() (*RotationConfig, error) {
+	if c.Level != "" {
+		if _, err := parseLevel(c.Level); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := parseFormat(c.Format); err != nil {
+		return nil, err
+	}
+	if _, _, err := parseComponentLevels(c.Components); err != nil {
+		return nil, err
+	}
+	if c.Rotation == nil {
+		return nil, nil
+	}
+	r := &RotationConfig{
+		MaxSize:    c.Rotation.MaxSize,
+		MaxBackups: c.Rotation.MaxBackups,
+		Compress:   c.Rotation.Compress,
+		Shard:      c.Rotation.Shard,
+		Schedule:   c.Rotation.Schedule,
+		Symlink:    c.Rotation.Symlink,
+	}
+	if c.Rotation.MaxAge != "" {
+		var err error
+		if r.MaxAge, err = time.ParseDuration(c.Rotation.MaxAge); err != nil {
+			return nil, fmt.Errorf("rotation max_age: %v", err)
+		}
+	}
+	if c.Rotation.MaxBackupAge != "" {
+		var err error
+		if r.MaxBackupAge, err = time.ParseDuration(c.Rotation.MaxBackupAge); err != nil {
+			return nil, fmt.Errorf("rotation max_backup_age: %v", err)
+		}
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// init initializes the package logger from c on behalf of the function by.
+func (c FileConfig) init(by string) error {
+	rot, err := c.validate()
+	if err != nil {
+		return err
+	}
+	level := c.Level
+	if level == "" {
+		level = "info"
+	}
+	initOnce(by, c.File, level, func() {
+		if c.Format != "" {
+			SetFormat(c.Format)
+		}
+		if rot != nil {
+			SetRotation(*rot)
+		}
+		if c.File == "" {
+			initStream(os.Stderr, level)
+		} else {
+			initFile(c.File, level)
+		}
+		SetComponentLevels(c.Components)
+	})
+	return nil
+}
+
+// reloadConfig applies the configuration file path to the initialized
+// package logger.
+func reloadConfig(path string) error {
+	c, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	rc := CurrentConfig()
+	rc.Level, rc.Format, rc.File = c.Level, c.Format, c.File
+	if rc.Level == "" {
+		rc.Level = "info"
+	}
+	if err := Reconfigure(rc); err != nil {
+		return fmt.Errorf("config %s: %v", path, err)
+	}
+	if before := CurrentConfig(); c.File == "" && before.File != "" {
+		// Reconfigure keeps the output for an empty File.
+		SetOutputs(os.Stderr)
+		output(log.InfoLevel, "configuration changed", changeFields(DiffConfig(before, CurrentConfig())))
+	}
+	return SetComponentLevels(c.Components)
+}
@@ -0,0 +1,171 @@
+package log
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInitFromConfig(t *testing.T) {
+	resetInit(t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "probe.log")
+	path := filepath.Join(dir, "log.json")
+	config := `{"level": "warning", "format": "json", "file": "` + name + `",
+		"rotation": {"max_size": 1048576, "max_age": "24h"}, "components": "storage=debug"}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer SetComponentLevels("")
+	if err := InitFromConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	Info("hidden")
+	Warning("disk almost full")
+	WithComponent(Storage).Debug("compacting")
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	if strings.Contains(got, "hidden") || !strings.Contains(got, `"msg":"disk almost full"`) || !strings.Contains(got, `"msg":"compacting"`) {
+		t.Errorf("log file = %q", got)
+	}
+	if cfg, ok := rotationConfig(); !ok || cfg.MaxSize != 1048576 || cfg.MaxAge != 24*time.Hour {
+		t.Errorf("rotation = %+v, %v", cfg, ok)
+	}
+}
+
+func TestInitFromConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"level.json":   `{"level": "loud"}`,
+		"unknown.json": `{"level": "info", "colour": true}`,
+		"age.json":     `{"rotation": {"max_age": "a day"}}`,
+		"cron.json":    `{"rotation": {"schedule": "0 25 * * *"}}`,
+		"unknown.yaml": "level: info\ncolour: true\n",
+		"level.toml":   "level = \"loud\"\n",
+		"unknown.toml": "level = \"info\"\n[rotation]\nmax_ages = \"24h\"\n",
+		"syntax.toml":  "level: info\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if err := InitFromConfig(path); err == nil {
+			t.Errorf("InitFromConfig accepted %s", name)
+		}
+	}
+}
+
+func TestInitFromConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"log.yaml": "level: warning\nformat: json\nrotation:\n  max_age: 24h\n  max_backups: 3\ncomponents: storage=debug\n",
+		"log.yml":  "level: warning\nformat: json\nrotation: {max_age: 24h, max_backups: 3}\ncomponents: storage=debug\n",
+		"log.toml": "level = \"warning\"\nformat = \"json\"\ncomponents = \"storage=debug\"\n\n[rotation]\nmax_age = \"24h\"\nmax_backups = 3\n",
+	} {
+		c, err := readConfigFile(writeConfig(t, filepath.Join(dir, name), content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if c.Level != "warning" || c.Format != "json" || c.Components != "storage=debug" || c.Rotation == nil || c.Rotation.MaxAge != "24h" || c.Rotation.MaxBackups != 3 {
+			t.Errorf("%s = %+v, rotation %+v", name, c, c.Rotation)
+		}
+	}
+}
+
+func TestInitFromEnv(t *testing.T) {
+	resetInit(t)
+	name := filepath.Join(t.TempDir(), "probe.log")
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("LOG_FORMAT", "json")
+	t.Setenv("LOG_FILE", name)
+	if err := InitFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if c := CurrentConfig(); c.Level != "error" || c.Format != JSONFormat || c.File != name {
+		t.Errorf("config = %+v", c)
+	}
+
+	t.Setenv("LOG_FORMAT", "xml")
+	if err := InitFromEnv(); err == nil {
+		t.Error("InitFromEnv accepted an invalid format")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	resetInit(t)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+	path := filepath.Join(t.TempDir(), "log.json")
+	os.WriteFile(path, []byte(`{"level": "info"}`), 0644)
+	if err := InitFromConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	SetOutputs(io.Discard)
+
+	stop, err := WatchConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	// Replace the file by a rename, as editors and deployment tools do.
+	os.WriteFile(path+".tmp", []byte(`{"level": "debug", "format": "json"}`), 0644)
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for CurrentConfig().Level != "debug" {
+		if time.Now().After(deadline) {
+			t.Fatalf("config not reloaded: %+v", CurrentConfig())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f := CurrentConfig().Format; f != JSONFormat {
+		t.Errorf("format = %q after the reload, want json", f)
+	}
+
+	os.WriteFile(path, []byte(`{"level": "loud"}`), 0644)
+	time.Sleep(100 * time.Millisecond)
+	if l := CurrentConfig().Level; l != "debug" {
+		t.Errorf("invalid config applied, level = %s", l)
+	}
+}
+
+func TestReloadConfigToStderr(t *testing.T) {
+	resetInit(t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "probe.log")
+	path := writeConfig(t, filepath.Join(dir, "log.json"), `{"level": "info", "file": "`+name+`"}`)
+	if err := InitFromConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if f := CurrentConfig().File; f != name {
+		t.Fatalf("file = %q, want %q", f, name)
+	}
+
+	writeConfig(t, path, `{"level": "info"}`)
+	if err := reloadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if f := CurrentConfig().File; f != "" {
+		t.Errorf("file = %q after removing it from the config, want stderr", f)
+	}
+	Info("after the reload")
+	if b, _ := os.ReadFile(name); strings.Contains(string(b), "after the reload") {
+		t.Errorf("log file %q still written after the reload", b)
+	}
+}
+
+// writeConfig writes content to path and returns path.
+func writeConfig(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,158 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Widths above which the caller and message columns of ConsoleFormatter
+// stop growing, so that a long message does not push every later line.
+const (
+	consoleCallerWidth  = 32
+	consoleMessageWidth = 48
+)
+
+// ConsoleFormatter formats entries for a developer reading a terminal,
+//
+//	1.204s WARNING capture.go:88   link flap           iface=eth1 retries=3
+//
+// with the time since the start of the process, zero in the deterministic
+// test mode, the severity label, the caller and the message in columns
+// aligned on the widest seen so far, followed by the fields. Fields holding
+// maps, slices or structs are printed as indented JSON on the lines below.
+// The lines are not meant to be parsed back.
+type ConsoleFormatter struct {
+	// Color prints the severity label in the color of its level, see
+	// SetLevelColors, the field keys dimmed and the error field in red.
+	Color bool
+
+	callerWidth, msgWidth int32
+}
+
+func (c *ConsoleFormatter) Format(entry *log.Entry) ([]byte, error) {
+	var b strings.Builder
+	var since time.Duration
+	if !deterministic() {
+		since = entry.Time.Sub(processStart)
+	}
+	fmt.Fprintf(&b, "%9.3fs ", since.Seconds())
+
+	label := levelLabel(entry.Level)
+	pad := strings.Repeat(" ", labelWidth()-len(label))
+	if c.Color {
+		label = colorLabel(entry.Level, label)
+	}
+	b.WriteString(label)
+	b.WriteString(pad)
+	b.WriteString(" ")
+
+	caller := packageFormatter().caller(entryCaller(entry.Data))
+	if t, ok := entry.Data[TagKey].(string); ok && t != "" {
+		if caller != "" {
+			caller += " "
+		}
+		caller += string(appendTag(nil, t))
+	}
+	if caller != "" {
+		b.WriteString(caller)
+		b.WriteString(padding(&c.callerWidth, len(caller), consoleCallerWidth))
+		b.WriteString(" ")
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		if !isReserved(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	b.WriteString(entry.Message)
+	if len(keys) > 0 {
+		b.WriteString(padding(&c.msgWidth, len(entry.Message), consoleMessageWidth))
+	}
+	var nested []string
+	for _, k := range keys {
+		value := entry.Data[k]
+		if s, ok := value.(string); ok && k == LayersKey {
+			value = parseLayers(s)
+		}
+		if compound(value) {
+			if js, err := json.MarshalIndent(value, "\t  ", "  "); err == nil {
+				nested = append(nested, c.key(k)+": "+string(js))
+				continue
+			}
+		}
+		b.WriteString(" ")
+		b.WriteString(c.key(k))
+		b.WriteString("=")
+		v := string(appendValue(nil, value))
+		if c.Color && k == "error" {
+			v = "\x1b[31m" + v + "\x1b[0m"
+		}
+		b.WriteString(v)
+	}
+	b.WriteString("\n")
+	for _, n := range nested {
+		b.WriteString("\t")
+		b.WriteString(n)
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// key returns the key k as printed.
+func (c *ConsoleFormatter) key(k string) string {
+	if c.Color {
+		return "\x1b[90m" + k + "\x1b[0m"
+	}
+	return k
+}
+
+// padding returns the spaces aligning a column of n characters on the
+// widest one so far, width, up to max.
+func padding(width *int32, n, max int) string {
+	w := int(atomic.LoadInt32(width))
+	if n > w && n <= max {
+		atomic.StoreInt32(width, int32(n))
+		w = n
+	}
+	if n >= w {
+		return ""
+	}
+	return strings.Repeat(" ", w-n)
+}
+
+// labelWidth returns the length of the longest severity label.
+func labelWidth() int {
+	labels.RLock()
+	defer labels.RUnlock()
+	w := 0
+	for _, l := range labels.printed {
+		if len(l) > w {
+			w = len(l)
+		}
+	}
+	return w
+}
+
+// compound reports whether v is a map, a slice or a struct other than
+// those with a text form, such as times and errors.
+func compound(v interface{}) bool {
+	switch v.(type) {
+	case error, fmt.Stringer, []byte:
+		return false
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func consoleEntry(msg string, fields log.Fields) *log.Entry {
+	entry := log.NewEntry(log.StandardLogger()).WithFields(fields)
+	entry.Time = processStart.Add(1204 * time.Millisecond)
+	entry.Level = log.WarnLevel
+	entry.Message = msg
+	return entry
+}
+
+func TestConsoleFormatter(t *testing.T) {
+	c := &ConsoleFormatter{}
+	var lines []string
+	for _, e := range []*log.Entry{
+		consoleEntry("link flap", log.Fields{"iface": "eth1", "retries": 3}),
+		consoleEntry("up", log.Fields{"iface": "eth0"}),
+		consoleEntry("no fields", nil),
+	} {
+		b, err := c.Format(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(b))
+	}
+	want := []string{
+		"    1.204s WARNING link flap iface=eth1 retries=3\n",
+		"    1.204s WARNING up        iface=eth0\n",
+		"    1.204s WARNING no fields\n",
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestConsoleFormatterNested(t *testing.T) {
+	c := &ConsoleFormatter{Color: true}
+	b, _ := c.Format(consoleEntry("request", log.Fields{
+		"headers": map[string]string{"Accept": "*/*"},
+		"error":   "timeout",
+	}))
+	want := "\x1b[33mWARNING\x1b[0m request \x1b[90merror\x1b[0m=\x1b[31mtimeout\x1b[0m\n" +
+		"\t\x1b[90mheaders\x1b[0m: {\n\t    \"Accept\": \"*/*\"\n\t  }\n"
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("entry = %q, want suffix %q", b, want)
+	}
+}
+
+func TestSetFormatConsole(t *testing.T) {
+	defer SetFormat(TextFormat)
+	if err := SetFormat(ConsoleFormat); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := log.StandardLogger().Formatter.(*ConsoleFormatter); !ok {
+		t.Errorf("formatter = %T, want the console formatter", log.StandardLogger().Formatter)
+	}
+	if _, err := ExportParsingRules(GrokRules); err == nil {
+		t.Error("ExportParsingRules for the console format = nil error")
+	}
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// InitContainer configures the package logger for container runtimes that
+// collect stdout: entries are written as JSON lines to stdout, no file is
+// opened, and a broken pipe, e.g. while the runtime's log driver restarts,
+// drops entries instead of killing the process with SIGPIPE. Like Init, only
+// the first call has an effect.
+func InitContainer(logLevel string) {
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	init := func() {
+		tag = os.Args[0]
+		format = JSONFormat
+		log.SetFormatter(activeFormatter())
+		if err := SetLevel(logLevel); err != nil {
+			Fatal(err.Error())
+		}
+
+		// Once SIGPIPE is subscribed to, writes to a closed stdout fail
+		// with EPIPE rather than terminate the process.
+		signal.Notify(make(chan os.Signal, 1), syscall.SIGPIPE)
+		setOutput(&lineWriter{w: os.Stdout})
+	}
+
+	initOnce("InitContainer", "", logLevel, init)
+}
+
+// lineWriter passes only complete lines to w, each in a single Write, so
+// entries are never split or interleaved by the reader of the pipe.
+type lineWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// Name returns the name of the underlying output.
+func (l *lineWriter) Name() string {
+	return outputName(l.w)
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	i := bytes.LastIndexByte(l.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+
+	_, err := l.w.Write(l.buf[:i+1])
+	// Lines that could not be written are dropped, retrying them would
+	// only grow the buffer while the reader is gone.
+	l.buf = append(l.buf[:0], l.buf[i+1:]...)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing incomplete line, if any.
+func (l *lineWriter) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buf) == 0 {
+		return nil
+	}
+	_, err := l.w.Write(l.buf)
+	l.buf = l.buf[:0]
+	return err
+}
@@ -0,0 +1,35 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var out writeRecorder
+	l := &lineWriter{w: &out}
+
+	l.Write([]byte("first "))
+	l.Write([]byte("line\nsecond"))
+	l.Write([]byte(" line\n"))
+
+	want := []string{"first line\n", "second line\n"}
+	if len(out.writes) != len(want) {
+		t.Fatalf("got writes %q, want %q", out.writes, want)
+	}
+	for i := range want {
+		if out.writes[i] != want[i] {
+			t.Errorf("write %d = %q, want %q", i, out.writes[i], want[i])
+		}
+	}
+}
+
+type writeRecorder struct {
+	bytes.Buffer
+	writes []string
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return w.Buffer.Write(p)
+}
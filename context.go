@@ -0,0 +1,73 @@
+package log
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// contextKey is the key of the fields stored in a context by NewContext.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying fields, in addition to those
+// of the contexts it derives from, for every entry logged with it through
+// InfoCtx and the like or FromContext, e.g. in an HTTP middleware:
+//
+//	ctx := log.NewContext(r.Context(), log.Fields{"request_id": id})
+//	next.ServeHTTP(w, r.WithContext(ctx))
+//
+// Fields of the innermost context win over those of outer ones with the
+// same key.
+func NewContext(ctx context.Context, fields Fields) context.Context {
+	outer := ContextFields(ctx)
+	merged := make(Fields, len(outer)+len(fields))
+	for k, v := range outer {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+// ContextFields returns the fields stored in ctx by NewContext, nil if
+// there are none. The map must not be modified.
+func ContextFields(ctx context.Context) Fields {
+	fields, _ := ctx.Value(contextKey{}).(Fields)
+	return fields
+}
+
+// FromContext returns a Logger adding the fields stored in ctx, and the
+// trace active in it, see AddTraceExtractor, to every entry.
+func FromContext(ctx context.Context) *Logger {
+	return WithFields(traceFields(ctx, ContextFields(ctx)))
+}
+
+// DebugCtx logs a message with severity DEBUG and the fields of ctx.
+func DebugCtx(ctx context.Context, v ...interface{}) {
+	if enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprint(v...), ctxData(ctx))
+	}
+}
+
+// ErrorCtx logs a message with severity ERROR and the fields of ctx.
+func ErrorCtx(ctx context.Context, v ...interface{}) {
+	if enabled(log.ErrorLevel) {
+		output(log.ErrorLevel, fmt.Sprint(v...), ctxData(ctx))
+	}
+}
+
+// InfoCtx logs a message with severity INFO and the fields of ctx.
+func InfoCtx(ctx context.Context, v ...interface{}) {
+	if enabled(log.InfoLevel) {
+		output(log.InfoLevel, fmt.Sprint(v...), ctxData(ctx))
+	}
+}
+
+// WarningCtx logs a message with severity WARNING and the fields of ctx.
+func WarningCtx(ctx context.Context, v ...interface{}) {
+	if enabled(log.WarnLevel) {
+		output(log.WarnLevel, fmt.Sprint(v...), ctxData(ctx))
+	}
+}
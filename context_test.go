@@ -0,0 +1,45 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	ctx := NewContext(context.Background(), Fields{"request_id": "r1", "user": "alice"})
+	inner := NewContext(ctx, Fields{"user": "bob", "trace_id": "t9"})
+
+	InfoCtx(ctx, "outer")
+	InfoCtx(inner, "inner")
+	if !strings.Contains(buf.String(), "outer request_id=r1 user=alice\n") {
+		t.Errorf("output %q lacks the fields of the context", buf.String())
+	}
+	if !strings.Contains(buf.String(), "inner request_id=r1 trace_id=t9 user=bob\n") {
+		t.Errorf("output %q lacks the merged fields of the inner context", buf.String())
+	}
+	if ContextFields(ctx)["user"] != "alice" {
+		t.Error("NewContext modified the fields of the outer context")
+	}
+	if !strings.Contains(buf.String(), "context_test.go:") {
+		t.Errorf("output %q does not report the caller of InfoCtx", buf.String())
+	}
+
+	buf.Reset()
+	FromContext(inner).With("status", 200).Warning("done")
+	if !strings.Contains(buf.String(), "done request_id=r1 status=200 trace_id=t9 user=bob\n") {
+		t.Errorf("output = %q", buf.String())
+	}
+
+	buf.Reset()
+	ErrorCtx(context.Background(), "no fields")
+	if !strings.HasSuffix(buf.String(), "] no fields\n") {
+		t.Errorf("output = %q, want no fields", buf.String())
+	}
+}
@@ -0,0 +1,129 @@
+package log
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// gauge is a value reported by SetCounterInterval.
+type gauge struct {
+	counter func() uint64
+	value   func() float64
+	// last is the value of a counter at the previous report.
+	last uint64
+}
+
+// counterReport holds the registered counters and gauges.
+type counterReport struct {
+	mu       sync.Mutex
+	interval time.Duration
+	gauges   map[string]*gauge
+	stop     chan struct{}
+}
+
+var counters = &counterReport{gauges: make(map[string]*gauge)}
+
+// RegisterCounter reports the monotonic counter fn, e.g. the packets seen
+// or dropped by a capture driver, under name at every interval set with
+// SetCounterInterval: the entry holds its value as name and its increase
+// since the previous report as name_delta. Registering a name again
+// replaces the callback. The returned function unregisters it.
+func RegisterCounter(name string, fn func() uint64) (unregister func()) {
+	return counters.register(name, &gauge{counter: fn, last: fn()})
+}
+
+// RegisterGauge reports the current value of fn, e.g. the fill level of a
+// ring buffer, under name at every interval set with SetCounterInterval.
+func RegisterGauge(name string, fn func() float64) (unregister func()) {
+	return counters.register(name, &gauge{value: fn})
+}
+
+func (c *counterReport) register(name string, g *gauge) func() {
+	c.mu.Lock()
+	c.gauges[name] = g
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		if c.gauges[name] == g {
+			delete(c.gauges, name)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// SetCounterInterval logs, every interval, an INFO entry "counters" with
+// the registered counters and gauges as fields, plus the interval. The
+// callbacks run on the reporting goroutine and must be safe to call from
+// it. A non-positive interval disables the report, which is the default.
+func SetCounterInterval(interval time.Duration) {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	if counters.stop != nil {
+		close(counters.stop)
+		counters.stop = nil
+	}
+	counters.interval = interval
+	if interval <= 0 {
+		return
+	}
+	counters.stop = make(chan struct{})
+	go counters.run(interval, counters.stop)
+}
+
+func (c *counterReport) run(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.report(interval)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// report logs the current values, if anything is registered.
+func (c *counterReport) report(interval time.Duration) {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.gauges))
+	for name := range c.gauges {
+		names = append(names, name)
+	}
+	gauges := make([]*gauge, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		gauges[i] = c.gauges[name]
+	}
+	c.mu.Unlock()
+
+	if len(names) == 0 {
+		return
+	}
+
+	fields := log.Fields{"interval": interval.String()}
+	for i, g := range gauges {
+		if g.counter == nil {
+			fields[names[i]] = g.value()
+			continue
+		}
+		v := g.counter()
+		c.mu.Lock()
+		delta := v - g.last
+		if v < g.last {
+			// The driver was restarted and counts from zero again.
+			delta = v
+		}
+		g.last = v
+		c.mu.Unlock()
+		fields[names[i]] = v
+		fields[names[i]+"_delta"] = delta
+	}
+	emit(log.InfoLevel, captureCaller(log.InfoLevel, 0), "counters", fields)
+}
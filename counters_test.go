@@ -0,0 +1,58 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCounterReport(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	var packets uint64 = 100
+	unregister := RegisterCounter("packets", func() uint64 { return atomic.LoadUint64(&packets) })
+	defer unregister()
+	defer RegisterGauge("ring_fill", func() float64 { return 0.25 })()
+
+	atomic.StoreUint64(&packets, 160)
+	counters.report(10 * time.Second)
+	out := buf.String()
+	if want := "counters interval=10s packets=160 packets_delta=60 ring_fill=0.25"; !strings.Contains(out, want) {
+		t.Errorf("output %q does not contain %q", out, want)
+	}
+
+	// A restarted driver counts from zero.
+	atomic.StoreUint64(&packets, 5)
+	buf.Reset()
+	counters.report(10 * time.Second)
+	if !strings.Contains(buf.String(), "packets=5 packets_delta=5") {
+		t.Errorf("output %q does not treat the reset as a restart", buf.String())
+	}
+
+	unregister()
+	buf.Reset()
+	counters.report(10 * time.Second)
+	if strings.Contains(buf.String(), "packets") {
+		t.Errorf("output %q holds the unregistered counter", buf.String())
+	}
+}
+
+func TestSetCounterInterval(t *testing.T) {
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	defer RegisterGauge("queue", func() float64 { return 3 })()
+	SetCounterInterval(10 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	SetCounterInterval(0)
+
+	if got := buf.String(); !strings.Contains(got, "counters interval=10ms queue=3") {
+		t.Errorf("output %q does not hold the periodic report", got)
+	}
+}
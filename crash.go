@@ -0,0 +1,68 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxCrashReport is the size of the part of a crash report logged by
+// SetCrashFile; the rest of the goroutine dump stays in the file.
+const maxCrashReport = 32 << 10
+
+var crashMu sync.Mutex
+
+// SetCrashFile makes the Go runtime write the report of a crash, i.e. an
+// unrecovered panic or a fatal runtime error such as a concurrent map
+// write, to the file name as well as to stderr, which is often lost in
+// containers, see runtime/debug.SetCrashOutput. If the file holds the
+// report of a previous run, its beginning is first logged as an ERROR
+// entry "previous run crashed" with the report in the crash field, so
+// that it reaches the outputs and forwarders of the package logger, and
+// the file is emptied. Call SetCrashFile right after Init. An empty name
+// stops writing crash reports.
+func SetCrashFile(name string) error {
+	crashMu.Lock()
+	defer crashMu.Unlock()
+
+	if name == "" {
+		return debug.SetCrashOutput(nil, debug.CrashOptions{})
+	}
+
+	if err := createLogDir(name); err != nil {
+		return err
+	}
+	f, err := createLogFile(name, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	// The runtime writes to a duplicate of the descriptor.
+	defer f.Close()
+
+	report := make([]byte, maxCrashReport)
+	n, err := io.ReadFull(f, report)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := debug.SetCrashOutput(f, debug.CrashOptions{}); err != nil {
+		return fmt.Errorf("set crash output: %v", err)
+	}
+
+	if n > 0 {
+		emit(log.ErrorLevel, captureCaller(log.ErrorLevel, 0), "previous run crashed", log.Fields{
+			"crash":      string(report[:n]),
+			"crash_file": name,
+		})
+	}
+	return nil
+}
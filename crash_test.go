@@ -0,0 +1,56 @@
+package log
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetCrashFile(t *testing.T) {
+	if name := os.Getenv("LOG_TEST_CRASH_FILE"); name != "" {
+		if err := SetCrashFile(name); err != nil {
+			t.Fatal(err)
+		}
+		panic("capture ring corrupted")
+	}
+
+	name := filepath.Join(t.TempDir(), "crash", "probe.crash")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSetCrashFile$")
+	cmd.Env = append(os.Environ(), "LOG_TEST_CRASH_FILE="+name)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("crashing process exited successfully")
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "panic: capture ring corrupted") {
+		t.Fatalf("crash file = %q, want the panic", b)
+	}
+
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	if err := SetCrashFile(name); err != nil {
+		t.Fatal(err)
+	}
+	defer SetCrashFile("")
+	out := buf.String()
+	if !strings.Contains(out, "previous run crashed") || !strings.Contains(out, "panic: capture ring corrupted") {
+		t.Errorf("output = %q, want the previous crash logged", out)
+	}
+	if fi, err := os.Stat(name); err != nil || fi.Size() != 0 {
+		t.Errorf("crash file not emptied: %v, %v", fi, err)
+	}
+
+	buf = syncBuffer{}
+	SetOutputs(&buf)
+	if err := SetCrashFile(name); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "previous run crashed") {
+		t.Errorf("empty crash file reported: %q", buf.String())
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Command cshared builds package log as a C shared library, so that capture
+plugins written in C or Python log through the same pipeline, formats and
+outputs as the Go code of the process:
+
+	go build -buildmode=c-shared -o libgolog.so ./cshared
+
+The build also writes libgolog.h, declaring:
+
+	int GoLogInit(char* file, char* level);
+	int GoLogSetLevel(char* level);
+	int GoLogEmit(char* level, char* msg, char* fields);
+	int GoLogEmitJSON(char* entry);
+	int GoLogFlush(void);
+	int GoLogClose(void);
+
+GoLogInit is Init for the process; the other functions may be called
+before and from any thread. GoLogEmit logs msg at level, a name as
+accepted by SetLevel, with fields, a JSON object, or NULL for none.
+GoLogEmitJSON logs an entry in the format of JSONFormatter, keeping its
+time, file and line. The strings are copied and remain owned by the
+caller. Every function returns 0, or -1 if it failed, the reason being
+logged.
+
+From Python:
+
+	lib = ctypes.CDLL("./libgolog.so")
+	lib.GoLogInit(b"/var/log/capture.log", b"info")
+	lib.GoLogEmit(b"warning", b"ring full", b'{"iface": "eth0"}')
+
+A process holds one copy of the library and its pipeline, shared by all
+the plugins that load it. A Go program hosting plugins does not load it:
+it would hold a second pipeline besides its own package log.
+*/
+package main
+
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+
+	golog "github.com/net-sniper/go-log"
+)
+
+func main() {}
+
+//export GoLogInit
+func GoLogInit(file, level *C.char) C.int {
+	golog.Init(C.GoString(file), C.GoString(level))
+	return 0
+}
+
+//export GoLogSetLevel
+func GoLogSetLevel(level *C.char) C.int {
+	return result(golog.SetLevel(C.GoString(level)))
+}
+
+//export GoLogEmit
+func GoLogEmit(level, msg, fields *C.char) C.int {
+	var f string
+	if fields != nil {
+		f = C.GoString(fields)
+	}
+	return result(emit(C.GoString(level), C.GoString(msg), f))
+}
+
+//export GoLogEmitJSON
+func GoLogEmitJSON(entry *C.char) C.int {
+	return result(emitJSON(C.GoString(entry)))
+}
+
+//export GoLogFlush
+func GoLogFlush() C.int {
+	return result(golog.Flush())
+}
+
+//export GoLogClose
+func GoLogClose() C.int {
+	return result(golog.Close())
+}
+
+// emit logs msg at level with the fields of the JSON object fields, if not
+// empty.
+func emit(level, msg, fields string) error {
+	e := golog.Entry{Level: level, Message: msg}
+	if fields != "" {
+		if err := json.Unmarshal([]byte(fields), &e.Fields); err != nil {
+			return fmt.Errorf("fields of %q: %v", msg, err)
+		}
+	}
+	return golog.Ingest(e)
+}
+
+// emitJSON logs the entry encoded in entry.
+func emitJSON(entry string) error {
+	var e golog.Entry
+	if err := json.Unmarshal([]byte(entry), &e); err != nil {
+		return fmt.Errorf("entry %q: %v", entry, err)
+	}
+	return golog.Ingest(e)
+}
+
+// result returns the status of a call failing with err, logging the
+// failure, as C callers get no error.
+func result(err error) C.int {
+	if err != nil {
+		golog.Error("cshared: " + err.Error())
+		return -1
+	}
+	return 0
+}
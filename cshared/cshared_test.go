@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	golog "github.com/net-sniper/go-log"
+	"github.com/net-sniper/go-log/logtest"
+)
+
+func TestEmit(t *testing.T) {
+	golog.SetLevel("debug")
+	rec := logtest.Capture(t)
+
+	if err := emit("warning", "ring full", `{"iface": "eth0"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := emit("info", "started", ""); err != nil {
+		t.Fatal(err)
+	}
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v, want 2", entries)
+	}
+	if e := entries[0]; e.Level != "warning" || e.Message != "ring full" || e.Fields["iface"] != "eth0" {
+		t.Errorf("entry = %+v", e)
+	}
+
+	for _, c := range []struct{ level, fields string }{
+		{"loud", ""},
+		{"info", "[1]"},
+	} {
+		if err := emit(c.level, "x", c.fields); err == nil {
+			t.Errorf("emit(%q, %q) = nil, want error", c.level, c.fields)
+		}
+	}
+}
+
+func TestEmitJSON(t *testing.T) {
+	golog.SetLevel("debug")
+	rec := logtest.Capture(t)
+
+	if err := emitJSON(`{"level":"error","msg":"decode failed","file":"plugin.c","line":42,"flow":7}`); err != nil {
+		t.Fatal(err)
+	}
+	entries := rec.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %v, want 1", entries)
+	}
+	if e := entries[0]; e.Level != "error" || e.Message != "decode failed" || e.Fields["flow"] != float64(7) {
+		t.Errorf("entry = %+v", e)
+	}
+	if err := emitJSON(`{"level":`); err == nil {
+		t.Error("emitJSON of a truncated entry = nil, want error")
+	}
+}
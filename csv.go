@@ -0,0 +1,84 @@
+package log
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultCSVColumns are the columns written by a CSVFormatter without
+// Columns.
+var DefaultCSVColumns = []string{"time", "level", "tag", "caller", "msg"}
+
+// CSVFormatter formats each entry as a CSV record, for loading logs into
+// spreadsheets or DuckDB. Columns selects the values written: the built-in
+// columns time, hostname, level, tag, caller and msg, or the name of a
+// field, which is empty for entries without it. Set Comma to '\t' for TSV.
+type CSVFormatter struct {
+	// Columns are the columns of each record, DefaultCSVColumns if empty.
+	Columns []string
+	// Comma is the field delimiter, ',' if zero.
+	Comma rune
+}
+
+func (c *CSVFormatter) Format(entry *log.Entry) ([]byte, error) {
+	columns := c.columns()
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		switch col {
+		case "time":
+			record[i] = entry.Time.Format(time.RFC3339Nano)
+		case "hostname":
+			record[i] = entryHost(entry.Data)
+		case "level":
+			record[i] = severityName(entry.Level)
+		case "tag":
+			record[i] = tag
+			if t, ok := entry.Data[TagKey].(string); ok {
+				record[i] = t
+			}
+		case "caller":
+			record[i] = packageFormatter().caller(entryCaller(entry.Data))
+		case "msg":
+			record[i] = entry.Message
+		default:
+			if v, ok := entry.Data[col]; ok && !isReserved(col) {
+				if s, ok := v.(string); ok && col == LayersKey {
+					v = parseLayers(s)
+				}
+				record[i] = fmt.Sprint(v)
+			}
+		}
+	}
+	return c.encode(record)
+}
+
+// Header returns the header record naming the columns.
+func (c *CSVFormatter) Header() []byte {
+	b, _ := c.encode(c.columns())
+	return b
+}
+
+func (c *CSVFormatter) columns() []string {
+	if len(c.Columns) == 0 {
+		return DefaultCSVColumns
+	}
+	return c.Columns
+}
+
+func (c *CSVFormatter) encode(record []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if c.Comma != 0 {
+		w.Comma = c.Comma
+	}
+	w.Write(record)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("encode entry as CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
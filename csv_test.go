@@ -0,0 +1,48 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestCSVFormatter(t *testing.T) {
+	entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
+		"user_id": 42,
+		TagKey:    "auth",
+	})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = log.WarnLevel
+	entry.Message = `login failed, "bad password"`
+
+	f := &CSVFormatter{Columns: []string{"time", "level", "tag", "msg", "user_id", "missing"}}
+	b, err := f.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `2017-03-01T12:00:00Z,warning,auth,"login failed, ""bad password""",42,` + "\n"
+	if string(b) != want {
+		t.Errorf("Format() = %q, want %q", b, want)
+	}
+	if h := string(f.Header()); h != "time,level,tag,msg,user_id,missing\n" {
+		t.Errorf("Header() = %q", h)
+	}
+}
+
+func TestCSVFormatterTSV(t *testing.T) {
+	entry := log.NewEntry(log.StandardLogger())
+	entry.Level = log.InfoLevel
+	entry.Message = "started"
+
+	b, err := (&CSVFormatter{Columns: []string{"level", "msg"}, Comma: '\t'}).Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "info\tstarted\n" {
+		t.Errorf("Format() = %q, want %q", b, "info\tstarted\n")
+	}
+	if h := string((&CSVFormatter{}).Header()); h != "time,level,tag,caller,msg\n" {
+		t.Errorf("default Header() = %q", h)
+	}
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Field keys of the SLA metadata of an entry.
+const (
+	// DeadlineKey is the field holding the time by which the operation an
+	// entry reports on was due, see WithDeadline.
+	DeadlineKey = "deadline"
+	// OwnerKey is the field naming the team or service accountable for
+	// the operation, see WithOwner.
+	OwnerKey = "owner"
+	// OverdueKey is the field holding how late an entry was logged past
+	// its deadline.
+	OverdueKey = "overdue"
+)
+
+// WithDeadline returns a Logger for an operation due by t. Entries logged
+// after t carry the overdue field with their lateness, and can be
+// escalated with PastDeadline:
+//
+//	l := log.WithOwner("capture").WithDeadline(start.Add(50 * time.Millisecond))
+//	l.Info("ring flushed")
+func WithDeadline(t time.Time) *Logger {
+	return &Logger{typed: []Field{Time(DeadlineKey, t)}}
+}
+
+// WithDeadline returns a child of l for an operation due by t.
+func (l *Logger) WithDeadline(t time.Time) *Logger {
+	return &Logger{parent: l, typed: []Field{Time(DeadlineKey, t)}}
+}
+
+// WithOwner returns a Logger attributing the operations it reports on to
+// owner.
+func WithOwner(owner string) *Logger {
+	return &Logger{typed: []Field{String(OwnerKey, owner)}}
+}
+
+// WithOwner returns a child of l attributing its operations to owner.
+func (l *Logger) WithOwner(owner string) *Logger {
+	return &Logger{parent: l, typed: []Field{String(OwnerKey, owner)}}
+}
+
+// PastDeadline matches entries logged after their deadline, for use with
+// AddUpgradeRule, e.g. to make late operations visible at the warning
+// level:
+//
+//	log.AddUpgradeRule("warn", log.PastDeadline())
+func PastDeadline() Matcher {
+	return func(_ string, fields Fields) bool {
+		_, ok := fields[OverdueKey]
+		return ok
+	}
+}
+
+// markOverdue adds the overdue field to entries logged after their
+// deadline, measured at the time of the entry.
+func markOverdue(fields log.Fields) {
+	deadline, ok := fields[DeadlineKey].(time.Time)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if t, ok := fields[timeKey].(time.Time); ok {
+		now = t
+	}
+	if late := now.Sub(deadline); late > 0 {
+		fields[OverdueKey] = late
+	}
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadline(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	WithOwner("capture").WithDeadline(due).Info("on time")
+	out := buf.String()
+	if !strings.Contains(out, "on time deadline=2030-01-02T02:04:05Z owner=capture\n") {
+		t.Errorf("output = %q, want the deadline in UTC and the owner", out)
+	}
+
+	buf.Reset()
+	WithDeadline(time.Now().Add(-time.Second)).Info("late")
+	if !strings.Contains(buf.String(), "overdue=1") {
+		t.Errorf("output = %q, want the overdue field", buf.String())
+	}
+}
+
+func TestPastDeadline(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	if err := AddUpgradeRule("warn", PastDeadline()); err != nil {
+		t.Fatal(err)
+	}
+	defer ClearUpgradeRules()
+
+	WithDeadline(time.Now().Add(time.Hour)).Info("early")
+	WithDeadline(time.Now().Add(-time.Millisecond)).Info("late")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("output = %q, want 2 lines", buf.String())
+	}
+	if !strings.Contains(lines[0], ": INFO\t") {
+		t.Errorf("entry before its deadline escalated: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], ": WARNING\t") || !strings.Contains(lines[1], "upgraded_from=info") {
+		t.Errorf("entry after its deadline not escalated: %q", lines[1])
+	}
+}
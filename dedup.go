@@ -0,0 +1,206 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SuppressedKey is the field counting the duplicates of an entry dropped
+// since the previous one was written, see SetDedup.
+const SuppressedKey = "suppressed"
+
+// dedupRule lets the first entries of a key through, then one per every.
+type dedupRule struct {
+	first int
+	every time.Duration
+}
+
+// dedupKey is the state of the entries logged from one place at a level.
+type dedupKey struct {
+	level      log.Level
+	where, msg string
+	passed     int
+	last       time.Time
+	suppressed uint64
+}
+
+// deduper drops repetitions of the same entry.
+type deduper struct {
+	// active is non-zero while a rule is set, sparing entries the lock
+	// otherwise.
+	active int32
+	mu     sync.Mutex
+	rules  map[log.Level]dedupRule
+	keys   map[string]*dedupKey
+	stop   chan struct{}
+	now    func() time.Time
+}
+
+var dedup = &deduper{now: time.Now}
+
+// SetDedup limits the repetitions of the same entry at every level except
+// fatal and panic: the first first entries logged from one place in the
+// code, or with one message if the caller is not recorded, are written,
+// then one per every, carrying the suppressed field with the number of
+// duplicates dropped since the previous one. Once a place stops logging,
+// a WARNING entry "suppressed duplicates" reports the duplicates dropped
+// last, and after another quiet interval the place gets its first entries
+// back. Dropped entries are counted in Stats. A non-positive every
+// disables deduplication, which is the default.
+func SetDedup(first int, every time.Duration) {
+	dedup.mu.Lock()
+	defer dedup.mu.Unlock()
+
+	dedup.rules = make(map[log.Level]dedupRule)
+	if every > 0 {
+		for _, lvl := range allLevels {
+			if lvl > log.FatalLevel {
+				dedup.rules[lvl] = dedupRule{first, every}
+			}
+		}
+	}
+	dedup.restart()
+}
+
+// SetLevelDedup is SetDedup for the entries at level only, overriding the
+// setting of SetDedup for that level.
+func SetLevelDedup(level string, first int, every time.Duration) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	dedup.mu.Lock()
+	defer dedup.mu.Unlock()
+
+	if dedup.rules == nil {
+		dedup.rules = make(map[log.Level]dedupRule)
+	}
+	if every > 0 && lvl > log.FatalLevel {
+		dedup.rules[severityOf(lvl)] = dedupRule{first, every}
+	} else {
+		delete(dedup.rules, severityOf(lvl))
+	}
+	dedup.restart()
+	return nil
+}
+
+// restart resets the keys and runs the reports at the shortest interval.
+// The caller must hold d.mu.
+func (d *deduper) restart() {
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+	d.keys = nil
+	atomic.StoreInt32(&d.active, 0)
+	var interval time.Duration
+	for _, r := range d.rules {
+		if interval == 0 || r.every < interval {
+			interval = r.every
+		}
+	}
+	if interval <= 0 {
+		return
+	}
+	d.keys = make(map[string]*dedupKey)
+	atomic.StoreInt32(&d.active, 1)
+	d.stop = make(chan struct{})
+	go d.run(interval, d.stop)
+}
+
+// allow reports whether an entry is to be written, adding the suppressed
+// field to an entry written after duplicates were dropped.
+func (d *deduper) allow(level log.Level, site callSite, msg string, fields log.Fields) (log.Fields, bool) {
+	if atomic.LoadInt32(&d.active) == 0 {
+		return fields, true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rule, ok := d.rules[severityOf(level)]
+	if !ok || d.keys == nil {
+		return fields, true
+	}
+	where := fingerprint(site, msg)
+	key := level.String() + " " + where
+	k, ok := d.keys[key]
+	if !ok {
+		if len(d.keys) >= maxFingerprints {
+			return fields, true
+		}
+		k = &dedupKey{level: level, where: where}
+		d.keys[key] = k
+	}
+	now := d.now()
+	k.msg = msg
+	if k.passed < rule.first || now.Sub(k.last) >= rule.every {
+		k.passed++
+		k.last = now
+		if k.suppressed > 0 {
+			if fields == nil {
+				fields = make(log.Fields, 1)
+			}
+			fields[SuppressedKey] = k.suppressed
+			k.suppressed = 0
+		}
+		return fields, true
+	}
+	k.suppressed++
+	atomic.AddUint64(&stats.Deduplicated, 1)
+	return fields, false
+}
+
+func (d *deduper) run(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.report()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// report logs the duplicates of the places that stopped logging and
+// forgets the places that have been quiet since.
+func (d *deduper) report() {
+	type report struct {
+		level      log.Level
+		where, msg string
+		suppressed uint64
+	}
+	var reports []report
+
+	d.mu.Lock()
+	now := d.now()
+	for key, k := range d.keys {
+		if now.Sub(k.last) < d.rules[k.level].every {
+			continue
+		}
+		if k.suppressed == 0 {
+			delete(d.keys, key)
+			continue
+		}
+		reports = append(reports, report{k.level, k.where, k.msg, k.suppressed})
+		k.suppressed = 0
+		k.last = now
+	}
+	d.mu.Unlock()
+
+	for _, r := range reports {
+		// Like the rate limit report, the summary must get through.
+		countWritten(log.WarnLevel)
+		dispatch(log.WarnLevel, captureCaller(log.WarnLevel, 0), "suppressed duplicates", log.Fields{
+			SuppressedKey: r.suppressed,
+			"level":       severityName(r.level),
+			"where":       r.where,
+			"last_msg":    r.msg,
+		})
+	}
+}
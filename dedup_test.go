@@ -0,0 +1,83 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	dedup.now = func() time.Time { return now }
+	defer func() { dedup.now = time.Now }()
+	SetDedup(2, time.Hour)
+	defer SetDedup(0, 0)
+	before := Stats().Deduplicated
+
+	storm := func(n int) {
+		for i := 0; i < n; i++ {
+			Errorf("connection refused by %d", i)
+		}
+	}
+	storm(5)
+	Info("other place")
+	if n := strings.Count(buf.String(), "connection refused"); n != 2 {
+		t.Errorf("%d entries written, want the first 2:\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "other place") {
+		t.Error("entry from another place was dropped")
+	}
+	if got := Stats().Deduplicated - before; got != 3 {
+		t.Errorf("Deduplicated increased by %d, want 3", got)
+	}
+
+	now = now.Add(time.Hour)
+	buf.Reset()
+	storm(2)
+	if got := buf.String(); strings.Count(got, "connection refused") != 1 || !strings.Contains(got, "suppressed=3") {
+		t.Errorf("entry after the interval = %q, want one carrying the count", got)
+	}
+
+	now = now.Add(time.Hour)
+	buf.Reset()
+	dedup.report()
+	if got := buf.String(); !strings.Contains(got, "suppressed duplicates") || !strings.Contains(got, `last_msg="connection refused by 1" level=error suppressed=1 where=dedup_test.go:`) {
+		t.Errorf("report = %q", got)
+	}
+	now = now.Add(time.Hour)
+	dedup.report()
+	buf.Reset()
+	storm(2)
+	if n := strings.Count(buf.String(), "connection refused"); n != 2 {
+		t.Errorf("%d entries written after a quiet interval, want the first 2 again", n)
+	}
+}
+
+func TestLevelDedup(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	if err := SetLevelDedup("warning", 1, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer SetDedup(0, 0)
+
+	for i := 0; i < 3; i++ {
+		Warning("disk almost full")
+		Error("disk full")
+	}
+	if n := strings.Count(buf.String(), "disk almost full"); n != 1 {
+		t.Errorf("%d warnings written, want 1", n)
+	}
+	if n := strings.Count(buf.String(), "disk full"); n != 3 {
+		t.Errorf("%d errors written, want all 3", n)
+	}
+	if err := SetLevelDedup("loud", 1, time.Hour); err == nil {
+		t.Error("SetLevelDedup accepted an invalid level")
+	}
+}
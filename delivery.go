@@ -0,0 +1,48 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DeliveryReport is the outcome of delivering one batch of entries to a
+// remote output.
+type DeliveryReport struct {
+	// Output names the remote output, see OutputStats.Name.
+	Output string
+	// Delivered and Failed count the entries of the batch that were
+	// acknowledged by the remote end and that were given up on.
+	Delivered int
+	Failed    int
+	// Err is the error that caused entries to fail, if any.
+	Err error
+}
+
+var delivery = struct {
+	sync.RWMutex
+	callback func(DeliveryReport)
+}{}
+
+// SetDeliveryCallback registers fn to be called after every batch a remote
+// output attempts to deliver, so applications can alert when log delivery
+// fails even though local writes succeed. fn runs on the output's delivery
+// goroutine and must return quickly. Passing nil removes the callback.
+func SetDeliveryCallback(fn func(DeliveryReport)) {
+	delivery.Lock()
+	delivery.callback = fn
+	delivery.Unlock()
+}
+
+// reportDelivery is called by remote outputs once a batch has been
+// acknowledged or given up on.
+func reportDelivery(r DeliveryReport) {
+	atomic.AddUint64(&stats.Delivered, uint64(r.Delivered))
+	atomic.AddUint64(&stats.DeliveryFailed, uint64(r.Failed))
+
+	delivery.RLock()
+	fn := delivery.callback
+	delivery.RUnlock()
+	if fn != nil {
+		fn(r)
+	}
+}
@@ -0,0 +1,24 @@
+package log
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeliveryCallback(t *testing.T) {
+	var got []DeliveryReport
+	SetDeliveryCallback(func(r DeliveryReport) { got = append(got, r) })
+	defer SetDeliveryCallback(nil)
+
+	before := Stats()
+	reportDelivery(DeliveryReport{Output: "collector", Delivered: 9, Failed: 1, Err: errors.New("broker unavailable")})
+
+	if len(got) != 1 || got[0].Failed != 1 || got[0].Err == nil {
+		t.Fatalf("callback got %+v, want one report with a failure", got)
+	}
+	after := Stats()
+	if after.Delivered-before.Delivered != 9 || after.DeliveryFailed-before.DeliveryFailed != 1 {
+		t.Errorf("Stats delivery counters moved by %d/%d, want 9/1",
+			after.Delivered-before.Delivered, after.DeliveryFailed-before.DeliveryFailed)
+	}
+}
@@ -0,0 +1,168 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Diff logs the fields that differ between old and new, two versions of
+// the configuration or state name, with severity INFO as "<name> changed",
+// the way Reconfigure logs its own changes: the changed field lists their
+// keys and each one is recorded as <key>_old and <key>_new.
+//
+//	log.Diff("config", oldCfg, cfg)
+//
+// Structs, maps and pointers to them are compared field by field, their
+// keys being dotted paths such as capture.snaplen, from the JSON names of
+// the exported fields. Other values, including slices and types with a
+// String or MarshalJSON method such as time.Time, are compared as a whole,
+// under name if old and new are such values. Nothing is logged if they
+// are equal. Diff returns the changes.
+func Diff(name string, old, new interface{}) []ConfigChange {
+	changes := diffChanges(name, old, new)
+	if len(changes) > 0 && enabled(log.InfoLevel) {
+		output(log.InfoLevel, name+" changed", changeFields(changes))
+	}
+	return changes
+}
+
+// Diff is the Diff function logging with l.
+func (l *Logger) Diff(name string, old, new interface{}) []ConfigChange {
+	changes := diffChanges(name, old, new)
+	if len(changes) > 0 && l.enabled(log.InfoLevel) {
+		fields := l.data()
+		for k, v := range changeFields(changes) {
+			fields[k] = v
+		}
+		output(log.InfoLevel, name+" changed", fields)
+	}
+	return changes
+}
+
+// diffChanges returns the changes between old and new, sorted by key.
+func diffChanges(name string, old, new interface{}) []ConfigChange {
+	var changes []ConfigChange
+	diffValues("", reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+	for i := range changes {
+		if changes[i].Key == "" {
+			changes[i].Key = name
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// changeFields returns the fields of an entry logging changes.
+func changeFields(changes []ConfigChange) log.Fields {
+	keys := make([]string, len(changes))
+	fields := make(log.Fields, 2*len(changes)+1)
+	for i, ch := range changes {
+		keys[i] = ch.Key
+		fields[ch.Key+"_old"] = ch.Old
+		fields[ch.Key+"_new"] = ch.New
+	}
+	fields["changed"] = strings.Join(keys, ",")
+	return fields
+}
+
+var (
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	marshalType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// diffValues appends the differences between o and n under the key path
+// to changes.
+func diffValues(path string, o, n reflect.Value, changes *[]ConfigChange) {
+	o, n = unwrap(o), unwrap(n)
+	if o.IsValid() && n.IsValid() && o.Type() == n.Type() && comparedByField(o.Type()) {
+		diffFields(path, o, n, changes)
+		return
+	}
+	ov, nv := interfaceOf(o), interfaceOf(n)
+	if !reflect.DeepEqual(ov, nv) {
+		*changes = append(*changes, ConfigChange{Key: path, Old: ov, New: nv})
+	}
+}
+
+// diffFields compares the fields of the structs or the entries of the maps
+// o and n.
+func diffFields(path string, o, n reflect.Value, changes *[]ConfigChange) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	if o.Kind() == reflect.Map {
+		keys := make(map[string]reflect.Value)
+		for _, k := range append(o.MapKeys(), n.MapKeys()...) {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		for s, k := range keys {
+			diffValues(join(s), o.MapIndex(k), n.MapIndex(k), changes)
+		}
+		return
+	}
+	t := o.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if key, ok := fieldKey(t.Field(i)); ok {
+			diffValues(join(key), o.Field(i), n.Field(i), changes)
+		}
+	}
+}
+
+// comparedByField reports whether values of t are compared field by field.
+func comparedByField(t reflect.Type) bool {
+	if t.Implements(stringerType) || t.Implements(marshalType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if _, ok := fieldKey(t.Field(i)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldKey returns the key of a struct field in a diff, its JSON name.
+func fieldKey(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+	return name, true
+}
+
+// unwrap returns the value held by an interface v or pointed at by a
+// pointer v, if not nil.
+func unwrap(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// interfaceOf returns the value held by v, nil for a missing value or a
+// nil pointer.
+func interfaceOf(v reflect.Value) interface{} {
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	return v.Interface()
+}
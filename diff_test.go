@@ -0,0 +1,68 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type captureConfig struct {
+	Iface   string        `json:"iface"`
+	Snaplen int           `json:"snaplen"`
+	Filters []string      `json:"filters"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+	Started time.Time     `json:"started"`
+	Labels  map[string]string
+	Secret  string `json:"-"`
+	buffers int
+}
+
+type sniperConfig struct {
+	Level   string         `json:"level"`
+	Capture *captureConfig `json:"capture"`
+}
+
+func TestDiff(t *testing.T) {
+	var out bytes.Buffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	old := sniperConfig{Level: "info", Capture: &captureConfig{
+		Iface: "eth0", Snaplen: 1500, Filters: []string{"tcp"}, Started: start,
+		Labels: map[string]string{"site": "fra", "rack": "r1"}, Secret: "a", buffers: 4,
+	}}
+	cfg := sniperConfig{Level: "info", Capture: &captureConfig{
+		Iface: "eth0", Snaplen: 9000, Filters: []string{"tcp", "udp"}, Started: start.Add(time.Second),
+		Labels: map[string]string{"site": "fra", "zone": "b"}, Secret: "b", buffers: 8,
+	}}
+
+	changes := Diff("config", old, &cfg)
+	var keys []string
+	for _, ch := range changes {
+		keys = append(keys, ch.Key)
+	}
+	want := "capture.Labels.rack,capture.Labels.zone,capture.filters,capture.snaplen,capture.started"
+	if got := strings.Join(keys, ","); got != want {
+		t.Errorf("changed keys = %s, want %s", got, want)
+	}
+	line := out.String()
+	for _, s := range []string{"config changed", "changed=" + want, "capture.snaplen_old=1500", "capture.snaplen_new=9000", "capture.Labels.rack_old=r1", "capture.Labels.rack_new=<nil>"} {
+		if !strings.Contains(line, s) {
+			t.Errorf("entry %q lacks %s", line, s)
+		}
+	}
+
+	out.Reset()
+	if changes := Diff("config", old, old); len(changes) != 0 || out.Len() != 0 {
+		t.Errorf("Diff of equal values = %v, wrote %q", changes, out.String())
+	}
+	if changes := Diff("mtu", 1500, 9000); len(changes) != 1 || changes[0].Key != "mtu" {
+		t.Errorf("Diff of numbers = %v", changes)
+	}
+	if changes := Diff("config", sniperConfig{Level: "info"}, cfg); len(changes) != 1 || changes[0].Key != "capture" || changes[0].Old != nil {
+		t.Errorf("Diff from a nil pointer = %+v", changes)
+	}
+}
@@ -0,0 +1,102 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxDNSAnswers is the number of resource records summarized in dns_answer.
+const maxDNSAnswers = 8
+
+// DNSFields describes a wire-format DNS message: dns_id, dns_response,
+// dns_rcode, dns_qname and dns_qtype of the first question, the number of
+// answers in dns_answers and a summary of them such as
+// "CNAME edge.example.net., A 192.0.2.7" in dns_answer.
+func DNSFields(msg []byte) (Fields, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := Fields{
+		"dns_id":       h.ID,
+		"dns_response": h.Response,
+		"dns_rcode":    strings.TrimPrefix(h.RCode.String(), "RCode"),
+	}
+
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return fields, err
+	}
+	if len(questions) > 0 {
+		fields["dns_qname"] = questions[0].Name.String()
+		fields["dns_qtype"] = strings.TrimPrefix(questions[0].Type.String(), "Type")
+	}
+
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return fields, err
+	}
+	fields["dns_answers"] = len(answers)
+	if len(answers) > 0 {
+		fields["dns_answer"] = summarizeAnswers(answers)
+	}
+	return fields, nil
+}
+
+// DumpDNS logs a wire-format DNS message with severity DEBUG, see DNSFields.
+// Messages that cannot be parsed are logged with what could be decoded and
+// a dns_error field.
+func DumpDNS(msg []byte) {
+	if !enabled(log.DebugLevel) {
+		return
+	}
+
+	fields, err := DNSFields(msg)
+	if fields == nil {
+		fields = Fields{}
+	}
+	if err != nil {
+		fields["dns_error"] = err.Error()
+	}
+	output(log.DebugLevel, "dns message", log.Fields(fields))
+}
+
+func summarizeAnswers(answers []dnsmessage.Resource) string {
+	parts := make([]string, 0, len(answers))
+	for i, rr := range answers {
+		if i == maxDNSAnswers {
+			parts = append(parts, fmt.Sprintf("(%d more)", len(answers)-i))
+			break
+		}
+		parts = append(parts, strings.TrimPrefix(rr.Header.Type.String(), "Type")+" "+resourceValue(rr.Body))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func resourceValue(body dnsmessage.ResourceBody) string {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(b.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(b.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return b.CNAME.String()
+	case *dnsmessage.NSResource:
+		return b.NS.String()
+	case *dnsmessage.PTRResource:
+		return b.PTR.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", b.Pref, b.MX)
+	case *dnsmessage.TXTResource:
+		return fmt.Sprintf("%q", strings.Join(b.TXT, ""))
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", b.Priority, b.Weight, b.Port, b.Target)
+	}
+	return "..."
+}
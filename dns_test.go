@@ -0,0 +1,52 @@
+package log
+
+import (
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSFields(t *testing.T) {
+	name := dnsmessage.MustNewName("www.example.net.")
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 7, Response: true, RCode: dnsmessage.RCodeSuccess},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+		Answers: []dnsmessage.Resource{
+			{
+				Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("edge.example.net.")},
+			},
+			{
+				Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 7}},
+			},
+		},
+	}
+	wire, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := DNSFields(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Fields{
+		"dns_id":       uint16(7),
+		"dns_response": true,
+		"dns_rcode":    "Success",
+		"dns_qname":    "www.example.net.",
+		"dns_qtype":    "A",
+		"dns_answers":  2,
+		"dns_answer":   "CNAME edge.example.net., A 192.0.2.7",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, fields[k], v)
+		}
+	}
+
+	if _, err := DNSFields(wire[:5]); err == nil {
+		t.Error("DNSFields accepted a truncated message")
+	}
+}
@@ -0,0 +1,119 @@
+package log
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// EmbargoKey is the field holding the delay of entries logged through
+// Embargo.
+const EmbargoKey = "embargo"
+
+// Embargoed logs entries only once a delay has passed, unless they are
+// canceled first. It is obtained from Embargo.
+type Embargoed struct {
+	l *Logger
+	d time.Duration
+}
+
+// Pending is an entry waiting for its embargo to end.
+type Pending struct {
+	timer    *time.Timer
+	canceled int32
+}
+
+// Embargo returns an Embargoed holding entries back for d, for warnings
+// that only matter if a condition persists:
+//
+//	slow := log.Embargo(5 * time.Second).Warning("sync taking too long")
+//	err := sync()
+//	slow.Cancel()
+//
+// The entry is written after d with the fields it was logged with and the
+// embargo field, unless Cancel is called before.
+func Embargo(d time.Duration) *Embargoed {
+	return &Embargoed{d: d}
+}
+
+// Embargo returns an Embargoed holding back entries carrying the fields of
+// l.
+func (l *Logger) Embargo(d time.Duration) *Embargoed {
+	return &Embargoed{l: l, d: d}
+}
+
+// Cancel prevents the entry from being written. It reports whether the
+// entry was still pending.
+func (p *Pending) Cancel() bool {
+	if !atomic.CompareAndSwapInt32(&p.canceled, 0, 1) {
+		return false
+	}
+	// The timer may have fired already, but its function found the entry
+	// canceled.
+	p.timer.Stop()
+	return true
+}
+
+// hold schedules the entry. The caller is captured now, so the entry
+// points at the code that logged it rather than at the timer.
+func (e *Embargoed) hold(level log.Level, msg string) *Pending {
+	site := captureCaller(level, 2)
+	var fields log.Fields
+	if e.l != nil {
+		fields = e.l.data()
+	} else {
+		fields = make(log.Fields, 1)
+	}
+	fields[EmbargoKey] = e.d.String()
+
+	p := &Pending{}
+	p.timer = time.AfterFunc(e.d, func() {
+		if !atomic.CompareAndSwapInt32(&p.canceled, 0, 1) {
+			return
+		}
+		emit(level, site, msg, fields)
+	})
+	return p
+}
+
+// Debug holds back a message with severity DEBUG.
+func (e *Embargoed) Debug(v ...interface{}) *Pending {
+	return e.hold(log.DebugLevel, fmt.Sprint(v...))
+}
+
+// Error holds back a message with severity ERROR.
+func (e *Embargoed) Error(v ...interface{}) *Pending {
+	return e.hold(log.ErrorLevel, fmt.Sprint(v...))
+}
+
+// Info holds back a message with severity INFO.
+func (e *Embargoed) Info(v ...interface{}) *Pending {
+	return e.hold(log.InfoLevel, fmt.Sprint(v...))
+}
+
+// Warning holds back a message with severity WARNING.
+func (e *Embargoed) Warning(v ...interface{}) *Pending {
+	return e.hold(log.WarnLevel, fmt.Sprint(v...))
+}
+
+// Debugf holds back a formatted message with severity DEBUG.
+func (e *Embargoed) Debugf(format string, v ...interface{}) *Pending {
+	return e.hold(log.DebugLevel, fmt.Sprintf(format, v...))
+}
+
+// Errorf holds back a formatted message with severity ERROR.
+func (e *Embargoed) Errorf(format string, v ...interface{}) *Pending {
+	return e.hold(log.ErrorLevel, fmt.Sprintf(format, v...))
+}
+
+// Infof holds back a formatted message with severity INFO.
+func (e *Embargoed) Infof(format string, v ...interface{}) *Pending {
+	return e.hold(log.InfoLevel, fmt.Sprintf(format, v...))
+}
+
+// Warningf holds back a formatted message with severity WARNING.
+func (e *Embargoed) Warningf(format string, v ...interface{}) *Pending {
+	return e.hold(log.WarnLevel, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbargo(t *testing.T) {
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	canceled := Embargo(50 * time.Millisecond).Warning("finished in time")
+	With("op", "sync").Embargo(20*time.Millisecond).Warningf("%s taking too long", "sync")
+	if !canceled.Cancel() {
+		t.Error("Cancel() = false for a pending entry")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(buf.String(), "too long") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	got := buf.String()
+	for _, want := range []string{": WARNING\t", "embargo_test.go:16", "sync taking too long", "embargo=20ms", "op=sync"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "in time") {
+		t.Errorf("canceled entry was written: %q", got)
+	}
+	if canceled.Cancel() {
+		t.Error("second Cancel() = true")
+	}
+}
+
+func TestCancelAfterTimerFired(t *testing.T) {
+	// The timer fired but its function has yet to run: Cancel still wins.
+	fired := make(chan struct{})
+	p := &Pending{}
+	p.timer = time.AfterFunc(0, func() { close(fired) })
+	<-fired
+	if !p.Cancel() {
+		t.Error("Cancel() = false although the entry was never written")
+	}
+}
@@ -0,0 +1,124 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// errorFileSink copies severe entries to a file of their own, see
+// SetErrorFile.
+type errorFileSink struct {
+	mu    sync.Mutex
+	level log.Level
+	f     *rotatingFile
+	// registered is set while the sink is flushed along with the outputs.
+	registered bool
+}
+
+var errorFile = &errorFileSink{}
+
+func init() {
+	addHook(errorFileHook{})
+}
+
+// SetErrorFile also writes the entries at level, warning if empty, or more
+// severe to the file name, e.g. app.err.log next to the main log, so that
+// on-call engineers find the problems in a small file rather than by
+// grepping the whole log. The file is rotated with cfg, independently of
+// the main log, and accepts the placeholders of Init. It is opened on the
+// first such entry and written in the format of the outputs. An empty name
+// closes the file and stops the copy.
+func SetErrorFile(name, level string, cfg RotationConfig) error {
+	lvl := log.WarnLevel
+	if level != "" {
+		var err error
+		if lvl, err = parseLevel(level); err != nil {
+			return err
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	var f *rotatingFile
+	if name != "" {
+		f = newRotatingFile(name, cfg)
+	}
+
+	errorFile.mu.Lock()
+	old := errorFile.f
+	errorFile.f, errorFile.level = f, lvl
+	register := f != nil && !errorFile.registered
+	if register {
+		errorFile.registered = true
+	}
+	errorFile.mu.Unlock()
+	if register {
+		addFlusher(errorFile)
+	}
+	if old != nil {
+		if err := old.Close(); err != nil {
+			reportError(fmt.Errorf("close %s: %v", old.Name(), err))
+		}
+	}
+	return nil
+}
+
+// Name returns the path of the error file.
+func (s *errorFileSink) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return "error file"
+	}
+	return s.f.Name()
+}
+
+// Flush syncs the error file.
+func (s *errorFileSink) Flush() error {
+	s.mu.Lock()
+	f := s.f
+	s.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	return f.Flush()
+}
+
+// Close closes the error file and stops the copy, as part of Close.
+func (s *errorFileSink) Close() error {
+	s.mu.Lock()
+	f := s.f
+	s.f, s.registered = nil, false
+	s.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	return f.Close()
+}
+
+// errorFileHook writes severe entries to the error file. It is always
+// registered and checks the settings on every entry.
+type errorFileHook struct{}
+
+func (errorFileHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (errorFileHook) Fire(entry *log.Entry) error {
+	errorFile.mu.Lock()
+	f, level := errorFile.f, errorFile.level
+	errorFile.mu.Unlock()
+	if f == nil || entry.Level > level {
+		return nil
+	}
+	b, err := entry.Logger.Formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		reportError(fmt.Errorf("write error file %s: %v", f.Name(), err))
+	}
+	return nil
+}
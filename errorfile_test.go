@@ -0,0 +1,82 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrorFile(t *testing.T) {
+	var out bytes.Buffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	name := filepath.Join(t.TempDir(), "sniper.err.log")
+	if err := SetErrorFile(name, "", RotationConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetErrorFile("", "", RotationConfig{})
+
+	Info("capture started")
+	Warning("ring buffer 90% full")
+	Error("dropped 12 packets")
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); strings.Contains(got, "capture started") || !strings.Contains(got, "90% full") || !strings.Contains(got, "dropped 12") {
+		t.Errorf("error file = %q, want the WARNING and ERROR entries only", got)
+	}
+	if strings.Count(out.String(), "\n") != 3 {
+		t.Errorf("output = %q, want every entry", out.String())
+	}
+
+	if err := SetErrorFile(name, "error", RotationConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(name)
+	Warning("ring buffer 95% full")
+	Error("dropped 20 packets")
+	if b, _ := os.ReadFile(name); strings.Contains(string(b), "95%") || !strings.Contains(string(b), "dropped 20") {
+		t.Errorf("error file at error = %q", b)
+	}
+
+	if err := SetErrorFile(name, "loud", RotationConfig{}); err == nil {
+		t.Error(`SetErrorFile(name, "loud") = nil, want an error`)
+	}
+	SetErrorFile("", "", RotationConfig{})
+	os.Remove(name)
+	Error("dropped 30 packets")
+	if _, err := os.Stat(name); err == nil {
+		t.Error("error file written after SetErrorFile with no name")
+	}
+}
+
+func TestErrorFileRotation(t *testing.T) {
+	SetOutputs(&bytes.Buffer{})
+	defer SetOutputs(os.Stderr)
+	name := filepath.Join(t.TempDir(), "sniper.err.log")
+	if err := SetErrorFile(name, "error", RotationConfig{MaxSize: 200, MaxBackups: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetErrorFile("", "", RotationConfig{})
+
+	for i := 0; i < 10; i++ {
+		Errorf("dropped %d packets", i)
+	}
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := filepath.Glob(name + ".*")
+	if len(matches) != 1 {
+		t.Errorf("backups = %q, want 1", matches)
+	}
+	if fi, err := os.Stat(name); err != nil || fi.Size() > 200 {
+		t.Errorf("error file = %v, %v, want at most 200 bytes", fi, err)
+	}
+}
@@ -0,0 +1,148 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxFingerprints bounds the number of distinct errors tracked per summary
+// interval; further fingerprints are only counted in the total.
+const maxFingerprints = 10000
+
+// errorCount is the number of ERROR entries logged from one place.
+type errorCount struct {
+	where string
+	msg   string
+	count uint64
+}
+
+// errorSummary counts ERROR entries per fingerprint between summaries.
+type errorSummary struct {
+	mu       sync.Mutex
+	interval time.Duration
+	top      int
+	total    uint64
+	counts   map[string]*errorCount
+	stop     chan struct{}
+}
+
+var summary = &errorSummary{}
+
+// SetErrorSummary logs, every interval, a WARNING entry summarizing the
+// ERROR entries of the interval: their total and the top most frequent
+// fingerprints with their count and last message. The fingerprint is the
+// file and line an error is logged from. Errors are counted before the rate
+// limit, so the summary also covers errors that were dropped. A
+// non-positive interval disables the summary.
+func SetErrorSummary(interval time.Duration, top int) {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+
+	if summary.stop != nil {
+		close(summary.stop)
+		summary.stop = nil
+	}
+	summary.interval = interval
+	summary.top = top
+	summary.total = 0
+	summary.counts = nil
+	if interval <= 0 {
+		return
+	}
+	summary.counts = make(map[string]*errorCount)
+	summary.stop = make(chan struct{})
+	go summary.run(interval, summary.stop)
+}
+
+// add counts an entry if it is an ERROR.
+func (s *errorSummary) add(level log.Level, site callSite, msg string) {
+	if level != log.ErrorLevel {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counts == nil {
+		return
+	}
+	s.total++
+	key := msg
+	if site.file != "" {
+		key = filepath.Base(site.file) + ":" + strconv.Itoa(site.line)
+	}
+	c, ok := s.counts[key]
+	if !ok {
+		if len(s.counts) >= maxFingerprints {
+			return
+		}
+		c = &errorCount{where: key}
+		s.counts[key] = c
+	}
+	c.count++
+	c.msg = msg
+}
+
+func (s *errorSummary) run(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.report(stop)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// report logs the summary of the interval and starts a new one, unless
+// the summary run by stop was disabled or replaced in the meantime.
+func (s *errorSummary) report(stop chan struct{}) {
+	s.mu.Lock()
+	if s.stop != stop || s.counts == nil {
+		s.mu.Unlock()
+		return
+	}
+	total, counts, interval, top := s.total, s.counts, s.interval, s.top
+	s.total = 0
+	s.counts = make(map[string]*errorCount)
+	s.mu.Unlock()
+
+	if total == 0 {
+		return
+	}
+
+	sorted := make([]*errorCount, 0, len(counts))
+	for _, c := range counts {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].where < sorted[j].where
+	})
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+
+	fields := log.Fields{
+		"errors":       total,
+		"fingerprints": len(counts),
+		"interval":     interval.String(),
+	}
+	for i, c := range sorted {
+		fields[fmt.Sprintf("top_%d", i+1)] = fmt.Sprintf("%d %s %s", c.count, c.where, c.msg)
+	}
+	// Like the rate limit report, the summary must get through.
+	countWritten(log.WarnLevel)
+	dispatch(log.WarnLevel, captureCaller(log.WarnLevel, 0), "error summary", fields)
+}
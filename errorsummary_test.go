@@ -0,0 +1,76 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorSummary(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetErrorSummary(time.Hour, 1)
+	defer SetErrorSummary(0, 0)
+
+	for i := 0; i < 5; i++ {
+		Errorf("connection refused by %d", i)
+	}
+	Error("disk full")
+	Warning("not counted")
+
+	buf.Reset()
+	summary.report(summary.stop)
+	got := buf.String()
+	for _, want := range []string{
+		"error summary",
+		"errors=6",
+		"fingerprints=2",
+		`top_1="5 errorsummary_test.go:19 connection refused by 4"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary %q does not contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "top_2") {
+		t.Errorf("summary %q lists more than the top 1", got)
+	}
+
+	buf.Reset()
+	summary.report(summary.stop)
+	if buf.Len() != 0 {
+		t.Errorf("empty interval logged %q", buf.String())
+	}
+}
+
+func TestErrorSummaryStaleReport(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetErrorSummary(time.Hour, 1)
+	defer SetErrorSummary(0, 0)
+
+	// The ticker of the first summary fires after it was replaced.
+	stale := summary.stop
+	SetErrorSummary(0, 0)
+	SetErrorSummary(time.Hour, 1)
+	Error("disk full")
+	summary.report(stale)
+	if strings.Contains(buf.String(), "error summary") {
+		t.Errorf("stale report logged %q", buf.String())
+	}
+
+	buf.Reset()
+	summary.report(summary.stop)
+	if !strings.Contains(buf.String(), "errors=1") {
+		t.Errorf("summary %q lost the error of the new interval", buf.String())
+	}
+
+	SetErrorSummary(0, 0)
+	summary.report(nil)
+	if summary.counts != nil {
+		t.Error("report re-enabled a disabled summary")
+	}
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// eventWriter reports events to the Windows Event Log, implemented by
+// *eventlog.Log of golang.org/x/sys.
+type eventWriter interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+	Close() error
+}
+
+// EventLogSink reports entries to the Windows Event Log under an event
+// source, see AddEventLogSink. It is a logrus hook. Entries are written
+// as events of type error for ERROR and more severe, warning for WARNING
+// and information otherwise, with the event ID 1 plus their syslog
+// severity, e.g. 4 for ERROR, so that they can be filtered by level in
+// Event Viewer. The text of an event is the message and the fields.
+type EventLogSink struct {
+	source string
+	level  log.Level
+
+	mu sync.Mutex
+	w  eventWriter
+}
+
+// AddEventLogSink reports every entry of the package logger at level,
+// info if empty, or more severe to the Windows Event Log under source,
+// typically the name of the service. The source must have been registered
+// with InstallEventSource, which needs administrator rights and is
+// usually done by the installer; Event Viewer shows the events of an
+// unregistered source with a warning that their description is missing.
+// It fails on other platforms.
+func AddEventLogSink(source, level string) (*EventLogSink, error) {
+	lvl := log.InfoLevel
+	if level != "" {
+		var err error
+		if lvl, err = parseLevel(level); err != nil {
+			return nil, err
+		}
+	}
+	w, err := openEventLog(source)
+	if err != nil {
+		return nil, fmt.Errorf("open event log %s: %v", source, err)
+	}
+	s := newEventLogSink(source, lvl, w)
+	addHook(s)
+	addFlusher(s)
+	return s, nil
+}
+
+func newEventLogSink(source string, level log.Level, w eventWriter) *EventLogSink {
+	return &EventLogSink{source: source, level: level, w: w}
+}
+
+// Name returns the event source of the sink.
+func (s *EventLogSink) Name() string {
+	return "eventlog:" + s.source
+}
+
+// Levels implements logrus.Hook.
+func (s *EventLogSink) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (s *EventLogSink) Fire(entry *log.Entry) error {
+	if entry.Level > s.level {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return nil
+	}
+
+	eid := uint32(1 + syslogSeverity[severityOf(entry.Level)])
+	msg := entry.Message + formatFields(entry.Data)
+	var err error
+	switch {
+	case entry.Level <= log.ErrorLevel:
+		err = s.w.Error(eid, msg)
+	case entry.Level == log.WarnLevel:
+		err = s.w.Warning(eid, msg)
+	default:
+		err = s.w.Info(eid, msg)
+	}
+	if err != nil {
+		reportError(fmt.Errorf("report event to %s: %v", s.source, err))
+	}
+	return nil
+}
+
+// Flush does nothing, events are written synchronously. The sink is
+// closed along with the outputs by Close.
+func (s *EventLogSink) Flush() error {
+	return nil
+}
+
+// Close closes the event log handle and stops reporting entries.
+func (s *EventLogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return nil
+	}
+	err := s.w.Close()
+	s.w = nil
+	return err
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package log
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errNoEventLog = errors.New("the event log is not supported on " + runtime.GOOS)
+
+func openEventLog(source string) (eventWriter, error) {
+	return nil, errNoEventLog
+}
+
+// InstallEventSource is not supported on this platform, which has no
+// Windows Event Log.
+func InstallEventSource(source string) error {
+	return errNoEventLog
+}
+
+// RemoveEventSource is not supported on this platform.
+func RemoveEventSource(source string) error {
+	return errNoEventLog
+}
@@ -0,0 +1,76 @@
+package log
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+type fakeEventLog struct {
+	events []string
+	closed bool
+}
+
+func (f *fakeEventLog) report(typ string, eid uint32, msg string) error {
+	f.events = append(f.events, fmt.Sprintf("%s %d %s", typ, eid, msg))
+	return nil
+}
+
+func (f *fakeEventLog) Info(eid uint32, msg string) error    { return f.report("info", eid, msg) }
+func (f *fakeEventLog) Warning(eid uint32, msg string) error { return f.report("warning", eid, msg) }
+func (f *fakeEventLog) Error(eid uint32, msg string) error   { return f.report("error", eid, msg) }
+
+func (f *fakeEventLog) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestEventLogSink(t *testing.T) {
+	w := &fakeEventLog{}
+	s := newEventLogSink("probe", log.InfoLevel, w)
+	for _, e := range []struct {
+		level log.Level
+		msg   string
+	}{
+		{log.DebugLevel, "noise"},
+		{log.InfoLevel, "started"},
+		{log.WarnLevel, "ring full"},
+		{log.ErrorLevel, "decode failed"},
+		{log.FatalLevel, "out of memory"},
+	} {
+		s.Fire(&log.Entry{Level: e.level, Message: e.msg, Time: time.Now(), Data: log.Fields{"iface": "eth0"}})
+	}
+	want := []string{
+		"info 7 started iface=eth0",
+		"warning 5 ring full iface=eth0",
+		"error 4 decode failed iface=eth0",
+		"error 3 out of memory iface=eth0",
+	}
+	if strings.Join(w.events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events =\n%s\nwant\n%s", strings.Join(w.events, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err := s.Close(); err != nil || !w.closed {
+		t.Errorf("Close = %v, closed %v", err, w.closed)
+	}
+	s.Fire(&log.Entry{Level: log.ErrorLevel, Message: "after close", Data: log.Fields{}})
+	if len(w.events) != len(want) {
+		t.Errorf("event reported after Close: %q", w.events[len(w.events)-1])
+	}
+}
+
+func TestAddEventLogSinkUnsupported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the event log is supported")
+	}
+	if _, err := AddEventLogSink("probe", ""); err == nil {
+		t.Error("AddEventLogSink = nil error, want unsupported")
+	}
+	if _, err := AddEventLogSink("probe", "loud"); err == nil {
+		t.Error("AddEventLogSink with an invalid level = nil error")
+	}
+}
@@ -0,0 +1,23 @@
+package log
+
+import (
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+func openEventLog(source string) (eventWriter, error) {
+	return eventlog.Open(source)
+}
+
+// InstallEventSource registers source with the Windows Event Log, for
+// AddEventLogSink, with the message file of EventCreate so that Event
+// Viewer displays the text of the events as is. It needs administrator
+// rights and fails if source is registered already.
+func InstallEventSource(source string) error {
+	return eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info)
+}
+
+// RemoveEventSource unregisters source, e.g. when the service is
+// uninstalled.
+func RemoveEventSource(source string) error {
+	return eventlog.Remove(source)
+}
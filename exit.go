@@ -0,0 +1,80 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var exiting = struct {
+	sync.Mutex
+	exit     func(code int)
+	handlers []func()
+	panic    bool
+}{exit: os.Exit}
+
+// SetExitFunc makes Fatal call fn instead of os.Exit, e.g. to record the
+// exit code in a test or to run deferred cleanup through a panic caught in
+// main. If fn returns, so does Fatal. nil restores os.Exit.
+func SetExitFunc(fn func(code int)) {
+	if fn == nil {
+		fn = os.Exit
+	}
+	exiting.Lock()
+	exiting.exit = fn
+	exiting.Unlock()
+}
+
+// RegisterExitHandler adds fn to the functions Fatal runs before the
+// outputs are closed and the process exits, e.g. to close database
+// connections. Handlers run in the order they are registered; a panicking
+// handler is reported to the self-log and does not stop the others.
+func RegisterExitHandler(fn func()) {
+	exiting.Lock()
+	exiting.handlers = append(exiting.handlers, fn)
+	exiting.Unlock()
+}
+
+// SetFatalPanic makes Fatal panic with its message instead of exiting, for
+// tests exercising a code path that ends in Fatal. The outputs are flushed,
+// the exit handlers are not run.
+func SetFatalPanic(on bool) {
+	exiting.Lock()
+	exiting.panic = on
+	exiting.Unlock()
+}
+
+// fatalExit ends the process after a Fatal entry with message msg has been
+// written.
+func fatalExit(msg string) {
+	exiting.Lock()
+	exit, handlers, panics := exiting.exit, exiting.handlers, exiting.panic
+	exiting.Unlock()
+
+	dumpRecentOn("fatal")
+	timeout := flushTimeout()
+	if panics {
+		flushOutputs(timeout)
+		panic(msg)
+	}
+	for _, fn := range handlers {
+		runExitHandler(fn)
+	}
+	// A non-positive timeout skips closing, Fatal must not hang on a
+	// wedged output.
+	if timeout > 0 {
+		for _, err := range shutdown(timeout, true) {
+			reportError(err)
+		}
+	}
+	exit(1)
+}
+
+func runExitHandler(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportError(fmt.Errorf("exit handler panicked: %v", r))
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFatalExitFunc(t *testing.T) {
+	var out syncBuffer
+	c := &closeRecorder{}
+	SetOutputs(&out, c)
+	defer SetOutputs(os.Stderr)
+	SetStderrMirror("")
+	defer SetStderrMirror("fatal")
+
+	var steps []string
+	RegisterExitHandler(func() { steps = append(steps, "handler") })
+	RegisterExitHandler(func() { panic("broken handler") })
+	RegisterExitHandler(func() { steps = append(steps, "after broken") })
+	defer func() {
+		exiting.Lock()
+		exiting.handlers = nil
+		exiting.Unlock()
+	}()
+	SetExitFunc(func(code int) {
+		steps = append(steps, "exit")
+		if code != 1 {
+			t.Errorf("exit code = %d, want 1", code)
+		}
+	})
+	defer SetExitFunc(nil)
+	var self syncBuffer
+	SetSelfLog(&self)
+	defer SetSelfLog(os.Stderr)
+
+	Fatal("disk full")
+	if got := strings.Join(steps, ","); got != "handler,after broken,exit" {
+		t.Errorf("steps = %s", got)
+	}
+	if !strings.Contains(out.String(), "disk full") {
+		t.Errorf("output = %q, want the fatal entry", out.String())
+	}
+	if !c.closed {
+		t.Error("outputs were not closed before exiting")
+	}
+	if !strings.Contains(self.String(), "broken handler") {
+		t.Errorf("self-log = %q, want the panicking handler", self.String())
+	}
+}
+
+func TestFatalPanic(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetStderrMirror("")
+	defer SetStderrMirror("fatal")
+	SetFatalPanic(true)
+	defer SetFatalPanic(false)
+	SetExitFunc(func(int) { t.Error("Fatal exited in panic mode") })
+	defer SetExitFunc(nil)
+
+	defer func() {
+		if r := recover(); r != "bad config" {
+			t.Errorf("recover() = %v, want the message", r)
+		}
+		if !strings.Contains(out.String(), "bad config") {
+			t.Errorf("output = %q, want the fatal entry", out.String())
+		}
+	}()
+	WithFields(Fields{"path": "/etc/probe.conf"}).Fatalf("bad %s", "config")
+}
@@ -0,0 +1,344 @@
+package log
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Fields holds structured key/value pairs attached to log entries.
+type Fields map[string]interface{}
+
+// Logger logs entries carrying a set of fields. It is obtained from
+// WithFields or With and is cheap to create: fields are only merged into
+// the entry once a message is actually logged, so
+//
+//	log.WithFields(log.Fields{"flow": id}).Debug("dropped")
+//
+// does no work beyond allocating the Logger when debug is disabled. With
+// additionally avoids the caller-side map literal.
+type Logger struct {
+	inst   *instance
+	parent *Logger
+	fields Fields
+	pairs  []interface{}
+	typed  []Field
+	tag    string
+	time   time.Time
+	host   string
+}
+
+// TagKey is the reserved key holding the tag of entries logged through
+// WithTag. A field the caller names "tag" is an ordinary field.
+const TagKey = "_tag"
+
+// RelayedKey is the field marking entries whose timestamp or host were
+// overridden with WithTime or WithHost, i.e. entries re-logged on behalf of
+// another process or machine.
+const RelayedKey = "relayed"
+
+// Reserved keys carry per-entry metadata through the logrus entry data.
+// Formatters render them in their dedicated positions, not as fields.
+const (
+	timeKey   = "_time"
+	hostKey   = "_host"
+	callerKey = "_caller"
+)
+
+// isReserved reports whether k is a reserved key.
+func isReserved(k string) bool {
+	return k == timeKey || k == hostKey || k == callerKey || k == TagKey || k == instanceKey || k == ctxKey
+}
+
+// WithFields returns a Logger adding fields to every entry. The map is
+// referenced, not copied, and must not be modified afterwards.
+func WithFields(fields Fields) *Logger {
+	return &Logger{fields: fields}
+}
+
+// With returns a Logger adding the alternating keys and values to every
+// entry, e.g. With("user_id", 42, "req", id). Typed fields such as
+// Int("user_id", 42) may be mixed in and stand for a whole pair.
+func With(keysAndValues ...interface{}) *Logger {
+	return &Logger{pairs: keysAndValues}
+}
+
+// WithTag returns a Logger labelling its entries with t instead of the
+// process-wide tag, for subsystems that do not warrant a logger of their
+// own:
+//
+//	log.WithTag("migrator").Info("schema up to date")
+//
+// The text format writes the tag in front of the PID, e.g.
+// "migrator.go:42 migrator[1234]", the JSON format as its tag key.
+func WithTag(t string) *Logger {
+	return &Logger{tag: t}
+}
+
+// WithTag returns a child of l labelling its entries with t below the tag
+// of l, if any, as in "dhcp-server/lease".
+func (l *Logger) WithTag(t string) *Logger {
+	return &Logger{parent: l, tag: t}
+}
+
+// WithTime returns a Logger whose entries carry the timestamp t instead of
+// the current time, e.g. when re-logging events received from remote
+// agents. Such entries are marked with the relayed field.
+func WithTime(t time.Time) *Logger {
+	return &Logger{time: t}
+}
+
+// WithTime returns a child of l whose entries carry the timestamp t.
+func (l *Logger) WithTime(t time.Time) *Logger {
+	return &Logger{parent: l, time: t}
+}
+
+// WithHost returns a Logger whose entries carry the hostname host instead of
+// the local one. Such entries are marked with the relayed field.
+func WithHost(host string) *Logger {
+	return &Logger{host: host}
+}
+
+// WithHost returns a child of l whose entries carry the hostname host.
+func (l *Logger) WithHost(host string) *Logger {
+	return &Logger{parent: l, host: host}
+}
+
+// WithFields returns a child of l adding fields to those of l.
+func (l *Logger) WithFields(fields Fields) *Logger {
+	return &Logger{parent: l, fields: fields}
+}
+
+// With returns a child of l adding the alternating keys and values to the
+// fields of l.
+func (l *Logger) With(keysAndValues ...interface{}) *Logger {
+	return &Logger{parent: l, pairs: keysAndValues}
+}
+
+// Snapshot returns a Logger logging the fields l logs now, for handing to
+// long-lived workers: later changes to the maps passed to WithFields of l
+// or its ancestors no longer affect its entries. The values themselves are
+// not copied, so a pointer still shows the current state of its target.
+func (l *Logger) Snapshot() *Logger {
+	return &Logger{fields: Fields(l.data())}
+}
+
+// data merges the fields of l and its ancestors, children overriding their
+// parents.
+func (l *Logger) data() log.Fields {
+	n := 0
+	for p := l; p != nil; p = p.parent {
+		n += len(p.fields) + len(p.pairs)/2 + len(p.typed) + 3
+	}
+	data := make(log.Fields, n)
+	l.merge(data)
+	return data
+}
+
+func (l *Logger) merge(data log.Fields) {
+	if l.parent != nil {
+		l.parent.merge(data)
+	}
+	if l.inst != nil {
+		data[instanceKey] = l.inst
+	}
+	for k, v := range l.fields {
+		data[k] = v
+	}
+	if l.tag != "" {
+		if parent, ok := data[TagKey].(string); ok && parent != "" {
+			data[TagKey] = parent + "/" + l.tag
+		} else {
+			data[TagKey] = l.tag
+		}
+	}
+	if !l.time.IsZero() {
+		data[timeKey] = l.time
+		data[RelayedKey] = true
+	}
+	if l.host != "" {
+		data[hostKey] = l.host
+		data[RelayedKey] = true
+	}
+	for _, f := range l.typed {
+		data[f.Key] = f.Value()
+	}
+	for i := 0; i < len(l.pairs); i += 2 {
+		if f, ok := l.pairs[i].(Field); ok {
+			data[f.Key] = f.Value()
+			i--
+			continue
+		}
+		key := fmt.Sprint(l.pairs[i])
+		if i+1 < len(l.pairs) {
+			data[key] = l.pairs[i+1]
+		} else {
+			data[key] = "(MISSING)"
+		}
+	}
+}
+
+// enabled reports whether an entry at level has any chance of being kept,
+// either written, possibly by a component level, retained by KeepRecent,
+// escalated by an upgrade rule or recorded.
+func enabled(level log.Level) bool {
+	return level <= getLevel() || level <= componentVerbose() || recent.enabled() || upgradesActive() || recordingActive()
+}
+
+// enabled reports whether an entry of l at level has any chance of being
+// kept, taking the level of a Logger created by New into account.
+func (l *Logger) enabled(level log.Level) bool {
+	if in := l.instance(); in != nil {
+		return level <= log.Level(atomic.LoadUint32(&in.level)) || recent.enabled() || upgradesActive() || recordingActive()
+	}
+	return enabled(level)
+}
+
+// Debug logs a message with severity DEBUG.
+func (l *Logger) Debug(v ...interface{}) {
+	if l.enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprint(v...), l.data())
+	}
+}
+
+// Error logs a message with severity ERROR.
+func (l *Logger) Error(v ...interface{}) {
+	if l.enabled(log.ErrorLevel) {
+		output(log.ErrorLevel, fmt.Sprint(v...), l.data())
+	}
+}
+
+// Fatal logs a message with severity ERROR followed by a call to os.Exit().
+func (l *Logger) Fatal(v ...interface{}) {
+	output(log.FatalLevel, fmt.Sprint(v...), l.data())
+}
+
+// Panic logs a message with severity PANIC followed by a call to panic with
+// the message.
+func (l *Logger) Panic(v ...interface{}) {
+	msg := fmt.Sprint(v...)
+	output(log.PanicLevel, msg, l.data())
+	panic(msg)
+}
+
+// Info logs a message with severity INFO.
+func (l *Logger) Info(v ...interface{}) {
+	if l.enabled(log.InfoLevel) {
+		output(log.InfoLevel, fmt.Sprint(v...), l.data())
+	}
+}
+
+// Warning logs a message with severity WARNING.
+func (l *Logger) Warning(v ...interface{}) {
+	if l.enabled(log.WarnLevel) {
+		output(log.WarnLevel, fmt.Sprint(v...), l.data())
+	}
+}
+
+// Debugf logs a formatted message with severity DEBUG.
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	if l.enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprintf(format, v...), l.data())
+	}
+}
+
+// Errorf logs a formatted message with severity ERROR.
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	if l.enabled(log.ErrorLevel) {
+		output(log.ErrorLevel, fmt.Sprintf(format, v...), l.data())
+	}
+}
+
+// Fatalf logs a formatted message with severity ERROR followed by a call to
+// os.Exit().
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	output(log.FatalLevel, fmt.Sprintf(format, v...), l.data())
+}
+
+// Panicf logs a formatted message with severity PANIC followed by a call to
+// panic with the message.
+func (l *Logger) Panicf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	output(log.PanicLevel, msg, l.data())
+	panic(msg)
+}
+
+// Infof logs a formatted message with severity INFO.
+func (l *Logger) Infof(format string, v ...interface{}) {
+	if l.enabled(log.InfoLevel) {
+		output(log.InfoLevel, fmt.Sprintf(format, v...), l.data())
+	}
+}
+
+// Warningf logs a formatted message with severity WARNING.
+func (l *Logger) Warningf(format string, v ...interface{}) {
+	if l.enabled(log.WarnLevel) {
+		output(log.WarnLevel, fmt.Sprintf(format, v...), l.data())
+	}
+}
+
+// Debugw logs msg with severity DEBUG and the alternating keys and values
+// as fields, a shorthand for With(keysAndValues...).Debug(msg).
+func Debugw(msg string, keysAndValues ...interface{}) {
+	if enabled(log.DebugLevel) {
+		output(log.DebugLevel, msg, With(keysAndValues...).data())
+	}
+}
+
+// Errorw logs msg with severity ERROR and the alternating keys and values
+// as fields.
+func Errorw(msg string, keysAndValues ...interface{}) {
+	if enabled(log.ErrorLevel) {
+		output(log.ErrorLevel, msg, With(keysAndValues...).data())
+	}
+}
+
+// Infow logs msg with severity INFO and the alternating keys and values as
+// fields, e.g. Infow("login ok", "user_id", 42).
+func Infow(msg string, keysAndValues ...interface{}) {
+	if enabled(log.InfoLevel) {
+		output(log.InfoLevel, msg, With(keysAndValues...).data())
+	}
+}
+
+// Warningw logs msg with severity WARNING and the alternating keys and
+// values as fields.
+func Warningw(msg string, keysAndValues ...interface{}) {
+	if enabled(log.WarnLevel) {
+		output(log.WarnLevel, msg, With(keysAndValues...).data())
+	}
+}
+
+// Debugw logs msg with severity DEBUG and the alternating keys and values
+// added to the fields of l.
+func (l *Logger) Debugw(msg string, keysAndValues ...interface{}) {
+	if l.enabled(log.DebugLevel) {
+		output(log.DebugLevel, msg, l.With(keysAndValues...).data())
+	}
+}
+
+// Errorw logs msg with severity ERROR and the alternating keys and values
+// added to the fields of l.
+func (l *Logger) Errorw(msg string, keysAndValues ...interface{}) {
+	if l.enabled(log.ErrorLevel) {
+		output(log.ErrorLevel, msg, l.With(keysAndValues...).data())
+	}
+}
+
+// Infow logs msg with severity INFO and the alternating keys and values
+// added to the fields of l.
+func (l *Logger) Infow(msg string, keysAndValues ...interface{}) {
+	if l.enabled(log.InfoLevel) {
+		output(log.InfoLevel, msg, l.With(keysAndValues...).data())
+	}
+}
+
+// Warningw logs msg with severity WARNING and the alternating keys and
+// values added to the fields of l.
+func (l *Logger) Warningw(msg string, keysAndValues ...interface{}) {
+	if l.enabled(log.WarnLevel) {
+		output(log.WarnLevel, msg, l.With(keysAndValues...).data())
+	}
+}
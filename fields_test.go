@@ -0,0 +1,184 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestLoggerData(t *testing.T) {
+	l := WithFields(Fields{"user_id": 42, "req": "a"}).With("req", "b", "dangling")
+	data := l.data()
+
+	if data["user_id"] != 42 {
+		t.Errorf("user_id = %v, want 42", data["user_id"])
+	}
+	if data["req"] != "b" {
+		t.Errorf("req = %v, want the child's value b", data["req"])
+	}
+	if data["dangling"] != "(MISSING)" {
+		t.Errorf("dangling = %v, want (MISSING)", data["dangling"])
+	}
+}
+
+type countingStringer struct{ calls *int }
+
+func (s countingStringer) String() string {
+	*s.calls++
+	return "expensive"
+}
+
+func TestLoggerSkipsDisabledLevels(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetLevel("info")
+	defer SetLevel("debug")
+
+	calls := 0
+	With("k", "v").Debug(countingStringer{&calls})
+	if calls != 0 {
+		t.Error("disabled Debug formatted its arguments")
+	}
+
+	With("k", "v").Info("enabled")
+	if !strings.Contains(buf.String(), "enabled k=v") {
+		t.Errorf("output = %q, want message followed by k=v", buf.String())
+	}
+}
+
+func TestWithTag(t *testing.T) {
+	if got := WithTag("migrator").data()[TagKey]; got != "migrator" {
+		t.Errorf("tag = %v, want migrator", got)
+	}
+	if got := WithTag("migrator").With("step", 3).WithTag("backfill").data()[TagKey]; got != "migrator/backfill" {
+		t.Errorf("tag = %v, want the hierarchy migrator/backfill", got)
+	}
+	if _, ok := With("k", "v").data()[TagKey]; ok {
+		t.Error("entries without WithTag carry a tag field")
+	}
+}
+
+func TestWithTimeAndHost(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	ts := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	WithTime(ts).WithHost("probe-7").Info("relayed event")
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "2017-03-01T12:00:00Z probe-7 : INFO") {
+		t.Errorf("entry does not carry the overridden time and host: %q", out)
+	}
+	if !strings.Contains(out, "relayed=true") || strings.Contains(out, "_time") || strings.Contains(out, "_host") {
+		t.Errorf("entry fields = %q, want relayed=true and no reserved keys", out)
+	}
+}
+
+func TestInfow(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	Infow("login ok", "user_id", 42, "req", "a b")
+	WithTag("auth").Warningw("login slow", "ms", 900)
+
+	out := buf.String()
+	if !strings.Contains(out, `login ok req="a b" user_id=42`) {
+		t.Errorf("output %q does not carry the Infow fields", out)
+	}
+	if !strings.Contains(out, " auth[") || !strings.Contains(out, "login slow ms=900") {
+		t.Errorf("output %q does not merge the Warningw fields with the logger's", out)
+	}
+	if !strings.Contains(out, "fields_test.go:") {
+		t.Errorf("output %q does not attribute the entries to the caller", out)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	fields := Fields{"conn": 1}
+	parent := WithFields(fields).WithTag("worker")
+	snap := parent.Snapshot()
+
+	fields["conn"] = 2
+	fields["late"] = true
+	data := snap.With("job", 7).data()
+	if data["conn"] != 1 || data["late"] != nil {
+		t.Errorf("snapshot data = %v, want the fields at the time of the snapshot", data)
+	}
+	if data[TagKey] != "worker" || data["job"] != 7 {
+		t.Errorf("snapshot data = %v, want the tag and the child's fields", data)
+	}
+	if parent.data()["conn"] != 2 {
+		t.Error("the parent does not see its changed fields")
+	}
+}
+
+func TestTagPosition(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer SetReportCaller(true)
+
+	dhcp := WithTag("dhcp-server")
+	dhcp.WithTag("lease").Info("renewed")
+	SetReportCaller(false)
+	dhcp.Info("offer sent")
+	if out := buf.String(); !regexp.MustCompile(`\t\S*fields_test.go:\d+ dhcp-server/lease\[\d+\] renewed\n`).MatchString(out) ||
+		!regexp.MustCompile(`\tdhcp-server\[\d+\] offer sent\n`).MatchString(out) || strings.Contains(out, "tag=") {
+		t.Errorf("output = %q, want the tags in front of the pid", out)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if e, ok := parseEntry([]byte(line)); !ok || !strings.HasPrefix(e.Tag, "dhcp-server") {
+			t.Errorf("parseEntry(%q) = %+v, want the tag", line, e)
+		}
+	}
+
+	buf.Reset()
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+	dhcp.WithTag("lease").Info("renewed")
+	if out := buf.String(); !strings.Contains(out, `"tag":"dhcp-server/lease"`) || strings.Contains(out, "fields.tag") {
+		t.Errorf("JSON entry = %s, want the tag as its tag key", out)
+	}
+}
+
+func TestTagField(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	WithTag("dhcp-server").With("tag", "v1.2").Info("released")
+	if out := buf.String(); !strings.Contains(out, " dhcp-server[") || !strings.HasSuffix(out, "released tag=v1.2\n") {
+		t.Errorf("output = %q, want the tag and the tag field", out)
+	}
+
+	buf.Reset()
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+	WithTag("dhcp-server").With("tag", "v1.2").Info("released")
+	if out := buf.String(); !strings.Contains(out, `"tag":"dhcp-server"`) || !strings.Contains(out, `"fields.tag":"v1.2"`) {
+		t.Errorf("JSON entry = %s, want the tag and the tag field", out)
+	}
+
+	entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{TagKey: "dhcp-server", "tag": "v1.2"})
+	entry.Message = "released"
+	for _, f := range []log.Formatter{&SyslogFormatter{}, &CSVFormatter{Columns: []string{"tag", "msg"}}} {
+		b, err := f.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "dhcp-server") {
+			t.Errorf("%T output %q lost the tag", f, b)
+		}
+		if _, ok := f.(*SyslogFormatter); ok && !strings.Contains(string(b), "tag=v1.2") {
+			t.Errorf("%T output %q lost the tag field", f, b)
+		}
+	}
+}
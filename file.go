@@ -0,0 +1,249 @@
+package log
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Default modes for files and directories created by Init: readable by
+// the owner's group, e.g. a log shipper, and by nobody else. The process
+// umask applies on Unix; on Windows only the owner write bit is honored,
+// clearing it makes the file read-only.
+const (
+	DefaultFileMode os.FileMode = 0640
+	DefaultDirMode  os.FileMode = 0750
+)
+
+var fileModes = struct {
+	sync.Mutex
+	file, dir os.FileMode
+	// uid and gid are the owner of the files, -1 to leave it unchanged.
+	uid, gid int
+	lazy     bool
+	tee      bool
+}{file: DefaultFileMode, dir: DefaultDirMode, uid: -1, gid: -1}
+
+// SetFileModes sets the permissions of log files and of the directories
+// created for them. It must be called before Init.
+func SetFileModes(file, dir os.FileMode) {
+	fileModes.Lock()
+	fileModes.file = file.Perm()
+	fileModes.dir = dir.Perm()
+	fileModes.Unlock()
+}
+
+// SetFileOwner makes uid and gid the owner of log files and of the
+// directories created for them when the process runs as root, e.g. for a
+// capture daemon started as root whose logs are read by an unprivileged
+// shipper. -1 leaves the user or the group unchanged. It has no effect for
+// other users and on Windows. It must be called before Init.
+func SetFileOwner(uid, gid int) {
+	fileModes.Lock()
+	fileModes.uid, fileModes.gid = uid, gid
+	fileModes.Unlock()
+}
+
+// chownLog gives name the owner set with SetFileOwner.
+func chownLog(name string) error {
+	fileModes.Lock()
+	uid, gid := fileModes.uid, fileModes.gid
+	fileModes.Unlock()
+	if uid < 0 && gid < 0 || os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Chown(name, uid, gid)
+}
+
+// SetLazyOpen defers creating the log file and its directory until the
+// first entry is written, so short-lived commands that log nothing at the
+// active level leave no files behind. Errors opening the file are then
+// reported to the self-log instead of terminating the process. It must be
+// called before Init.
+func SetLazyOpen(lazy bool) {
+	fileModes.Lock()
+	fileModes.lazy = lazy
+	fileModes.Unlock()
+}
+
+func lazyOpen() bool {
+	fileModes.Lock()
+	defer fileModes.Unlock()
+	return fileModes.lazy
+}
+
+// SetTeeToStdout makes Init write every entry to stdout as well as to the
+// log file, e.g. for containers whose collector reads stdout while
+// operators want the file on disk. The two outputs fail independently, see
+// SetOutputs; SetStderrMirror additionally copies severe entries to
+// stderr. It must be called before Init.
+func SetTeeToStdout(tee bool) {
+	fileModes.Lock()
+	fileModes.tee = tee
+	fileModes.Unlock()
+}
+
+// setFileOutput makes the log file w the output of the package logger,
+// next to stdout if SetTeeToStdout was called.
+func setFileOutput(w io.Writer) {
+	fileModes.Lock()
+	tee := fileModes.tee
+	fileModes.Unlock()
+
+	if tee {
+		w = newMultiWriter(w, os.Stdout)
+	}
+	setOutput(w)
+}
+
+// lazyFile is a log file that is opened on the first write, unless f is
+// set, and reopened when removed or renamed, e.g. by logrotate, or when a
+// write fails.
+type lazyFile struct {
+	mu   sync.Mutex
+	name string
+	f    *os.File
+	// checked is when the file was last compared with name.
+	checked time.Time
+}
+
+// Name returns the path of the file.
+func (l *lazyFile) Name() string {
+	return l.name
+}
+
+// Write opens the file if necessary and appends p to it, reopening the
+// file and retrying once if the write fails.
+func (l *lazyFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil || fileMoved(l.f, l.name, &l.checked) {
+		if err := l.reopenLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.f.Write(p)
+	if err != nil && l.reopenLocked() == nil {
+		var m int
+		m, err = l.f.Write(p[n:])
+		n += m
+	}
+	return n, err
+}
+
+// Reopen closes the file and opens name again, unless it was not opened
+// yet.
+func (l *lazyFile) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	return l.reopenLocked()
+}
+
+func (l *lazyFile) reopenLocked() error {
+	if l.f != nil {
+		l.f.Close()
+		l.f = nil
+	}
+	if err := createLogDir(l.name); err != nil {
+		return err
+	}
+	f, err := openLogFile(l.name)
+	if err != nil {
+		return err
+	}
+	l.f = f
+	return nil
+}
+
+// Flush syncs the file if it has been opened.
+func (l *lazyFile) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Sync()
+}
+
+// Close syncs and closes the file. A later Write opens it again.
+func (l *lazyFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Sync()
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// createLogDir creates the directory of the log file name. Both slash and,
+// on Windows, backslash separated names are accepted.
+func createLogDir(name string) error {
+	dir := filepath.Dir(filepath.Clean(name))
+	if dir == "." {
+		return nil
+	}
+	return mkdirLog(dir)
+}
+
+// mkdirLog creates dir and its missing parents with the mode and the
+// owner of log directories.
+func mkdirLog(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	fileModes.Lock()
+	mode := fileModes.dir
+	fileModes.Unlock()
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if err := chownLog(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openLogFile opens name for appending, creating it if needed.
+func openLogFile(name string) (*os.File, error) {
+	return createLogFile(filepath.Clean(name), os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+}
+
+// createLogFile opens name with flag, creating it with the mode and the
+// owner of log files.
+func createLogFile(name string, flag int) (*os.File, error) {
+	fileModes.Lock()
+	mode := fileModes.file
+	fileModes.Unlock()
+	f, err := openFile(name, flag, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := chownLog(name); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,60 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenLogFileCreatesDirectories(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "var", "log", "probe.log")
+	if err := createLogDir(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openLogFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	info, err := os.Stat(filepath.Dir(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Fatalf("%s is not a directory", filepath.Dir(name))
+	}
+}
+
+func TestLazyFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lazy", "probe.log")
+	l := &lazyFile{name: name}
+
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(name)); !os.IsNotExist(err) {
+		t.Fatalf("directory exists before the first write: %v", err)
+	}
+
+	if _, err := l.Write([]byte("first entry\n")); err != nil {
+		t.Fatal(err)
+	}
+	l.f.Close()
+	if b, err := os.ReadFile(name); err != nil || string(b) != "first entry\n" {
+		t.Errorf("file content = %q, %v; want the first entry", b, err)
+	}
+}
+
+func TestSetTeeToStdout(t *testing.T) {
+	SetTeeToStdout(true)
+	defer SetTeeToStdout(false)
+	defer SetOutputs(os.Stderr)
+
+	l := &lazyFile{name: filepath.Join(t.TempDir(), "probe.log")}
+	setFileOutput(l)
+	outputs := CurrentConfig().Outputs
+	if len(outputs) != 2 || outputs[0] != l.name || outputs[1] != os.Stdout.Name() {
+		t.Errorf("outputs = %q, want the file and stdout", outputs)
+	}
+}
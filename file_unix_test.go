@@ -0,0 +1,98 @@
+//go:build unix
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestLogDirModeUnix(t *testing.T) {
+	SetFileModes(0600, 0700)
+	defer SetFileModes(DefaultFileMode, DefaultDirMode)
+
+	umask := syscall.Umask(0)
+	defer syscall.Umask(umask)
+
+	name := filepath.Join(t.TempDir(), "logs", "probe.log")
+	if err := createLogDir(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openLogFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for path, want := range map[string]os.FileMode{filepath.Dir(name): 0700, name: 0600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("mode of %s = %o, want %o", path, got, want)
+		}
+	}
+}
+
+func TestLogFileOwnerUnix(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chown needs root")
+	}
+	SetFileOwner(1, 2)
+	defer SetFileOwner(-1, -1)
+
+	root := t.TempDir()
+	name := filepath.Join(root, "logs", "capture", "probe.log")
+	if err := createLogDir(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openLogFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for path, want := range map[string]uint32{
+		root:                        0,
+		filepath.Join(root, "logs"): 1,
+		filepath.Dir(name):          1,
+		name:                        1,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := info.Sys().(*syscall.Stat_t)
+		if st.Uid != want || want != 0 && st.Gid != 2 {
+			t.Errorf("owner of %s = %d:%d, want %d", path, st.Uid, st.Gid, want)
+		}
+	}
+}
+
+func TestDefaultLogFileModesUnix(t *testing.T) {
+	umask := syscall.Umask(0)
+	defer syscall.Umask(umask)
+
+	name := filepath.Join(t.TempDir(), "logs", "probe.log")
+	if err := createLogDir(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openLogFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for path, want := range map[string]os.FileMode{filepath.Dir(name): 0750, name: 0640} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("mode of %s = %o, want %o", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// openFile is os.OpenFile sharing the file for deletion too, so that, as
+// on Unix, log shippers and operators may rename or remove a log file the
+// process has open, and the rotated files of another instance do not fail
+// with a sharing violation.
+func openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	path, err := syscall.UTF16PtrFromString(fixLongPath(name))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	var access uint32
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		access = syscall.GENERIC_READ
+	case os.O_WRONLY:
+		access = syscall.GENERIC_WRITE
+	case os.O_RDWR:
+		access = syscall.GENERIC_READ | syscall.GENERIC_WRITE
+	}
+	if flag&os.O_APPEND != 0 {
+		access &^= syscall.GENERIC_WRITE
+		access |= syscall.FILE_APPEND_DATA
+	}
+
+	var disposition uint32
+	switch {
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		disposition = syscall.CREATE_NEW
+	case flag&(os.O_CREATE|os.O_TRUNC) == os.O_CREATE|os.O_TRUNC:
+		disposition = syscall.CREATE_ALWAYS
+	case flag&os.O_CREATE == os.O_CREATE:
+		disposition = syscall.OPEN_ALWAYS
+	case flag&os.O_TRUNC == os.O_TRUNC:
+		disposition = syscall.TRUNCATE_EXISTING
+	default:
+		disposition = syscall.OPEN_EXISTING
+	}
+
+	attrs := uint32(syscall.FILE_ATTRIBUTE_NORMAL)
+	if perm&0200 == 0 {
+		attrs = syscall.FILE_ATTRIBUTE_READONLY
+	}
+	share := uint32(syscall.FILE_SHARE_READ | syscall.FILE_SHARE_WRITE | syscall.FILE_SHARE_DELETE)
+	h, err := syscall.CreateFile(path, access, share, nil, disposition, attrs, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.NewFile(uintptr(h), name), nil
+}
+
+// fixLongPath makes an absolute path longer than MAX_PATH an extended
+// length one, which CreateFile accepts, as os.OpenFile does.
+func fixLongPath(name string) string {
+	const maxPath = 248 // MAX_PATH minus the room of an 8.3 file name
+	if len(name) < maxPath || !filepath.IsAbs(name) || strings.HasPrefix(name, `\\`) {
+		return name
+	}
+	return `\\?\` + filepath.Clean(name)
+}
@@ -0,0 +1,83 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOpenLogFileWindowsPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		dir + `\logs\probe.log`,
+		strings.ReplaceAll(dir, `\`, "/") + "/mixed/logs/probe.log",
+	} {
+		if err := createLogDir(name); err != nil {
+			t.Fatalf("createLogDir(%q): %v", name, err)
+		}
+		f, err := openLogFile(name)
+		if err != nil {
+			t.Fatalf("openLogFile(%q): %v", name, err)
+		}
+		f.Close()
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("log file %q was not created: %v", name, err)
+		}
+	}
+}
+
+func TestReadOnlyFileModeWindows(t *testing.T) {
+	SetFileModes(0444, DefaultDirMode)
+	defer SetFileModes(DefaultFileMode, DefaultDirMode)
+
+	name := t.TempDir() + `\readonly.log`
+	f, err := openLogFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0200 != 0 {
+		t.Errorf("mode = %o, want the file to be read-only", info.Mode().Perm())
+	}
+}
+
+func TestLogFileRenamedWhileOpenWindows(t *testing.T) {
+	name := t.TempDir() + `\probe.log`
+	f, err := openLogFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A log shipper moves the file away; the process keeps writing to it.
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatalf("rename of an open log file: %v", err)
+	}
+	if _, err := f.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if b, _ := os.ReadFile(name + ".1"); string(b) != "first\nsecond\n" {
+		t.Errorf("renamed file = %q", b)
+	}
+}
+
+func TestOpenLogFileLongPathWindows(t *testing.T) {
+	name := t.TempDir() + `\` + strings.Repeat("d", 100) + `\` + strings.Repeat("e", 100) + `\probe.log`
+	if err := createLogDir(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openLogFile(name)
+	if err != nil {
+		t.Fatalf("openLogFile of a %d character path: %v", len(name), err)
+	}
+	f.Close()
+}
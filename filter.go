@@ -0,0 +1,106 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// filter is a predicate added with AddFilter.
+type filter struct {
+	keep func(e *Entry) bool
+}
+
+var filters = struct {
+	sync.Mutex
+	list atomic.Value // []*filter
+}{}
+
+// AddFilter drops the entries of the package logger and of the Loggers
+// created by New for which keep returns false, until remove is called, so
+// that noise such as health checks and known benign errors is dropped in
+// one place rather than at every call site:
+//
+//	log.AddFilter(log.Deny(log.FieldEquals(log.HTTPPathKey, "/healthz")))
+//	log.AddFilter(func(e *log.Entry) bool { return e.Fields["peer"] != "192.0.2.7" })
+//
+// An entry is written only if every filter keeps it. Filters run after the
+// level and before sampling and formatting, calling them for entries that
+// are dropped anyway would be wasted work; they must be fast, must not log
+// and must not modify e. FATAL and PANIC entries are never dropped.
+// Dropped entries are counted in Stats.
+func AddFilter(keep func(e *Entry) bool) (remove func()) {
+	f := &filter{keep}
+	filters.Lock()
+	list, _ := filters.list.Load().([]*filter)
+	filters.list.Store(append(list[:len(list):len(list)], f))
+	filters.Unlock()
+
+	return func() {
+		filters.Lock()
+		defer filters.Unlock()
+		list, _ := filters.list.Load().([]*filter)
+		kept := make([]*filter, 0, len(list))
+		for _, g := range list {
+			if g != f {
+				kept = append(kept, g)
+			}
+		}
+		filters.list.Store(kept)
+	}
+}
+
+// ClearFilters removes every filter.
+func ClearFilters() {
+	filters.Lock()
+	filters.list.Store([]*filter(nil))
+	filters.Unlock()
+}
+
+// Deny returns a filter dropping the entries selected by any of the
+// matchers.
+func Deny(matchers ...Matcher) func(e *Entry) bool {
+	return func(e *Entry) bool {
+		for _, m := range matchers {
+			if m(e.Message, e.Fields) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// filtered reports whether a filter drops an entry.
+func filtered(level log.Level, site callSite, msg string, fields log.Fields) bool {
+	list, _ := filters.list.Load().([]*filter)
+	if len(list) == 0 || level <= log.FatalLevel {
+		return false
+	}
+	e := Entry{
+		Time:    time.Now(),
+		Level:   severityName(level),
+		Host:    entryHost(fields),
+		Tag:     entryTag(fields),
+		File:    site.file,
+		Line:    site.line,
+		Message: msg,
+		Fields:  make(Fields, len(fields)),
+	}
+	if t, ok := fields[timeKey].(time.Time); ok {
+		e.Time = t
+	}
+	for k, v := range fields {
+		if !isReserved(k) {
+			e.Fields[k] = v
+		}
+	}
+	for _, f := range list {
+		if !f.keep(&e) {
+			atomic.AddUint64(&stats.Filtered, 1)
+			return true
+		}
+	}
+	return false
+}
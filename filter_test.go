@@ -0,0 +1,42 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAddFilter(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	before := Stats().Filtered
+
+	removeHealth := AddFilter(Deny(FieldEquals(HTTPPathKey, "/healthz"), MessageContains("benign")))
+	var seen []*Entry
+	removePeer := AddFilter(func(e *Entry) bool {
+		seen = append(seen, e)
+		return e.Fields["peer"] != "192.0.2.7"
+	})
+	defer ClearFilters()
+
+	WithFields(Fields{HTTPPathKey: "/healthz"}).Info("GET /healthz 200")
+	Warning("benign reset by peer")
+	WithFields(Fields{"peer": "192.0.2.7"}).Warning("retransmit")
+	WithFields(Fields{"peer": "192.0.2.8"}).Warning("retransmit")
+	if n := Stats().Filtered - before; n != 3 {
+		t.Errorf("Filtered increased by %d, want 3", n)
+	}
+	// The second filter only sees the entries the first kept.
+	if len(seen) != 2 || seen[1].Level != "warning" || seen[1].Fields["peer"] != "192.0.2.8" || seen[1].Line == 0 {
+		t.Errorf("filter saw %+v", seen)
+	}
+
+	removePeer()
+	removeHealth()
+	WithFields(Fields{"peer": "192.0.2.7"}).Warning("retransmit again")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "peer=192.0.2.8") || !strings.Contains(lines[1], "retransmit again") {
+		t.Errorf("wrote %q", out.String())
+	}
+}
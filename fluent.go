@@ -0,0 +1,253 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FluentSink is a Sink sending entries to Fluentd or Fluent Bit in the
+// Forward protocol, as records with the keys of JSONFormatter and their
+// time at nanosecond precision. It connects on the first entry and
+// reconnects once to retry an entry that cannot be sent, the collector
+// may have been restarted.
+//
+//	log.AddSink(log.NewFluentSink("127.0.0.1:24224", "app.probe", nil))
+type FluentSink struct {
+	addr string
+	tag  string
+	cfg  *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewFluentSink returns a FluentSink sending to the forward input at addr
+// with the Fluentd tag t, the tag of the entry if empty. A non-nil cfg
+// secures the connection with TLS.
+func NewFluentSink(addr, t string, cfg *tls.Config) *FluentSink {
+	return &FluentSink{addr: addr, tag: t, cfg: cfg}
+}
+
+// Name returns the address of the collector.
+func (f *FluentSink) Name() string {
+	return "fluent:" + f.addr
+}
+
+// Write sends e.
+func (f *FluentSink) Write(e *Entry) error {
+	return f.WriteContext(context.Background(), e)
+}
+
+// WriteContext sends e, giving up when ctx is done.
+func (f *FluentSink) WriteContext(ctx context.Context, e *Entry) error {
+	t := f.tag
+	if t == "" {
+		t = e.Tag
+	}
+	// Message mode: [tag, time, record].
+	msg := []byte{0x93}
+	msg = msgpackAppend(msg, t)
+	msg = appendEventTime(msg, e.Time)
+	msg = msgpackAppend(msg, entryRecord(e))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if f.conn == nil {
+			if f.conn, err = f.dial(ctx); err != nil {
+				f.conn = nil
+				continue
+			}
+		}
+		f.conn.SetWriteDeadline(time.Now().Add(forwardDialTimeout))
+		stop := interruptible(ctx, f.conn)
+		_, err = f.conn.Write(msg)
+		stop()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			f.conn.Close()
+			f.conn = nil
+			return ctx.Err()
+		}
+		f.conn.Close()
+		f.conn = nil
+	}
+	return err
+}
+
+// Close closes the connection.
+func (f *FluentSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+	return err
+}
+
+func (f *FluentSink) dial(ctx context.Context) (net.Conn, error) {
+	d := &net.Dialer{Timeout: forwardDialTimeout}
+	if f.cfg != nil {
+		td := &tls.Dialer{NetDialer: d, Config: f.cfg}
+		return td.DialContext(ctx, "tcp", f.addr)
+	}
+	return d.DialContext(ctx, "tcp", f.addr)
+}
+
+// appendEventTime appends t as the EventTime extension of the Forward
+// protocol.
+func appendEventTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xd7, 0x00)
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Unix()))
+	return binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+}
+
+// msgpackAppend appends v in MessagePack. Types other than the basic ones
+// are encoded as their JSON encoding decodes, so fields look the same as
+// in the output of JSONFormatter.
+func msgpackAppend(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if v {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case int:
+		return msgpackInt(b, int64(v))
+	case int8:
+		return msgpackInt(b, int64(v))
+	case int16:
+		return msgpackInt(b, int64(v))
+	case int32:
+		return msgpackInt(b, int64(v))
+	case int64:
+		return msgpackInt(b, v)
+	case uint:
+		return msgpackUint(b, uint64(v))
+	case uint8:
+		return msgpackUint(b, uint64(v))
+	case uint16:
+		return msgpackUint(b, uint64(v))
+	case uint32:
+		return msgpackUint(b, uint64(v))
+	case uint64:
+		return msgpackUint(b, v)
+	case float32:
+		return msgpackFloat(b, float64(v))
+	case float64:
+		return msgpackFloat(b, v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return msgpackInt(b, i)
+		}
+		f, _ := v.Float64()
+		return msgpackFloat(b, f)
+	case string:
+		return msgpackString(b, v)
+	case error:
+		return msgpackString(b, v.Error())
+	case []byte:
+		b = msgpackLength(b, len(v), 0, -1, 0xc4, 0xc5, 0xc6)
+		return append(b, v...)
+	case []interface{}:
+		b = msgpackLength(b, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			b = msgpackAppend(b, e)
+		}
+		return b
+	case Fields:
+		return msgpackAppend(b, map[string]interface{}(v))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = msgpackLength(b, len(keys), 0x80, 15, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			b = msgpackString(b, k)
+			b = msgpackAppend(b, v[k])
+		}
+		return b
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return msgpackString(b, fmt.Sprint(v))
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return msgpackString(b, string(j))
+	}
+	return msgpackAppend(b, decoded)
+}
+
+func msgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return msgpackUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+	}
+}
+
+func msgpackUint(b []byte, u uint64) []byte {
+	switch {
+	case u < 0x80:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+	}
+}
+
+func msgpackFloat(b []byte, f float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f))
+}
+
+func msgpackString(b []byte, s string) []byte {
+	b = msgpackLength(b, len(s), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	return append(b, s...)
+}
+
+// msgpackLength appends the header of a string, binary, array or map of n
+// elements: the fix form OR-ed with n up to fixMax elements, then the 8, 16
+// and 32 bits length forms. A negative fixMax and a zero l8 stand for forms
+// the type does not have.
+func msgpackLength(b []byte, n int, fix byte, fixMax int, l8, l16, l32 byte) []byte {
+	switch {
+	case n <= fixMax:
+		return append(b, fix|byte(n))
+	case l8 != 0 && n <= math.MaxUint8:
+		return append(b, l8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, l16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, l32), uint32(n))
+	}
+}
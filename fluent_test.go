@@ -0,0 +1,91 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMsgpackAppend(t *testing.T) {
+	for _, tc := range []struct {
+		v    interface{}
+		want []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{5, []byte{0x05}},
+		{-3, []byte{0xfd}},
+		{200, []byte{0xcc, 0xc8}},
+		{-100, []byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x9c}},
+		{70000, []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"eth1", []byte{0xa4, 'e', 't', 'h', '1'}},
+		{[]interface{}{1, "a"}, []byte{0x92, 0x01, 0xa1, 'a'}},
+		{Fields{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+		// Through JSON, like JSONFormatter.
+		{time.Second, []byte{0xce, 0x3b, 0x9a, 0xca, 0x00}},
+		{struct{ Port int }{443}, []byte{0x81, 0xa4, 'P', 'o', 'r', 't', 0xcd, 0x01, 0xbb}},
+	} {
+		if got := msgpackAppend(nil, tc.v); !bytes.Equal(got, tc.want) {
+			t.Errorf("msgpackAppend(%#v) = % x, want % x", tc.v, got, tc.want)
+		}
+	}
+	long := string(make([]byte, 40))
+	if got := msgpackAppend(nil, long); got[0] != 0xd9 || got[1] != 40 || len(got) != 42 {
+		t.Errorf("40 bytes string header = % x", got[:2])
+	}
+}
+
+func TestFluentSink(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+
+	f := NewFluentSink(l.Addr().String(), "app.probe", nil)
+	e := &Entry{
+		Time:    time.Unix(1488369600, 250),
+		Level:   "warning",
+		Message: "link flap",
+		Fields:  Fields{"iface": "eth1"},
+	}
+	if err := f.Write(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+	select {
+	case got = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing received")
+	}
+	head := []byte{0x93, 0xa9, 'a', 'p', 'p', '.', 'p', 'r', 'o', 'b', 'e', 0xd7, 0x00, 0x58, 0xb6, 0xb7, 0xc0, 0x00, 0x00, 0x00, 0xfa}
+	if !bytes.HasPrefix(got, head) {
+		t.Fatalf("message starts with % x, want % x", got[:len(head)], head)
+	}
+	for _, want := range [][]byte{
+		msgpackAppend(msgpackString(nil, "iface"), "eth1"),
+		msgpackAppend(msgpackString(nil, "msg"), "link flap"),
+		msgpackAppend(msgpackString(nil, "level"), "warning"),
+	} {
+		if !bytes.Contains(got[len(head):], want) {
+			t.Errorf("record % x does not contain % x", got[len(head):], want)
+		}
+	}
+}
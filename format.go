@@ -0,0 +1,138 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Formats selectable with SetFormat.
+const (
+	// TextFormat is the package's native line format.
+	TextFormat = "text"
+	// JSONFormat writes entries as single-line JSON objects, see
+	// JSONFormatter.
+	JSONFormat = "json"
+	// FlatJSONFormat is JSONFormat with nested objects flattened into
+	// dotted keys and unencodable fields left out, see
+	// JSONFormatter.Flat.
+	FlatJSONFormat = "json-flat"
+	// CEFFormat, LEEFFormat and RFC5424Format write entries for SIEMs as
+	// ArcSight CEF, QRadar LEEF and RFC 5424 syslog with the fields as
+	// structured data, see CEFFormatter, LEEFFormatter and
+	// SyslogFormatter.
+	CEFFormat     = "cef"
+	LEEFFormat    = "leef"
+	RFC5424Format = "rfc5424"
+	// ConsoleFormat is a colored, aligned format for developers reading a
+	// terminal, see ConsoleFormatter. InitDevelopment selects it if
+	// stderr is a terminal.
+	ConsoleFormat = "console"
+	// CustomFormat is the format set with SetFormatter or SetTemplate.
+	CustomFormat = "custom"
+)
+
+// format is the name of the active format, and custom the formatter of
+// CustomFormat.
+var (
+	format = TextFormat
+	custom EntryFormatter
+)
+
+// EntryFormatter renders entries for formats the package has no built-in
+// support for, e.g. the line format of an existing fleet. FormatEntry
+// returns the bytes written for e, including the trailing newline.
+type EntryFormatter interface {
+	FormatEntry(e *Entry) ([]byte, error)
+}
+
+// EntryFormatterFunc adapts a function to an EntryFormatter.
+type EntryFormatterFunc func(e *Entry) ([]byte, error)
+
+// FormatEntry calls fn(e).
+func (fn EntryFormatterFunc) FormatEntry(e *Entry) ([]byte, error) {
+	return fn(e)
+}
+
+// SetFormatter selects f as the format of every entry written from now on,
+// CustomFormat in Config; SetFormat selects a built-in format again. Lines
+// in a custom format are not parsed back by Compact and have no parsing
+// rules. A nil f restores the text format. It may be called before or
+// after Init.
+func SetFormatter(f EntryFormatter) {
+	custom = f
+	format = CustomFormat
+	if f == nil {
+		format = TextFormat
+	}
+	log.SetFormatter(activeFormatter())
+}
+
+// SetFormat selects the format of every entry written from now on, text,
+// json, json-flat, cef, leef, rfc5424 or console. It may be called before or after Init, which keeps
+// the selected format.
+func SetFormat(name string) error {
+	if _, err := parseFormat(name); err != nil {
+		return err
+	}
+	format = name
+	log.SetFormatter(activeFormatter())
+	return nil
+}
+
+// parseFormat validates a format name, the empty name standing for text.
+func parseFormat(name string) (string, error) {
+	switch name {
+	case "":
+		return TextFormat, nil
+	case TextFormat, JSONFormat, FlatJSONFormat, CEFFormat, LEEFFormat, RFC5424Format, ConsoleFormat:
+		return name, nil
+	case CustomFormat:
+		if custom != nil {
+			return name, nil
+		}
+		return TextFormat, errors.New("custom format: no formatter set with SetFormatter")
+	}
+	return TextFormat, fmt.Errorf("not a valid format: %q", name)
+}
+
+// activeFormatter returns the logrus formatter of the selected format.
+func activeFormatter() log.Formatter {
+	return formatterFor(format)
+}
+
+// formatterFor returns the logrus formatter of the format name.
+func formatterFor(name string) log.Formatter {
+	switch name {
+	case JSONFormat:
+		return &JSONFormatter{}
+	case FlatJSONFormat:
+		return &JSONFormatter{Flat: true}
+	case CEFFormat:
+		return &CEFFormatter{}
+	case LEEFFormat:
+		return &LEEFFormatter{}
+	case RFC5424Format:
+		return &SyslogFormatter{StructuredData: true}
+	case ConsoleFormat:
+		return &ConsoleFormatter{Color: packageFormatter().Color}
+	case CustomFormat:
+		return customFormatter{custom}
+	}
+	return packageFormatter()
+}
+
+// customFormatter adapts an EntryFormatter to logrus.
+type customFormatter struct {
+	f EntryFormatter
+}
+
+func (c customFormatter) Format(entry *log.Entry) ([]byte, error) {
+	if t, ok := c.f.(*TemplateFormatter); ok {
+		// Keep the function of the caller, an Entry has none.
+		return t.execute(templateData(toEntry(entry), packageFormatter().caller(entryCaller(entry.Data)), entry.Level))
+	}
+	e := toEntry(entry)
+	return c.f.FormatEntry(&e)
+}
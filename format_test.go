@@ -0,0 +1,74 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetFormat(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	if err := SetFormat(JSONFormat); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFormat(TextFormat)
+	// Changing the caller style keeps the selected format.
+	SetCallerStyle(CallerShort, false)
+	defer SetCallerStyle(CallerFull, false)
+
+	Infow("login ok", "user_id", 42)
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output %q is not JSON: %v", buf.String(), err)
+	}
+	if got["msg"] != "login ok" || got["user_id"] != float64(42) {
+		t.Errorf("entry = %v, want msg and user_id", got)
+	}
+	if CurrentConfig().Format != JSONFormat {
+		t.Errorf("Config.Format = %q, want json", CurrentConfig().Format)
+	}
+
+	buf.Reset()
+	if err := SetFormat(TextFormat); err != nil {
+		t.Fatal(err)
+	}
+	Info("plain")
+	if !strings.Contains(buf.String(), ": INFO\t") {
+		t.Errorf("output %q is not in the text format", buf.String())
+	}
+
+	if err := SetFormat("xml"); err == nil {
+		t.Error("SetFormat(xml) = nil, want error")
+	}
+}
+
+func TestSetFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	SetFormatter(EntryFormatterFunc(func(e *Entry) ([]byte, error) {
+		return []byte(e.Level + "|" + e.Message + "|" + e.Fields["iface"].(string) + "\n"), nil
+	}))
+	defer SetFormatter(nil)
+	WithFields(Fields{"iface": "eth1"}).Warning("link flap")
+	if got := buf.String(); got != "warning|link flap|eth1\n" {
+		t.Errorf("output = %q", got)
+	}
+	if f := CurrentConfig().Format; f != CustomFormat {
+		t.Errorf("Config.Format = %q, want custom", f)
+	}
+	if _, err := ExportParsingRules(GrokRules); err == nil {
+		t.Error("ExportParsingRules succeeded for a custom format")
+	}
+
+	SetFormatter(nil)
+	if _, err := parseFormat(CustomFormat); err == nil {
+		t.Error("custom format accepted without a formatter")
+	}
+}
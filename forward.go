@@ -0,0 +1,400 @@
+package log
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Defaults of a Forwarder.
+const (
+	DefaultForwardQueue = 10000
+	// DefaultPriorityQueue is the capacity of the lane reserved for
+	// WARNING and more severe entries.
+	DefaultPriorityQueue = 1000
+	// DefaultPriorityWait is how long a WARNING or more severe entry waits
+	// for room when both lanes are full before it is dropped.
+	DefaultPriorityWait = 100 * time.Millisecond
+	forwardBatch        = 256
+	forwardDialTimeout  = 10 * time.Second
+	forwardMaxBackoff   = 30 * time.Second
+)
+
+// forwardSync ends a batch of a Forwarder; the Receiver answers forwardAck
+// once it ingested the lines before it.
+const (
+	forwardSync = "SYNC\n"
+	forwardAck  = "OK\n"
+)
+
+// interruptible makes the pending and future I/O on conn fail as soon as
+// ctx is done, until stop is called, so that closing a remote output does
+// not wait for a slow collector.
+func interruptible(ctx context.Context, conn net.Conn) (stop func() bool) {
+	return context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+}
+
+// waitPending waits until *pending is zero, done is closed or ctx is done.
+func waitPending(ctx context.Context, pending *int64, done chan struct{}, closed string) error {
+	for atomic.LoadInt64(pending) > 0 {
+		select {
+		case <-done:
+			return errors.New(closed)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// LoadMutualTLS returns a TLS configuration for mutually authenticated
+// forwarding: it presents the certificate in certFile and keyFile and
+// trusts only peers whose certificate is signed by a CA in caFile. The same
+// configuration serves both a Forwarder and Receiver.ServeTLS.
+func LoadMutualTLS(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ServeTLS is like ServeTCP but performs a TLS handshake on every
+// connection. Unless cfg says otherwise, clients must present a certificate
+// signed by one of cfg.ClientCAs.
+func (r *Receiver) ServeTLS(l net.Listener, cfg *tls.Config) error {
+	cfg = cfg.Clone()
+	if cfg.ClientAuth == tls.NoClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return r.ServeTCP(tls.NewListener(l, cfg))
+}
+
+// Forwarder streams entries as JSON lines to a Receiver of another process
+// over TLS. It is a logrus hook registered with AddForwarder; entries are
+// queued and sent by a background goroutine that reconnects with backoff,
+// so a slow or unreachable aggregator delays logging by DefaultPriorityWait
+// at most. Entries are delivered at least once: every batch ends with a
+// SYNC line the Receiver acknowledges once it ingested the batch, and a
+// batch that is not acknowledged is resent on the next connection. When
+// the queue is full, new entries are dropped and counted.
+//
+// WARNING, ERROR and FATAL entries travel in a priority lane that is sent
+// before anything else, so a flood of DEBUG entries neither delays nor
+// drops them. If the priority lane is full as well, logging waits up to
+// DefaultPriorityWait for room before the entry is dropped and counted.
+type Forwarder struct {
+	addr  string
+	cfg   *tls.Config
+	token string
+
+	format   JSONFormatter
+	queue    chan forwardItem
+	priority chan forwardItem
+	pending  int64
+	dropped  uint64
+	latency  int64
+	started  int64
+
+	// priorityWait bounds the wait of Fire for room in the priority lane.
+	priorityWait time.Duration
+
+	// ctx is cancelled by Close, aborting the connection in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type forwardItem struct {
+	line     []byte
+	enqueued time.Time
+}
+
+// NewForwarder returns a Forwarder sending to the Receiver at addr. If
+// token is not empty it is sent as the receiver's shared secret.
+func NewForwarder(addr string, cfg *tls.Config, token string) *Forwarder {
+	f := &Forwarder{
+		addr:         addr,
+		cfg:          cfg,
+		token:        token,
+		queue:        make(chan forwardItem, DefaultForwardQueue),
+		priority:     make(chan forwardItem, DefaultPriorityQueue),
+		priorityWait: DefaultPriorityWait,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	f.ctx, f.cancel = context.WithCancel(context.Background())
+	go f.run()
+	return f
+}
+
+// AddForwarder registers f with the package logger.
+func AddForwarder(f *Forwarder) {
+	addHook(f)
+	addFlusher(f)
+}
+
+// Name returns the address of the receiver.
+func (f *Forwarder) Name() string {
+	return "forward:" + f.addr
+}
+
+// Levels implements logrus.Hook.
+func (f *Forwarder) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook. It only waits, for up to
+// DefaultPriorityWait, for WARNING and more severe entries while both lanes
+// are full.
+func (f *Forwarder) Fire(entry *log.Entry) error {
+	line, err := f.format.Format(entry)
+	if err != nil {
+		return err
+	}
+	item := forwardItem{line, time.Now()}
+
+	atomic.AddInt64(&f.pending, 1)
+	if entry.Level <= log.WarnLevel {
+		select {
+		case f.priority <- item:
+			return nil
+		default:
+		}
+		select {
+		case f.queue <- item:
+			return nil
+		default:
+		}
+		t := time.NewTimer(f.priorityWait)
+		select {
+		case f.priority <- item:
+			t.Stop()
+			return nil
+		case <-t.C:
+		case <-f.done:
+			t.Stop()
+		}
+	} else {
+		select {
+		case f.queue <- item:
+			return nil
+		default:
+		}
+	}
+	atomic.AddInt64(&f.pending, -1)
+	atomic.AddUint64(&f.dropped, 1)
+	return nil
+}
+
+// QueueDepth implements QueueReporter.
+func (f *Forwarder) QueueDepth() int {
+	return len(f.priority) + len(f.queue)
+}
+
+// QueueLatency implements QueueReporter.
+func (f *Forwarder) QueueLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&f.latency))
+}
+
+// busySince implements busyReporter.
+func (f *Forwarder) busySince() time.Time {
+	return unixNano(atomic.LoadInt64(&f.started))
+}
+
+// Dropped returns the number of entries dropped because the queue was full.
+func (f *Forwarder) Dropped() uint64 {
+	return atomic.LoadUint64(&f.dropped)
+}
+
+// Flush waits until every queued entry has been sent.
+func (f *Forwarder) Flush() error {
+	return f.FlushContext(context.Background())
+}
+
+// FlushContext is Flush giving up when ctx is done.
+func (f *Forwarder) FlushContext(ctx context.Context) error {
+	return waitPending(ctx, &f.pending, f.done, "forwarder closed with entries pending")
+}
+
+// Close stops the forwarder, aborting a connection or a batch in flight.
+// Entries still queued are discarded; call Flush first to deliver them.
+func (f *Forwarder) Close() error {
+	f.closeOnce.Do(func() {
+		f.cancel()
+		close(f.stop)
+	})
+	<-f.done
+	return nil
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+
+	var conn net.Conn
+	var batch []forwardItem
+	failing := false
+	backoff := 100 * time.Millisecond
+	for {
+		if len(batch) == 0 {
+			select {
+			case item := <-f.priority:
+				batch = append(batch, item)
+			case item := <-f.queue:
+				batch = append(batch, item)
+			case <-f.stop:
+				if conn != nil {
+					conn.Close()
+				}
+				f.discard(nil, nil)
+				return
+			}
+		}
+		batch = f.fill(batch)
+
+		var err error
+		atomic.StoreInt64(&f.started, time.Now().UnixNano())
+		if conn == nil {
+			conn, err = f.dial()
+		}
+		if err == nil {
+			err = f.send(conn, batch)
+		}
+		atomic.StoreInt64(&f.started, 0)
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+				conn = nil
+			}
+			if !failing {
+				failing = true
+				reportError(fmt.Errorf("output %s: %v", f.Name(), err))
+			}
+			select {
+			case <-time.After(backoff):
+			case <-f.stop:
+				f.discard(batch, err)
+				return
+			}
+			if backoff *= 2; backoff > forwardMaxBackoff {
+				backoff = forwardMaxBackoff
+			}
+			continue
+		}
+
+		if failing {
+			failing = false
+			reportError(fmt.Errorf("output %s recovered", f.Name()))
+		}
+		backoff = 100 * time.Millisecond
+		now := time.Now()
+		for _, item := range batch {
+			atomic.StoreInt64(&f.latency, int64(now.Sub(item.enqueued)))
+		}
+		reportDelivery(DeliveryReport{Output: f.Name(), Delivered: len(batch)})
+		atomic.AddInt64(&f.pending, -int64(len(batch)))
+		batch = batch[:0]
+	}
+}
+
+// discard gives up on batch and the queued entries when the forwarder is
+// closed, reporting them as failed once.
+func (f *Forwarder) discard(batch []forwardItem, err error) {
+	n := len(batch)
+	for _, lane := range []chan forwardItem{f.priority, f.queue} {
+		for len(lane) > 0 {
+			<-lane
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	if err == nil {
+		err = errors.New("forwarder closed")
+	}
+	atomic.AddInt64(&f.pending, -int64(n))
+	reportDelivery(DeliveryReport{Output: f.Name(), Failed: n, Err: err})
+}
+
+// fill tops up batch with queued entries, taking the priority lane first.
+func (f *Forwarder) fill(batch []forwardItem) []forwardItem {
+	for _, lane := range []chan forwardItem{f.priority, f.queue} {
+		for len(batch) < forwardBatch {
+			select {
+			case item := <-lane:
+				batch = append(batch, item)
+				continue
+			default:
+			}
+			break
+		}
+	}
+	return batch
+}
+
+func (f *Forwarder) dial() (net.Conn, error) {
+	d := &tls.Dialer{NetDialer: &net.Dialer{Timeout: forwardDialTimeout}, Config: f.cfg}
+	conn, err := d.DialContext(f.ctx, "tcp", f.addr)
+	if err != nil {
+		return nil, err
+	}
+	if f.token != "" {
+		defer interruptible(f.ctx, conn)()
+		if _, err := fmt.Fprintf(conn, "AUTH %s\n", f.token); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (f *Forwarder) send(conn net.Conn, batch []forwardItem) error {
+	conn.SetWriteDeadline(time.Now().Add(forwardDialTimeout))
+	defer interruptible(f.ctx, conn)()
+	buf := make([]byte, 0, 512*len(batch))
+	for _, item := range batch {
+		buf = append(buf, item.line...)
+	}
+	buf = append(buf, forwardSync...)
+	if _, err := conn.Write(buf); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(forwardDialTimeout))
+	ack := make([]byte, len(forwardAck))
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("no acknowledgement: %v", err)
+	}
+	if string(ack) != forwardAck {
+		return fmt.Errorf("unexpected acknowledgement %q", ack)
+	}
+	return nil
+}
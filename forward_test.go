@@ -0,0 +1,295 @@
+package log
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// writeTestPKI writes a CA and a certificate signed by it for 127.0.0.1 to
+// dir, returning the paths of the certificate, its key and the CA.
+func writeTestPKI(t *testing.T, dir string) (certFile, keyFile, caFile string) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "probe"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name, typ string, b []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	return write("cert.pem", "CERTIFICATE", der), write("key.pem", "EC PRIVATE KEY", keyDER), write("ca.pem", "CERTIFICATE", caDER)
+}
+
+func TestForwarder(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	certFile, keyFile, caFile := writeTestPKI(t, t.TempDir())
+	cfg, err := LoadMutualTLS(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Receiver{Token: "s3cret"}
+	go r.ServeTLS(l, cfg)
+	defer r.Close()
+
+	f := NewForwarder(l.Addr().String(), cfg, "s3cret")
+	defer f.Close()
+	entry := log.WithFields(log.Fields{"iface": "eth1"})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = log.WarnLevel
+	entry.Message = "link flap"
+	if err := f.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "link flap") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := out.String()
+	for _, want := range []string{"2017-03-01T12:00:00Z", "WARNING", "link flap", "iface=eth1", "relayed=true"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+}
+
+func TestForwarderRejectsUnknownClient(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	dir := t.TempDir()
+	certFile, keyFile, caFile := writeTestPKI(t, dir)
+	serverCfg, err := LoadMutualTLS(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Receiver{}
+	go r.ServeTLS(l, serverCfg)
+	defer r.Close()
+
+	// The client trusts the server but presents no certificate.
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: serverCfg.RootCAs})
+	if err == nil {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if err == nil || err == io.EOF {
+		t.Fatalf("handshake without client certificate: err = %v, want certificate error", err)
+	}
+}
+
+func TestForwarderDropsWhenFull(t *testing.T) {
+	// Nothing listens on the address: entries stay queued.
+	f := NewForwarder("127.0.0.1:1", &tls.Config{}, "")
+	defer f.Close()
+
+	entry := log.WithFields(nil)
+	entry.Level = log.DebugLevel
+	for i := 0; i < 2*DefaultForwardQueue; i++ {
+		f.Fire(entry)
+	}
+	if f.Dropped() == 0 {
+		t.Error("Dropped() = 0, want entries dropped")
+	}
+}
+
+func TestForwarderPriorityLane(t *testing.T) {
+	f := NewForwarder("127.0.0.1:1", &tls.Config{}, "")
+	defer f.Close()
+
+	debug := log.WithFields(nil)
+	debug.Level = log.DebugLevel
+	for i := 0; i < 2*DefaultForwardQueue; i++ {
+		f.Fire(debug)
+	}
+	dropped := f.Dropped()
+
+	warn := log.WithFields(nil)
+	warn.Level = log.WarnLevel
+	for i := 0; i < DefaultPriorityQueue/2; i++ {
+		f.Fire(warn)
+	}
+	if f.Dropped() != dropped {
+		t.Errorf("%d WARNING entries dropped behind a full queue", f.Dropped()-dropped)
+	}
+
+	batch := f.fill(nil)
+	if len(batch) == 0 || !strings.Contains(string(batch[0].line), `"level":"warning"`) {
+		t.Errorf("first queued entry is not from the priority lane")
+	}
+}
+
+func TestForwarderPriorityLaneFull(t *testing.T) {
+	f := NewForwarder("127.0.0.1:1", &tls.Config{}, "")
+	defer f.Close()
+	f.priorityWait = 10 * time.Millisecond
+
+	warn := log.WithFields(nil)
+	warn.Level = log.WarnLevel
+	// The background goroutine holds a batch of its own while it fails to
+	// connect; fill the lanes until an entry is dropped.
+	for i := 0; i < 2*(DefaultPriorityQueue+DefaultForwardQueue) && f.Dropped() == 0; i++ {
+		f.Fire(warn)
+	}
+	dropped := f.Dropped()
+	if dropped == 0 {
+		t.Fatal("no WARNING entry dropped behind full lanes")
+	}
+	start := time.Now()
+	f.Fire(warn)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Fire blocked for %s behind full lanes", d)
+	}
+	if f.Dropped() != dropped+1 {
+		t.Errorf("Dropped() = %d, want %d", f.Dropped(), dropped+1)
+	}
+}
+
+func TestForwarderCloseAbortsHandshake(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+	l := silentListener(t)
+	defer l.Close()
+
+	f := NewForwarder(l.Addr().String(), &tls.Config{InsecureSkipVerify: true}, "")
+	entry := log.WithFields(log.Fields{})
+	entry.Level = log.InfoLevel
+	entry.Message = "started"
+	f.Fire(entry)
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	f.Close()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Close took %s waiting for the handshake", d)
+	}
+}
+
+func TestForwarderWaitsForAcknowledgement(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+	var reports []DeliveryReport
+	var mu sync.Mutex
+	SetDeliveryCallback(func(r DeliveryReport) {
+		mu.Lock()
+		reports = append(reports, r)
+		mu.Unlock()
+	})
+	defer SetDeliveryCallback(nil)
+
+	certFile, keyFile, caFile := writeTestPKI(t, t.TempDir())
+	cfg, err := LoadMutualTLS(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := tls.NewListener(raw, cfg)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// Read the batches but never acknowledge them.
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	f := NewForwarder(raw.Addr().String(), cfg, "")
+	entry := log.WithFields(log.Fields{})
+	entry.Level = log.InfoLevel
+	entry.Message = "started"
+	f.Fire(entry)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := f.FlushContext(ctx); err == nil {
+		t.Error("FlushContext succeeded without an acknowledgement")
+	}
+	f.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) != 1 || reports[0].Delivered != 0 || reports[0].Failed != 1 {
+		t.Errorf("delivery reports %+v, want a single failure of one entry", reports)
+	}
+}
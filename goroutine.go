@@ -0,0 +1,161 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// FieldExtractor returns fields to add to an entry logged by the calling
+// goroutine, e.g. tenant or request IDs kept in goroutine-local storage by
+// code without context.Context plumbing. It runs on every entry and must be
+// fast; fields passed with the entry take precedence.
+type FieldExtractor func() Fields
+
+var extractors = struct {
+	sync.Mutex
+	list atomic.Value // []FieldExtractor
+}{}
+
+// AddFieldExtractor registers fn to contribute fields to every entry.
+func AddFieldExtractor(fn FieldExtractor) {
+	extractors.Lock()
+	defer extractors.Unlock()
+
+	list, _ := extractors.list.Load().([]FieldExtractor)
+	extractors.list.Store(append(list[:len(list):len(list)], fn))
+}
+
+// extractFields adds the fields of all extractors to fields, returning the
+// possibly newly allocated map.
+func extractFields(fields log.Fields) log.Fields {
+	list, _ := extractors.list.Load().([]FieldExtractor)
+	for _, fn := range list {
+		for k, v := range fn() {
+			if fields == nil {
+				fields = make(log.Fields)
+			}
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+	}
+	return fields
+}
+
+var (
+	goroutineFields     sync.Map // goroutine ID -> Fields
+	goroutineFieldsOnce sync.Once
+)
+
+// Do calls f with fields attached to every entry the calling goroutine logs
+// until f returns. The fields are also set as pprof labels, so they show up
+// in CPU profiles; goroutines started by f inherit the labels but not the
+// fields.
+func Do(ctx context.Context, fields Fields, f func(context.Context)) {
+	goroutineFieldsOnce.Do(func() {
+		AddFieldExtractor(func() Fields {
+			if v, ok := goroutineFields.Load(goroutineID()); ok {
+				return v.(Fields)
+			}
+			return nil
+		})
+	})
+
+	labels := make([]string, 0, 2*len(fields))
+	for k, v := range fields {
+		labels = append(labels, k, fmt.Sprint(v))
+	}
+
+	pprof.Do(ctx, pprof.Labels(labels...), func(ctx context.Context) {
+		id := goroutineID()
+		merged := Fields{}
+		if outer, ok := goroutineFields.Load(id); ok {
+			for k, v := range outer.(Fields) {
+				merged[k] = v
+			}
+			defer goroutineFields.Store(id, outer)
+		} else {
+			defer goroutineFields.Delete(id)
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		goroutineFields.Store(id, merged)
+
+		f(ctx)
+	})
+}
+
+// WorkerKey is the field naming the worker of entries logged through
+// WithWorker, GoroutineKey the field holding the goroutine ID once
+// SetGoroutineID enabled it.
+const (
+	WorkerKey    = "worker"
+	GoroutineKey = "goroutine"
+)
+
+// WithWorker returns a Logger labelling its entries with the worker name,
+// e.g. the loop of a worker pool, so that the interleaved entries of the
+// pool can be told apart:
+//
+//	l := log.WithWorker(fmt.Sprintf("rx-loop-%d", i))
+//	l.Go(func() { rxLoop(l) })
+//
+// The label is the worker field, written by the text and JSON formats like
+// other fields, and carried by the Loggers derived from the returned one.
+func WithWorker(name string) *Logger {
+	return &Logger{fields: Fields{WorkerKey: name}}
+}
+
+// WithWorker returns a child of l labelling its entries with the worker
+// name, replacing that of l, if any.
+func (l *Logger) WithWorker(name string) *Logger {
+	return &Logger{parent: l, fields: Fields{WorkerKey: name}}
+}
+
+var (
+	goroutineIDs     int32
+	goroutineIDsOnce sync.Once
+)
+
+// SetGoroutineID adds the goroutine field, the ID of the goroutine logging
+// the entry as printed in stack traces, to every entry, e.g. to follow the
+// goroutines of a pool without labels. Reading the ID costs about a
+// microsecond per entry; WithWorker is cheaper and its labels are stable
+// across runs.
+func SetGoroutineID(on bool) {
+	goroutineIDsOnce.Do(func() {
+		AddFieldExtractor(func() Fields {
+			if atomic.LoadInt32(&goroutineIDs) == 0 {
+				return nil
+			}
+			return Fields{GoroutineKey: goroutineID()}
+		})
+	})
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&goroutineIDs, v)
+}
+
+// goroutineID returns the ID of the calling goroutine as printed in stack
+// traces.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
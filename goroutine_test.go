@@ -0,0 +1,90 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDoAttachesGoroutineFields(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	Do(context.Background(), Fields{"tenant": "acme"}, func(ctx context.Context) {
+		Do(ctx, Fields{"request_id": "r-1"}, func(context.Context) {
+			Info("nested")
+		})
+		Info("outer")
+	})
+	Info("outside")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "request_id=r-1") || !strings.Contains(lines[0], "tenant=acme") {
+		t.Errorf("nested entry lacks fields: %s", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") || !strings.Contains(lines[1], "tenant=acme") {
+		t.Errorf("outer entry has wrong fields: %s", lines[1])
+	}
+	if strings.Contains(lines[2], "tenant") {
+		t.Errorf("entry outside Do has fields: %s", lines[2])
+	}
+}
+
+func TestGoroutineID(t *testing.T) {
+	ids := make(chan uint64)
+	go func() { ids <- goroutineID() }()
+	if a, b := goroutineID(), <-ids; a == 0 || a == b {
+		t.Errorf("goroutineID() = %d in both goroutines (%d), want distinct non-zero IDs", a, b)
+	}
+}
+
+func TestWithWorker(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	pool := WithFields(Fields{"pool": "rx"})
+	pool.WithWorker("rx-loop-3").With("queue", 2).Info("burst")
+	WithWorker("rx-loop-1").WithWorker("rx-loop-4").Info("moved")
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+	WithWorker("tx-loop-0").Info("sent")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if !strings.HasSuffix(lines[0], "burst pool=rx queue=2 worker=rx-loop-3") {
+		t.Errorf("derived entry = %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "moved worker=rx-loop-4") {
+		t.Errorf("relabelled entry = %s", lines[1])
+	}
+	if !strings.Contains(lines[2], `"worker":"tx-loop-0"`) {
+		t.Errorf("JSON entry = %s", lines[2])
+	}
+}
+
+func TestSetGoroutineID(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	SetGoroutineID(true)
+	Info("with id")
+	SetGoroutineID(false)
+	Info("without id")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := "with id goroutine=" + strconv.FormatUint(goroutineID(), 10)
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], want) || strings.Contains(lines[1], "goroutine=") {
+		t.Errorf("wrote %q, want the goroutine ID on the first line only", buf.String())
+	}
+}
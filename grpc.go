@@ -0,0 +1,200 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Fields of the entries of the gRPC interceptors, which also have the
+// remote_addr and request_id fields of HTTPMiddleware.
+const (
+	GRPCMethodKey  = "grpc_method"
+	GRPCCodeKey    = "grpc_code"
+	GRPCLatencyKey = "grpc_latency"
+)
+
+// rpcLevels are the default levels of the gRPC status codes, those of
+// client mistakes being INFO.
+var rpcLevels = map[codes.Code]log.Level{
+	codes.OK:                 log.InfoLevel,
+	codes.Canceled:           log.InfoLevel,
+	codes.Unknown:            log.ErrorLevel,
+	codes.InvalidArgument:    log.InfoLevel,
+	codes.DeadlineExceeded:   log.WarnLevel,
+	codes.NotFound:           log.InfoLevel,
+	codes.AlreadyExists:      log.InfoLevel,
+	codes.PermissionDenied:   log.WarnLevel,
+	codes.ResourceExhausted:  log.WarnLevel,
+	codes.FailedPrecondition: log.WarnLevel,
+	codes.Aborted:            log.WarnLevel,
+	codes.OutOfRange:         log.WarnLevel,
+	codes.Unimplemented:      log.ErrorLevel,
+	codes.Internal:           log.ErrorLevel,
+	codes.Unavailable:        log.WarnLevel,
+	codes.DataLoss:           log.ErrorLevel,
+	codes.Unauthenticated:    log.InfoLevel,
+}
+
+// RPCLog configures the log of gRPC calls, the counterpart of AccessLog.
+type RPCLog struct {
+	// Fields lists the fields of the entries, among grpc_method,
+	// grpc_code, grpc_latency, remote_addr, request_id and error. nil
+	// means all of them.
+	Fields []string
+	// Levels maps status codes to the level of the entries, overriding
+	// the default: ERROR for Unknown, Unimplemented, Internal and
+	// DataLoss, WARNING for DeadlineExceeded, PermissionDenied,
+	// ResourceExhausted, FailedPrecondition, Aborted, OutOfRange and
+	// Unavailable, INFO for the others.
+	Levels map[codes.Code]string
+}
+
+// UnaryServerInterceptor logs an entry per unary call with the default
+// RPCLog:
+//
+//	grpc.NewServer(
+//		grpc.UnaryInterceptor(log.UnaryServerInterceptor()),
+//		grpc.StreamInterceptor(log.StreamServerInterceptor()),
+//	)
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return (&RPCLog{}).UnaryServerInterceptor()
+}
+
+// StreamServerInterceptor logs an entry per streaming call with the
+// default RPCLog.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return (&RPCLog{}).StreamServerInterceptor()
+}
+
+// UnaryServerInterceptor logs an entry "<method> <code>" per unary call
+// once the handler returns, with the fields grpc_method, grpc_code,
+// grpc_latency, the time the handler took, remote_addr, the address of
+// the peer, request_id, error, the message of the status if the call
+// failed, and those of the call context, see NewContext. The request ID is that of the x-request-id metadata if
+// valid, a new one from the ID generator otherwise; it is sent back in the
+// header metadata and stored with the method in the context of the
+// handler, so that the entries it logs through FromContext or InfoCtx and
+// the like carry them. Calls are logged at the level of their status
+// code, see Levels. An invalid level in Levels is reported and the
+// default kept.
+func (c *RPCLog) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	rl := c.compile()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, id := rpcContext(ctx, info.FullMethod)
+		grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+		resp, err := handler(ctx, req)
+		rl.log(ctx, info.FullMethod, id, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs an entry per streaming call like
+// UnaryServerInterceptor, once the stream ends.
+func (c *RPCLog) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	rl := c.compile()
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, id := rpcContext(ss.Context(), info.FullMethod)
+		ss.SetHeader(metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		rl.log(ctx, info.FullMethod, id, start, err)
+		return err
+	}
+}
+
+// rpcLogger is an RPCLog with its levels parsed.
+type rpcLogger struct {
+	levels map[codes.Code]log.Level
+	keep   map[string]bool
+}
+
+func (c *RPCLog) compile() *rpcLogger {
+	rl := &rpcLogger{levels: make(map[codes.Code]log.Level, len(rpcLevels))}
+	for code, lvl := range rpcLevels {
+		rl.levels[code] = lvl
+	}
+	for code, name := range c.Levels {
+		lvl, err := parseLevel(name)
+		if err != nil {
+			reportError(fmt.Errorf("rpc log: %v", err))
+			continue
+		}
+		rl.levels[code] = lvl
+	}
+	if c.Fields != nil {
+		rl.keep = make(map[string]bool, len(c.Fields))
+		for _, k := range c.Fields {
+			rl.keep[k] = true
+		}
+	}
+	return rl
+}
+
+// rpcContext returns the context of the handler of a call of method, with
+// its request ID.
+func rpcContext(ctx context.Context, method string) (context.Context, string) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDHeader); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if !validCorrelationID(id) {
+		id = NewID()
+	}
+	return NewContext(ctx, Fields{RequestIDKey: id, GRPCMethodKey: method}), id
+}
+
+// log writes the entry of a call of method that ended with err.
+func (rl *rpcLogger) log(ctx context.Context, method, id string, start time.Time, err error) {
+	code := status.Code(err)
+	level, ok := rl.levels[code]
+	if !ok {
+		level = log.ErrorLevel
+	}
+	if !enabled(level) {
+		return
+	}
+	addr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	fields := ctxData(ctx)
+	for k, v := range map[string]interface{}{
+		GRPCMethodKey:  method,
+		GRPCCodeKey:    code.String(),
+		GRPCLatencyKey: time.Since(start),
+		RemoteAddrKey:  addr,
+		RequestIDKey:   id,
+	} {
+		if rl.keep == nil || rl.keep[k] {
+			fields[k] = v
+		} else {
+			delete(fields, k)
+		}
+	}
+	if err != nil && (rl.keep == nil || rl.keep["error"]) {
+		fields["error"] = status.Convert(err).Message()
+	}
+	emit(level, captureCaller(level, 1), method+" "+code.String(), fields)
+}
+
+// contextStream is a ServerStream with the context of the handler.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,96 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStream is a ServerStream with a context and nothing else.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func (s *fakeStream) SetHeader(md metadata.MD) error {
+	s.header = md
+	return nil
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5000}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-request-id", "req-1"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/probe.Flows/Get"}
+	intercept := UnaryServerInterceptor()
+
+	resp, err := intercept(ctx, "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		FromContext(ctx).Info("looking up")
+		return "resp", nil
+	})
+	if resp != "resp" || err != nil {
+		t.Fatalf("interceptor = %v, %v, want the handler result", resp, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "request_id=req-1") || !strings.Contains(lines[0], "grpc_method=/probe.Flows/Get") {
+		t.Fatalf("output = %q, want the handler entry with the request scoped fields, then the call", buf.String())
+	}
+	for _, want := range []string{"INFO", "/probe.Flows/Get OK", "grpc_code=OK", "grpc_latency=", "remote_addr=192.0.2.1:5000", "grpc.go:"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("call entry is missing %q: %s", want, lines[1])
+		}
+	}
+
+	buf.Reset()
+	intercept(ctx, "req", info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Internal, "database gone")
+	})
+	if out := buf.String(); !strings.Contains(out, "ERROR") || !strings.Contains(out, "grpc_code=Internal") || !strings.Contains(out, `error="database gone"`) {
+		t.Errorf("output = %q, want an error with the status message", out)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	rl := &RPCLog{
+		Fields: []string{GRPCCodeKey, RequestIDKey},
+		Levels: map[codes.Code]string{codes.NotFound: "warning"},
+	}
+	ss := &fakeStream{ctx: context.Background()}
+	var id interface{}
+	err := rl.StreamServerInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: "/probe.Flows/Watch"}, func(srv interface{}, ss grpc.ServerStream) error {
+		id = ContextFields(ss.Context())[RequestIDKey]
+		return status.Error(codes.NotFound, "no such flow")
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("interceptor = %v, want the handler error", err)
+	}
+	if id == nil || ss.header.Get("x-request-id")[0] != id {
+		t.Errorf("request ID = %v, header %v, want a new ID sent back", id, ss.header)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "WARNING") || !strings.Contains(out, "grpc_code=NotFound") || !strings.Contains(out, "request_id=") {
+		t.Errorf("output = %q, want a warning with the code and the request ID", out)
+	}
+	if strings.Contains(out, "grpc_latency") || strings.Contains(out, "error=") {
+		t.Errorf("output = %q has fields left out", out)
+	}
+}
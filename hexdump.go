@@ -0,0 +1,76 @@
+package log
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Fields of HexDump and Packet.
+const (
+	// BytesKey is the length of the dumped data.
+	BytesKey = "bytes"
+	// OmittedBytesKey is the number of bytes Packet left out of the dump.
+	OmittedBytesKey = "omitted_bytes"
+)
+
+// HexDump logs data at level as a canonical hex dump below label, 16 bytes
+// a line with their offset and their printable characters,
+//
+//	rx frame
+//	00000000  45 00 00 54 a8 3b 40 00  40 01 8e 5e c0 a8 01 02  |E..T.;@.@..^....|
+//
+// with the length of data in the field bytes. The lines are shaped by the
+// message policy, e.g. split into an entry each with SplitNewlines. Nothing
+// is formatted nor allocated unless entries at level are enabled. An
+// invalid level is reported to the self-log.
+func HexDump(level, label string, data []byte) {
+	if lvl, ok := dumpLevel(level, label); ok && enabled(lvl) {
+		hexDump(lvl, captureCaller(lvl, 1), label, data, len(data))
+	}
+}
+
+// Packet is HexDump for packet payloads, dumping at most the first
+// maxBytes bytes of data unless maxBytes is zero or negative, e.g. the
+// headers of a frame. The number of bytes left out is in the field
+// omitted_bytes.
+func Packet(level, label string, data []byte, maxBytes int) {
+	if lvl, ok := dumpLevel(level, label); ok && enabled(lvl) {
+		n := len(data)
+		if maxBytes > 0 && n > maxBytes {
+			data = data[:maxBytes]
+		}
+		hexDump(lvl, captureCaller(lvl, 1), label, data, n)
+	}
+}
+
+// dumpLevel parses the level of a dump.
+func dumpLevel(level, label string) (log.Level, bool) {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		reportError(fmt.Errorf("hex dump %q: %v", label, err))
+		return 0, false
+	}
+	return lvl, true
+}
+
+// hexDump logs the dump of data, the first bytes of n, from site.
+func hexDump(level log.Level, site callSite, label string, data []byte, n int) {
+	var b strings.Builder
+	// A line of the dump is 79 bytes with its newline.
+	b.Grow(len(label) + 1 + (len(data)+15)/16*79)
+	b.WriteString(label)
+	if len(data) > 0 {
+		b.WriteString("\n")
+		d := hex.Dumper(&b)
+		d.Write(data)
+		d.Close()
+	}
+	fields := log.Fields{BytesKey: n}
+	if n > len(data) {
+		fields[OmittedBytesKey] = n - len(data)
+	}
+	emit(level, site, strings.TrimSuffix(b.String(), "\n"), withStack(level, 1, fields))
+}
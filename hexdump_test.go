@@ -0,0 +1,58 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHexDump(t *testing.T) {
+	var out bytes.Buffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+
+	HexDump("info", "rx frame", []byte("E\x00\x00\x54GET / HTTP/1.1\r\n"))
+	got := out.String()
+	for _, want := range []string{
+		"hexdump_test.go:",
+		"rx frame\n00000000  45 00 00 54 47 45 54 20  2f 20 48 54 54 50 2f 31  |E..TGET / HTTP/1|\n",
+		"00000010  2e 31 0d 0a                                       |.1..| bytes=20\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("dump %q does not contain %q", got, want)
+		}
+	}
+
+	out.Reset()
+	Packet("info", "tx", bytes.Repeat([]byte{0xff}, 40), 16)
+	if got := out.String(); !strings.HasSuffix(got, "tx\n00000000  ff ff ff ff ff ff ff ff  ff ff ff ff ff ff ff ff  |................| bytes=40 omitted_bytes=24\n") {
+		t.Errorf("packet = %q", got)
+	}
+}
+
+func TestHexDumpSplit(t *testing.T) {
+	var out bytes.Buffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	defer SetMessagePolicy(MessagePolicy{})
+	SetMessagePolicy(MessagePolicy{Newlines: SplitNewlines})
+
+	Packet("warning", "rx", make([]byte, 20), 0)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "rx bytes=20 part=1/3") || !strings.Contains(lines[2], "00000010  00 00 00 00") {
+		t.Errorf("split dump = %q", out.String())
+	}
+}
+
+func TestHexDumpDisabled(t *testing.T) {
+	defer SetLevel(getLevel().String())
+	SetLevel("info")
+	data := make([]byte, 64)
+	if n := testing.AllocsPerRun(100, func() {
+		HexDump("debug", "rx", data)
+		Packet("debug", "rx", data, 16)
+	}); n != 0 {
+		t.Errorf("disabled dumps allocate %v times", n)
+	}
+}
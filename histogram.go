@@ -0,0 +1,173 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// histogramBuckets is the number of power-of-two latency buckets, starting
+// at one microsecond. The last bucket also collects everything above it.
+const histogramBuckets = 32
+
+// LatencyHistogram aggregates request latencies and logs a percentile summary
+// every interval instead of one line per request. Durations are recorded
+// with Observe or, since the histogram is also a logrus hook, taken from the
+// designated field of every entry that carries it.
+type LatencyHistogram struct {
+	name  string
+	field string
+
+	mu      sync.Mutex
+	buckets [histogramBuckets]uint64
+	count   uint64
+	max     time.Duration
+	stopped bool
+
+	stop chan struct{}
+}
+
+// histogramSummary is a point-in-time view of a LatencyHistogram.
+type histogramSummary struct {
+	count         uint64
+	p50, p90, p99 time.Duration
+	max           time.Duration
+}
+
+// NewLatencyHistogram creates a histogram named name that reads durations
+// from field and logs a summary at severity INFO every interval. The
+// histogram is registered as a hook on the package logger.
+func NewLatencyHistogram(name, field string, interval time.Duration) *LatencyHistogram {
+	h := &LatencyHistogram{
+		name:  name,
+		field: field,
+		stop:  make(chan struct{}),
+	}
+	addHook(h)
+	go h.run(interval)
+	return h
+}
+
+// Observe records a single latency.
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		return
+	}
+	h.buckets[bucketFor(d)]++
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Stop stops periodic summaries, unregisters the hook and ignores further
+// observations. A final summary is logged for anything recorded since the
+// last interval.
+func (h *LatencyHistogram) Stop() {
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		return
+	}
+	h.stopped = true
+	h.mu.Unlock()
+
+	removeHook(h)
+	close(h.stop)
+}
+
+// Levels implements logrus.Hook.
+func (h *LatencyHistogram) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (h *LatencyHistogram) Fire(entry *log.Entry) error {
+	if d, ok := entry.Data[h.field].(time.Duration); ok {
+		h.Observe(d)
+	}
+	return nil
+}
+
+func (h *LatencyHistogram) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.report()
+		case <-h.stop:
+			h.report()
+			return
+		}
+	}
+}
+
+// report logs and resets the current summary. It must not be called with
+// h.mu held, since logging fires the hook again.
+func (h *LatencyHistogram) report() {
+	s, ok := h.reset()
+	if !ok {
+		return
+	}
+	Infof("latency %s: count=%d p50=%s p90=%s p99=%s max=%s", h.name, s.count, s.p50, s.p90, s.p99, s.max)
+}
+
+// reset returns the summary of the current interval and clears it. ok is
+// false when nothing was observed.
+func (h *LatencyHistogram) reset() (s histogramSummary, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		return s, false
+	}
+	s = histogramSummary{
+		count: h.count,
+		p50:   h.percentile(0.50),
+		p90:   h.percentile(0.90),
+		p99:   h.percentile(0.99),
+		max:   h.max,
+	}
+	h.buckets = [histogramBuckets]uint64{}
+	h.count = 0
+	h.max = 0
+	return s, true
+}
+
+// percentile returns the upper bound of the bucket containing the p-th
+// percentile, capped at the largest observation. The caller must hold h.mu.
+func (h *LatencyHistogram) percentile(p float64) time.Duration {
+	rank := uint64(p*float64(h.count) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			if upper := bucketUpper(i); upper < h.max {
+				return upper
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+func bucketFor(d time.Duration) int {
+	i := 0
+	for upper := time.Microsecond; d > upper && i < histogramBuckets-1; upper *= 2 {
+		i++
+	}
+	return i
+}
+
+func bucketUpper(i int) time.Duration {
+	return time.Microsecond << uint(i)
+}
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestLatencyHistogramPercentiles(t *testing.T) {
+	h := &LatencyHistogram{stop: make(chan struct{})}
+	for i := 1; i <= 100; i++ {
+		h.Observe(time.Duration(i) * time.Millisecond)
+	}
+
+	s, ok := h.reset()
+	if !ok {
+		t.Fatal("reset() reported no observations")
+	}
+	if s.count != 100 {
+		t.Errorf("count = %d, want 100", s.count)
+	}
+	if s.max != 100*time.Millisecond {
+		t.Errorf("max = %s, want 100ms", s.max)
+	}
+	if s.p50 < 50*time.Millisecond || s.p50 > s.p99 || s.p99 > s.max {
+		t.Errorf("percentiles out of order: p50=%s p99=%s max=%s", s.p50, s.p99, s.max)
+	}
+	if _, ok := h.reset(); ok {
+		t.Error("reset() did not clear the histogram")
+	}
+}
+
+func TestLatencyHistogramStop(t *testing.T) {
+	h := NewLatencyHistogram("rpc", "latency", time.Hour)
+	h.Stop()
+	h.Stop()
+
+	list, _ := hooks.list.Load().([]registeredHook)
+	for _, r := range list {
+		if r.h == log.Hook(h) {
+			t.Error("the hook of the stopped histogram is still registered")
+		}
+	}
+}
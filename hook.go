@@ -0,0 +1,157 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Hook processes the entries of the package logger before they are
+// written, e.g. to forward errors to an error tracker, count entries per
+// level in metrics or scrub fields.
+type Hook interface {
+	// Levels returns the levels the hook fires for, as accepted by
+	// SetLevel; nil means every level.
+	Levels() []string
+	// Fire is called for every entry at one of the levels, one entry at a
+	// time: it must be fast and must not log. Changes to the
+	// message, tag and fields of e are written, and seen by the hooks
+	// added later. An error is reported to stderr and does not stop the
+	// entry.
+	Fire(e *Entry) error
+}
+
+// AddHook adds h to the hooks of the package logger until remove is
+// called. Hooks fire in the order they are added, after the level,
+// sampling and rate limit have kept the entry. Loggers created by New have
+// no hooks.
+func AddHook(h Hook) (remove func(), err error) {
+	var levels []log.Level
+	for _, name := range h.Levels() {
+		lvl, err := parseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("hook %s: %v", outputName(h), err)
+		}
+		levels = append(levels, lvl)
+	}
+	if levels == nil {
+		levels = log.AllLevels
+	}
+	a := &hookAdapter{h: h, levels: levels}
+	addHook(a)
+	return func() {
+		atomic.StoreInt32(&a.removed, 1)
+		removeHook(a)
+	}, nil
+}
+
+// hooks holds the logrus hooks of the package logger. logrus cannot remove
+// a hook, so a single one, hookList, fires them in the order they were
+// added from a list replaced on every change.
+var hooks struct {
+	sync.Mutex
+	list atomic.Value // []registeredHook
+	once sync.Once
+	// fire serializes hookList.Fire: logrus fires hooks with its logger
+	// locked, but its mutex is unexported and renderEntry fires them for
+	// the entries the package writes itself.
+	fire sync.Mutex
+}
+
+// registeredHook is a hook of the list with the levels it fires for.
+type registeredHook struct {
+	h      log.Hook
+	levels uint64
+}
+
+// addHook adds h to the hooks of the package logger until removeHook(h).
+func addHook(h log.Hook) {
+	hooks.once.Do(func() { log.AddHook(hookList{}) })
+	r := registeredHook{h: h}
+	for _, lvl := range h.Levels() {
+		r.levels |= 1 << uint(lvl)
+	}
+	hooks.Lock()
+	list, _ := hooks.list.Load().([]registeredHook)
+	hooks.list.Store(append(list[:len(list):len(list)], r))
+	hooks.Unlock()
+}
+
+// removeHook removes h from the hooks of the package logger. Entries being
+// logged meanwhile may still reach it.
+func removeHook(h log.Hook) {
+	hooks.Lock()
+	list, _ := hooks.list.Load().([]registeredHook)
+	kept := make([]registeredHook, 0, len(list))
+	for _, r := range list {
+		if r.h != h {
+			kept = append(kept, r)
+		}
+	}
+	hooks.list.Store(kept)
+	hooks.Unlock()
+}
+
+// hookList is the logrus hook firing the hooks of the package logger.
+type hookList struct{}
+
+func (hookList) Levels() []log.Level {
+	return allLevels
+}
+
+// Fire fires the hooks for the level of entry, stopping at the first
+// error like logrus.
+func (hookList) Fire(entry *log.Entry) error {
+	hooks.fire.Lock()
+	defer hooks.fire.Unlock()
+	list, _ := hooks.list.Load().([]registeredHook)
+	for _, r := range list {
+		if r.levels&(1<<uint(entry.Level)) == 0 {
+			continue
+		}
+		if err := r.h.Fire(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hookAdapter runs a Hook as a logrus hook.
+type hookAdapter struct {
+	h       Hook
+	levels  []log.Level
+	removed int32
+}
+
+func (a *hookAdapter) Levels() []log.Level {
+	return a.levels
+}
+
+func (a *hookAdapter) Fire(entry *log.Entry) error {
+	if atomic.LoadInt32(&a.removed) != 0 {
+		return nil
+	}
+	e := toEntry(entry)
+	if e.Fields == nil {
+		e.Fields = Fields{}
+	}
+	err := a.h.Fire(&e)
+
+	entry.Message = e.Message
+	for k := range entry.Data {
+		if _, ok := e.Fields[k]; !ok && !isReserved(k) {
+			delete(entry.Data, k)
+		}
+	}
+	for k, v := range e.Fields {
+		if !isReserved(k) {
+			entry.Data[k] = v
+		}
+	}
+	if e.Tag != tag {
+		entry.Data[TagKey] = e.Tag
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+type scrubHook struct {
+	fired []string
+}
+
+func (h *scrubHook) Levels() []string { return []string{"error", "warn"} }
+
+func (h *scrubHook) Fire(e *Entry) error {
+	h.fired = append(h.fired, e.Level+" "+e.Message)
+	delete(e.Fields, "password")
+	e.Fields["scrubbed"] = true
+	e.Message = strings.ToUpper(e.Message)
+	return nil
+}
+
+func TestAddHook(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	h := &scrubHook{}
+	remove, err := AddHook(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	With("password", "hunter2", "user", "alice").Error("login failed")
+	Info("not hooked")
+	Warning("no fields")
+
+	out := buf.String()
+	if !strings.Contains(out, "LOGIN FAILED scrubbed=true user=alice\n") {
+		t.Errorf("output = %q, want the entry changed by the hook", out)
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("output = %q, want the field removed", out)
+	}
+	if !strings.Contains(out, "not hooked\n") || !strings.Contains(out, "NO FIELDS scrubbed=true\n") {
+		t.Errorf("output = %q, want only error and warning entries hooked", out)
+	}
+	if len(h.fired) != 2 || h.fired[0] != "error login failed" || h.fired[1] != "warning no fields" {
+		t.Errorf("hook fired for %q", h.fired)
+	}
+
+	remove()
+	Error("after removal")
+	if len(h.fired) != 2 || !strings.Contains(buf.String(), "after removal\n") {
+		t.Errorf("removed hook fired: %q", h.fired)
+	}
+}
+
+type badLevelsHook struct{}
+
+func (badLevelsHook) Levels() []string    { return []string{"loud"} }
+func (badLevelsHook) Fire(e *Entry) error { return nil }
+
+func TestAddHookInvalidLevel(t *testing.T) {
+	if _, err := AddHook(badLevelsHook{}); err == nil {
+		t.Error("AddHook accepted an invalid level")
+	}
+}
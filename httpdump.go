@@ -0,0 +1,98 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// MaxDumpBody is the number of body bytes included by DumpRequest and
+// DumpResponse.
+const MaxDumpBody = 4096
+
+// redactedHeaders are replaced by a placeholder in HTTP dumps.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// DumpRequest logs r with severity DEBUG as the fields http_method, http_url,
+// http_proto, one header.<Name> field per header and, if body is true,
+// http_body holding up to MaxDumpBody bytes of the body. Credentials such as
+// the Authorization and Cookie headers and the password of the URL are
+// redacted. The body remains readable by the caller.
+func DumpRequest(r *http.Request, body bool) {
+	if !enabled(log.DebugLevel) {
+		return
+	}
+
+	fields := log.Fields{
+		"http_method": r.Method,
+		"http_url":    r.URL.Redacted(),
+		"http_proto":  r.Proto,
+	}
+	headerFields(fields, r.Header)
+	if body && r.Body != nil {
+		fields["http_body"], r.Body = peekBody(r.Body)
+	}
+	output(log.DebugLevel, "http request", fields)
+}
+
+// DumpResponse logs resp with severity DEBUG like DumpRequest, with the
+// fields http_status and http_proto instead of the method and URL.
+func DumpResponse(resp *http.Response, body bool) {
+	if !enabled(log.DebugLevel) {
+		return
+	}
+
+	fields := log.Fields{
+		"http_status": resp.StatusCode,
+		"http_proto":  resp.Proto,
+	}
+	if resp.Request != nil {
+		fields["http_method"] = resp.Request.Method
+		fields["http_url"] = resp.Request.URL.Redacted()
+	}
+	headerFields(fields, resp.Header)
+	if body && resp.Body != nil {
+		fields["http_body"], resp.Body = peekBody(resp.Body)
+	}
+	output(log.DebugLevel, "http response", fields)
+}
+
+func headerFields(fields log.Fields, h http.Header) {
+	for name, values := range h {
+		v := strings.Join(values, ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			v = "[REDACTED]"
+		}
+		fields["header."+name] = v
+	}
+}
+
+// peekBody reads up to MaxDumpBody bytes of body and returns them together
+// with a replacement body that yields the complete original content.
+func peekBody(body io.ReadCloser) (string, io.ReadCloser) {
+	prefix, err := io.ReadAll(io.LimitReader(body, MaxDumpBody+1))
+	rest := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), body), body}
+
+	s := string(prefix)
+	if len(prefix) > MaxDumpBody {
+		s = string(prefix[:MaxDumpBody]) + "... (truncated)"
+	}
+	if err != nil {
+		s += " (read error: " + strconv.Quote(err.Error()) + ")"
+	}
+	return s, rest
+}
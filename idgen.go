@@ -0,0 +1,207 @@
+package log
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator makes the IDs of correlated entries: the correlation IDs of
+// CorrelationMiddleware and the flow IDs of NewFlow. IDs must be unique;
+// those of UUIDv7, ULID and Snowflake also sort by creation time.
+// Implementations are called concurrently.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to an IDGenerator, e.g. to keep an
+// existing convention:
+//
+//	log.SetIDGenerator(log.IDGeneratorFunc(ids.NextRequestID))
+type IDGeneratorFunc func() string
+
+// NewID calls f.
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// idGenerator holds the generator set with SetIDGenerator, wrapped as an
+// atomic.Value requires a single concrete type.
+var idGenerator atomic.Value // idGeneratorHolder
+
+type idGeneratorHolder struct {
+	g IDGenerator
+}
+
+// SetIDGenerator makes g the generator of correlation and flow IDs. nil
+// restores the default, UUIDv7.
+func SetIDGenerator(g IDGenerator) {
+	idGenerator.Store(idGeneratorHolder{g})
+}
+
+// NewID returns an ID from the generator set with SetIDGenerator.
+func NewID() string {
+	if h, _ := idGenerator.Load().(idGeneratorHolder); h.g != nil {
+		return h.g.NewID()
+	}
+	return defaultIDGenerator.NewID()
+}
+
+var defaultIDGenerator = UUIDv7()
+
+// randomBytes fills b from crypto/rand. A failure is reported and leaves
+// the IDs unique by their time part only.
+func randomBytes(b []byte) {
+	if _, err := crand.Read(b); err != nil {
+		reportError(fmt.Errorf("generate ID: %v", err))
+	}
+}
+
+// UUIDv7 returns a generator of RFC 9562 version 7 UUIDs, a millisecond
+// timestamp followed by random bits, in the canonical hyphenated form.
+func UUIDv7() IDGenerator {
+	return IDGeneratorFunc(func() string {
+		var u [16]byte
+		binary.BigEndian.PutUint64(u[:8], uint64(time.Now().UnixNano()/int64(time.Millisecond))<<16)
+		randomBytes(u[6:])
+		u[6] = u[6]&0x0f | 0x70
+		u[8] = u[8]&0x3f | 0x80
+
+		var s [36]byte
+		hex.Encode(s[0:8], u[0:4])
+		hex.Encode(s[9:13], u[4:6])
+		hex.Encode(s[14:18], u[6:8])
+		hex.Encode(s[19:23], u[8:10])
+		hex.Encode(s[24:], u[10:])
+		s[8], s[13], s[18], s[23] = '-', '-', '-', '-'
+		return string(s[:])
+	})
+}
+
+// crockford is the base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a generator of ULIDs, a millisecond timestamp followed by
+// 80 random bits in 26 characters of Crockford's base32.
+func ULID() IDGenerator {
+	return IDGeneratorFunc(func() string {
+		var u [16]byte
+		binary.BigEndian.PutUint64(u[:8], uint64(time.Now().UnixNano()/int64(time.Millisecond))<<16)
+		randomBytes(u[6:])
+
+		hi := binary.BigEndian.Uint64(u[:8])
+		lo := binary.BigEndian.Uint64(u[8:])
+		var s [26]byte
+		for i := len(s) - 1; i >= 0; i-- {
+			s[i] = crockford[lo&31]
+			lo = lo>>5 | hi<<59
+			hi >>= 5
+		}
+		return string(s[:])
+	})
+}
+
+// SnowflakeEpoch is the time Snowflake IDs count from.
+var SnowflakeEpoch = time.Date(2010, 11, 4, 1, 42, 54, 657000000, time.UTC)
+
+// Snowflake returns a generator of Twitter's snowflake IDs, 41 bits of
+// milliseconds since SnowflakeEpoch, the 10 bits of node, which must be
+// unique among the processes generating IDs, and a 12 bits sequence, in
+// decimal. It generates up to 4096 IDs per millisecond and waits for the
+// next one beyond.
+func Snowflake(node int) (IDGenerator, error) {
+	if node < 0 || node > 1023 {
+		return nil, fmt.Errorf("snowflake node %d out of the range 0-1023", node)
+	}
+	s := &snowflake{node: int64(node)}
+	return IDGeneratorFunc(s.next), nil
+}
+
+type snowflake struct {
+	mu       sync.Mutex
+	node     int64
+	last     int64
+	sequence int64
+}
+
+func (s *snowflake) next() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := int64(time.Since(SnowflakeEpoch) / time.Millisecond)
+	// A clock stepping back must not repeat IDs.
+	if ms < s.last {
+		ms = s.last
+	}
+	if ms == s.last {
+		s.sequence = (s.sequence + 1) & 4095
+		if s.sequence == 0 {
+			for ms <= s.last {
+				time.Sleep(100 * time.Microsecond)
+				ms = int64(time.Since(SnowflakeEpoch) / time.Millisecond)
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.last = ms
+	return strconv.FormatInt(ms<<22|s.node<<12|s.sequence, 10)
+}
+
+// CorrelationIDKey is the field holding the correlation ID of a request,
+// see CorrelationMiddleware.
+const CorrelationIDKey = "correlation_id"
+
+// CorrelationHeader is the HTTP header carrying the correlation ID of a
+// request across services.
+const CorrelationHeader = "X-Correlation-ID"
+
+// maxCorrelationID bounds the length of a correlation ID taken from a
+// request.
+const maxCorrelationID = 128
+
+// CorrelationMiddleware gives every request a correlation ID: the one in
+// its X-Correlation-ID header if any, a new one from the ID generator
+// otherwise. The ID is echoed in the response header and stored in the
+// request context, so that the entries logged with it through InfoCtx and
+// the like or FromContext carry the correlation_id field:
+//
+//	http.ListenAndServe(":8080", log.CorrelationMiddleware(mux))
+func CorrelationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(CorrelationHeader)
+		if !validCorrelationID(id) {
+			id = NewID()
+		}
+		w.Header().Set(CorrelationHeader, id)
+		ctx := NewContext(r.Context(), Fields{CorrelationIDKey: id})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validCorrelationID reports whether id, taken from a request, is short
+// and printable ASCII, so that a client cannot forge entries with it.
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewFlow returns a new flow ID from the ID generator and a Logger adding
+// it as the flow field to every entry.
+func NewFlow() (string, *Logger) {
+	id := NewID()
+	return id, With(FlowKey, id)
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUUIDv7(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	g := UUIDv7()
+	first := g.NewID()
+	time.Sleep(2 * time.Millisecond)
+	second := g.NewID()
+	for _, id := range []string{first, second} {
+		if !re.MatchString(id) {
+			t.Errorf("%q is not a version 7 UUID", id)
+		}
+	}
+	if first >= second {
+		t.Errorf("%q sorts after the later %q", first, second)
+	}
+}
+
+func TestULID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	g := ULID()
+	first := g.NewID()
+	time.Sleep(2 * time.Millisecond)
+	second := g.NewID()
+	for _, id := range []string{first, second} {
+		if !re.MatchString(id) {
+			t.Errorf("%q is not a ULID", id)
+		}
+	}
+	if first >= second {
+		t.Errorf("%q sorts after the later %q", first, second)
+	}
+	// The first 10 characters are the time in milliseconds.
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	var decoded int64
+	for _, c := range second[:10] {
+		decoded = decoded<<5 | int64(strings.IndexRune(crockford, c))
+	}
+	if d := ms - decoded; d < 0 || d > 1000 {
+		t.Errorf("time of %q is %dms off", second, d)
+	}
+}
+
+func TestSnowflake(t *testing.T) {
+	if _, err := Snowflake(1024); err == nil {
+		t.Error("Snowflake accepted node 1024")
+	}
+	g, err := Snowflake(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last int64
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := g.NewID()
+		if seen[id] {
+			t.Fatalf("%s generated twice", id)
+		}
+		seen[id] = true
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n <= last {
+			t.Fatalf("%d does not follow %d", n, last)
+		}
+		last = n
+		if node := n >> 12 & 1023; node != 5 {
+			t.Fatalf("node of %d is %d", n, node)
+		}
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	n := 0
+	SetIDGenerator(IDGeneratorFunc(func() string {
+		n++
+		return "req-" + strconv.Itoa(n)
+	}))
+	defer SetIDGenerator(nil)
+
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	id, l := NewFlow()
+	l.Info("flow opened")
+	if id != "req-1" || !strings.Contains(buf.String(), "flow=req-1") {
+		t.Errorf("flow %q logged as %q", id, buf.String())
+	}
+
+	SetIDGenerator(nil)
+	if id := NewID(); len(id) != 36 {
+		t.Errorf("default ID %q is not a UUID", id)
+	}
+}
+
+func TestCorrelationMiddleware(t *testing.T) {
+	SetIDGenerator(IDGeneratorFunc(func() string { return "generated" }))
+	defer SetIDGenerator(nil)
+
+	var got interface{}
+	h := CorrelationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ContextFields(r.Context())[CorrelationIDKey]
+	}))
+	for _, tc := range []struct {
+		header, want string
+	}{
+		{"", "generated"},
+		{"abc-123", "abc-123"},
+		{"forged\nlevel=fatal", "generated"},
+		{strings.Repeat("x", maxCorrelationID+1), "generated"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tc.header != "" {
+			r.Header.Set(CorrelationHeader, tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got != tc.want {
+			t.Errorf("header %q: context ID %v, want %q", tc.header, got, tc.want)
+		}
+		if id := w.Header().Get(CorrelationHeader); id != tc.want {
+			t.Errorf("header %q: response ID %q, want %q", tc.header, id, tc.want)
+		}
+	}
+}
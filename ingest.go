@@ -0,0 +1,181 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Entry is a pre-formed log entry, e.g. one received from a remote agent.
+type Entry struct {
+	// Time is when the event happened; the zero time means now.
+	Time time.Time
+	// Level is a level name as accepted by SetLevel.
+	Level string
+	// Host and Tag identify the originating machine and process; empty
+	// values are replaced with those of the local process.
+	Host string
+	Tag  string
+	// File and Line locate the call site in the originating process.
+	File string
+	Line int
+
+	Message string
+	Fields  Fields
+}
+
+// UnmarshalJSON decodes an entry in the format written by JSONFormatter:
+// the keys time, hostname, level, tag, file, line and msg, with every other
+// key except pid becoming a field. The time is RFC3339, in the layout
+// selected with SetTimeFormat or epoch milliseconds.
+func (e *Entry) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	*e = Entry{}
+	for k, v := range raw {
+		var ok bool
+		switch k {
+		case "time":
+			switch t := v.(type) {
+			case string:
+				ts, err := parseJSONTime(t)
+				if err != nil {
+					return fmt.Errorf("entry time: %v", err)
+				}
+				e.Time, ok = ts, true
+			case float64:
+				// Written with TimeEpochMillis.
+				e.Time, ok = time.UnixMilli(int64(t)), true
+			}
+		case "hostname":
+			e.Host, ok = v.(string)
+		case "level":
+			e.Level, ok = v.(string)
+		case "tag":
+			e.Tag, ok = v.(string)
+		case "file":
+			e.File, ok = v.(string)
+		case "line":
+			var n float64
+			n, ok = v.(float64)
+			e.Line = int(n)
+		case "msg":
+			e.Message, ok = v.(string)
+		case "pid":
+			ok = true
+		default:
+			if e.Fields == nil {
+				e.Fields = Fields{}
+			}
+			e.Fields[k] = v
+			ok = true
+		}
+		if !ok && v != nil {
+			return fmt.Errorf("entry key %q has unexpected type %T", k, v)
+		}
+	}
+	return nil
+}
+
+// Ingest pushes e through the local pipeline, i.e. the same level, rate
+// limit, field extractors and outputs as entries logged by this process,
+// turning the process into a lightweight log relay. Entries with a time or
+// host of their own are marked with the relayed field; entries with both
+// also carry the estimated clock skew of their host, see ClockSkews. A
+// FATAL or PANIC entry is written at its level but never terminates the
+// process.
+func Ingest(e Entry) error {
+	level, err := parseLevel(e.Level)
+	if err != nil {
+		return err
+	}
+
+	l := WithFields(e.Fields)
+	if !e.Time.IsZero() && e.Host != "" {
+		l = l.WithFields(Fields{ClockSkewKey: skews.observe(e.Host, e.Time, time.Now())})
+	}
+	if !e.Time.IsZero() {
+		l = l.WithTime(e.Time)
+	}
+	if e.Host != "" {
+		l = l.WithHost(e.Host)
+	}
+	if e.Tag != "" {
+		l = l.WithTag(e.Tag)
+	}
+
+	site := callSite{file: e.File, line: e.Line}
+	if level <= log.FatalLevel {
+		writeEntry(level, site, e.Message, l.data())
+		return nil
+	}
+	emit(level, site, e.Message, l.data())
+	return nil
+}
+
+// writeEntry runs an entry through the pipeline of emit, from redaction to
+// the message policy, and writes it through the hooks, formatter and output
+// of the standard logrus logger without the exit or panic that logrus
+// attaches to the FATAL and PANIC levels.
+func writeEntry(level log.Level, site callSite, msg string, fields log.Fields) {
+	level, msg, fields, ok := prepare(level, site, msg, fields)
+	if !ok {
+		return
+	}
+	countWritten(level)
+	parts := shapeMessage(level, msg, fields)
+	if parts == nil {
+		parts = []messagePart{{msg, fields}}
+	}
+	for _, p := range parts {
+		if b, ok := renderEntry(level, site, p.msg, p.fields); ok {
+			writeOutput(instanceOf(p.fields), b)
+		}
+	}
+}
+
+// renderEntry fires the hooks for an entry and formats it, the steps logrus
+// performs before writing.
+func renderEntry(level log.Level, site callSite, msg string, fields log.Fields) ([]byte, bool) {
+	std := loggerOf(instanceOf(fields))
+	entry := log.NewEntry(std).WithFields(withCaller(fields, site))
+	entry.Time = time.Now()
+	if t, ok := fields[timeKey].(time.Time); ok {
+		entry.Time = t
+	}
+	entry.Level = level
+	entry.Message = msg
+
+	if err := std.Hooks.Fire(level, entry); err != nil {
+		reportError(fmt.Errorf("fire hooks: %v", err))
+	}
+	b, err := std.Formatter.Format(entry)
+	if err != nil {
+		reportError(fmt.Errorf("format entry: %v", err))
+		return nil, false
+	}
+	return b, true
+}
+
+// writeOutput writes formatted entries to the output of in, or of the
+// package logger for nil, in a single write.
+func writeOutput(in *instance, b []byte) {
+	if in != nil {
+		in.out.Write(b)
+		return
+	}
+	current.Lock()
+	w := current.output
+	current.Unlock()
+	if w == nil {
+		os.Stderr.Write(b)
+		return
+	}
+	w.Write(b)
+}
@@ -0,0 +1,60 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIngest(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	err := Ingest(Entry{
+		Time:    time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+		Level:   "fatal",
+		Host:    "probe-7",
+		File:    "capture.go",
+		Line:    88,
+		Message: "ring buffer lost",
+		Fields:  Fields{"iface": "eth0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"2017-03-01T12:00:00Z probe-7 : FATAL", "capture.go:88", "ring buffer lost", "iface=eth0", "relayed=true"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("ingested entry is missing %q: %q", want, out)
+		}
+	}
+
+	if err := Ingest(Entry{Level: "loud"}); err == nil {
+		t.Error("Ingest accepted an invalid level")
+	}
+}
+
+func TestIngestFatalPipeline(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer redactRules.v.Store((*redaction)(nil))
+	RedactFields("password")
+	RedactPattern(EmailPattern)
+
+	Ingest(Entry{Level: "fatal", Message: "login failed", Fields: Fields{"password": "hunter2"}})
+	Ingest(Entry{Level: "panic", Message: "lost bob@example.com"})
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "bob@"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("ingested FATAL and PANIC entries hold %q: %q", secret, out)
+		}
+	}
+	if !strings.Contains(out, "login failed") || !strings.Contains(out, "lost ") {
+		t.Errorf("ingested entries = %q", out)
+	}
+}
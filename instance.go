@@ -0,0 +1,213 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// instanceKey is the reserved key carrying the instance an entry of a
+// Logger created by New is written by.
+const instanceKey = "_instance"
+
+// instance is an independent logger created by New: it has a level and
+// outputs of its own and no hooks. The rest of the pipeline, e.g. the
+// rate limit, sampling and KeepRecent, is shared with the package logger.
+type instance struct {
+	std   *log.Logger
+	out   *multiWriter
+	level uint32
+}
+
+// Option configures a Logger created by New.
+type Option func(*options)
+
+type options struct {
+	outputs  []outputOption
+	level    log.Level
+	tag      string
+	format   string
+	rotation *RotationConfig
+	async    *asyncOptions
+	fields   Fields
+	// errs are the invalid options, reported by New and returned by
+	// Setup.
+	errs []error
+}
+
+// outputOption is an output given by OutputFile, with the name of the
+// file, or by OutputWriters.
+type outputOption struct {
+	file string
+	w    io.Writer
+}
+
+// newOptions applies opts to the defaults.
+func newOptions(opts []Option) options {
+	o := options{level: log.InfoLevel}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// OutputFile writes the entries of the Logger to the file name, which is
+// created, like the directory it is in, on the first entry. Errors opening
+// it are reported to the self-log.
+func OutputFile(name string) Option {
+	return func(o *options) {
+		o.outputs = append(o.outputs, outputOption{file: name})
+	}
+}
+
+// OutputWriters writes the entries of the Logger to all of the writers.
+func OutputWriters(outputs ...io.Writer) Option {
+	return func(o *options) {
+		for _, w := range outputs {
+			o.outputs = append(o.outputs, outputOption{w: w})
+		}
+	}
+}
+
+// MinLevel sets the level of the Logger, as accepted by SetLevel. An
+// invalid level is reported to the self-log and leaves the default, info.
+func MinLevel(level string) Option {
+	return func(o *options) {
+		lvl, err := parseLevel(level)
+		if err != nil {
+			o.errs = append(o.errs, err)
+			return
+		}
+		o.level = lvl
+	}
+}
+
+// Tag labels the entries of the Logger with t, see WithTag.
+func Tag(t string) Option {
+	return func(o *options) {
+		o.tag = t
+	}
+}
+
+// New returns a Logger independent of the package logger, e.g. for an
+// access log next to the application log:
+//
+//	access := log.New(log.OutputFile("/var/log/probe/access.log"), log.Tag("access"))
+//
+// Its entries are written at its own level, info unless MinLevel is given,
+// to its own outputs, stderr unless OutputFile or OutputWriters are given,
+// in the format given by Format or else selected when New is called. Hooks
+// such as forwarders and Subscribe only see the entries of the package
+// logger. Entries of its children created with WithFields and the like
+// also go to the new Logger. Invalid options are reported to the self-log
+// and ignored.
+func New(opts ...Option) *Logger {
+	o := newOptions(opts)
+	for _, err := range o.errs {
+		reportError(err)
+	}
+	var outputs []io.Writer
+	for _, out := range o.outputs {
+		w := out.w
+		if w == nil && o.rotation != nil {
+			w = newRotatingFile(out.file, *o.rotation)
+		} else if w == nil {
+			w = &lazyFile{name: out.file}
+		}
+		outputs = append(outputs, o.wrap(w))
+	}
+	if len(outputs) == 0 {
+		outputs = []io.Writer{o.wrap(os.Stderr)}
+	}
+
+	in := &instance{
+		std:   log.New(),
+		out:   newMultiWriter(outputs...),
+		level: uint32(o.level),
+	}
+	in.std.Out = in.out
+	in.std.Formatter = activeFormatter()
+	if o.format != "" {
+		in.std.Formatter = formatterFor(o.format)
+	}
+	// The level is applied by prepare, logrus is to write every entry it
+	// is handed.
+	in.std.Level = log.DebugLevel
+	addFlusher(in)
+	return &Logger{inst: in, tag: o.tag, fields: o.fields}
+}
+
+// SetLevel sets the level of a Logger created by New; for other Loggers
+// it sets the level of the package logger like the SetLevel function.
+func (l *Logger) SetLevel(level string) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	if in := l.instance(); in != nil {
+		atomic.StoreUint32(&in.level, uint32(lvl))
+	} else {
+		setLevel(lvl)
+	}
+	return nil
+}
+
+// Name identifies the instance in error messages by its first output.
+func (in *instance) Name() string {
+	return "logger " + outputName(in.out.outputs[0])
+}
+
+// Flush flushes the outputs of the instance.
+func (in *instance) Flush() error {
+	if errs := flushWriter(in.out); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Close closes the outputs of the instance.
+func (in *instance) Close() error {
+	if errs := closeWriter(in.out); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// instance returns the instance l writes to, nil for the package logger.
+func (l *Logger) instance() *instance {
+	for p := l; p != nil; p = p.parent {
+		if p.inst != nil {
+			return p.inst
+		}
+	}
+	return nil
+}
+
+// instanceOf returns the instance entry data is written by, nil for the
+// package logger.
+func instanceOf(data log.Fields) *instance {
+	in, _ := data[instanceKey].(*instance)
+	return in
+}
+
+// levelOf returns the level of the logger entry data is written by, or of
+// its component.
+func levelOf(data log.Fields) log.Level {
+	if in := instanceOf(data); in != nil {
+		return log.Level(atomic.LoadUint32(&in.level))
+	}
+	if lvl, ok := componentLevel(data); ok {
+		return lvl
+	}
+	return getLevel()
+}
+
+// loggerOf returns the logrus logger of an instance or the standard one.
+func loggerOf(in *instance) *log.Logger {
+	if in != nil {
+		return in.std
+	}
+	return log.StandardLogger()
+}
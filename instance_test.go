@@ -0,0 +1,60 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var app, access bytes.Buffer
+	SetOutputs(&app)
+	defer SetOutputs(os.Stderr)
+
+	l := New(OutputWriters(&access), MinLevel("warning"), Tag("access"))
+	l.Info("below the level")
+	l.With("status", 404).Warning("not found")
+	Info("application entry")
+
+	if got := access.String(); strings.Contains(got, "below the level") || !strings.Contains(got, " access[") || !strings.Contains(got, "not found status=404") {
+		t.Errorf("instance output = %q, want only the tagged WARNING entry", got)
+	}
+	if !strings.Contains(access.String(), "instance_test.go:") {
+		t.Errorf("instance output %q does not record the caller", access.String())
+	}
+	if got := app.String(); strings.Contains(got, "not found") || !strings.Contains(got, "application entry") {
+		t.Errorf("package output = %q, want only the package entry", got)
+	}
+
+	level := CurrentConfig().Level
+	if err := l.SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	b := l.Batch()
+	b.Debug("batched")
+	b.Commit()
+	if !strings.Contains(access.String(), "batched") {
+		t.Errorf("instance output %q does not hold the committed batch", access.String())
+	}
+	if got := CurrentConfig().Level; got != level {
+		t.Errorf("instance SetLevel changed the package level to %s", got)
+	}
+}
+
+func TestNewOutputFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "access", "access.log")
+	l := New(OutputFile(name))
+	l.Info("first request")
+	if err := l.instance().Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "first request") {
+		t.Errorf("file = %q, want the entry", b)
+	}
+}
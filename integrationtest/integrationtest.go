@@ -0,0 +1,516 @@
+/*
+Package integrationtest provides in-memory fakes of the remote destinations
+of package log, to test the whole logging pipeline of an application,
+queueing, batching, retries and TLS included, without the real
+infrastructure:
+
+	func TestShipping(t *testing.T) {
+		k := integrationtest.NewKafka()
+		remove := log.AddSink(log.NewKafkaSink(k, "logs", "flow"))
+		defer remove()
+
+		k.FailNext(1)
+		run()
+		k.AssertEntry(t, "error", "decode failed")
+	}
+
+Kafka is a KafkaProducer; HTTPServer receives webhook requests such as
+those of AlertWebhook; LineServer is a collector of JSON lines for a
+NetworkSink; SyslogServer is a syslog daemon for AddSyslogSink. Each keeps
+what it received in an Inbox, whose assertions wait up to WaitTimeout for
+the asynchronous outputs to deliver. The servers listen on the loopback
+interface and are stopped when the test ends; SelfSignedTLS returns the TLS
+configurations to secure them.
+*/
+package integrationtest
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	golog "github.com/net-sniper/go-log"
+)
+
+// WaitTimeout bounds the wait of the assertions of an Inbox.
+var WaitTimeout = 5 * time.Second
+
+// Message is a message received by a fake.
+type Message struct {
+	// Topic and Key are those of a Kafka message.
+	Topic string
+	Key   []byte
+	// Method, Path and Header are those of an HTTP request.
+	Method string
+	Path   string
+	Header http.Header
+	// Body is the value of a Kafka message, the body of a request, a JSON
+	// line or a syslog message, without its framing.
+	Body []byte
+}
+
+// Entry decodes the body of m as an entry in the format of JSONFormatter.
+func (m Message) Entry() (golog.Entry, error) {
+	var e golog.Entry
+	err := json.Unmarshal(m.Body, &e)
+	return e, err
+}
+
+// Inbox holds the messages received by a fake, in order.
+type Inbox struct {
+	mu   sync.Mutex
+	msgs []Message
+	// changed is closed and replaced when a message arrives.
+	changed chan struct{}
+	// failures is the number of messages still to refuse.
+	failures int
+}
+
+func newInbox() *Inbox {
+	return &Inbox{changed: make(chan struct{})}
+}
+
+// add records m.
+func (b *Inbox) add(m Message) {
+	b.mu.Lock()
+	b.msgs = append(b.msgs, m)
+	close(b.changed)
+	b.changed = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// failNext refuses the next n messages.
+func (b *Inbox) failNext(n int) {
+	b.mu.Lock()
+	b.failures = n
+	b.mu.Unlock()
+}
+
+// refuse reports whether the next message is to be refused.
+func (b *Inbox) refuse() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures > 0 {
+		b.failures--
+		return true
+	}
+	return false
+}
+
+// Messages returns the messages received so far.
+func (b *Inbox) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.msgs...)
+}
+
+// Entries returns the messages received so far decoded as entries,
+// skipping those that are not JSON entries.
+func (b *Inbox) Entries() []golog.Entry {
+	var entries []golog.Entry
+	for _, m := range b.Messages() {
+		if e, err := m.Entry(); err == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Reset forgets the messages received so far.
+func (b *Inbox) Reset() {
+	b.mu.Lock()
+	b.msgs = nil
+	b.mu.Unlock()
+}
+
+// wait waits until match returns true for the messages received so far,
+// failing t after WaitTimeout.
+func (b *Inbox) wait(t testing.TB, what string, match func([]Message) bool) {
+	t.Helper()
+	deadline := time.NewTimer(WaitTimeout)
+	defer deadline.Stop()
+	for {
+		b.mu.Lock()
+		ok, changed := match(b.msgs), b.changed
+		b.mu.Unlock()
+		if ok {
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			t.Fatalf("no %s received in %v, got %d messages", what, WaitTimeout, len(b.Messages()))
+		}
+	}
+}
+
+// Wait waits until n messages were received and returns them, failing t
+// if they are not within WaitTimeout.
+func (b *Inbox) Wait(t testing.TB, n int) []Message {
+	t.Helper()
+	b.wait(t, fmt.Sprintf("%d messages", n), func(msgs []Message) bool { return len(msgs) >= n })
+	return b.Messages()
+}
+
+// AssertMessage waits for a message whose body contains substr and
+// returns it, failing t if none is received within WaitTimeout.
+func (b *Inbox) AssertMessage(t testing.TB, substr string) Message {
+	t.Helper()
+	var found Message
+	b.wait(t, fmt.Sprintf("message containing %q", substr), func(msgs []Message) bool {
+		for _, m := range msgs {
+			if strings.Contains(string(m.Body), substr) {
+				found = m
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// AssertEntry waits for an entry at level whose message contains substr
+// and returns it, failing t if none is received within WaitTimeout.
+func (b *Inbox) AssertEntry(t testing.TB, level, substr string) golog.Entry {
+	t.Helper()
+	var found golog.Entry
+	b.wait(t, fmt.Sprintf("%s entry containing %q", level, substr), func(msgs []Message) bool {
+		for _, m := range msgs {
+			if e, err := m.Entry(); err == nil && e.Level == level && strings.Contains(e.Message, substr) {
+				found = e
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// errRefused is the error of the messages refused by a Kafka.
+var errRefused = errors.New("integrationtest: message refused")
+
+// Kafka is an in-memory KafkaProducer for a KafkaSink.
+type Kafka struct {
+	*Inbox
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewKafka returns an empty Kafka.
+func NewKafka() *Kafka {
+	return &Kafka{Inbox: newInbox()}
+}
+
+// FailNext makes the next n messages fail to be produced, like a broker
+// that is down.
+func (k *Kafka) FailNext(n int) {
+	k.failNext(n)
+}
+
+// Produce implements golog.KafkaProducer.
+func (k *Kafka) Produce(topic string, key, value []byte) error {
+	k.mu.Lock()
+	closed := k.closed
+	k.mu.Unlock()
+	if closed {
+		return errors.New("integrationtest: producer closed")
+	}
+	if k.refuse() {
+		return errRefused
+	}
+	k.add(Message{
+		Topic: topic,
+		Key:   append([]byte(nil), key...),
+		Body:  append([]byte(nil), value...),
+	})
+	return nil
+}
+
+// ProduceContext implements golog.KafkaContextProducer.
+func (k *Kafka) ProduceContext(ctx context.Context, topic string, key, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return k.Produce(topic, key, value)
+}
+
+// Close implements golog.KafkaProducer.
+func (k *Kafka) Close() error {
+	k.mu.Lock()
+	k.closed = true
+	k.mu.Unlock()
+	return nil
+}
+
+// Closed reports whether the producer was closed.
+func (k *Kafka) Closed() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.closed
+}
+
+// HTTPServer records the requests it receives, answering 204 No Content.
+type HTTPServer struct {
+	*Inbox
+	srv *httptest.Server
+}
+
+// NewHTTPServer starts an HTTPServer, serving TLS with cfg if not nil.
+func NewHTTPServer(t testing.TB, cfg *tls.Config) *HTTPServer {
+	s := &HTTPServer{Inbox: newInbox()}
+	s.srv = httptest.NewUnstartedServer(http.HandlerFunc(s.serve))
+	if cfg != nil {
+		s.srv.TLS = cfg
+		s.srv.StartTLS()
+	} else {
+		s.srv.Start()
+	}
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// URL returns the base URL of s.
+func (s *HTTPServer) URL() string {
+	return s.srv.URL
+}
+
+// FailNext makes the next n requests fail with 503 Service Unavailable.
+func (s *HTTPServer) FailNext(n int) {
+	s.failNext(n)
+}
+
+func (s *HTTPServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.refuse() {
+		http.Error(w, "refused", http.StatusServiceUnavailable)
+		return
+	}
+	s.add(Message{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// streamServer is a TCP server splitting the connections into messages.
+type streamServer struct {
+	*Inbox
+	l net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+// listenStream starts a streamServer, serving TLS with cfg if not nil, whose
+// connections are split by split.
+func listenStream(t testing.TB, cfg *tls.Config, split bufio.SplitFunc) *streamServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != nil {
+		l = tls.NewListener(l, cfg)
+	}
+	s := &streamServer{Inbox: newInbox(), l: l, conns: make(map[net.Conn]bool)}
+	s.wg.Add(1)
+	go s.accept(split)
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *streamServer) accept(split bufio.SplitFunc) {
+	defer s.wg.Done()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.read(conn, split)
+	}
+}
+
+func (s *streamServer) read(conn net.Conn, split bufio.SplitFunc) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(nil, 1<<20)
+	sc.Split(split)
+	for sc.Scan() {
+		s.add(Message{Body: append([]byte(nil), sc.Bytes()...)})
+	}
+}
+
+// Addr returns the address of the server.
+func (s *streamServer) Addr() string {
+	return s.l.Addr().String()
+}
+
+// CloseConns closes the open connections, like a restarted collector, so
+// that the clients reconnect.
+func (s *streamServer) CloseConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *streamServer) close() {
+	s.l.Close()
+	s.CloseConns()
+	s.wg.Wait()
+}
+
+// LineServer is a collector of newline delimited messages over TCP, such
+// as the JSON lines of a NetworkSink.
+type LineServer struct {
+	*streamServer
+}
+
+// NewLineServer starts a LineServer, serving TLS with cfg if not nil.
+func NewLineServer(t testing.TB, cfg *tls.Config) *LineServer {
+	return &LineServer{listenStream(t, cfg, bufio.ScanLines)}
+}
+
+// SyslogServer is a syslog daemon recording the messages it receives.
+type SyslogServer struct {
+	*Inbox
+	addr string
+	// stream is set over TCP.
+	stream *streamServer
+}
+
+// NewSyslogServer starts a SyslogServer on network, "udp" or "tcp"; TCP
+// messages are framed by octet counting (RFC 6587).
+func NewSyslogServer(t testing.TB, network string) *SyslogServer {
+	switch network {
+	case "tcp":
+		s := listenStream(t, nil, scanOctetCounted)
+		return &SyslogServer{Inbox: s.Inbox, addr: s.Addr(), stream: s}
+	case "udp":
+	default:
+		t.Fatalf("integrationtest: unsupported syslog network %q", network)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SyslogServer{Inbox: newInbox(), addr: conn.LocalAddr().String()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 64<<10)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			s.add(Message{Body: append([]byte(nil), buf[:n]...)})
+		}
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-done
+	})
+	return s
+}
+
+// Addr returns the address of the server.
+func (s *SyslogServer) Addr() string {
+	return s.addr
+}
+
+// CloseConns closes the open TCP connections, like a restarted daemon.
+func (s *SyslogServer) CloseConns() {
+	if s.stream != nil {
+		s.stream.CloseConns()
+	}
+}
+
+// scanOctetCounted splits messages prefixed with their length and a space.
+func scanOctetCounted(data []byte, atEOF bool) (int, []byte, error) {
+	sp := strings.IndexByte(string(data), ' ')
+	if sp < 0 {
+		if atEOF && len(data) > 0 {
+			return 0, nil, errors.New("integrationtest: truncated syslog frame")
+		}
+		return 0, nil, nil
+	}
+	n, err := strconv.Atoi(string(data[:sp]))
+	if err != nil || n < 0 {
+		return 0, nil, fmt.Errorf("integrationtest: invalid syslog frame length %q", data[:sp])
+	}
+	if len(data) < sp+1+n {
+		if atEOF {
+			return 0, nil, errors.New("integrationtest: truncated syslog frame")
+		}
+		return 0, nil, nil
+	}
+	return sp + 1 + n, data[sp+1 : sp+1+n], nil
+}
+
+// SelfSignedTLS returns the configuration of a server with a self-signed
+// certificate for 127.0.0.1 and localhost, and that of a client trusting
+// it.
+func SelfSignedTLS(t testing.TB) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "integrationtest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}}}
+	client = &tls.Config{RootCAs: pool}
+	return server, client
+}
@@ -0,0 +1,120 @@
+package integrationtest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	golog "github.com/net-sniper/go-log"
+)
+
+func TestKafka(t *testing.T) {
+	golog.SetLevel("debug")
+	k := NewKafka()
+	remove := golog.AddSink(golog.NewKafkaSink(k, "logs", "flow"))
+	defer remove()
+
+	k.FailNext(1)
+	golog.WithFields(golog.Fields{"flow": 7}).Error("lost")
+	golog.WithFields(golog.Fields{"flow": 8}).Error("decode failed")
+	e := k.AssertEntry(t, "error", "decode failed")
+	if e.Fields["flow"] != float64(8) {
+		t.Errorf("fields = %v", e.Fields)
+	}
+	golog.Flush()
+	msgs := k.Messages()
+	if len(msgs) != 1 || msgs[0].Topic != "logs" || string(msgs[0].Key) != "8" {
+		t.Errorf("messages = %+v, want the second entry only", msgs)
+	}
+
+	remove()
+	if !k.Closed() {
+		t.Error("producer not closed with the sink")
+	}
+}
+
+func TestHTTPServerTLS(t *testing.T) {
+	server, client := SelfSignedTLS(t)
+	s := NewHTTPServer(t, server)
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: client}}
+
+	s.FailNext(1)
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusNoContent} {
+		resp, err := c.Post(s.URL()+"/hook", "application/json", strings.NewReader(`{"level":"info","msg":"up"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("request %d: status %d, want %d", i, resp.StatusCode, want)
+		}
+	}
+	msgs := s.Wait(t, 1)
+	if len(msgs) != 1 || msgs[0].Method != "POST" || msgs[0].Path != "/hook" {
+		t.Errorf("messages = %+v", msgs)
+	}
+	s.AssertEntry(t, "info", "up")
+}
+
+func TestLineServerTLS(t *testing.T) {
+	golog.SetLevel("debug")
+	server, client := SelfSignedTLS(t)
+	s := NewLineServer(t, server)
+	sink, err := golog.NewNetworkSink("tcp", s.Addr(), client, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golog.AddNetworkSink(sink)
+	defer sink.Close()
+
+	golog.Info("first")
+	s.AssertEntry(t, "info", "first")
+	// The entries written before the sink notices the closed connection
+	// are lost, keep logging until it reconnects.
+	s.CloseConns()
+	for i := 0; i < 100 && len(s.Entries()) < 2; i++ {
+		golog.Warning("after restart")
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.AssertEntry(t, "warning", "after restart")
+}
+
+func TestSyslogServer(t *testing.T) {
+	golog.SetLevel("debug")
+	for _, network := range []string{"udp", "tcp"} {
+		s := NewSyslogServer(t, network)
+		sink, err := golog.AddSyslogSink(network, s.Addr(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		golog.Warning("syslog " + network)
+		m := s.AssertMessage(t, "syslog "+network)
+		if !strings.HasPrefix(string(m.Body), "<12>1 ") {
+			t.Errorf("%s: message %q, want a user.warning RFC 5424 message", network, m.Body)
+		}
+		sink.Close()
+	}
+}
+
+func TestScanOctetCounted(t *testing.T) {
+	data := []byte("5 hello11 hello world3 ab")
+	var got []string
+	for {
+		n, tok, err := scanOctetCounted(data, true)
+		if err != nil {
+			break
+		}
+		if n == 0 {
+			break
+		}
+		got = append(got, string(tok))
+		data = data[n:]
+	}
+	if strings.Join(got, "|") != "hello|hello world" {
+		t.Errorf("frames = %q", got)
+	}
+	if _, _, err := scanOctetCounted([]byte("x hello"), false); err == nil {
+		t.Error("invalid length accepted")
+	}
+}
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// encodeJournal renders an entry in the native protocol of journald: one
+// NAME=value line per field, values holding a newline in the binary form
+// NAME, newline, little-endian 64-bit length, value, newline.
+func encodeJournal(entry *log.Entry) ([]byte, error) {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", entry.Message)
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(syslogSeverity[severityOf(entry.Level)]))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", filepath.Base(tag))
+	writeJournalField(&b, "SYSLOG_PID", pidText)
+	if site := entryCaller(entry.Data); site.file != "" {
+		writeJournalField(&b, "CODE_FILE", site.file)
+		writeJournalField(&b, "CODE_LINE", strconv.Itoa(site.line))
+	}
+	if h, ok := entry.Data[hostKey].(string); ok {
+		// The journal records the local host itself; a relayed entry
+		// keeps its origin in a field.
+		writeJournalField(&b, "REMOTE_HOSTNAME", h)
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		if !isReserved(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := entry.Data[k]
+		if s, ok := value.(string); ok && k == LayersKey {
+			value = parseLayers(s)
+		}
+		writeJournalField(&b, journalName(k), fmt.Sprint(value))
+	}
+	return b.Bytes(), nil
+}
+
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalName makes a field key a valid journal field name: upper case
+// letters, digits and underscores, not starting with an underscore or a
+// digit, which would make it a trusted field or invalid.
+func journalName(k string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, k)
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "F" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,42 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestEncodeJournal(t *testing.T) {
+	defer SetTag(tag)
+	SetTag("/usr/bin/sniper")
+
+	entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
+		"iface":    "eth1",
+		"dump":     "line 1\nline 2",
+		"_private": 1,
+	})
+	entry.Level = log.WarnLevel
+	entry.Message = "link flap"
+
+	b, err := encodeJournal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"MESSAGE=link flap\n", "PRIORITY=4\n", "SYSLOG_IDENTIFIER=sniper\n",
+		"IFACE=eth1\n", "F_PRIVATE=1\n",
+	} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("journal message %q does not contain %q", b, want)
+		}
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len("line 1\nline 2")))
+	want := "DUMP\n" + string(size[:]) + "line 1\nline 2\n"
+	if !bytes.Contains(b, []byte(want)) {
+		t.Errorf("journal message %q does not hold the binary form of dump", b)
+	}
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// JSONFormatter formats each entry as a single-line JSON object with the
+// keys time, hostname, level, tag, pid, file, line and msg. Fields are
+// added as further keys; a field named like one of these is prefixed with
+// "fields.".
+type JSONFormatter struct {
+	// Keys renames the keys above to match what the log pipeline expects,
+	// e.g. {"time": "@timestamp", "level": "severity", "msg": "message"}.
+	Keys map[string]string
+	// Flat writes the fields holding objects as dotted keys, e.g.
+	// {"http":{"status":200}} as "http.status":200, for collectors that
+	// take one level of keys and split strictly on newlines. A field that
+	// cannot be encoded, such as a channel or NaN, is left out and listed
+	// in the json_rejected field instead of failing the entry, so that
+	// every entry is exactly one line.
+	Flat bool
+}
+
+// JSONRejectedKey is the field listing the fields left out by a flat
+// JSONFormatter.
+const JSONRejectedKey = "json_rejected"
+
+// jsonKeys are the keys written by JSONFormatter for every entry.
+var jsonKeys = map[string]bool{
+	"time": true, "hostname": true, "level": true, "tag": true,
+	"pid": true, "file": true, "line": true, "msg": true,
+}
+
+func (c *JSONFormatter) Format(entry *log.Entry) ([]byte, error) {
+	key, taken := c.key, jsonKeys
+	if len(c.Keys) > 0 {
+		taken = make(map[string]bool, len(jsonKeys))
+		for k := range jsonKeys {
+			taken[key(k)] = true
+		}
+	}
+
+	nf := activeNumberFormat()
+	data := make(map[string]interface{}, len(entry.Data)+len(jsonKeys))
+	var objects map[string][]byte
+	var rejected []string
+	for k, v := range entry.Data {
+		if isReserved(k) {
+			continue
+		}
+		if taken[k] {
+			k = "fields." + k
+		}
+		if err, ok := v.(error); ok {
+			// Most error types have no exported fields and would
+			// marshal as {}.
+			v = err.Error()
+		}
+		if nf != (NumberFormat{}) {
+			v = jsonNumber(nf, v)
+		}
+		if c.Flat {
+			b, err := json.Marshal(v)
+			if err != nil {
+				rejected = append(rejected, k)
+				continue
+			}
+			if b[0] == '{' {
+				if objects == nil {
+					objects = make(map[string][]byte)
+				}
+				objects[k] = b
+				continue
+			}
+			v = json.RawMessage(b)
+		}
+		data[k] = v
+	}
+	// Flattened keys come after the fields, which keep their values if
+	// named alike.
+	nested := make([]string, 0, len(objects))
+	for k := range objects {
+		nested = append(nested, k)
+	}
+	sort.Strings(nested)
+	for _, k := range nested {
+		flatten(data, k, objects[k])
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		data[JSONRejectedKey] = strings.Join(rejected, ",")
+	}
+	data[key("time")] = jsonTime(entry.Time)
+	data[key("hostname")] = entryHost(entry.Data)
+	data[key("level")] = severityName(entry.Level)
+	data[key("tag")] = entryTag(entry.Data)
+	data[key("pid")] = pid
+	site := entryCaller(entry.Data)
+	data[key("file")] = site.file
+	data[key("line")] = site.line
+	data[key("msg")] = entry.Message
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal entry to JSON: %v", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// flatten adds the JSON value b to data as key, the members of objects as
+// dotted keys. Keys already present are kept.
+func flatten(data map[string]interface{}, key string, b json.RawMessage) {
+	if len(b) == 0 || b[0] != '{' {
+		if _, ok := data[key]; !ok {
+			data[key] = b
+		}
+		return
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return
+	}
+	for k, v := range obj {
+		flatten(data, key+"."+k, v)
+	}
+}
+
+// key returns the name written for the built-in key k.
+func (c *JSONFormatter) key(k string) string {
+	if n := c.Keys[k]; n != "" {
+		return n
+	}
+	return k
+}
@@ -0,0 +1,136 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestJSONFormatter(t *testing.T) {
+	entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
+		"user_id": 42,
+		"msg":     "shadowed",
+		"error":   errors.New("refused"),
+	})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = log.WarnLevel
+	entry.Message = "login failed"
+
+	b, err := (&JSONFormatter{}).Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[len(b)-1] != '\n' {
+		t.Errorf("entry does not end with a newline: %q", b)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", b, err)
+	}
+	want := map[string]interface{}{
+		"time":       "2017-03-01T12:00:00Z",
+		"level":      "warning",
+		"msg":        "login failed",
+		"fields.msg": "shadowed",
+		"user_id":    float64(42),
+		"error":      "refused",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, got[k], v)
+		}
+	}
+}
+
+func TestJSONFormatterKeys(t *testing.T) {
+	entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
+		"msg":     "kept",
+		"message": "shadowed",
+	})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = log.ErrorLevel
+	entry.Message = "disk full"
+
+	f := &JSONFormatter{Keys: map[string]string{
+		"time":  "@timestamp",
+		"level": "severity",
+		"msg":   "message",
+	}}
+	b, err := f.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", b, err)
+	}
+	want := map[string]interface{}{
+		"@timestamp":     "2017-03-01T12:00:00Z",
+		"severity":       "error",
+		"message":        "disk full",
+		"fields.message": "shadowed",
+		"msg":            "kept",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, got[k], v)
+		}
+	}
+	for _, k := range []string{"time", "level"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("renamed key %s still written", k)
+		}
+	}
+}
+
+func TestJSONFormatterFlat(t *testing.T) {
+	type status struct {
+		Code   int    `json:"code"`
+		Reason string `json:"reason"`
+	}
+	entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
+		"http":        map[string]interface{}{"status": status{503, "busy\nretry"}, "method": "GET"},
+		"http.method": "POST",
+		"ports":       []int{80, 443},
+		"notify":      make(chan int),
+		"ratio":       math.NaN(),
+	})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = log.WarnLevel
+	entry.Message = "upstream failed"
+
+	b, err := (&JSONFormatter{Flat: true}).Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := bytes.IndexByte(b, '\n'); i != len(b)-1 {
+		t.Errorf("entry is not a single line: %q", b)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", b, err)
+	}
+	for k, want := range map[string]interface{}{
+		"http.status.code":   float64(503),
+		"http.status.reason": "busy\nretry",
+		"http.method":        "POST",
+		"ports":              []interface{}{float64(80), float64(443)},
+		JSONRejectedKey:      "notify,ratio",
+		"msg":                "upstream failed",
+	} {
+		if !reflect.DeepEqual(got[k], want) {
+			t.Errorf("%s = %#v, want %#v", k, got[k], want)
+		}
+	}
+	if _, ok := got["http"]; ok {
+		t.Error("nested object kept")
+	}
+}
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+// KafkaProducer publishes messages to Kafka. It is implemented by a thin
+// adapter over the Kafka client of the application, keeping the package
+// free of that dependency, e.g. for sarama:
+//
+//	type saramaProducer struct{ sarama.SyncProducer }
+//
+//	func (p saramaProducer) Produce(topic string, key, value []byte) error {
+//		m := &sarama.ProducerMessage{Topic: topic, Value: sarama.ByteEncoder(value)}
+//		if key != nil {
+//			m.Key = sarama.ByteEncoder(key)
+//		}
+//		_, _, err := p.SendMessage(m)
+//		return err
+//	}
+//
+// A nil key leaves the choice of the partition to the client.
+type KafkaProducer interface {
+	Produce(topic string, key, value []byte) error
+	Close() error
+}
+
+// KafkaContextProducer is implemented by producers whose requests can be
+// cancelled, such as adapters over franz-go. KafkaSink prefers
+// ProduceContext, so that closing the sink aborts a request to a slow
+// broker.
+type KafkaContextProducer interface {
+	KafkaProducer
+	ProduceContext(ctx context.Context, topic string, key, value []byte) error
+}
+
+// KafkaSink is a Sink publishing entries to a Kafka topic as JSON objects
+// in the format of JSONFormatter:
+//
+//	log.AddSink(log.NewKafkaSink(saramaProducer{p}, "logs", "flow"))
+type KafkaSink struct {
+	producer KafkaProducer
+	topic    string
+	keyField string
+}
+
+// NewKafkaSink returns a KafkaSink publishing to topic through p. If
+// keyField is not empty, the value of that field is the message key, so
+// that the entries about one flow or user stay ordered in a partition.
+func NewKafkaSink(p KafkaProducer, topic, keyField string) *KafkaSink {
+	return &KafkaSink{producer: p, topic: topic, keyField: keyField}
+}
+
+// Name returns the topic of the sink.
+func (k *KafkaSink) Name() string {
+	return "kafka:" + k.topic
+}
+
+// Write publishes e.
+func (k *KafkaSink) Write(e *Entry) error {
+	return k.WriteContext(context.Background(), e)
+}
+
+// WriteContext publishes e, giving up when ctx is done if the producer is
+// a KafkaContextProducer.
+func (k *KafkaSink) WriteContext(ctx context.Context, e *Entry) error {
+	value, err := entryJSON(e)
+	if err != nil {
+		return err
+	}
+	var key []byte
+	if v, ok := e.Fields[k.keyField]; ok && k.keyField != "" {
+		key = []byte(fmt.Sprint(v))
+	}
+	if p, ok := k.producer.(KafkaContextProducer); ok {
+		return p.ProduceContext(ctx, k.topic, key, value)
+	}
+	return k.producer.Produce(k.topic, key, value)
+}
+
+// Close closes the producer.
+func (k *KafkaSink) Close() error {
+	return k.producer.Close()
+}
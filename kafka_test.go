@@ -0,0 +1,95 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// fakeProducer records the messages it is given.
+type fakeProducer struct {
+	topic      string
+	key, value []byte
+	closed     bool
+}
+
+func (p *fakeProducer) Produce(topic string, key, value []byte) error {
+	p.topic, p.key, p.value = topic, key, value
+	return nil
+}
+
+func (p *fakeProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	p := &fakeProducer{}
+	k := NewKafkaSink(p, "logs", "flow")
+	e := &Entry{
+		Time:    time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+		Level:   "warning",
+		Tag:     "probe",
+		Message: "retransmission storm",
+		Fields:  Fields{"flow": 42, "msg": "shadowed"},
+	}
+	if err := k.Write(e); err != nil {
+		t.Fatal(err)
+	}
+	if p.topic != "logs" || string(p.key) != "42" {
+		t.Errorf("produced to %q with key %q, want logs and 42", p.topic, p.key)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(p.value, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]interface{}{
+		"time":       "2017-03-01T12:00:00Z",
+		"level":      "warning",
+		"tag":        "probe",
+		"msg":        "retransmission storm",
+		"flow":       float64(42),
+		"fields.msg": "shadowed",
+	} {
+		if doc[k] != want {
+			t.Errorf("%s = %v, want %v", k, doc[k], want)
+		}
+	}
+
+	delete(e.Fields, "flow")
+	if err := k.Write(e); err != nil {
+		t.Fatal(err)
+	}
+	if p.key != nil {
+		t.Errorf("key %q for an entry without the key field, want nil", p.key)
+	}
+	if err := k.Close(); err != nil || !p.closed {
+		t.Errorf("Close() = %v, producer closed = %v", err, p.closed)
+	}
+}
+
+// contextProducer is a KafkaContextProducer recording the context it is
+// given.
+type contextProducer struct {
+	fakeProducer
+	ctx context.Context
+}
+
+func (p *contextProducer) ProduceContext(ctx context.Context, topic string, key, value []byte) error {
+	p.ctx = ctx
+	return p.Produce(topic, key, value)
+}
+
+func TestKafkaSinkContext(t *testing.T) {
+	p := &contextProducer{}
+	k := NewKafkaSink(p, "logs", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := k.WriteContext(ctx, &Entry{Level: "info", Message: "started"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.ctx != ctx || p.topic != "logs" {
+		t.Errorf("produced to %q with context %v, want the context of the write", p.topic, p.ctx)
+	}
+}
@@ -0,0 +1,198 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// LabelCase controls the casing of severity labels in log lines.
+type LabelCase int
+
+const (
+	// UpperCase prints labels in upper case, e.g. WARNING. This is the
+	// default.
+	UpperCase LabelCase = iota
+	// LowerCase prints labels in lower case, e.g. warning.
+	LowerCase
+	// AsIs prints labels exactly as given to SetLevelLabels.
+	AsIs
+)
+
+// FourLetterLevelLabels is a set of fixed-width labels for use with
+// SetLevelLabels.
+var FourLetterLevelLabels = map[string]string{
+	"panic":   "PANC",
+	"fatal":   "FATL",
+	"error":   "EROR",
+	"warning": "WARN",
+	"info":    "INFO",
+	"debug":   "DEBG",
+	"trace":   "TRCE",
+}
+
+// labelSettings are the labels set with SetLevelLabels and SetLevelCase.
+type labelSettings struct {
+	sync.RWMutex
+	names     map[log.Level]string
+	labelCase LabelCase
+	// printed holds the label of each level, cased, so that formatting an
+	// entry does not allocate it.
+	printed [traceLevel + 1]string
+}
+
+var labels labelSettings
+
+func init() {
+	labels.Lock()
+	labels.update()
+	labels.Unlock()
+}
+
+// SetLevelLabels overrides the severity labels printed in log lines. Keys
+// are level names as accepted by SetLevel; levels that are not present keep
+// their default label. Passing nil restores the defaults.
+func SetLevelLabels(m map[string]string) error {
+	names := make(map[log.Level]string, len(m))
+	for name, label := range m {
+		lvl, err := parseLevel(name)
+		if err != nil {
+			return err
+		}
+		names[severityOf(lvl)] = label
+	}
+
+	labels.Lock()
+	labels.names = names
+	labels.update()
+	labels.Unlock()
+	return nil
+}
+
+// SetLevelCase sets the casing applied to severity labels.
+func SetLevelCase(c LabelCase) {
+	labels.Lock()
+	labels.labelCase = c
+	labels.update()
+	labels.Unlock()
+}
+
+// levelLabel returns the label printed for level.
+func levelLabel(level log.Level) string {
+	labels.RLock()
+	defer labels.RUnlock()
+	return labels.printed[severityOf(level)]
+}
+
+// update computes the printed labels, with labels locked.
+func (l *labelSettings) update() {
+	for _, level := range allLevels {
+		label, ok := l.names[level]
+		if !ok {
+			label = levelName(level)
+		}
+		switch l.labelCase {
+		case UpperCase:
+			label = strings.ToUpper(label)
+		case LowerCase:
+			label = strings.ToLower(label)
+		}
+		l.printed[level] = label
+	}
+}
+
+// SetColor prints the severity labels of the text format in the ANSI
+// color of their level, by default red for errors, yellow for warnings and
+// gray for debug and trace, e.g. for a terminal; see SetLevelColors. The presets
+// enable it for terminals unless colors are turned off with the NO_COLOR
+// environment variable.
+func SetColor(color bool) {
+	updateFormatter(func(f *Formatter) {
+		f.Color = color
+	})
+}
+
+// colorNames are the names of SetLevelColors and their SGR parameters.
+var colorNames = map[string]string{
+	"bold": "1", "dim": "2", "italic": "3", "underline": "4", "reverse": "7",
+	"black": "30", "red": "31", "green": "32", "yellow": "33",
+	"blue": "34", "magenta": "35", "cyan": "36", "white": "37", "gray": "90",
+}
+
+// sgrParams matches the parameters of an SGR escape sequence, e.g. 38;5;208.
+var sgrParams = regexp.MustCompile(`^[0-9]+(;[0-9]+)*$`)
+
+// colors holds the SGR parameters set with SetLevelColors, nil for the
+// defaults.
+var colors = struct {
+	sync.RWMutex
+	sgr map[log.Level]string
+}{}
+
+// SetLevelColors overrides the styles of the severity labels printed with
+// SetColor, e.g. for a terminal theme on which the defaults are hard to
+// read. Keys are level names as accepted by SetLevel; values are lists of
+// style names separated by spaces, from bold, dim, italic, underline,
+// reverse, black, red, green, yellow, blue, magenta, cyan, white and gray,
+// or raw SGR parameters such as 38;5;208:
+//
+//	log.SetLevelColors(map[string]string{"error": "bold red", "info": "cyan", "debug": ""})
+//
+// An empty value prints the label of that level without color. Levels
+// that are not present keep their default color. Passing nil restores the
+// defaults.
+func SetLevelColors(m map[string]string) error {
+	sgr := make(map[log.Level]string, len(m))
+	for name, style := range m {
+		lvl, err := parseLevel(name)
+		if err != nil {
+			return err
+		}
+		var params []string
+		for _, word := range strings.Fields(style) {
+			p, ok := colorNames[strings.ToLower(word)]
+			if !ok {
+				if !sgrParams.MatchString(word) {
+					return fmt.Errorf("level %s: not a valid color: %q", name, word)
+				}
+				p = word
+			}
+			params = append(params, p)
+		}
+		sgr[severityOf(lvl)] = strings.Join(params, ";")
+	}
+
+	colors.Lock()
+	colors.sgr = sgr
+	if m == nil {
+		colors.sgr = nil
+	}
+	colors.Unlock()
+	return nil
+}
+
+// colorLabel wraps label in the ANSI color of level, by default the one
+// the logui viewer uses.
+func colorLabel(level log.Level, label string) string {
+	level = severityOf(level)
+	colors.RLock()
+	color, ok := colors.sgr[level]
+	colors.RUnlock()
+	if !ok {
+		switch level {
+		case log.PanicLevel, log.FatalLevel, log.ErrorLevel:
+			color = "31"
+		case log.WarnLevel:
+			color = "33"
+		case log.DebugLevel, traceLevel:
+			color = "90"
+		}
+	}
+	if color == "" {
+		return label
+	}
+	return "\x1b[" + color + "m" + label + "\x1b[0m"
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestLevelLabels(t *testing.T) {
+	defer SetLevelLabels(nil)
+	defer SetLevelCase(UpperCase)
+
+	if got := levelLabel(log.WarnLevel); got != "WARNING" {
+		t.Errorf("default label = %q, want WARNING", got)
+	}
+
+	if err := SetLevelLabels(FourLetterLevelLabels); err != nil {
+		t.Fatal(err)
+	}
+	for _, lvl := range log.AllLevels {
+		if got := levelLabel(lvl); len(got) != 4 {
+			t.Errorf("label for %s = %q, want four letters", lvl, got)
+		}
+	}
+
+	SetLevelCase(LowerCase)
+	if got := levelLabel(log.ErrorLevel); got != "eror" {
+		t.Errorf("lower-case label = %q, want eror", got)
+	}
+
+	if err := SetLevelLabels(map[string]string{"loud": "LOUD"}); err == nil {
+		t.Error("SetLevelLabels accepted an unknown level")
+	}
+}
+
+func TestSetColor(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetColor(true)
+	defer SetColor(false)
+
+	Error("failed")
+	Info("plain")
+	out := buf.String()
+	if !strings.Contains(out, ": \x1b[31mERROR\x1b[0m\t") {
+		t.Errorf("output %q lacks the red error label", out)
+	}
+	if !strings.Contains(out, ": INFO\t") {
+		t.Errorf("output %q colors the info label", out)
+	}
+}
+
+func TestSetLevelColors(t *testing.T) {
+	if err := SetLevelColors(map[string]string{"error": "bold red", "info": "38;5;208", "debug": ""}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetLevelColors(nil)
+
+	for _, tt := range []struct {
+		level log.Level
+		want  string
+	}{
+		{log.ErrorLevel, "\x1b[1;31mERROR\x1b[0m"},
+		{log.InfoLevel, "\x1b[38;5;208mINFO\x1b[0m"},
+		{log.DebugLevel, "DEBUG"},
+		{log.WarnLevel, "\x1b[33mWARNING\x1b[0m"},
+	} {
+		if got := colorLabel(tt.level, levelLabel(tt.level)); got != tt.want {
+			t.Errorf("colorLabel(%s) = %q, want %q", tt.level, got, tt.want)
+		}
+	}
+
+	for _, style := range []string{"purple", "31m", "1;;2"} {
+		if err := SetLevelColors(map[string]string{"error": style}); err == nil {
+			t.Errorf("SetLevelColors accepted %q", style)
+		}
+	}
+	if err := SetLevelColors(map[string]string{"loud": "red"}); err == nil {
+		t.Error("SetLevelColors accepted an invalid level")
+	}
+}
+
+func TestColorTerminalNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if colorTerminal(os.Stderr) {
+		t.Error("colorTerminal() = true with NO_COLOR set")
+	}
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"strings"
+)
+
+// LayersKey is the field summarizing the protocol stack of a decoded packet.
+const LayersKey = "layers"
+
+// LayerStack is the protocol stack of a packet, outermost layer first. It
+// is rendered as a comma separated list of lower-case layer names, e.g.
+// eth,ip,tcp,http.
+type LayerStack []string
+
+// String implements fmt.Stringer.
+func (s LayerStack) String() string {
+	return strings.Join(s, ",")
+}
+
+// Layers returns the layers field for a packet. Each argument may itself be
+// a comma separated list:
+//
+//	log.WithFields(log.Layers("eth,ip,tcp,http")).Debug("decoded")
+//	log.WithFields(log.Layers("eth", "ip", "udp", "dns")).Debug("decoded")
+func Layers(layers ...string) Fields {
+	return Fields{LayersKey: parseLayers(strings.Join(layers, ","))}
+}
+
+// parseLayers normalizes a comma separated layer list, dropping empty names
+// and surrounding whitespace.
+func parseLayers(s string) LayerStack {
+	var stack LayerStack
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.ToLower(strings.TrimSpace(l)); l != "" {
+			stack = append(stack, l)
+		}
+	}
+	return stack
+}
@@ -0,0 +1,29 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestLayers(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want string
+	}{
+		{[]string{"eth,ip,tcp,http"}, "eth,ip,tcp,http"},
+		{[]string{"ETH", " ip ", "udp,dns"}, "eth,ip,udp,dns"},
+		{[]string{"eth,,ip,"}, "eth,ip"},
+	}
+	for _, tt := range tests {
+		got := Layers(tt.in...)[LayersKey].(LayerStack).String()
+		if got != tt.want {
+			t.Errorf("Layers(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatFieldsNormalizesLayers(t *testing.T) {
+	got := formatFields(map[string]interface{}{LayersKey: "Eth, IP,TCP"})
+	if want := " layers=eth,ip,tcp"; got != want {
+		t.Errorf("formatFields() = %q, want %q", got, want)
+	}
+}
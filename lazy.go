@@ -0,0 +1,59 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// lazyValue is a message argument or field value built when the entry is
+// written.
+type lazyValue func() string
+
+// Lazy defers building an expensive message argument or field value, such
+// as the hex dump of a packet, to the time the entry is logged: fn is not
+// called when the level discards the entry.
+//
+//	log.Debug("rx ", log.Lazy(func() string { return hex.Dump(pkt) }))
+//	log.WithFields(log.Fields{"frame": log.Lazy(frame.JSON)}).Debug("rx")
+func Lazy(fn func() string) fmt.Stringer {
+	return lazyValue(fn)
+}
+
+func (l lazyValue) String() string {
+	return l()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (l lazyValue) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(l())), nil
+}
+
+// resolveLazy replaces the Lazy values in fields with their string, so
+// that they are built once and redacted.
+func resolveLazy(fields log.Fields) log.Fields {
+	for k, v := range fields {
+		if l, ok := v.(lazyValue); ok {
+			fields[k] = l()
+		}
+	}
+	return fields
+}
+
+// DebugFn logs the values returned by fn like Debug, calling fn only if
+// the entry may be kept, e.g. for arguments too costly to build on the hot
+// path while debug is off.
+func DebugFn(fn func() []interface{}) {
+	if enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprint(fn()...), nil)
+	}
+}
+
+// DebugFn logs the values returned by fn like Debug, calling fn only if
+// the entry may be kept.
+func (l *Logger) DebugFn(fn func() []interface{}) {
+	if l.enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprint(fn()...), l.data())
+	}
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	var out bytes.Buffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+	SetLevel("info")
+	defer SetLevel("debug")
+
+	calls := 0
+	dump := func() string {
+		calls++
+		return "00 1b 21"
+	}
+	Debug("rx ", Lazy(dump))
+	Debugf("rx %v", Lazy(dump))
+	WithFields(Fields{"dump": Lazy(dump)}).Debug("rx")
+	DebugFn(func() []interface{} {
+		calls++
+		return nil
+	})
+	if calls != 0 || out.Len() != 0 {
+		t.Fatalf("%d calls and %q at info, want none", calls, out.String())
+	}
+
+	SetLevel("debug")
+	Debug("rx ", Lazy(dump))
+	WithFields(Fields{"dump": Lazy(dump)}).Debug("rx")
+	DebugFn(func() []interface{} { return []interface{}{"rx ", len("frame")} })
+	if calls != 2 {
+		t.Errorf("%d calls at debug, want 2", calls)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"msg":"rx 00 1b 21"`) || !strings.Contains(lines[1], `"dump":"00 1b 21"`) || !strings.Contains(lines[2], `"msg":"rx 5"`) {
+		t.Errorf("wrote %q", out.String())
+	}
+}
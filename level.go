@@ -0,0 +1,176 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// levelAliases maps the accepted spellings of the levels to them.
+var levelAliases = map[string]log.Level{
+	"panic":   log.PanicLevel,
+	"fatal":   log.FatalLevel,
+	"error":   log.ErrorLevel,
+	"err":     log.ErrorLevel,
+	"warning": log.WarnLevel,
+	"warn":    log.WarnLevel,
+	"info":    log.InfoLevel,
+	"debug":   log.DebugLevel,
+	"trace":   traceLevel,
+}
+
+// ParseLevel validates a level as accepted by SetLevel and returns its
+// canonical name: panic, fatal, error, warning, info, debug, trace or a
+// verbosity v2, v3 and so on, see V. Levels are case-insensitive, "err" and
+// "warn" are aliases of error and warning, v0 and v1 of debug and trace,
+// and the numbers 0 (panic) to 5 (debug) are accepted too.
+func ParseLevel(level string) (string, error) {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return "", err
+	}
+	return levelName(lvl), nil
+}
+
+func parseLevel(level string) (log.Level, error) {
+	s := strings.ToLower(strings.TrimSpace(level))
+	if lvl, ok := levelAliases[s]; ok {
+		return lvl, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= int(log.PanicLevel) && n <= int(log.DebugLevel) {
+		return log.Level(n), nil
+	}
+	if lvl, ok := parseVerbosity(s); ok {
+		return lvl, nil
+	}
+	return 0, fmt.Errorf(`not a valid level: "%s"`, level)
+}
+
+// stdLevel points at the level of the package logger. It is accessed
+// atomically rather than through logrus, which takes the mutex held while
+// entries are written: changing or checking the level never waits for a
+// slow output, and is safe from hooks.
+func stdLevel() *uint32 {
+	return (*uint32)(&log.StandardLogger().Level)
+}
+
+func getLevel() log.Level {
+	return log.Level(atomic.LoadUint32(stdLevel()))
+}
+
+func setLevel(lvl log.Level) {
+	atomic.StoreUint32(stdLevel(), uint32(lvl))
+}
+
+// IsDebugEnabled reports whether debug entries of the package logger may be
+// written, to skip building their arguments on hot paths:
+//
+//	if log.IsDebugEnabled() {
+//		log.Debug("rx ", hex.Dump(pkt))
+//	}
+func IsDebugEnabled() bool {
+	return enabled(log.DebugLevel)
+}
+
+// IsTraceEnabled is IsDebugEnabled for trace entries.
+func IsTraceEnabled() bool {
+	return enabled(traceLevel)
+}
+
+// IsDebugEnabled reports whether debug entries of l may be written.
+func (l *Logger) IsDebugEnabled() bool {
+	return l.enabled(log.DebugLevel)
+}
+
+// IsTraceEnabled reports whether trace entries of l may be written.
+func (l *Logger) IsTraceEnabled() bool {
+	return l.enabled(traceLevel)
+}
+
+// changeLevel sets the level of the package logger on behalf of source,
+// e.g. a signal, and logs the change.
+func changeLevel(lvl log.Level, source string) {
+	old := log.Level(atomic.SwapUint32(stdLevel(), uint32(lvl)))
+	if old == lvl {
+		return
+	}
+	emit(log.InfoLevel, captureCaller(log.InfoLevel, 0), "level changed", log.Fields{
+		"level_old": levelName(old),
+		"level_new": levelName(lvl),
+		"source":    source,
+	})
+}
+
+// stepLevel moves the level of the package logger steps levels towards
+// debug, or towards error if steps is negative, cycling through error,
+// warning, info and debug.
+func stepLevel(steps int, source string) {
+	const n = int(log.DebugLevel-log.ErrorLevel) + 1
+	i := int(getLevel()) - int(log.ErrorLevel)
+	if i < 0 {
+		// Fatal and panic count as one step less verbose than error.
+		i = -1
+	} else if i >= n {
+		// Trace and the verbosities count as debug.
+		i = n - 1
+	}
+	i = ((i+steps)%n + n) % n
+	changeLevel(log.ErrorLevel+log.Level(i), source)
+}
+
+// maxLevelRequest is the size of the largest body ServeLevelHandler reads.
+const maxLevelRequest = 1 << 10
+
+// levelBody is the JSON document served and accepted by ServeLevelHandler.
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// ServeLevelHandler returns an HTTP handler reading and changing the level
+// of the package logger on a live process. GET answers {"level":"info"};
+// PUT sets the level from such a document or from a plain text body as
+// accepted by SetLevel, e.g.
+//
+//	curl -X PUT -d debug http://localhost:8080/debug/level
+//
+// and answers the new level. The change is logged at INFO.
+func ServeLevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			b, err := io.ReadAll(io.LimitReader(r.Body, maxLevelRequest))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			name := strings.TrimSpace(string(b))
+			if strings.HasPrefix(name, "{") {
+				var body levelBody
+				if err := json.Unmarshal(b, &body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				name = body.Level
+			}
+			lvl, err := parseLevel(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			changeLevel(lvl, "http "+r.RemoteAddr)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(levelBody{levelName(getLevel())})
+	})
+}
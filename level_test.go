@@ -0,0 +1,156 @@
+package log
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]string{
+		"debug":   "debug",
+		"INFO":    "info",
+		" Warn ":  "warning",
+		"warning": "warning",
+		"err":     "error",
+		"Error":   "error",
+		"fatal":   "fatal",
+		"0":       "panic",
+		"5":       "debug",
+		"TRACE":   "trace",
+		"v1":      "trace",
+		"v0":      "debug",
+		"v3":      "v3",
+	} {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "verbose", "6", "-1", "2.0", "v", "v-1", "v+2"} {
+		if _, err := ParseLevel(in); err == nil {
+			t.Errorf("ParseLevel(%q) = nil error, want an error", in)
+		}
+	}
+}
+
+func TestSetLevelInvalid(t *testing.T) {
+	defer SetLevel("debug")
+	if err := SetLevel("WARN"); err != nil {
+		t.Fatalf(`SetLevel("WARN") = %v`, err)
+	}
+	if err := SetLevel("loud"); err == nil || err.Error() != `not a valid level: "loud"` {
+		t.Errorf(`SetLevel("loud") = %v, want an error`, err)
+	}
+	if log.GetLevel() != log.WarnLevel {
+		t.Errorf("level = %s after an invalid SetLevel, want it unchanged", log.GetLevel())
+	}
+}
+
+func TestServeLevelHandler(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetLevel("info")
+	defer SetLevel("debug")
+
+	srv := httptest.NewServer(ServeLevelHandler())
+	defer srv.Close()
+
+	get := func() string {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return strings.TrimSpace(string(b))
+	}
+	put := func(body string) int {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := get(); got != `{"level":"info"}` {
+		t.Errorf("GET = %s", got)
+	}
+	if code := put("debug"); code != http.StatusOK {
+		t.Errorf("PUT debug = %d", code)
+	}
+	if got := get(); got != `{"level":"debug"}` {
+		t.Errorf("GET after PUT = %s", got)
+	}
+	if code := put(`{"level":"WARN"}`); code != http.StatusOK {
+		t.Errorf("PUT JSON = %d", code)
+	}
+	if log.GetLevel() != log.WarnLevel {
+		t.Errorf("level = %s, want warning", log.GetLevel())
+	}
+	if code := put("loud"); code != http.StatusBadRequest {
+		t.Errorf("PUT loud = %d, want 400", code)
+	}
+	if log.GetLevel() != log.WarnLevel {
+		t.Errorf("level = %s after an invalid PUT, want it unchanged", log.GetLevel())
+	}
+	if !strings.Contains(out.String(), "level_new=debug level_old=info") {
+		t.Errorf("output = %q, want the change logged", out.String())
+	}
+
+	resp, err := http.Post(srv.URL, "text/plain", strings.NewReader("debug"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestIsDebugEnabled(t *testing.T) {
+	defer SetLevel("debug")
+	SetLevel("info")
+	if IsDebugEnabled() || IsTraceEnabled() || WithFields(Fields{"iface": "eth1"}).IsDebugEnabled() {
+		t.Error("debug enabled at info")
+	}
+	SetLevel("debug")
+	if !IsDebugEnabled() || IsTraceEnabled() {
+		t.Error("IsDebugEnabled or IsTraceEnabled wrong at debug")
+	}
+	SetLevel("trace")
+	if !IsTraceEnabled() || !WithFields(Fields{"iface": "eth1"}).IsTraceEnabled() {
+		t.Error("trace disabled at trace")
+	}
+}
+
+func TestStepLevel(t *testing.T) {
+	SetOutputs(&syncBuffer{})
+	defer SetOutputs(os.Stderr)
+	defer SetLevel("debug")
+	for _, tt := range []struct {
+		from  string
+		steps int
+		want  log.Level
+	}{
+		{"info", 1, log.DebugLevel},
+		{"debug", 1, log.ErrorLevel},
+		{"error", -1, log.DebugLevel},
+		{"warning", -1, log.ErrorLevel},
+		{"fatal", 1, log.ErrorLevel},
+	} {
+		SetLevel(tt.from)
+		stepLevel(tt.steps, "test")
+		if got := log.GetLevel(); got != tt.want {
+			t.Errorf("stepLevel(%d) from %s = %s, want %s", tt.steps, tt.from, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package log
+
+import (
+	"errors"
+	"runtime"
+)
+
+// SetLevelSignals is not supported on this platform, which has no SIGUSR1
+// and SIGUSR2; use ServeLevelHandler instead.
+func SetLevelSignals(on bool) error {
+	if !on {
+		return nil
+	}
+	return errors.New("level signals are not supported on " + runtime.GOOS)
+}
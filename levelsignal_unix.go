@@ -0,0 +1,47 @@
+//go:build unix
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var levelSignals struct {
+	sync.Mutex
+	ch chan os.Signal
+}
+
+// SetLevelSignals makes SIGUSR1 switch the package logger to the next more
+// verbose level and SIGUSR2 to the next less verbose one, cycling through
+// error, warning, info and debug, e.g. to get debug entries from a live
+// process with kill -USR1. Every change is logged at INFO. false stops
+// handling the signals.
+func SetLevelSignals(on bool) error {
+	levelSignals.Lock()
+	defer levelSignals.Unlock()
+
+	if levelSignals.ch != nil {
+		signal.Stop(levelSignals.ch)
+		close(levelSignals.ch)
+		levelSignals.ch = nil
+	}
+	if !on {
+		return nil
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	levelSignals.ch = ch
+	go func() {
+		for sig := range ch {
+			if sig == syscall.SIGUSR1 {
+				stepLevel(1, "SIGUSR1")
+			} else {
+				stepLevel(-1, "SIGUSR2")
+			}
+		}
+	}()
+	return nil
+}
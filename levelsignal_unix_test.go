@@ -0,0 +1,38 @@
+//go:build unix
+
+package log
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestSetLevelSignals(t *testing.T) {
+	SetOutputs(&syncBuffer{})
+	defer SetOutputs(os.Stderr)
+	SetLevel("info")
+	defer SetLevel("debug")
+	if err := SetLevelSignals(true); err != nil {
+		t.Fatal(err)
+	}
+	defer SetLevelSignals(false)
+
+	waitLevel := func(want log.Level) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for log.GetLevel() != want && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := log.GetLevel(); got != want {
+			t.Fatalf("level = %s, want %s", got, want)
+		}
+	}
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	waitLevel(log.DebugLevel)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	waitLevel(log.InfoLevel)
+}
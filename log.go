@@ -10,10 +10,11 @@ package log
 import (
 	"fmt"
 	"os"
-	"path"
-	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -21,46 +22,305 @@ import (
 
 type Formatter struct {
 	once *sync.Once
+
+	// CallerStyle selects how the caller's file is printed.
+	CallerStyle CallerStyle
+	// CallerFunction appends the caller's function name to file:line.
+	CallerFunction bool
+	// Color prints the severity label in the ANSI color of its level.
+	Color bool
 }
 
 // tag represents the application name generating the log message. The tag
 // string will appear in all log entires.
 var (
-	formatter = &Formatter{&sync.Once{}}
-	tag       string
-	file      string
-	line      int
+	tag string
+
+	// formatter holds the *Formatter of the package logger, whose options
+	// the other formats use as well. It is replaced by updateFormatter,
+	// never modified, so readers load it once per entry without a lock.
+	formatter   atomic.Value
+	formatterMu sync.Mutex
 )
 
+// packageFormatter returns the Formatter of the package logger.
+func packageFormatter() *Formatter {
+	return formatter.Load().(*Formatter)
+}
+
+// updateFormatter replaces the Formatter of the package logger with a copy
+// changed by update and installs the active format again.
+func updateFormatter(update func(f *Formatter)) {
+	formatterMu.Lock()
+	f := *packageFormatter()
+	update(&f)
+	formatter.Store(&f)
+	log.SetFormatter(activeFormatter())
+	formatterMu.Unlock()
+}
+
 func (c *Formatter) Format(entry *log.Entry) ([]byte, error) {
-	timestamp := time.Now().Format(time.RFC3339)
-	hostname, _ := os.Hostname()
-	return []byte(fmt.Sprintf("%s %s : %s\t%s:%d[%d] %s\n", timestamp, hostname, strings.ToUpper(entry.Level.String()), file, line, os.Getpid(), entry.Message)), nil
+	label := levelLabel(entry.Level)
+	if c.Color {
+		label = colorLabel(entry.Level, label)
+	}
+	return formatLabeled(entry.Time, label, c.caller(entryCaller(entry.Data)), entry.Message, entry.Data), nil
 }
 
-func Init(logFile, logLevel string) {
-	init := func() {
-		if logLevel == "" {
-			logLevel = "debug"
+// formatLine renders a single log line in the package format. Fields are
+// appended after the message as key=value pairs sorted by key.
+func formatLine(ts time.Time, level log.Level, caller string, msg string, fields log.Fields) []byte {
+	return formatLabeled(ts, levelLabel(level), caller, msg, fields)
+}
+
+// formatLabeled renders a log line with the given severity label. The line
+// is built in a single allocation.
+func formatLabeled(ts time.Time, label string, caller string, msg string, fields log.Fields) []byte {
+	hostname := entryHost(fields)
+	b := make([]byte, 0, 64+len(hostname)+len(label)+len(caller)+len(msg)+32*len(fields))
+	b = appendTime(b, ts, time.RFC3339)
+	b = append(b, ' ')
+	b = append(b, hostname...)
+	b = append(b, " : "...)
+	b = append(b, label...)
+	b = append(b, '\t')
+	b = append(b, caller...)
+	t, _ := fields[TagKey].(string)
+	if t != "" {
+		if caller != "" {
+			b = append(b, ' ')
 		}
+		b = appendTag(b, t)
+	}
+	b = append(b, '[')
+	b = append(b, pidText...)
+	b = append(b, "] "...)
+	b = append(b, msg...)
+	b = appendFields(b, fields)
+	return append(b, '\n')
+}
+
+// appendTag appends the tag t of an entry, with the characters delimiting
+// the caller and the PID replaced by underscores.
+func appendTag(b []byte, t string) []byte {
+	for i := 0; i < len(t); i++ {
+		switch c := t[i]; c {
+		case ' ', '\t', '\n', '[', ']', ':':
+			b = append(b, '_')
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// entryTag returns the tag of an entry: the one set with WithTag or the
+// process-wide one.
+func entryTag(fields log.Fields) string {
+	if t, ok := fields[TagKey].(string); ok && t != "" {
+		return t
+	}
+	return tag
+}
+
+// pid is the process ID written in entries.
+var (
+	pid     = os.Getpid()
+	pidText = strconv.Itoa(pid)
+)
+
+// localHost is the local hostname, looked up once rather than for every
+// entry.
+var localHost struct {
+	once sync.Once
+	name string
+}
+
+// entryHost returns the hostname of an entry: the one set with WithHost or
+// the local one.
+func entryHost(fields log.Fields) string {
+	if h, ok := fields[hostKey].(string); ok {
+		return h
+	}
+	localHost.once.Do(func() { localHost.name, _ = os.Hostname() })
+	return localHost.name
+}
+
+// formatFields renders fields as " key=value" pairs, quoting values that
+// would otherwise be ambiguous.
+func formatFields(fields log.Fields) string {
+	return string(appendFields(nil, fields))
+}
 
-		tag = os.Args[0]
-		log.SetFormatter(formatter)
-		SetLevel(logLevel)
+// keysPool recycles the slices sorting the keys of appendFields.
+var keysPool = sync.Pool{New: func() interface{} { return new([]string) }}
 
-		if err := os.MkdirAll(path.Dir(logFile), os.ModeDir); err != nil {
-			Fatal(fmt.Sprintf(`create log file dir error: "%s".`, path.Dir(logFile)))
+// appendFields appends formatFields(fields) to b.
+func appendFields(b []byte, fields log.Fields) []byte {
+	if len(fields) == 0 {
+		return b
+	}
+
+	kp := keysPool.Get().(*[]string)
+	keys := (*kp)[:0]
+	for k := range fields {
+		if !isReserved(k) {
+			keys = append(keys, k)
 		}
+	}
+	sort.Strings(keys)
 
-		f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
-		if err != nil {
-			Fatal(fmt.Sprintf(`can not open log file: "%s".`, logFile))
+	for _, k := range keys {
+		value := fields[k]
+		if s, ok := value.(string); ok && k == LayersKey {
+			// Hand-written layer lists get the same rendering as Layers.
+			value = parseLayers(s)
 		}
-		log.SetOutput(f)
+		if t, ok := value.(time.Time); ok && k == DeadlineKey {
+			// Deadlines are compared across hosts: UTC, without spaces.
+			value = t.UTC().Format(time.RFC3339Nano)
+		}
+		b = append(b, ' ')
+		b = append(b, k...)
+		b = append(b, '=')
+		b = appendValue(b, value)
+	}
+	*kp = keys[:0]
+	keysPool.Put(kp)
+	return b
+}
 
+// appendValue appends a field value, quoted if it would otherwise be
+// ambiguous. Common types are rendered without fmt.
+func appendValue(b []byte, value interface{}) []byte {
+	if nf := activeNumberFormat(); nf != (NumberFormat{}) {
+		if v, ok := nf.formatNumber(value, true); ok {
+			return append(b, v...)
+		}
+	}
+	var v string
+	switch value := value.(type) {
+	case string:
+		v = value
+	case int:
+		return strconv.AppendInt(b, int64(value), 10)
+	case int64:
+		return strconv.AppendInt(b, value, 10)
+	case uint64:
+		return strconv.AppendUint(b, value, 10)
+	case bool:
+		return strconv.AppendBool(b, value)
+	default:
+		v = fmt.Sprint(value)
+	}
+	if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+		return strconv.AppendQuote(b, v)
 	}
+	return append(b, v...)
+}
+
+func init() {
+	formatter.Store(&Formatter{once: &sync.Once{}})
+	log.SetFormatter(packageFormatter())
+}
 
-	formatter.once.Do(init)
+// Init configures the package logger to write the entries at logLevel,
+// debug if empty, or more severe to logFile. Only the first call of Init
+// or one of the Init presets has an effect: concurrent calls wait for it to
+// finish and a later call asking for another file or level only logs a
+// warning naming what it asked for. Entries logged before go to stderr in
+// the text format; the first BootstrapBuffer of them are also written to
+// the outputs of Init once it is done, unless those are stderr.
+func Init(logFile, logLevel string) {
+	if logLevel == "" {
+		logLevel = "debug"
+	}
+	o := newOptions([]Option{OutputFile(logFile), MinLevel(logLevel)})
+	if len(o.errs) > 0 {
+		Fatal(o.errs[0].Error())
+	}
+	var err error
+	initOnce("Init", logFile, logLevel, func() { err = o.init(logFile) })
+	if err != nil {
+		Fatal(err.Error())
+	}
+}
+
+// initFile initializes the package logger with the file logFile at
+// logLevel, for the presets.
+func initFile(logFile, logLevel string) {
+	lvl, err := parseLevel(logLevel)
+	if err != nil {
+		Fatal(err.Error())
+	}
+	o := options{level: lvl, outputs: []outputOption{{file: logFile}}}
+	if err := o.init(logFile); err != nil {
+		Fatal(err.Error())
+	}
+}
+
+// initState records the first initialization of the package logger.
+var initState struct {
+	sync.Mutex
+	by, file, level string
+}
+
+// initOnce runs setup, the initialization of the package logger by the
+// function by with the log file and level, unless the package logger is
+// initialized already, then reports the configuration problems found by
+// CheckConfig. Concurrent calls wait for the first one to finish.
+// A later call asking for a different configuration has no effect either,
+// a warning names what it asked for. initOnce reports whether setup ran.
+func initOnce(by, file, level string, setup func()) bool {
+	if initFirst(by, file, level, 3, setup) {
+		return true
+	}
+
+	initState.Lock()
+	prevBy, prevFile, prevLevel := initState.by, initState.file, initState.level
+	initState.Unlock()
+	fields := log.Fields{}
+	if by != prevBy {
+		fields["init"], fields["ignored_init"] = prevBy, by
+	}
+	if file != prevFile {
+		fields["file"], fields["ignored_file"] = prevFile, file
+	}
+	if l, err := ParseLevel(level); err != nil || l != canonicalLevel(prevLevel) {
+		fields["level"], fields["ignored_level"] = prevLevel, level
+	}
+	if len(fields) > 0 {
+		emit(log.WarnLevel, captureCaller(log.WarnLevel, 2), "logger already initialized, configuration ignored", fields)
+	}
+	return false
+}
+
+// initFirst runs setup as initOnce, without the warning, and reports
+// whether it ran. skip is the number of frames above the function whose
+// caller the configuration problems are attributed to.
+func initFirst(by, file, level string, skip int, setup func()) bool {
+	first := false
+	packageFormatter().once.Do(func() {
+		first = true
+		initState.Lock()
+		initState.by, initState.file, initState.level = by, file, level
+		initState.Unlock()
+		setup()
+	})
+	if first {
+		endBootstrap(true)
+		reportConfigProblems(captureCaller(log.WarnLevel, skip))
+	}
+	return first
+}
+
+// canonicalLevel returns the canonical name of level, level itself if it
+// is not valid.
+func canonicalLevel(level string) string {
+	if l, err := ParseLevel(level); err == nil {
+		return l
+	}
+	return level
 }
 
 // SetTag sets the tag.
@@ -68,70 +328,200 @@ func SetTag(t string) {
 	tag = t
 }
 
-// SetLevel sets the log level. Valid levels are panic, fatal, error, warn, info and debug.
-func SetLevel(level string) {
-	lvl, err := log.ParseLevel(level)
+// SetLevel sets the log level. Valid levels are panic, fatal, error, warn,
+// info, debug and trace, in any case, or verbosities such as v3 or as
+// accepted by ParseLevel. An invalid level leaves the level unchanged. It
+// is safe to call at any time, from any goroutine.
+func SetLevel(level string) error {
+	lvl, err := parseLevel(level)
 	if err != nil {
-		Fatal(fmt.Sprintf(`not a valid level: "%s"`, level))
+		return err
+	}
+	setLevel(lvl)
+	return nil
+}
+
+// output records the caller of the exported logging function and hands msg
+// to logrus at the given level.
+func output(level log.Level, msg string, fields log.Fields) {
+	fields = withStack(level, 2, fields)
+	emit(level, captureCaller(level, 2), msg, fields)
+}
+
+// emit runs an entry through the pipeline: Lazy values and protobuf
+// encoding, redaction, recording, enrichment, type coercion, upgrade rules,
+// the recent buffer, the error summary, the level, the filters, the
+// component rates, the alert rules, sampling, deduplication, repeat
+// compaction, the component budgets, the rate limit, the message policy
+// and finally logrus or, in strict ordering mode, the reorder buffer.
+func emit(level log.Level, site callSite, msg string, fields log.Fields) {
+	level, msg, fields, ok := prepare(level, site, msg, fields)
+	if !ok {
+		return
+	}
+	countWritten(level)
+	if parts := shapeMessage(level, msg, fields); parts != nil {
+		for _, p := range parts {
+			if !emitOrdered(level, site, p.msg, p.fields) {
+				dispatch(level, site, p.msg, p.fields)
+			}
+		}
+		return
+	}
+	if !emitOrdered(level, site, msg, fields) {
+		dispatch(level, site, msg, fields)
+	}
+}
+
+// prepare runs the steps of emit before logrus and reports whether the
+// entry is to be written.
+func prepare(level log.Level, site callSite, msg string, fields log.Fields) (log.Level, string, log.Fields, bool) {
+	fields = encodeProtos(resolveLazy(entryTime(fields)))
+	msg = redact(msg, fields)
+	record(level, site, msg, fields)
+	n := len(fields)
+	if fields = extractFields(fields); len(fields) != n {
+		redact("", fields)
+	}
+	fields = coerceFields(fields)
+	markOverdue(fields)
+	fields = addUptime(fields)
+	level, fields = upgrade(level, msg, fields)
+	recent.add(level, site, msg, fields)
+	summary.add(level, site, msg)
+
+	if level > levelOf(fields) || filtered(level, site, msg, fields) {
+		return level, msg, fields, false
+	}
+	countEntry(fields, time.Now())
+	evaluateAlerts(level, site, msg, fields)
+	talkers.add(fields)
+	if !sampling.keep(level, fields) {
+		return level, msg, fields, false
+	}
+	fields, ok := dedup.allow(level, site, msg, fields)
+	return level, msg, fields, ok && repeats.allow(level, msg, fields) && allowBudget(level, msg, fields) && limiter.allow(level)
+}
+
+// dispatch hands an entry to logrus. The caller counts it, see countWritten.
+func dispatch(level log.Level, site callSite, msg string, fields log.Fields) {
+	in := instanceOf(fields)
+	if level > log.DebugLevel || in == nil && level > getLevel() {
+		// A trace entry, which logrus has no level for, or kept by a
+		// component level more verbose than logrus's, which would drop it.
+		if b, ok := renderEntry(level, site, msg, fields); ok {
+			writeOutput(in, b)
+		}
+		return
+	}
+	entry := log.NewEntry(loggerOf(in)).WithFields(withCaller(fields, site))
+	if t, ok := fields[timeKey].(time.Time); ok {
+		entry = entry.WithTime(t)
+	}
+	switch level {
+	case log.DebugLevel:
+		entry.Debug(msg)
+	case log.InfoLevel:
+		entry.Info(msg)
+	case log.WarnLevel:
+		entry.Warning(msg)
+	case log.ErrorLevel:
+		entry.Error(msg)
+	case log.FatalLevel:
+		// logrus would call os.Exit itself.
+		if b, ok := renderEntry(level, site, msg, fields); ok {
+			writeOutput(in, b)
+		}
+		fatalExit(msg)
+	case log.PanicLevel:
+		// Panic panics itself, with the message rather than logrus's entry.
+		// The panic may end the process, the entry must not be left in a
+		// buffer.
+		if b, ok := renderEntry(level, site, msg, fields); ok {
+			writeOutput(in, b)
+		}
+		dumpRecentOn("panic")
+		flushOutputs(flushTimeout())
 	}
-	log.SetLevel(lvl)
 }
 
 // Debug logs a message with severity DEBUG.
 func Debug(v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Debug(fmt.Sprint(v...))
+	if enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprint(v...), nil)
+	}
 }
 
 // Error logs a message with severity ERROR.
 func Error(v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Error(fmt.Sprint(v...))
+	output(log.ErrorLevel, fmt.Sprint(v...), nil)
 }
 
 // Fatal logs a message with severity ERROR followed by a call to os.Exit().
+// The exit handlers run and the outputs are closed first, see
+// RegisterExitHandler and SetFatalFlushTimeout.
 func Fatal(v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Fatal(fmt.Sprint(v...))
+	output(log.FatalLevel, fmt.Sprint(v...), nil)
+}
+
+// Panic logs a message with severity PANIC followed by a call to panic with
+// the message. Buffered outputs are flushed first.
+func Panic(v ...interface{}) {
+	msg := fmt.Sprint(v...)
+	output(log.PanicLevel, msg, nil)
+	panic(msg)
 }
 
 // Info logs a message with severity INFO.
 func Info(v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Info(fmt.Sprint(v...))
+	if enabled(log.InfoLevel) {
+		output(log.InfoLevel, fmt.Sprint(v...), nil)
+	}
 }
 
 // Warning logs a message with severity WARNING.
 func Warning(v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Warning(fmt.Sprint(v...))
+	if enabled(log.WarnLevel) {
+		output(log.WarnLevel, fmt.Sprint(v...), nil)
+	}
 }
 
 func Debugf(format string, v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Debug(fmt.Sprintf(format, v...))
+	if enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprintf(format, v...), nil)
+	}
 }
 
 // Error logs a message with severity ERROR.
 func Errorf(format string, v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Error(fmt.Sprintf(format, v...))
+	output(log.ErrorLevel, fmt.Sprintf(format, v...), nil)
 }
 
 // Fatal logs a message with severity ERROR followed by a call to os.Exit().
+// The exit handlers run and the outputs are closed first, see
+// RegisterExitHandler and SetFatalFlushTimeout.
 func Fatalf(format string, v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Fatal(fmt.Sprintf(format, v...))
+	output(log.FatalLevel, fmt.Sprintf(format, v...), nil)
+}
+
+// Panicf logs a formatted message with severity PANIC followed by a call to
+// panic with the message.
+func Panicf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	output(log.PanicLevel, msg, nil)
+	panic(msg)
 }
 
 // Info logs a message with severity INFO.
 func Infof(format string, v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Info(fmt.Sprintf(format, v...))
+	if enabled(log.InfoLevel) {
+		output(log.InfoLevel, fmt.Sprintf(format, v...), nil)
+	}
 }
 
 // Warning logs a message with severity WARNING.
 func Warningf(format string, v ...interface{}) {
-	_, file, line, _ = runtime.Caller(1)
-	log.Warning(fmt.Sprintf(format, v...))
+	if enabled(log.WarnLevel) {
+		output(log.WarnLevel, fmt.Sprintf(format, v...), nil)
+	}
 }
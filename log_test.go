@@ -2,6 +2,85 @@ package log
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	log "github.com/Sirupsen/logrus"
 )
+
+func TestFormatLine(t *testing.T) {
+	ts := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	got := string(formatLine(ts, log.WarnLevel, "main.go:42", "disk full", nil))
+	want := fmt.Sprintf("WARNING\tmain.go:42[%d] disk full\n", os.Getpid())
+	if len(got) < len(want) || got[len(got)-len(want):] != want {
+		t.Errorf("formatLine() = %q, want suffix %q", got, want)
+	}
+}
+
+func TestFormatFields(t *testing.T) {
+	got := formatFields(log.Fields{
+		"bytes": 1500, "count": int64(-3), "seq": uint64(7), "ok": true,
+		"empty": "", "msg": "a b", "ratio": 0.5, "peer": "192.0.2.7",
+	})
+	want := ` bytes=1500 count=-3 empty="" msg="a b" ok=true peer=192.0.2.7 ratio=0.5 seq=7`
+	if got != want {
+		t.Errorf("formatFields() = %q, want %q", got, want)
+	}
+
+	ts := time.Now()
+	fields := log.Fields{"iface": "eth1", "bytes": 1500}
+	allocs := testing.AllocsPerRun(100, func() {
+		formatLine(ts, log.InfoLevel, "main.go:42", "flow closed", fields)
+	})
+	if allocs > 1 {
+		t.Errorf("formatLine allocates %v times, want once", allocs)
+	}
+}
+
+func TestInitConflict(t *testing.T) {
+	resetInit(t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "probe.log")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Init(name, "info")
+		}()
+	}
+	wg.Wait()
+
+	Init(name, "INFO")
+	Init(filepath.Join(dir, "other.log"), "debug")
+	InitCLI()
+	Flush()
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log file = %q, want two warnings", b)
+	}
+	for i, want := range []string{
+		"logger already initialized, configuration ignored file=" + name + " ignored_file=" + filepath.Join(dir, "other.log") + " ignored_level=debug level=info",
+		"logger already initialized, configuration ignored file=" + name + " ignored_file=\"\" ignored_init=InitCLI init=Init",
+	} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+		if !strings.Contains(lines[i], "log_test.go:") {
+			t.Errorf("line %d = %q, want the caller of Init", i, lines[i])
+		}
+	}
+	if CurrentConfig().Level != "info" {
+		t.Errorf("level = %s, want the first Init's", CurrentConfig().Level)
+	}
+}
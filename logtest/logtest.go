@@ -0,0 +1,125 @@
+/*
+Package logtest records the entries of package log in tests, to assert that
+code logged, or did not log, something:
+
+	func TestRetry(t *testing.T) {
+		rec := logtest.Capture(t)
+		retry(failingDial)
+		if !rec.ContainsMessage("warning", "retrying") {
+			t.Errorf("no retry logged: %v", rec.Entries())
+		}
+		rec.AssertNoErrors(t)
+	}
+
+Entries are recorded as they are written, i.e. after the level, sampling,
+deduplication and the rate limit, and still reach the configured outputs.
+*/
+package logtest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	golog "github.com/net-sniper/go-log"
+)
+
+// Recorder holds the entries written since Capture or Reset. It is safe
+// for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []golog.Entry
+}
+
+// Capture records every entry written until the end of t. The level of the
+// package logger is restored then, so that t may change it, e.g. to record
+// debug entries.
+func Capture(t testing.TB) *Recorder {
+	r := &Recorder{}
+	level := golog.CurrentConfig().Level
+	// The filter is called synchronously for every entry written, unlike
+	// the channel, which drops entries when full.
+	_, cancel := golog.Subscribe(func(e golog.Entry) bool {
+		r.mu.Lock()
+		r.entries = append(r.entries, e)
+		r.mu.Unlock()
+		return false
+	})
+	t.Cleanup(func() {
+		cancel()
+		golog.SetLevel(level)
+	})
+	return r
+}
+
+// Epoch is the time of the first entry logged after Deterministic.
+var Epoch = time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// Deterministic puts the package logger in test mode until the end of t,
+// see golog.SetTestMode, so that its output can be compared with a golden
+// file: entries are written synchronously, the host is "test", the PID 1
+// and the nth entry is stamped Epoch plus n-1 milliseconds.
+func Deterministic(t testing.TB) {
+	var mu sync.Mutex
+	now := Epoch
+	golog.SetTestMode(&golog.TestMode{
+		Clock: func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			ts := now
+			now = now.Add(time.Millisecond)
+			return ts
+		},
+		Host: "test",
+		Pid:  1,
+	})
+	t.Cleanup(func() { golog.SetTestMode(nil) })
+}
+
+// Entries returns the recorded entries, oldest first.
+func (r *Recorder) Entries() []golog.Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]golog.Entry(nil), r.entries...)
+}
+
+// Reset discards the recorded entries.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+// ContainsMessage reports whether an entry at level has a message
+// containing substr. The empty level matches every level.
+func (r *Recorder) ContainsMessage(level, substr string) bool {
+	var want log.Level
+	if level != "" {
+		var err error
+		if want, err = log.ParseLevel(level); err != nil {
+			return false
+		}
+	}
+	for _, e := range r.Entries() {
+		lvl, err := log.ParseLevel(e.Level)
+		if err != nil || level != "" && lvl != want {
+			continue
+		}
+		if strings.Contains(e.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertNoErrors fails t for every recorded entry at error or more severe.
+func (r *Recorder) AssertNoErrors(t testing.TB) {
+	t.Helper()
+	for _, e := range r.Entries() {
+		if lvl, err := log.ParseLevel(e.Level); err == nil && lvl <= log.ErrorLevel {
+			t.Errorf("logged %s: %s %v", e.Level, e.Message, e.Fields)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package logtest
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	golog "github.com/net-sniper/go-log"
+)
+
+// failRecorder is a testing.TB recording failures instead of failing.
+type failRecorder struct {
+	testing.TB
+	failures []string
+}
+
+func (f *failRecorder) Helper() {}
+
+func (f *failRecorder) Errorf(format string, args ...interface{}) {
+	f.failures = append(f.failures, format)
+}
+
+func TestCapture(t *testing.T) {
+	golog.SetOutputs(io.Discard)
+	defer golog.SetOutputs(os.Stderr)
+
+	var rec *Recorder
+	t.Run("capture", func(t *testing.T) {
+		rec = Capture(t)
+		golog.SetLevel("debug")
+		golog.Debug("probing eth1")
+		golog.Warning("retrying in 5s")
+		golog.Error("link down")
+
+		if n := len(rec.Entries()); n != 3 {
+			t.Fatalf("recorded %d entries, want 3: %+v", n, rec.Entries())
+		}
+		for _, tt := range []struct {
+			level, substr string
+			want          bool
+		}{
+			{"warning", "retrying", true},
+			{"warn", "retrying", true},
+			{"", "probing", true},
+			{"error", "retrying", false},
+			{"info", "", false},
+			{"loud", "link", false},
+		} {
+			if got := rec.ContainsMessage(tt.level, tt.substr); got != tt.want {
+				t.Errorf("ContainsMessage(%q, %q) = %v, want %v", tt.level, tt.substr, got, tt.want)
+			}
+		}
+
+		f := &failRecorder{TB: t}
+		rec.AssertNoErrors(f)
+		if len(f.failures) != 1 {
+			t.Errorf("AssertNoErrors reported %d failures, want 1", len(f.failures))
+		}
+		rec.Reset()
+		rec.AssertNoErrors(t)
+	})
+
+	if lvl := golog.CurrentConfig().Level; lvl != "info" {
+		t.Errorf("level after the test = %s, want it restored to info", lvl)
+	}
+	golog.Warning("after the test")
+	if len(rec.Entries()) != 0 {
+		t.Errorf("recorded %+v after the test", rec.Entries())
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	rec := Capture(t)
+	golog.SetOutputs(io.Discard)
+	defer golog.SetOutputs(os.Stderr)
+
+	t.Run("deterministic", func(t *testing.T) {
+		Deterministic(t)
+		golog.Info("one")
+		golog.Info("two")
+	})
+	golog.Info("three")
+
+	e := rec.Entries()
+	if len(e) != 3 {
+		t.Fatalf("recorded %d entries, want 3", len(e))
+	}
+	if !e[0].Time.Equal(Epoch) || !e[1].Time.Equal(Epoch.Add(time.Millisecond)) || e[0].Host != "test" {
+		t.Errorf("entries in test mode = %+v, want the fake clock and host", e[:2])
+	}
+	if e[2].Time.Before(time.Now().Add(-time.Minute)) || e[2].Host == "test" {
+		t.Errorf("entry after the test = %+v, want the real clock and host", e[2])
+	}
+}
@@ -0,0 +1,349 @@
+/*
+Package logui renders the live entry stream of package log in a terminal,
+for interactive use of capture tools:
+
+	go logui.Run(ctx, os.Stdin, os.Stdout)
+
+Entries are colored by level. The viewer keeps a scrollback of the most
+recent entries and understands the following keys:
+
+	p, space   pause or resume following new entries
+	k, up      scroll back one line
+	j, down    scroll forward one line
+	b, f       scroll back or forward one page
+	G          jump to the newest entry and resume
+	/          edit the filter; enter applies, escape clears it
+	q, ctrl-c  quit
+
+The filter is a list of space separated terms that must all match: key=value
+matches entries whose field (or level, tag or host) equals value, any other
+term matches entries whose message contains it.
+*/
+package logui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	golog "github.com/net-sniper/go-log"
+	"golang.org/x/term"
+)
+
+// Scrollback is the number of entries kept by a Viewer.
+const Scrollback = 10000
+
+// redrawInterval bounds how often the screen is redrawn while entries
+// stream in.
+const redrawInterval = 50 * time.Millisecond
+
+// Viewer holds the state of the live view. Its methods are not safe for
+// concurrent use; Run serializes them.
+type Viewer struct {
+	entries []golog.Entry
+	paused  bool
+	// offset is the number of matching entries hidden below the view.
+	offset int
+
+	filter  string
+	editing bool
+	input   string
+	quit    bool
+}
+
+// Add appends e to the scrollback. While paused or scrolled back, the view
+// stays on the entries it shows.
+func (v *Viewer) Add(e golog.Entry) {
+	if len(v.entries) == Scrollback {
+		copy(v.entries, v.entries[1:])
+		v.entries = v.entries[:Scrollback-1]
+	}
+	v.entries = append(v.entries, e)
+	if (v.paused || v.offset > 0) && Match(v.filter, e) {
+		v.offset++
+	}
+}
+
+// HandleKey applies a key press. page is the number of entry lines shown.
+func (v *Viewer) HandleKey(key string, page int) {
+	if v.editing {
+		switch key {
+		case "\r", "\n":
+			v.filter, v.editing, v.offset = v.input, false, 0
+		case "\x1b":
+			v.filter, v.input, v.editing, v.offset = "", "", false, 0
+		case "\x7f", "\b":
+			if v.input != "" {
+				v.input = v.input[:len(v.input)-1]
+			}
+		default:
+			if len(key) == 1 && key[0] >= ' ' {
+				v.input += key
+			}
+		}
+		return
+	}
+
+	switch key {
+	case "q", "\x03":
+		v.quit = true
+	case "p", " ":
+		v.paused = !v.paused
+		if !v.paused {
+			v.offset = 0
+		}
+	case "k", "\x1b[A":
+		v.scroll(1)
+	case "j", "\x1b[B":
+		v.scroll(-1)
+	case "b", "\x1b[5~":
+		v.scroll(page)
+	case "f", "\x1b[6~":
+		v.scroll(-page)
+	case "G":
+		v.offset, v.paused = 0, false
+	case "/":
+		v.editing, v.input = true, v.filter
+	}
+}
+
+// Quit reports whether the user asked to quit.
+func (v *Viewer) Quit() bool {
+	return v.quit
+}
+
+func (v *Viewer) scroll(n int) {
+	v.offset += n
+	if max := v.matching() - 1; v.offset > max {
+		v.offset = max
+	}
+	if v.offset < 0 {
+		v.offset = 0
+	}
+}
+
+func (v *Viewer) matching() int {
+	n := 0
+	for _, e := range v.entries {
+		if Match(v.filter, e) {
+			n++
+		}
+	}
+	return n
+}
+
+// Render draws the view on a screen of the given size.
+func (v *Viewer) Render(w io.Writer, width, height int) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x1b[H\x1b[2J")
+
+	rows := height - 1
+	if rows < 1 {
+		rows = 1
+	}
+	var shown []golog.Entry
+	skip := v.offset
+	for i := len(v.entries) - 1; i >= 0 && len(shown) < rows; i-- {
+		if !Match(v.filter, v.entries[i]) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		shown = append(shown, v.entries[i])
+	}
+	for i := len(shown) - 1; i >= 0; i-- {
+		bw.WriteString(colorize(shown[i], truncate(formatEntry(shown[i]), width)))
+		bw.WriteString("\r\n")
+	}
+	for i := len(shown); i < rows; i++ {
+		bw.WriteString("\r\n")
+	}
+
+	bw.WriteString("\x1b[7m")
+	bw.WriteString(truncate(v.status(), width))
+	bw.WriteString("\x1b[0m")
+	return bw.Flush()
+}
+
+func (v *Viewer) status() string {
+	mode := "LIVE"
+	if v.paused {
+		mode = "PAUSED"
+	} else if v.offset > 0 {
+		mode = "SCROLLED"
+	}
+	if v.editing {
+		return fmt.Sprintf(" filter: %s_", v.input)
+	}
+	s := fmt.Sprintf(" %s  %d entries", mode, len(v.entries))
+	if v.offset > 0 {
+		s += fmt.Sprintf(", %d below", v.offset)
+	}
+	if v.filter != "" {
+		s += "  filter: " + v.filter
+	}
+	return s + "  [q]uit [p]ause [/]filter [j/k] scroll"
+}
+
+// formatEntry renders e on a single line.
+func formatEntry(e golog.Entry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-7s %s", e.Time.Format("15:04:05.000"), strings.ToUpper(e.Level), e.Message)
+	for _, k := range sortedKeys(e.Fields) {
+		fmt.Fprintf(&b, " %s=%v", k, e.Fields[k])
+	}
+	return strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", " ").Replace(b.String())
+}
+
+// colorize wraps line in the ANSI color of the level of e.
+func colorize(e golog.Entry, line string) string {
+	var color string
+	switch e.Level {
+	case "panic", "fatal", "error":
+		color = "31"
+	case "warning":
+		color = "33"
+	case "debug":
+		color = "90"
+	default:
+		return line
+	}
+	return "\x1b[" + color + "m" + line + "\x1b[0m"
+}
+
+func sortedKeys(fields golog.Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func truncate(s string, width int) string {
+	if width > 0 && len(s) > width {
+		return s[:width]
+	}
+	return s
+}
+
+// Match reports whether e matches filter.
+func Match(filter string, e golog.Entry) bool {
+	for _, term := range strings.Fields(filter) {
+		key, value, ok := strings.Cut(term, "=")
+		if !ok {
+			if !strings.Contains(e.Message, term) {
+				return false
+			}
+			continue
+		}
+		var got string
+		switch key {
+		case "level":
+			got = e.Level
+		case "tag":
+			got = e.Tag
+		case "host":
+			got = e.Host
+		default:
+			v, ok := e.Fields[key]
+			if !ok {
+				return false
+			}
+			got = fmt.Sprint(v)
+		}
+		if got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Run shows the live view on out, reading keys from in, until the user
+// quits or ctx is done. If in is a terminal it is put into raw mode for the
+// duration.
+func Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		state, err := term.MakeRaw(int(f.Fd()))
+		if err != nil {
+			return err
+		}
+		defer term.Restore(int(f.Fd()), state)
+	}
+	size := func() (int, int) {
+		if f, ok := out.(*os.File); ok {
+			if w, h, err := term.GetSize(int(f.Fd())); err == nil {
+				return w, h
+			}
+		}
+		return 120, 40
+	}
+
+	entries, cancel := golog.Subscribe(nil)
+	defer cancel()
+	keys := make(chan string)
+	go readKeys(in, keys)
+
+	v := &Viewer{}
+	ticker := time.NewTicker(redrawInterval)
+	defer ticker.Stop()
+	dirty := true
+	defer fmt.Fprint(out, "\x1b[0m\r\n")
+	for {
+		select {
+		case e := <-entries:
+			v.Add(e)
+			dirty = true
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			_, h := size()
+			v.HandleKey(k, h-1)
+			if v.Quit() {
+				return nil
+			}
+			dirty = true
+		case <-ticker.C:
+			if dirty {
+				w, h := size()
+				if err := v.Render(out, w, h); err != nil {
+					return err
+				}
+				dirty = false
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// readKeys splits in into key presses, keeping escape sequences such as
+// the arrow keys together.
+func readKeys(in io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		for s := string(buf[:n]); s != ""; {
+			k := s[:1]
+			if s[0] == '\x1b' && len(s) >= 3 && s[1] == '[' {
+				if end := strings.IndexAny(s[2:], "ABCD~"); end >= 0 {
+					k = s[:end+3]
+				}
+			}
+			keys <- k
+			s = s[len(k):]
+		}
+		if err != nil {
+			return
+		}
+	}
+}
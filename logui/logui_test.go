@@ -0,0 +1,122 @@
+package logui
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	golog "github.com/net-sniper/go-log"
+)
+
+func entry(level, msg string, fields golog.Fields) golog.Entry {
+	return golog.Entry{Time: time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC), Level: level, Message: msg, Fields: fields}
+}
+
+func TestMatch(t *testing.T) {
+	e := entry("error", "write failed", golog.Fields{"flow": 7})
+	for filter, want := range map[string]bool{
+		"":                   true,
+		"failed":             true,
+		"flow=7":             true,
+		"level=error failed": true,
+		"flow=8":             false,
+		"level=info":         false,
+		"iface=eth0":         false,
+		"failed succeeded":   false,
+	} {
+		if got := Match(filter, e); got != want {
+			t.Errorf("Match(%q) = %v, want %v", filter, got, want)
+		}
+	}
+}
+
+func TestViewer(t *testing.T) {
+	v := &Viewer{}
+	v.Add(entry("info", "first", nil))
+	v.Add(entry("error", "second", golog.Fields{"flow": 7}))
+
+	var buf bytes.Buffer
+	v.Render(&buf, 80, 5)
+	screen := buf.String()
+	if !strings.Contains(screen, "\x1b[31m12:00:00.000 ERROR   second flow=7\x1b[0m") {
+		t.Errorf("error entry not rendered in red: %q", screen)
+	}
+	if !strings.Contains(screen, "LIVE  2 entries") {
+		t.Errorf("status bar missing: %q", screen)
+	}
+
+	// Paused, new entries do not move the view.
+	v.HandleKey("p", 4)
+	v.Add(entry("info", "third", nil))
+	buf.Reset()
+	v.Render(&buf, 80, 2)
+	if !strings.Contains(buf.String(), "second") || strings.Contains(buf.String(), "third") {
+		t.Errorf("paused view moved: %q", buf.String())
+	}
+	v.HandleKey("G", 4)
+
+	for _, k := range []string{"/", "f", "l", "o", "w", "=", "7", "\r"} {
+		v.HandleKey(k, 4)
+	}
+	buf.Reset()
+	v.Render(&buf, 80, 5)
+	if strings.Contains(buf.String(), "first") || !strings.Contains(buf.String(), "filter: flow=7") {
+		t.Errorf("filter not applied: %q", buf.String())
+	}
+
+	v.HandleKey("q", 4)
+	if !v.Quit() {
+		t.Error("q does not quit")
+	}
+}
+
+func TestRun(t *testing.T) {
+	golog.SetOutputs(io.Discard)
+	defer golog.SetOutputs(os.Stderr)
+
+	in, keys := io.Pipe()
+	var out syncWriter
+	done := make(chan error)
+	go func() { done <- Run(context.Background(), in, &out) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "link flap") && time.Now().Before(deadline) {
+		golog.Warning("link flap")
+		time.Sleep(20 * time.Millisecond)
+	}
+	keys.Write([]byte("q"))
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after q")
+	}
+	if !strings.Contains(out.String(), "\x1b[33m") {
+		t.Errorf("warning not rendered in yellow: %q", out.String())
+	}
+}
+
+type syncWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *syncWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
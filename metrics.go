@@ -0,0 +1,108 @@
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// MetricsHandler returns an HTTP handler rendering Stats in the Prometheus
+// text exposition format, so that Prometheus scrapes the volume of entries
+// by level, the dropped entries, write errors and rotations directly,
+// without a client library, e.g. to alert on the rate of errors:
+//
+//	http.Handle("/metrics/log", log.MetricsHandler())
+//
+//	rate(golog_entries_total{level="error"}[5m]) > 1
+//
+// Every metric is prefixed with golog_.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		writeMetrics(bw, Stats())
+		bw.Flush()
+	})
+}
+
+// metric is a sample of a metric family.
+type metric struct {
+	labels string
+	value  interface{}
+}
+
+// writeMetrics writes s in the Prometheus text format.
+func writeMetrics(w *bufio.Writer, s Statistics) {
+	family := func(name, typ, help string, samples ...metric) {
+		fmt.Fprintf(w, "# HELP golog_%s %s\n# TYPE golog_%s %s\n", name, help, name, typ)
+		for _, m := range samples {
+			fmt.Fprintf(w, "golog_%s%s %v\n", name, m.labels, m.value)
+		}
+	}
+
+	levels := make([]string, 0, len(s.Entries))
+	for lvl := range s.Entries {
+		levels = append(levels, lvl)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		a, _ := parseLevel(levels[i])
+		b, _ := parseLevel(levels[j])
+		return a < b
+	})
+	var entries []metric
+	for _, lvl := range levels {
+		entries = append(entries, metric{metricLabels("level", lvl), s.Entries[lvl]})
+	}
+	family("entries_total", "counter", "Entries written, by level.", entries...)
+
+	family("dropped_entries_total", "counter", "Entries dropped before being written, by reason.",
+		metric{metricLabels("reason", "rate_limit"), s.RateLimited},
+		metric{metricLabels("reason", "sampling"), s.Sampled},
+		metric{metricLabels("reason", "filter"), s.Filtered},
+		metric{metricLabels("reason", "dedup"), s.Deduplicated},
+		metric{metricLabels("reason", "repeat"), s.Repeated},
+		metric{metricLabels("reason", "budget"), s.OverBudget},
+		metric{metricLabels("reason", "subscriber"), s.SubscriberDropped})
+	family("write_errors_total", "counter", "Failed writes to an output.", metric{"", s.WriteErrors})
+	family("fallback_writes_total", "counter", "Failed writes to a log file written to stderr instead.", metric{"", s.Fallback})
+	family("truncated_messages_total", "counter", "Messages truncated by the message policy.", metric{"", s.Truncated})
+	family("rotations_total", "counter", "Log files rotated.", metric{"", s.Rotations})
+	if r := s.Rotation; r != nil {
+		family("file_size_bytes", "gauge", "Size of the log file.", metric{"", r.Size})
+		family("backups", "gauge", "Rotated log files on disk.", metric{"", len(r.Backups)})
+		family("backup_size_bytes", "gauge", "Size of the rotated log files on disk.", metric{"", r.BackupSize})
+		if !r.NextRotation.IsZero() {
+			family("next_rotation_timestamp_seconds", "gauge", "Time of the next scheduled rotation.", metric{"", r.NextRotation.Unix()})
+		}
+	}
+	family("delivered_total", "counter", "Entries acknowledged by remote outputs.", metric{"", s.Delivered})
+	family("delivery_failed_total", "counter", "Entries remote outputs gave up on.", metric{"", s.DeliveryFailed})
+	family("received_total", "counter", "Entries accepted by Receivers.", metric{"", s.Received})
+	family("rejected_total", "counter", "Entries refused by Receivers.", metric{"", s.Rejected})
+
+	if len(s.Outputs) == 0 {
+		return
+	}
+	var writes, errs, bytes, depth []metric
+	for _, o := range s.Outputs {
+		l := metricLabels("output", o.Name)
+		writes = append(writes, metric{l, o.Writes})
+		errs = append(errs, metric{l, o.Errors})
+		bytes = append(bytes, metric{l, o.Bytes})
+		depth = append(depth, metric{l, o.QueueDepth})
+	}
+	family("output_writes_total", "counter", "Successful writes, by output.", writes...)
+	family("output_errors_total", "counter", "Failed writes, by output.", errs...)
+	family("output_bytes_total", "counter", "Bytes written, by output.", bytes...)
+	family("output_queue_depth", "gauge", "Entries waiting to be written, by output.", depth...)
+}
+
+// labelEscaper escapes label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricLabels returns the label set of a sample with the label name=value.
+func metricLabels(name, value string) string {
+	return "{" + name + `="` + labelEscaper.Replace(value) + `"}`
+}
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	before := Stats().Entries["error"]
+	Error("disk full")
+	Error("disk full")
+	if n := Stats().Entries["error"] - before; n != 2 {
+		t.Errorf("%d error entries counted, want 2", n)
+	}
+
+	w := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	got := w.Body.String()
+	for _, want := range []string{
+		"# TYPE golog_entries_total counter\n",
+		`golog_entries_total{level="error"} `,
+		`golog_dropped_entries_total{reason="rate_limit"} `,
+		"# TYPE golog_rotations_total counter\ngolog_rotations_total ",
+		`golog_output_bytes_total{output="*bytes.Buffer"} `,
+		"# TYPE golog_output_queue_depth gauge\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, got)
+		}
+	}
+	if i, j := strings.Index(got, `level="panic"`), strings.Index(got, `level="debug"`); i < 0 || i > j {
+		t.Error("levels are not in order of severity")
+	}
+}
+
+func TestMetricLabels(t *testing.T) {
+	if got := metricLabels("output", `C:\logs\"a"`+"\n"); got != `{output="C:\\logs\\\"a\"\n"}` {
+		t.Errorf("metricLabels = %s", got)
+	}
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// mirror copies severe entries to stderr, independently of the outputs.
+var mirror = struct {
+	sync.Mutex
+	level   log.Level
+	enabled bool
+	w       io.Writer
+}{level: log.FatalLevel, enabled: true, w: os.Stderr}
+
+func init() {
+	addHook(mirrorHook{})
+}
+
+// SetStderrMirror sets the least severe level mirrored to stderr. Entries
+// at that level or more severe are written to stderr synchronously, before
+// they reach the outputs, so the reason a process died shows up on the
+// terminal or in kubectl logs even if the log file is broken. The default
+// is "fatal"; "error" mirrors errors as well and "" disables the mirror.
+// Nothing is mirrored while stderr is itself an output, and neither are
+// relayed entries, which do not concern this process.
+func SetStderrMirror(level string) error {
+	if level == "" {
+		mirror.Lock()
+		mirror.enabled = false
+		mirror.Unlock()
+		return nil
+	}
+
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	mirror.Lock()
+	mirror.level = lvl
+	mirror.enabled = true
+	mirror.Unlock()
+	return nil
+}
+
+// mirrorHook writes mirrored entries. It is always registered and checks
+// the mirror settings on every entry.
+type mirrorHook struct{}
+
+func (mirrorHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (mirrorHook) Fire(entry *log.Entry) error {
+	mirror.Lock()
+	defer mirror.Unlock()
+
+	if !mirror.enabled || entry.Level > mirror.level || outputsInclude(mirror.w) {
+		return nil
+	}
+	if relayed, _ := entry.Data[RelayedKey].(bool); relayed {
+		return nil
+	}
+	b, err := entry.Logger.Formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := mirror.w.Write(b); err != nil {
+		reportError(fmt.Errorf("mirror entry to stderr: %v", err))
+	}
+	return nil
+}
+
+// outputsInclude reports whether w is one of the outputs.
+func outputsInclude(w io.Writer) bool {
+	current.Lock()
+	mw := current.output
+	current.Unlock()
+	if mw == nil {
+		return false
+	}
+	for _, o := range mw.outputs {
+		if o == w {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStderrMirror(t *testing.T) {
+	var out, stderr bytes.Buffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	mirror.Lock()
+	mirror.w = &stderr
+	mirror.Unlock()
+	defer func() {
+		mirror.Lock()
+		mirror.w = os.Stderr
+		mirror.Unlock()
+	}()
+	if err := SetStderrMirror("error"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetStderrMirror("fatal")
+
+	Error("disk full")
+	Warning("disk almost full")
+
+	if !strings.Contains(stderr.String(), "disk full") {
+		t.Errorf("stderr = %q, want the ERROR entry mirrored", stderr.String())
+	}
+	if strings.Contains(stderr.String(), "almost") {
+		t.Errorf("stderr = %q, want WARNING entries not mirrored", stderr.String())
+	}
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("output = %q, want both entries", out.String())
+	}
+
+	// No duplicates while the mirror target is an output.
+	stderr.Reset()
+	SetOutputs(&stderr)
+	Error("disk full")
+	if n := strings.Count(stderr.String(), "disk full"); n != 1 {
+		t.Errorf("entry written %d times to an output that is also the mirror", n)
+	}
+
+	if err := SetStderrMirror("loud"); err == nil {
+		t.Error(`SetStderrMirror("loud") = nil, want error`)
+	}
+}
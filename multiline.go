@@ -0,0 +1,142 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// NewlinePolicy selects how messages holding newlines are written, see
+// SetMessagePolicy.
+type NewlinePolicy int
+
+const (
+	// KeepNewlines writes newlines as they are, the default.
+	KeepNewlines NewlinePolicy = iota
+	// EscapeNewlines writes newlines and carriage returns as \n and \r.
+	EscapeNewlines
+	// IndentNewlines starts the continuation lines with a tab, which
+	// multiline parsers such as Filebeat's can join back to their entry.
+	IndentNewlines
+	// SplitNewlines writes every line as an entry of its own.
+	SplitNewlines
+)
+
+// PartKey is the field numbering the entries a message was split into by
+// SetMessagePolicy, as in "2/3".
+const PartKey = "part"
+
+// MessagePolicy is the handling of multiline and large messages.
+type MessagePolicy struct {
+	Newlines NewlinePolicy
+	// MaxSize is the size in bytes above which messages are truncated,
+	// with a " (truncated N bytes)" suffix. Zero means no limit.
+	MaxSize int
+	// SplitLong splits the messages above MaxSize into entries of MaxSize
+	// bytes instead.
+	SplitLong bool
+}
+
+var messagePolicy atomic.Value // MessagePolicy
+
+// SetMessagePolicy selects how messages holding newlines or larger than
+// p.MaxSize are written, e.g. stack traces and packet dumps breaking line
+// oriented parsers downstream:
+//
+//	log.SetMessagePolicy(log.MessagePolicy{Newlines: log.IndentNewlines, MaxSize: 16 << 10})
+//
+// Trailing newlines are dropped unless newlines are kept. The entries a
+// message is split into have the fields of the message and the part field.
+// FATAL and PANIC messages are never split: their lines are indented and
+// they are truncated instead. Truncated messages are counted in Stats. The
+// zero MessagePolicy, the default, writes messages as they are.
+func SetMessagePolicy(p MessagePolicy) {
+	if p.MaxSize < 0 {
+		p.MaxSize = 0
+	}
+	messagePolicy.Store(p)
+}
+
+// activeMessagePolicy returns the policy selected with SetMessagePolicy.
+func activeMessagePolicy() MessagePolicy {
+	p, _ := messagePolicy.Load().(MessagePolicy)
+	return p
+}
+
+var newlineEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`)
+
+// messagePart is an entry a message was shaped into.
+type messagePart struct {
+	msg    string
+	fields log.Fields
+}
+
+// shapeMessage applies the message policy to an entry and returns the
+// entries to write instead, nil if the entry is to be written as it is.
+func shapeMessage(level log.Level, msg string, fields log.Fields) []messagePart {
+	p := activeMessagePolicy()
+	if p == (MessagePolicy{}) {
+		return nil
+	}
+	multiline := p.Newlines != KeepNewlines && strings.ContainsAny(msg, "\r\n")
+	if !multiline && (p.MaxSize == 0 || len(msg) <= p.MaxSize) {
+		return nil
+	}
+	split := level > log.FatalLevel
+
+	var lines []string
+	if multiline {
+		msg = strings.TrimRight(msg, "\r\n")
+		switch {
+		case p.Newlines == EscapeNewlines:
+			msg = newlineEscaper.Replace(msg)
+		case p.Newlines == SplitNewlines && split:
+			lines = strings.Split(strings.Replace(msg, "\r\n", "\n", -1), "\n")
+		default:
+			msg = strings.Replace(strings.Replace(msg, "\r\n", "\n", -1), "\n", "\n\t", -1)
+		}
+	}
+	if lines == nil {
+		lines = []string{msg}
+	}
+
+	var msgs []string
+	for _, line := range lines {
+		for p.MaxSize > 0 && len(line) > p.MaxSize {
+			cut := p.MaxSize
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = p.MaxSize
+			}
+			if !p.SplitLong || !split {
+				atomic.AddUint64(&stats.Truncated, 1)
+				line = line[:cut] + fmt.Sprintf(" (truncated %d bytes)", len(line)-cut)
+				break
+			}
+			msgs = append(msgs, line[:cut])
+			line = line[cut:]
+		}
+		msgs = append(msgs, line)
+	}
+
+	parts := make([]messagePart, len(msgs))
+	for i, m := range msgs {
+		parts[i] = messagePart{m, fields}
+		if len(msgs) > 1 {
+			// The parts are written separately, each with its own copy.
+			f := make(log.Fields, len(fields)+1)
+			for k, v := range fields {
+				f[k] = v
+			}
+			f[PartKey] = strconv.Itoa(i+1) + "/" + strconv.Itoa(len(msgs))
+			parts[i].fields = f
+		}
+	}
+	return parts
+}
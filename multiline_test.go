@@ -0,0 +1,107 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestMessagePolicyNewlines(t *testing.T) {
+	defer SetMessagePolicy(MessagePolicy{})
+	for _, tt := range []struct {
+		policy NewlinePolicy
+		want   []string
+	}{
+		{EscapeNewlines, []string{`panic: boom\r\ngoroutine 1\n` + "\tmain.go:7"}},
+		{IndentNewlines, []string{"panic: boom", "\tgoroutine 1", "\t\tmain.go:7"}},
+		{SplitNewlines, []string{"panic: boom part=1/3", "goroutine 1 part=2/3", "\tmain.go:7 part=3/3"}},
+	} {
+		var out syncBuffer
+		SetOutputs(&out)
+		SetMessagePolicy(MessagePolicy{Newlines: tt.policy})
+		Warning("panic: boom\r\ngoroutine 1\n\tmain.go:7\n")
+		SetOutputs(os.Stderr)
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != len(tt.want) {
+			t.Errorf("policy %d wrote %q, want %d lines", tt.policy, out.String(), len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if i == 0 || tt.policy == SplitNewlines {
+				if !strings.HasSuffix(lines[i], "WARNING "+want) && !strings.HasSuffix(lines[i], "] "+want) {
+					t.Errorf("policy %d line %d = %q, want suffix %q", tt.policy, i, lines[i], want)
+				}
+			} else if lines[i] != want {
+				t.Errorf("policy %d line %d = %q, want %q", tt.policy, i, lines[i], want)
+			}
+		}
+	}
+}
+
+func TestMessagePolicyMaxSize(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	defer SetMessagePolicy(MessagePolicy{})
+	before := Stats().Truncated
+
+	SetMessagePolicy(MessagePolicy{MaxSize: 8})
+	Info("short")
+	Info("payload 0123456789")
+	// The cut falls inside é, which is kept out whole.
+	Info("1234567é89")
+	SetMessagePolicy(MessagePolicy{MaxSize: 8, SplitLong: true})
+	Info("payload 0123456789")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"short",
+		"payload  (truncated 10 bytes)",
+		"1234567 (truncated 4 bytes)",
+		"payload  part=1/3",
+		"01234567 part=2/3",
+		"89 part=3/3",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("wrote %q, want %d lines", out.String(), len(want))
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(lines[i], w) {
+			t.Errorf("line %d = %q, want suffix %q", i, lines[i], w)
+		}
+	}
+	if n := Stats().Truncated - before; n != 2 {
+		t.Errorf("Truncated increased by %d, want 2", n)
+	}
+}
+
+func TestMessagePolicyFatalNotSplit(t *testing.T) {
+	defer SetMessagePolicy(MessagePolicy{})
+	SetMessagePolicy(MessagePolicy{Newlines: SplitNewlines, MaxSize: 6, SplitLong: true})
+	parts := shapeMessage(log.PanicLevel, "ab\ncdefgh", nil)
+	if len(parts) != 1 || parts[0].msg != "ab\n\tcd (truncated 4 bytes)" {
+		t.Errorf("panic message shaped into %+v", parts)
+	}
+	if parts := shapeMessage(log.ErrorLevel, "ab", nil); parts != nil {
+		t.Errorf("short message shaped into %+v", parts)
+	}
+}
+
+func TestMessagePolicyBatch(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	defer SetMessagePolicy(MessagePolicy{})
+	SetMessagePolicy(MessagePolicy{Newlines: SplitNewlines})
+
+	b := With("report", "rings").Batch()
+	b.Info("first\nsecond")
+	b.Commit()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "first part=1/2 report=rings") || !strings.HasSuffix(lines[1], "second part=2/2 report=rings") {
+		t.Errorf("batch wrote %q", out.String())
+	}
+}
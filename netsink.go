@@ -0,0 +1,306 @@
+package log
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultNetworkBuffer is the number of entries a NetworkSink keeps while
+// the collector is unreachable.
+const DefaultNetworkBuffer = 10000
+
+// NetworkSink ships entries as JSON lines to a remote collector such as
+// Logstash's tcp or udp input with the json_lines codec, or Fluent Bit's
+// tcp or udp input with format json, for hosts without a disk to log to.
+// It is a logrus hook registered with AddNetworkSink. Entries are kept in
+// a ring buffer and sent by a background goroutine that reconnects with
+// backoff, so a slow or unreachable collector never blocks logging; when
+// the buffer is full, the oldest entries make room for new ones and are
+// counted as dropped. Over TCP, entries are delivered at least once: a
+// batch that fails is resent on the next connection.
+type NetworkSink struct {
+	network, addr string
+	cfg           *tls.Config
+	stream        bool
+	format        JSONFormatter
+
+	mu sync.Mutex
+	// ring holds the entries numbered first to next, entry i at
+	// i%len(ring).
+	ring        [][]byte
+	first, next uint64
+	dropped     uint64
+	started     int64
+
+	// ctx is cancelled by Close, aborting the connection in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	wake      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewNetworkSink returns a NetworkSink sending to the collector at addr.
+// network is "tcp" or "udp", or one of their IPv4 and IPv6 only variants;
+// over UDP every entry is a datagram of its own. A non-nil cfg secures the
+// TCP connection with TLS. size is the capacity of the buffer,
+// DefaultNetworkBuffer if not positive.
+func NewNetworkSink(network, addr string, cfg *tls.Config, size int) (*NetworkSink, error) {
+	s := &NetworkSink{network: network, addr: addr, cfg: cfg}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		s.stream = true
+	case "udp", "udp4", "udp6":
+		if cfg != nil {
+			return nil, fmt.Errorf("network sink %s: TLS requires tcp", addr)
+		}
+	default:
+		return nil, fmt.Errorf("network sink %s: unsupported network %q", addr, network)
+	}
+	if size <= 0 {
+		size = DefaultNetworkBuffer
+	}
+	s.ring = make([][]byte, size)
+	s.wake = make(chan struct{}, 1)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	return s, nil
+}
+
+// AddNetworkSink registers s with the package logger.
+func AddNetworkSink(s *NetworkSink) {
+	addHook(s)
+	addFlusher(s)
+}
+
+// Name returns the destination of the sink.
+func (s *NetworkSink) Name() string {
+	return "net:" + s.network + ":" + s.addr
+}
+
+// Levels implements logrus.Hook.
+func (s *NetworkSink) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook. It never blocks.
+func (s *NetworkSink) Fire(entry *log.Entry) error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	line, err := s.format.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.next-s.first == uint64(len(s.ring)) {
+		s.first++
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	s.ring[s.next%uint64(len(s.ring))] = line
+	s.next++
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// QueueDepth implements QueueReporter.
+func (s *NetworkSink) QueueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.next - s.first)
+}
+
+// QueueLatency implements QueueReporter; the sink does not measure it.
+func (s *NetworkSink) QueueLatency() time.Duration {
+	return 0
+}
+
+// busySince implements busyReporter.
+func (s *NetworkSink) busySince() time.Time {
+	return unixNano(atomic.LoadInt64(&s.started))
+}
+
+// Dropped returns the number of entries dropped because the buffer was
+// full.
+func (s *NetworkSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Flush waits until every buffered entry has been sent.
+func (s *NetworkSink) Flush() error {
+	return s.FlushContext(context.Background())
+}
+
+// FlushContext is Flush giving up when ctx is done.
+func (s *NetworkSink) FlushContext(ctx context.Context) error {
+	for s.QueueDepth() > 0 {
+		select {
+		case <-s.done:
+			return errors.New("network sink closed with entries pending")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// Close stops the sink, aborting a connection or a batch in flight.
+// Entries still buffered are discarded; call Flush first to deliver them.
+func (s *NetworkSink) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		close(s.stop)
+	})
+	<-s.done
+	return nil
+}
+
+func (s *NetworkSink) run() {
+	defer close(s.done)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	backoff := 100 * time.Millisecond
+	down := false
+	for {
+		start, batch := s.take()
+		if len(batch) == 0 {
+			select {
+			case <-s.wake:
+				continue
+			case <-s.stop:
+				return
+			}
+		}
+
+		var err error
+		atomic.StoreInt64(&s.started, time.Now().UnixNano())
+		if conn == nil {
+			conn, err = s.dial()
+		}
+		if err == nil {
+			err = s.send(conn, batch)
+		}
+		atomic.StoreInt64(&s.started, 0)
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+				conn = nil
+			}
+			// Only report the transition, the sink retries until the
+			// collector is back.
+			if !down {
+				down = true
+				reportError(fmt.Errorf("output %s: %v", s.Name(), err))
+			}
+			reportDelivery(DeliveryReport{Output: s.Name(), Failed: len(batch), Err: err})
+			select {
+			case <-time.After(backoff):
+			case <-s.stop:
+				return
+			}
+			if backoff *= 2; backoff > forwardMaxBackoff {
+				backoff = forwardMaxBackoff
+			}
+			continue
+		}
+
+		if down {
+			down = false
+			reportError(fmt.Errorf("output %s recovered", s.Name()))
+		}
+		backoff = 100 * time.Millisecond
+		s.ack(start, len(batch))
+		reportDelivery(DeliveryReport{Output: s.Name(), Delivered: len(batch)})
+	}
+}
+
+// take returns up to a batch of the oldest buffered entries and the number
+// of the first one. They stay buffered until acknowledged.
+func (s *NetworkSink) take() (uint64, [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.next - s.first
+	if n > forwardBatch {
+		n = forwardBatch
+	}
+	batch := make([][]byte, n)
+	for i := range batch {
+		batch[i] = s.ring[(s.first+uint64(i))%uint64(len(s.ring))]
+	}
+	return s.first, batch
+}
+
+// ack removes the n entries from start on from the buffer, those that new
+// entries have not pushed out meanwhile.
+func (s *NetworkSink) ack(start uint64, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if end := start + uint64(n); end > s.first {
+		for i := s.first; i < end; i++ {
+			s.ring[i%uint64(len(s.ring))] = nil
+		}
+		s.first = end
+	}
+}
+
+func (s *NetworkSink) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: forwardDialTimeout}
+	if s.cfg != nil {
+		td := &tls.Dialer{NetDialer: d, Config: s.cfg}
+		return td.DialContext(s.ctx, s.network, s.addr)
+	}
+	return d.DialContext(s.ctx, s.network, s.addr)
+}
+
+// send writes batch to conn, in one write over TCP and as a datagram per
+// entry over UDP.
+func (s *NetworkSink) send(conn net.Conn, batch [][]byte) error {
+	conn.SetWriteDeadline(time.Now().Add(forwardDialTimeout))
+	defer interruptible(s.ctx, conn)()
+	if !s.stream {
+		for _, line := range batch {
+			if _, err := conn.Write(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var size int
+	for _, line := range batch {
+		size += len(line)
+	}
+	buf := make([]byte, 0, size)
+	for _, line := range batch {
+		buf = append(buf, line...)
+	}
+	_, err := conn.Write(buf)
+	return err
+}
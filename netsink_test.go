@@ -0,0 +1,231 @@
+package log
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// fireEntry sends an entry with message msg to h.
+func fireEntry(t *testing.T, h log.Hook, msg string) {
+	t.Helper()
+	entry := log.WithFields(log.Fields{"iface": "eth1"})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = log.WarnLevel
+	entry.Message = msg
+	if err := h.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readLines returns the first n lines from the connections accepted by l.
+func readLines(t *testing.T, l net.Listener, n int) []string {
+	t.Helper()
+	lines := make(chan string)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				sc := bufio.NewScanner(conn)
+				for sc.Scan() {
+					lines <- sc.Text()
+				}
+			}()
+		}
+	}()
+
+	var got []string
+	timeout := time.After(10 * time.Second)
+	for len(got) < n {
+		select {
+		case line := <-lines:
+			got = append(got, line)
+		case <-timeout:
+			t.Fatalf("received %d of %d lines: %q", len(got), n, got)
+		}
+	}
+	return got
+}
+
+func TestNetworkSinkReconnects(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	// Reserve an address nothing listens on yet.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s, err := NewNetworkSink("tcp", addr, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, msg := range []string{"link flap", "link up", "link down"} {
+		fireEntry(t, s, msg)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if d := s.QueueDepth(); d != 3 {
+		t.Errorf("QueueDepth() = %d while the collector is down, want 3", d)
+	}
+
+	if l, err = net.Listen("tcp", addr); err != nil {
+		t.Skipf("address taken meanwhile: %v", err)
+	}
+	defer l.Close()
+	got := readLines(t, l, 3)
+	for i, want := range []string{"link flap", "link up", "link down"} {
+		if !strings.Contains(got[i], `"msg":"`+want+`"`) || !strings.Contains(got[i], `"iface":"eth1"`) {
+			t.Errorf("line %d = %q, want the JSON of %q", i, got[i], want)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNetworkSinkDropsOldest(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s, err := NewNetworkSink("tcp", addr, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, msg := range []string{"first", "second", "third"} {
+		fireEntry(t, s, msg)
+	}
+	if d, n := s.QueueDepth(), s.Dropped(); d != 2 || n != 1 {
+		t.Errorf("QueueDepth() = %d, Dropped() = %d, want 2 and 1", d, n)
+	}
+
+	if l, err = net.Listen("tcp", addr); err != nil {
+		t.Skipf("address taken meanwhile: %v", err)
+	}
+	defer l.Close()
+	got := readLines(t, l, 2)
+	if !strings.Contains(got[0], `"msg":"second"`) || !strings.Contains(got[1], `"msg":"third"`) {
+		t.Errorf("received %q, want the 2 newest entries", got)
+	}
+}
+
+func TestNetworkSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := NewNetworkSink("udp", pc.LocalAddr().String(), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fireEntry(t, s, "link flap")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "{") || !strings.Contains(msg, `"msg":"link flap"`) {
+		t.Errorf("datagram = %q, want a JSON entry", msg)
+	}
+}
+
+func TestNetworkSinkTLS(t *testing.T) {
+	certFile, keyFile, caFile := writeTestPKI(t, t.TempDir())
+	cfg, err := LoadMutualTLS(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s, err := NewNetworkSink("tcp", l.Addr().String(), cfg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fireEntry(t, s, "link flap")
+	if got := readLines(t, l, 1); !strings.Contains(got[0], `"msg":"link flap"`) {
+		t.Errorf("received %q", got)
+	}
+}
+
+func TestNewNetworkSinkInvalid(t *testing.T) {
+	if _, err := NewNetworkSink("unix", "/tmp/collector", nil, 0); err == nil {
+		t.Error("NewNetworkSink accepted a unix socket")
+	}
+	if _, err := NewNetworkSink("udp", "127.0.0.1:514", &tls.Config{}, 0); err == nil {
+		t.Error("NewNetworkSink accepted TLS over udp")
+	}
+}
+
+// silentListener accepts connections and never answers, like a wedged
+// collector.
+func silentListener(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return l
+}
+
+func TestNetworkSinkCloseAbortsHandshake(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+	l := silentListener(t)
+	defer l.Close()
+
+	s, err := NewNetworkSink("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireEntry(t, s, "link flap")
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	s.Close()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Close took %s waiting for the handshake", d)
+	}
+}
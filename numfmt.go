@@ -0,0 +1,167 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// NumberFormat controls the rendering of numeric field values, see
+// SetNumberFormat. The zero value keeps the defaults of the formats.
+type NumberFormat struct {
+	// FloatPrecision is the number of decimals of floats. Zero writes the
+	// fewest decimals reading back to the same float, never in exponent
+	// notation.
+	FloatPrecision int
+	// DurationUnit writes time.Duration values as a decimal number of the
+	// unit, e.g. 1.5 for 1500µs with time.Millisecond, rather than in the
+	// form of Duration.String.
+	DurationUnit time.Duration
+	// ThousandsSep groups the digits of the integer part of numbers by
+	// three in the text format, e.g. "_" or ",". JSON numbers are never
+	// grouped.
+	ThousandsSep string
+}
+
+var numberFormat atomic.Value // NumberFormat
+
+// SetNumberFormat selects the rendering of floats, durations and integers
+// in the fields of the text and JSON formats, the same whatever the locale
+// of the host and the Go version, for the parsers downstream:
+//
+//	log.SetNumberFormat(log.NumberFormat{FloatPrecision: 3, DurationUnit: time.Millisecond})
+//
+// It fails if the separator holds digits, signs, a dot or characters the
+// text format quotes. The zero NumberFormat restores the defaults. It may
+// be called before or after Init.
+func SetNumberFormat(nf NumberFormat) error {
+	if err := nf.validate(); err != nil {
+		return err
+	}
+	numberFormat.Store(nf)
+	return nil
+}
+
+// validate reports why nf cannot be selected.
+func (nf NumberFormat) validate() error {
+	if nf.FloatPrecision < 0 {
+		return fmt.Errorf("negative float precision %d", nf.FloatPrecision)
+	}
+	if nf.DurationUnit < 0 {
+		return fmt.Errorf("negative duration unit %v", nf.DurationUnit)
+	}
+	if strings.ContainsAny(nf.ThousandsSep, "0123456789+-.eE =\"\t\n") {
+		return fmt.Errorf("invalid thousands separator %q", nf.ThousandsSep)
+	}
+	return nil
+}
+
+// activeNumberFormat returns the format selected with SetNumberFormat.
+func activeNumberFormat() NumberFormat {
+	nf, _ := numberFormat.Load().(NumberFormat)
+	return nf
+}
+
+// formatNumber renders v if it is a number the format applies to, with
+// the thousands separator if group is true.
+func (nf NumberFormat) formatNumber(v interface{}, group bool) (string, bool) {
+	var s string
+	switch v := v.(type) {
+	case float64:
+		s = nf.formatFloat(v, 64)
+	case float32:
+		s = nf.formatFloat(float64(v), 32)
+	case time.Duration:
+		if nf.DurationUnit == 0 {
+			return "", false
+		}
+		s = nf.formatFloat(float64(v)/float64(nf.DurationUnit), 64)
+	case int:
+		s = strconv.FormatInt(int64(v), 10)
+	case int8:
+		s = strconv.FormatInt(int64(v), 10)
+	case int16:
+		s = strconv.FormatInt(int64(v), 10)
+	case int32:
+		s = strconv.FormatInt(int64(v), 10)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case uint:
+		s = strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		s = strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		s = strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		s = strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	default:
+		return "", false
+	}
+	if group && nf.ThousandsSep != "" {
+		s = groupDigits(s, nf.ThousandsSep)
+	}
+	return s, true
+}
+
+// formatFloat renders f with the precision of nf.
+func (nf NumberFormat) formatFloat(f float64, bits int) string {
+	prec := nf.FloatPrecision
+	if prec == 0 {
+		prec = -1
+	}
+	return strconv.FormatFloat(f, 'f', prec, bits)
+}
+
+// groupDigits inserts sep every three digits of the integer part of the
+// decimal number s.
+func groupDigits(s, sep string) string {
+	sign := ""
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		sign, s = s[:1], s[1:]
+	}
+	n := strings.IndexByte(s, '.')
+	if n < 0 {
+		n = len(s)
+	}
+	if n <= 3 || strings.IndexFunc(s[:n], func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return sign + s
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i := 0; i < n; i++ {
+		if i > 0 && (n-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteString(s[n:])
+	return b.String()
+}
+
+// jsonNumber returns v as a JSON number in the selected format, v itself
+// if the format does not apply to it.
+func jsonNumber(nf NumberFormat, v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return v
+		}
+	case float32:
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return v
+		}
+	case time.Duration:
+	default:
+		return v
+	}
+	if s, ok := nf.formatNumber(v, false); ok {
+		return json.Number(s)
+	}
+	return v
+}
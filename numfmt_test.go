@@ -0,0 +1,87 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetNumberFormat(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer SetNumberFormat(NumberFormat{})
+
+	fields := Fields{
+		"ratio":   2.0 / 3,
+		"big":     1e21,
+		"rtt":     1500 * time.Microsecond,
+		"packets": 1234567,
+		"drops":   int64(-4321),
+		"port":    uint16(443),
+	}
+	if err := SetNumberFormat(NumberFormat{FloatPrecision: 3, DurationUnit: time.Millisecond, ThousandsSep: "_"}); err != nil {
+		t.Fatal(err)
+	}
+	WithFields(fields).Info("stats")
+	for _, want := range []string{"ratio=0.667", "big=1_000_000_000_000_000_000_000.000", "rtt=1.500", "packets=1_234_567", "drops=-4_321", "port=443"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text entry is missing %s: %s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+	WithFields(fields).Info("stats")
+	for _, want := range []string{`"ratio":0.667`, `"rtt":1.500`, `"packets":1234567`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("JSON entry is missing %s: %s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	SetFormat(TextFormat)
+	SetNumberFormat(NumberFormat{})
+	WithFields(fields).Info("stats")
+	for _, want := range []string{"ratio=0.6666666666666666", "big=1e+21", "rtt=1.5ms", "packets=1234567"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("default entry is missing %s: %s", want, buf.String())
+		}
+	}
+
+	c := CurrentConfig()
+	c.FloatPrecision, c.DurationUnit = 2, "1us"
+	if err := Reconfigure(c); err != nil {
+		t.Fatal(err)
+	}
+	if nf := activeNumberFormat(); nf != (NumberFormat{FloatPrecision: 2, DurationUnit: time.Microsecond}) {
+		t.Errorf("number format after Reconfigure = %+v", nf)
+	}
+	if c := CurrentConfig(); c.DurationUnit != "1µs" || c.FloatPrecision != 2 {
+		t.Errorf("CurrentConfig() = %+v, want the number format", c)
+	}
+
+	for _, nf := range []NumberFormat{{FloatPrecision: -1}, {DurationUnit: -time.Second}, {ThousandsSep: " "}, {ThousandsSep: "."}} {
+		if err := SetNumberFormat(nf); err == nil {
+			t.Errorf("SetNumberFormat(%+v) succeeded", nf)
+		}
+	}
+}
+
+func TestGroupDigits(t *testing.T) {
+	for in, want := range map[string]string{
+		"0":           "0",
+		"999":         "999",
+		"1000":        "1,000",
+		"-1234567.25": "-1,234,567.25",
+		"NaN":         "NaN",
+		"+Inf":        "+Inf",
+	} {
+		if got := groupDigits(in, ","); got != want {
+			t.Errorf("groupDigits(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SequenceKey is the field holding the sequence number of entries written
+// in strict ordering mode.
+const SequenceKey = "seq"
+
+// reorderBuffer holds formatted entries until all entries with a lower
+// sequence number have been written.
+type reorderBuffer struct {
+	mu     sync.Mutex
+	window time.Duration
+	// seq is the last sequence number assigned and next the one to be
+	// written next.
+	seq, next uint64
+	held      map[uint64]heldEntry
+	timer     *time.Timer
+}
+
+type heldEntry struct {
+	b     []byte
+	since time.Time
+}
+
+var ordering = &reorderBuffer{held: make(map[uint64]heldEntry)}
+
+func init() {
+	addFlusher(ordering)
+}
+
+// SetStrictOrdering writes entries to the outputs in the order they were
+// logged, even across goroutines: every entry is numbered when it passes
+// the level, sampling and rate limit and carries the number as the seq
+// field, and entries overtaking one with a lower number are held back
+// until it has been written. If the missing entry does not arrive within
+// window, e.g. because a hook panicked, the entries held back are written
+// anyway. A non-positive window disables the mode, which is the default.
+// Entries written by Batch keep their own contiguous ordering.
+func SetStrictOrdering(window time.Duration) {
+	ordering.mu.Lock()
+	defer ordering.mu.Unlock()
+
+	ordering.flushLocked()
+	if window < 0 {
+		window = 0
+	}
+	ordering.window = window
+	ordering.next = ordering.seq + 1
+}
+
+// assign returns the sequence number of the next entry, if strict ordering
+// is enabled.
+func (o *reorderBuffer) assign() (uint64, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.window == 0 {
+		return 0, false
+	}
+	o.seq++
+	return o.seq, true
+}
+
+// put writes the formatted entry seq, or holds it back until the entries
+// before it have been written. A nil b marks an entry that could not be
+// formatted.
+func (o *reorderBuffer) put(seq uint64, b []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if seq < o.next {
+		// A late entry whose turn was skipped.
+		writeOutput(nil, b)
+		return
+	}
+	o.held[seq] = heldEntry{b: b, since: time.Now()}
+	o.drainLocked()
+	if len(o.held) > 0 && o.timer == nil {
+		o.timer = time.AfterFunc(o.window, o.expire)
+	}
+}
+
+// drainLocked writes the held entries that are next in sequence.
+func (o *reorderBuffer) drainLocked() {
+	for {
+		e, ok := o.held[o.next]
+		if !ok {
+			return
+		}
+		delete(o.held, o.next)
+		o.next++
+		if e.b != nil {
+			writeOutput(nil, e.b)
+		}
+	}
+}
+
+// expire skips the missing entries the oldest held entry has been waiting
+// for longer than the window.
+func (o *reorderBuffer) expire() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.timer = nil
+	for len(o.held) > 0 {
+		oldest := o.oldestLocked()
+		if wait := o.window - time.Since(o.held[oldest].since); wait > 0 {
+			o.timer = time.AfterFunc(wait, o.expire)
+			return
+		}
+		o.next = oldest
+		o.drainLocked()
+	}
+}
+
+// oldestLocked returns the lowest sequence number held back.
+func (o *reorderBuffer) oldestLocked() uint64 {
+	var oldest uint64
+	for seq := range o.held {
+		if oldest == 0 || seq < oldest {
+			oldest = seq
+		}
+	}
+	return oldest
+}
+
+// Flush writes every held entry in sequence order.
+func (o *reorderBuffer) Flush() error {
+	o.mu.Lock()
+	o.flushLocked()
+	o.mu.Unlock()
+	return nil
+}
+
+// Name identifies the buffer in error messages.
+func (o *reorderBuffer) Name() string {
+	return "strict ordering"
+}
+
+func (o *reorderBuffer) flushLocked() {
+	for len(o.held) > 0 {
+		o.next = o.oldestLocked()
+		o.drainLocked()
+	}
+}
+
+// emitOrdered writes an entry in strict ordering mode and reports whether
+// it did; otherwise the entry is left to dispatch. FATAL entries are
+// written by logrus once the held entries are out.
+func emitOrdered(level log.Level, site callSite, msg string, fields log.Fields) bool {
+	if level <= log.FatalLevel {
+		ordering.Flush()
+		return false
+	}
+	if instanceOf(fields) != nil {
+		return false
+	}
+	seq, ok := ordering.assign()
+	if !ok {
+		return false
+	}
+	if fields == nil {
+		fields = make(log.Fields, 2)
+	}
+	fields[SequenceKey] = seq
+	b, _ := renderEntry(level, site, msg, fields)
+	ordering.put(seq, b)
+	return true
+}
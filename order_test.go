@@ -0,0 +1,110 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReorderBuffer(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	o := &reorderBuffer{window: time.Hour, held: make(map[uint64]heldEntry), next: 1}
+	o.put(2, []byte("two\n"))
+	o.put(3, []byte("three\n"))
+	if buf.Len() != 0 {
+		t.Fatalf("entries overtaking seq 1 were written: %q", buf.String())
+	}
+	o.put(1, []byte("one\n"))
+	o.put(4, nil)
+	o.put(5, []byte("five\n"))
+	if got := buf.String(); got != "one\ntwo\nthree\nfive\n" {
+		t.Errorf("output = %q, want the entries in sequence order", got)
+	}
+	o.timer.Stop()
+}
+
+func TestReorderBufferSkipsMissingEntries(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	o := &reorderBuffer{window: 10 * time.Millisecond, held: make(map[uint64]heldEntry), next: 1}
+	o.put(2, []byte("two\n"))
+	time.Sleep(50 * time.Millisecond)
+
+	o.mu.Lock()
+	got := buf.String()
+	o.mu.Unlock()
+	if got != "two\n" {
+		t.Errorf("output = %q, want the entry written after the window", got)
+	}
+}
+
+func TestSetStrictOrdering(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetStrictOrdering(time.Second)
+	defer SetStrictOrdering(0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				Info("entry")
+			}
+		}()
+	}
+	wg.Wait()
+	ordering.Flush()
+
+	var last uint64
+	n := 0
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		i := strings.Index(sc.Text(), "seq=")
+		if i < 0 {
+			t.Fatalf("line %q has no seq field", sc.Text())
+		}
+		seq, err := strconv.ParseUint(sc.Text()[i+len("seq="):], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != last+1 && last != 0 {
+			t.Fatalf("seq %d follows %d", seq, last)
+		}
+		last = seq
+		n++
+	}
+	if n != 400 {
+		t.Errorf("got %d entries, want 400", n)
+	}
+}
+
+func TestStrictOrderingStats(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer SetLevel(getLevel().String())
+	SetLevel("info")
+	SetStrictOrdering(time.Second)
+	defer SetStrictOrdering(0)
+
+	before := Stats().Entries["info"]
+	Info("first")
+	Info("second")
+	ordering.Flush()
+	if n := Stats().Entries["info"] - before; n != 2 {
+		t.Errorf("Entries[info] grew by %d, want 2", n)
+	}
+}
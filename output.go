@@ -0,0 +1,386 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultFatalFlushTimeout is how long Fatal waits for outputs to flush
+// before the process exits.
+const DefaultFatalFlushTimeout = 5 * time.Second
+
+// flusher is implemented by outputs that hold entries in memory before
+// writing them, such as asynchronous or network outputs.
+type flusher interface {
+	Flush() error
+}
+
+// contextFlusher is implemented by flushers that can give up flushing, such
+// as remote outputs waiting for a slow collector.
+type contextFlusher interface {
+	FlushContext(ctx context.Context) error
+}
+
+var current = struct {
+	sync.Mutex
+	output            *multiWriter
+	file              string
+	fatalFlushTimeout time.Duration
+	// flushers are buffering components other than outputs, such as
+	// forwarding hooks.
+	flushers []flusher
+}{fatalFlushTimeout: DefaultFatalFlushTimeout}
+
+// SetFatalFlushTimeout bounds how long Fatal waits for buffered outputs to
+// drain before the process exits, and how long Flush and Close wait. A
+// non-positive timeout makes Fatal skip flushing, and Flush and Close wait
+// as long as it takes.
+func SetFatalFlushTimeout(timeout time.Duration) {
+	current.Lock()
+	current.fatalFlushTimeout = timeout
+	current.Unlock()
+}
+
+// Flush flushes every output and buffering component of the package
+// logger, such as an AsyncWriter or a forwarder, and syncs the log file. It
+// waits at most the timeout set with SetFatalFlushTimeout and returns the
+// first error.
+func Flush() error {
+	return firstError(shutdown(flushTimeout(), false))
+}
+
+// FlushContext is Flush giving up when ctx is done rather than after the
+// timeout set with SetFatalFlushTimeout.
+func FlushContext(ctx context.Context) error {
+	return firstError(shutdownContext(ctx, false))
+}
+
+// Close flushes like Flush, then closes the outputs and buffering
+// components of the package logger, e.g. the file opened by Init, and of
+// the Loggers created by New, before the process exits. Entries logged
+// after Close go to stderr. Fatal calls Close before exiting.
+func Close() error {
+	return firstError(shutdown(flushTimeout(), true))
+}
+
+// CloseContext is Close giving up on flushing when ctx is done: the remote
+// outputs, i.e. forwarders, syslog and network sinks and the sinks added
+// with AddSink, are closed right away, cancelling their requests in flight
+// rather than waiting for a slow collector or broker.
+func CloseContext(ctx context.Context) error {
+	return firstError(shutdownContext(ctx, true))
+}
+
+// firstError returns the first of errs, nil if there are none.
+func firstError(errs []error) error {
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// flushTimeout returns the timeout set with SetFatalFlushTimeout, zero
+// meaning no limit outside of Fatal.
+func flushTimeout() time.Duration {
+	current.Lock()
+	defer current.Unlock()
+	return current.fatalFlushTimeout
+}
+
+// shutdown flushes and, if close is set, closes every output, giving up
+// after a positive timeout.
+func shutdown(timeout time.Duration, close bool) []error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%s elapsed", timeout))
+		defer cancel()
+	}
+	return shutdownContext(ctx, close)
+}
+
+// shutdownContext is shutdown giving up when ctx is done.
+func shutdownContext(ctx context.Context, close bool) []error {
+	current.Lock()
+	w := current.output
+	flushers := current.flushers
+	if close {
+		// Closed components are done with, the others such as the
+		// ordering buffer live as long as the package.
+		var kept []flusher
+		for _, f := range flushers {
+			if _, ok := f.(io.Closer); !ok {
+				kept = append(kept, f)
+			}
+		}
+		current.flushers = kept
+	}
+	current.Unlock()
+	if close {
+		setOutput(os.Stderr)
+		current.Lock()
+		current.file = ""
+		current.Unlock()
+	}
+
+	done := make(chan []error, 1)
+	go func() {
+		var errs []error
+		if w != nil {
+			errs = flushWriter(w)
+		}
+		for _, f := range flushers {
+			var err error
+			if cf, ok := f.(contextFlusher); ok {
+				err = cf.FlushContext(ctx)
+			} else {
+				err = f.Flush()
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("flush %s: %v", outputName(f), err))
+			}
+		}
+		if close {
+			if w != nil {
+				errs = append(errs, closeWriter(w)...)
+			}
+			for _, f := range flushers {
+				if c, ok := f.(io.Closer); ok {
+					if err := c.Close(); err != nil {
+						errs = append(errs, fmt.Errorf("close %s: %v", outputName(f), err))
+					}
+				}
+			}
+		}
+		done <- errs
+	}()
+
+	select {
+	case errs := <-done:
+		return errs
+	case <-ctx.Done():
+	}
+	if close {
+		// The remote outputs abort their requests in flight when closed.
+		// Closing is idempotent, the flushing goroutine closes them again.
+		for _, f := range flushers {
+			if _, ok := f.(contextFlusher); ok {
+				if c, ok := f.(io.Closer); ok {
+					go c.Close()
+				}
+			}
+		}
+	}
+	return []error{fmt.Errorf("flushing outputs did not finish: %v", context.Cause(ctx))}
+}
+
+// setOutput makes w the output of the package logger.
+func setOutput(w io.Writer) {
+	mw, ok := w.(*multiWriter)
+	if !ok {
+		mw = newMultiWriter(w)
+	}
+
+	current.Lock()
+	current.output = mw
+	current.Unlock()
+	log.SetOutput(mw)
+}
+
+// flushOutputs flushes every output of the package logger, giving up after
+// timeout. Errors are reported to the self-log.
+func flushOutputs(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	for _, err := range shutdown(timeout, false) {
+		reportError(err)
+	}
+}
+
+// addFlusher registers f to be flushed along with the outputs.
+func addFlusher(f flusher) {
+	current.Lock()
+	current.flushers = append(current.flushers[:len(current.flushers):len(current.flushers)], f)
+	current.Unlock()
+}
+
+// removeFlusher unregisters f.
+func removeFlusher(f flusher) {
+	current.Lock()
+	kept := make([]flusher, 0, len(current.flushers))
+	for _, g := range current.flushers {
+		if g != f {
+			kept = append(kept, g)
+		}
+	}
+	current.flushers = kept
+	current.Unlock()
+}
+
+// flushWriter flushes w and, for a multiWriter, each of its outputs.
+func flushWriter(w io.Writer) []error {
+	var errs []error
+	switch w := w.(type) {
+	case *multiWriter:
+		for _, o := range w.outputs {
+			errs = append(errs, flushWriter(o)...)
+		}
+	case flusher:
+		if err := w.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("flush output %s: %v", outputName(w), err))
+		}
+	case *os.File:
+		// Sync fails on pipes and terminals, which have nothing to flush.
+		_ = w.Sync()
+	}
+	return errs
+}
+
+// closeWriter closes w and, for a multiWriter, each of its outputs, except
+// stdout and stderr.
+func closeWriter(w io.Writer) []error {
+	var errs []error
+	switch c := w.(type) {
+	case *multiWriter:
+		// Wait for a write in progress.
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, o := range c.outputs {
+			errs = append(errs, closeWriter(o)...)
+		}
+	case *os.File:
+		if c == os.Stdout || c == os.Stderr {
+			break
+		}
+		// Sync fails on pipes, which have nothing to flush.
+		_ = c.Sync()
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close output %s: %v", c.Name(), err))
+		}
+	case io.Closer:
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close output %s: %v", outputName(w), err))
+		}
+	}
+	return errs
+}
+
+// SetOutputs sends every entry to all of the given writers, replacing the
+// output configured by Init. A failing writer does not prevent the entry
+// from reaching the others; its errors are counted in Stats and reported
+// to the self-log. Entries logged before SetOutputs or Init are not
+// written to the outputs.
+func SetOutputs(outputs ...io.Writer) {
+	setOutput(newMultiWriter(outputs...))
+
+	current.Lock()
+	current.file = ""
+	current.Unlock()
+	endBootstrap(false)
+}
+
+// multiWriter duplicates writes to several outputs, isolating their
+// failures from each other.
+type multiWriter struct {
+	mu      sync.Mutex
+	outputs []io.Writer
+	failing []bool
+	metrics []outputMetrics
+}
+
+func newMultiWriter(outputs ...io.Writer) *multiWriter {
+	return &multiWriter{
+		outputs: outputs,
+		failing: make([]bool, len(outputs)),
+		metrics: make([]outputMetrics, len(outputs)),
+	}
+}
+
+// Write writes p to every output. It only fails if no output accepted p.
+func (m *multiWriter) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lastErr error
+	written := 0
+	for i, w := range m.outputs {
+		start := time.Now()
+		atomic.StoreInt64(&m.metrics[i].started, start.UnixNano())
+		n, err := w.Write(p)
+		atomic.StoreInt64(&m.metrics[i].started, 0)
+		m.metrics[i].record(n, err, time.Since(start))
+		if err == nil {
+			if m.failing[i] {
+				m.failing[i] = false
+				reportError(fmt.Errorf("output %s recovered", outputName(w)))
+			}
+			written++
+			continue
+		}
+
+		atomic.AddUint64(&stats.WriteErrors, 1)
+		lastErr = err
+		if !m.hasStderr() && fallback(w, p) {
+			// The entry is not lost, logrus need not complain.
+			written++
+		}
+		// Only report the transition to failing, a dead output would
+		// otherwise flood the self-log with one line per entry.
+		if !m.failing[i] {
+			m.failing[i] = true
+			reportError(fmt.Errorf("write to output %s: %v", outputName(w), err))
+		}
+	}
+
+	if written == 0 && lastErr != nil {
+		return 0, lastErr
+	}
+	return len(p), nil
+}
+
+// hasStderr reports whether stderr is one of the outputs.
+func (m *multiWriter) hasStderr() bool {
+	for _, w := range m.outputs {
+		if w == os.Stderr {
+			return true
+		}
+	}
+	return false
+}
+
+// outputName describes w for error messages.
+func outputName(w interface{}) string {
+	if n, ok := w.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", w)
+}
+
+// stats returns the metrics of every output.
+func (m *multiWriter) stats() []OutputStats {
+	s := make([]OutputStats, len(m.outputs))
+	for i, w := range m.outputs {
+		mt := &m.metrics[i]
+		s[i] = OutputStats{
+			Name:         outputName(w),
+			Writes:       atomic.LoadUint64(&mt.writes),
+			Errors:       atomic.LoadUint64(&mt.errors),
+			Bytes:        atomic.LoadUint64(&mt.bytes),
+			WriteTime:    time.Duration(atomic.LoadInt64(&mt.writeTime)),
+			MaxWriteTime: time.Duration(atomic.LoadInt64(&mt.maxWriteTime)),
+		}
+		if q, ok := w.(QueueReporter); ok {
+			s[i].QueueDepth = q.QueueDepth()
+			s[i].QueueLatency = q.QueueLatency()
+		}
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("socket gone")
+}
+
+func TestMultiWriterIsolatesFailures(t *testing.T) {
+	var self bytes.Buffer
+	SetSelfLog(&self)
+	defer SetSelfLog(os.Stderr)
+
+	var good bytes.Buffer
+	w := newMultiWriter(failingWriter{}, &good)
+	before := Stats().WriteErrors
+
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write([]byte("entry\n")); err != nil {
+			t.Fatalf("Write() = %v, want nil while one output works", err)
+		}
+	}
+
+	if got := good.String(); got != "entry\nentry\nentry\n" {
+		t.Errorf("healthy output got %q", got)
+	}
+	if got := Stats().WriteErrors - before; got != 3 {
+		t.Errorf("WriteErrors increased by %d, want 3", got)
+	}
+	if n := strings.Count(self.String(), "socket gone"); n != 1 {
+		t.Errorf("self-log reported the failure %d times, want once:\n%s", n, self.String())
+	}
+
+	if _, err := newMultiWriter(failingWriter{}).Write([]byte("entry\n")); err == nil {
+		t.Error("Write() = nil, want error when every output fails")
+	}
+}
+
+type slowWriter struct {
+	bytes.Buffer
+	flushed atomic.Bool
+	delay   time.Duration
+}
+
+func (w *slowWriter) Flush() error {
+	time.Sleep(w.delay)
+	w.flushed.Store(true)
+	return nil
+}
+
+func TestFlushOutputs(t *testing.T) {
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	fast := &slowWriter{}
+	slow := &slowWriter{delay: time.Second}
+	SetOutputs(fast, slow)
+	defer SetOutputs(os.Stderr)
+
+	start := time.Now()
+	flushOutputs(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("flushOutputs took %s, want it bounded by the timeout", elapsed)
+	}
+	if !fast.flushed.Load() {
+		t.Error("fast output was not flushed")
+	}
+}
+
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (w *closeRecorder) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out syncBuffer
+	a := NewAsyncWriter(&out, 0, time.Hour, DropWhenFull)
+	c := &closeRecorder{}
+	SetOutputs(f, a, c)
+	defer SetOutputs(os.Stderr)
+
+	Info("before close")
+	if err := Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if !strings.Contains(out.String(), "before close") {
+		t.Errorf("async output after Close = %q, want the entry", out.String())
+	}
+	if !c.closed {
+		t.Error("closer output was not closed")
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write to the log file after Close = %v, want %v", err, os.ErrClosed)
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "before close") {
+		t.Errorf("log file = %q, want the entry", b)
+	}
+
+	current.Lock()
+	w := current.output
+	current.Unlock()
+	if len(w.outputs) != 1 || w.outputs[0] != os.Stderr {
+		t.Errorf("output after Close = %v, want stderr", w.outputs)
+	}
+}
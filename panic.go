@@ -0,0 +1,156 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// LogPanic logs a value returned by recover with severity ERROR. Rather than
+// flattening the value with fmt.Sprint, its type, message and details are
+// attached as the panic_type, panic_message and panic_details fields:
+//
+//	defer func() {
+//		if r := recover(); r != nil {
+//			log.LogPanic(r)
+//		}
+//	}()
+func LogPanic(v interface{}) {
+	output(log.ErrorLevel, "recovered panic", panicFields(v))
+}
+
+// Recover, deferred, recovers a panic of the calling goroutine and logs it
+// with severity ERROR like LogPanic, together with the stack of the
+// goroutine and attributed to the place that panicked:
+//
+//	go func() {
+//		defer log.Recover()
+//		consume(packets)
+//	}()
+//
+// Recover must be deferred directly, not called by a deferred function.
+func Recover() {
+	if r := recover(); r != nil {
+		(*Logger)(nil).logRecovered(r)
+	}
+}
+
+// RecoverRepanic, deferred, is like Recover but panics again with the
+// recovered value once it is logged, for panics that must still end the
+// goroutine or reach an outer handler.
+func RecoverRepanic() {
+	if r := recover(); r != nil {
+		(*Logger)(nil).logRecovered(r)
+		panic(r)
+	}
+}
+
+// Recover is like the package-level Recover but adds the fields of l.
+func (l *Logger) Recover() {
+	if r := recover(); r != nil {
+		l.logRecovered(r)
+	}
+}
+
+// RecoverRepanic is like the package-level RecoverRepanic but adds the
+// fields of l.
+func (l *Logger) RecoverRepanic() {
+	if r := recover(); r != nil {
+		l.logRecovered(r)
+		panic(r)
+	}
+}
+
+// logRecovered logs the panic value r, called by a deferred function.
+func (l *Logger) logRecovered(r interface{}) {
+	var fields log.Fields
+	if l != nil {
+		fields = l.data()
+	} else {
+		fields = make(log.Fields, 4)
+	}
+	for k, v := range panicFields(r) {
+		fields[k] = v
+	}
+	fields[StackKey] = string(debug.Stack())
+	emit(log.ErrorLevel, panicSite(), "recovered panic", fields)
+	dumpRecentOn("panic")
+}
+
+// panicSite returns the place that panicked, the first frame above the
+// deferred function that is not in the runtime.
+func panicSite() callSite {
+	var pcs [16]uintptr
+	// Skip runtime.Callers, panicSite, logRecovered and the deferred
+	// function.
+	n := runtime.Callers(4, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "runtime.") {
+			return callSite{pc: f.PC, file: f.File, line: f.Line}
+		}
+		if !more {
+			return callSite{}
+		}
+	}
+}
+
+// panicFields describes a recovered panic value as structured fields.
+func panicFields(v interface{}) log.Fields {
+	fields := log.Fields{
+		"panic_type":    fmt.Sprintf("%T", v),
+		"panic_message": panicMessage(v),
+	}
+	if details := panicDetails(v); details != "" {
+		fields["panic_details"] = details
+	}
+	return fields
+}
+
+func panicMessage(v interface{}) string {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// panicDetails returns what the message alone does not convey: the chain of
+// wrapped errors, or the contents of a composite value.
+func panicDetails(v interface{}) string {
+	if err, ok := v.(error); ok {
+		var chain []string
+		for err = errors.Unwrap(err); err != nil; err = errors.Unwrap(err) {
+			chain = append(chain, fmt.Sprintf("%T: %s", err, err))
+		}
+		if len(chain) == 0 {
+			return ""
+		}
+		b, _ := json.Marshal(chain)
+		return string(b)
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+		return fmt.Sprintf("%+v", v)
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+type panicState struct {
+	Worker int
+	Queue  string
+}
+
+func TestPanicFields(t *testing.T) {
+	wrapped := fmt.Errorf("decode packet: %w", errors.New("short buffer"))
+
+	tests := []struct {
+		value   interface{}
+		typ     string
+		message string
+		details bool
+	}{
+		{"boom", "string", "boom", false},
+		{wrapped, "*fmt.wrapError", "decode packet: short buffer", true},
+		{panicState{3, "rx"}, "log.panicState", "{3 rx}", true},
+		{42, "int", "42", false},
+	}
+	for _, tt := range tests {
+		fields := panicFields(tt.value)
+		if fields["panic_type"] != tt.typ {
+			t.Errorf("panicFields(%v) type = %v, want %v", tt.value, fields["panic_type"], tt.typ)
+		}
+		if fields["panic_message"] != tt.message {
+			t.Errorf("panicFields(%v) message = %v, want %v", tt.value, fields["panic_message"], tt.message)
+		}
+		if _, ok := fields["panic_details"]; ok != tt.details {
+			t.Errorf("panicFields(%v) has details = %v, want %v", tt.value, ok, tt.details)
+		}
+	}
+}
+
+func TestPanicLevel(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetStderrMirror("")
+	defer SetStderrMirror("fatal")
+
+	defer func() {
+		if r := recover(); r != "queue 3 corrupt" {
+			t.Errorf("recover() = %v, want the message", r)
+		}
+		if !strings.Contains(buf.String(), "PANIC") || !strings.Contains(buf.String(), "queue 3 corrupt queue=rx") {
+			t.Errorf("output = %q, want the panic entry", buf.String())
+		}
+	}()
+	With("queue", "rx").Panicf("queue %d corrupt", 3)
+}
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	func() {
+		defer With("worker", 7).Recover()
+		panic(panicState{7, "tx"})
+	}()
+	got := buf.String()
+	for _, want := range []string{
+		"recovered panic",
+		"panic_type=log.panicState",
+		"worker=7",
+		"panic_test.go:",
+		`stack="goroutine `,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+
+	buf.Reset()
+	func() {
+		defer func() {
+			if r := recover(); r != "again" {
+				t.Errorf("recover() = %v, want the value panicked again", r)
+			}
+		}()
+		defer RecoverRepanic()
+		panic("again")
+	}()
+	if !strings.Contains(buf.String(), "panic_message=again") {
+		t.Errorf("output = %q, want the logged panic", buf.String())
+	}
+
+	buf.Reset()
+	func() {
+		defer Recover()
+	}()
+	if buf.Len() != 0 {
+		t.Errorf("Recover without a panic logged %q", buf.String())
+	}
+}
@@ -0,0 +1,233 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Defaults of a ParquetWriter.
+const (
+	DefaultParquetRows          = 100000
+	DefaultParquetFlushInterval = 5 * time.Minute
+)
+
+// ParquetRow is the schema of the files written by a ParquetWriter. Fields
+// are stored as strings in a map column.
+type ParquetRow struct {
+	Time     int64             `parquet:"time,timestamp(microsecond)"`
+	Level    string            `parquet:"level,dict"`
+	Hostname string            `parquet:"hostname,dict"`
+	Tag      string            `parquet:"tag,dict"`
+	File     string            `parquet:"file,dict"`
+	Line     int64             `parquet:"line"`
+	Message  string            `parquet:"msg"`
+	Fields   map[string]string `parquet:"fields"`
+}
+
+// ParquetWriter buffers entries and writes them as Parquet files partitioned
+// by hour, so analytical engines such as DuckDB or Athena can query them
+// directly:
+//
+//	dir/date=2017-03-01/hour=12/20170301T121500.000000000Z-1.parquet
+//
+// It is a logrus hook registered with AddParquetWriter. A file is written
+// whenever Rows entries are buffered, every FlushInterval, on Flush and on
+// Close; files appear atomically, so readers never see partial ones.
+type ParquetWriter struct {
+	dir           string
+	rows          int
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	buf     []ParquetRow
+	closed  bool
+	writeMu sync.Mutex
+	seq     uint64
+
+	full      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewParquetWriter returns a ParquetWriter writing into dir, which is
+// created if needed. A non-positive rows or flushInterval selects the
+// default.
+func NewParquetWriter(dir string, rows int, flushInterval time.Duration) (*ParquetWriter, error) {
+	if err := mkdirLog(dir); err != nil {
+		return nil, err
+	}
+	if rows <= 0 {
+		rows = DefaultParquetRows
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultParquetFlushInterval
+	}
+	p := &ParquetWriter{
+		dir:           dir,
+		rows:          rows,
+		flushInterval: flushInterval,
+		full:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// AddParquetWriter registers p with the package logger.
+func AddParquetWriter(p *ParquetWriter) {
+	addHook(p)
+	addFlusher(p)
+}
+
+// Name returns the directory written to.
+func (p *ParquetWriter) Name() string {
+	return "parquet:" + p.dir
+}
+
+// Levels implements logrus.Hook.
+func (p *ParquetWriter) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook. Files are written in the background.
+func (p *ParquetWriter) Fire(entry *log.Entry) error {
+	site := entryCaller(entry.Data)
+	row := ParquetRow{
+		Time:     entry.Time.UnixNano() / int64(time.Microsecond),
+		Level:    severityName(entry.Level),
+		Hostname: entryHost(entry.Data),
+		Tag:      entryTag(entry.Data),
+		File:     site.file,
+		Line:     int64(site.line),
+		Message:  entry.Message,
+	}
+	for k, v := range entry.Data {
+		if isReserved(k) {
+			continue
+		}
+		if row.Fields == nil {
+			row.Fields = make(map[string]string, len(entry.Data))
+		}
+		if s, ok := v.(string); ok && k == LayersKey {
+			v = parseLayers(s)
+		}
+		row.Fields[k] = fmt.Sprint(v)
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.buf = append(p.buf, row)
+	n := len(p.buf)
+	p.mu.Unlock()
+
+	if n >= p.rows {
+		select {
+		case p.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered entries. The entries of the hours not written
+// because of an error are buffered again for the next Flush.
+func (p *ParquetWriter) Flush() error {
+	p.mu.Lock()
+	rows := p.buf
+	p.buf = nil
+	p.mu.Unlock()
+	if len(rows) == 0 {
+		return nil
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	// Entries relayed with WithTime may belong to other hours.
+	parts := make(map[time.Time][]ParquetRow)
+	var hours []time.Time
+	for _, r := range rows {
+		h := time.Unix(0, r.Time*int64(time.Microsecond)).UTC().Truncate(time.Hour)
+		if _, ok := parts[h]; !ok {
+			hours = append(hours, h)
+		}
+		parts[h] = append(parts[h], r)
+	}
+	for i, h := range hours {
+		if err := p.writeFile(h, parts[h]); err != nil {
+			var unwritten []ParquetRow
+			for _, h := range hours[i:] {
+				unwritten = append(unwritten, parts[h]...)
+			}
+			p.mu.Lock()
+			p.buf = append(unwritten, p.buf...)
+			p.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes rows to a new file in the partition of hour.
+func (p *ParquetWriter) writeFile(hour time.Time, rows []ParquetRow) error {
+	dir := filepath.Join(p.dir, "date="+hour.Format("2006-01-02"), "hour="+hour.Format("15"))
+	if err := mkdirLog(dir); err != nil {
+		return err
+	}
+	seq := atomic.AddUint64(&p.seq, 1)
+	name := filepath.Join(dir, time.Now().UTC().Format("20060102T150405.000000000Z")+"-"+strconv.FormatUint(seq, 10)+".parquet")
+
+	// Readers globbing *.parquet skip the file until it is complete.
+	tmp := name + ".tmp"
+	if err := parquet.WriteFile(tmp, rows); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %v", name, err)
+	}
+	return os.Rename(tmp, name)
+}
+
+// Close unregisters the writer, writes the buffered entries and stops it.
+// Later entries are discarded.
+func (p *ParquetWriter) Close() error {
+	p.closeOnce.Do(func() {
+		removeHook(p)
+		removeFlusher(p)
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		close(p.stop)
+	})
+	<-p.done
+	return p.Flush()
+}
+
+func (p *ParquetWriter) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.full:
+		case <-p.stop:
+			return
+		}
+		if err := p.Flush(); err != nil {
+			reportError(err)
+		}
+	}
+}
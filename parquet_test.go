@@ -0,0 +1,146 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetWriter(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewParquetWriter(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, ts := range []time.Time{
+		time.Date(2017, 3, 1, 12, 15, 0, 0, time.UTC),
+		time.Date(2017, 3, 1, 12, 45, 0, 0, time.UTC),
+		time.Date(2017, 3, 1, 13, 5, 0, 0, time.UTC),
+	} {
+		entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{"seq": i, hostKey: "probe-7"})
+		entry.Time = ts
+		entry.Level = log.WarnLevel
+		entry.Message = "link flap"
+		if err := p.Fire(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "date=2017-03-01", "hour=12", "*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("hour=12 has files %v, want 1", files)
+	}
+	rows, err := parquet.ReadFile[ParquetRow](files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("read %d rows, want 2", len(rows))
+	}
+	r := rows[1]
+	if r.Level != "warning" || r.Message != "link flap" || r.Hostname != "probe-7" || r.Fields["seq"] != "1" {
+		t.Errorf("row = %+v", r)
+	}
+	if got := time.Unix(0, r.Time*int64(time.Microsecond)).UTC(); !got.Equal(time.Date(2017, 3, 1, 12, 45, 0, 0, time.UTC)) {
+		t.Errorf("row time = %s", got)
+	}
+
+	if files, _ := filepath.Glob(filepath.Join(dir, "date=2017-03-01", "hour=13", "*.parquet")); len(files) != 1 {
+		t.Errorf("hour=13 has files %v, want 1", files)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*.tmp")); len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestParquetWriterRows(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewParquetWriter(dir, 2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	entry := log.NewEntry(log.StandardLogger())
+	entry.Time = time.Now()
+	p.Fire(entry)
+	p.Fire(entry)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if files, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*.parquet")); len(files) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries, _ := os.ReadDir(dir)
+	t.Errorf("no file written after reaching the row limit, dir has %v", entries)
+}
+
+func TestParquetWriterClose(t *testing.T) {
+	p, err := NewParquetWriter(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddParquetWriter(p)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := hooks.list.Load().([]registeredHook)
+	for _, r := range list {
+		if r.h == log.Hook(p) {
+			t.Error("the hook of the closed writer is still registered")
+		}
+	}
+	p.Fire(log.NewEntry(log.StandardLogger()))
+	if len(p.buf) != 0 {
+		t.Errorf("closed writer buffered %d rows", len(p.buf))
+	}
+}
+
+func TestParquetWriterFlushError(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewParquetWriter(dir, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	// A file where the partition of the first hour goes makes it fail.
+	blocker := filepath.Join(dir, "date=2017-03-01")
+	os.WriteFile(blocker, nil, 0644)
+
+	for _, ts := range []time.Time{
+		time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2017, 3, 2, 12, 0, 0, 0, time.UTC),
+	} {
+		entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{TagKey: "capture"})
+		entry.Time = ts
+		p.Fire(entry)
+	}
+	if err := p.Flush(); err == nil {
+		t.Fatal("Flush = nil with an unwritable partition")
+	}
+	os.Remove(blocker)
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*.parquet"))
+	if len(files) != 2 {
+		t.Fatalf("files %v, want the rows of both hours written by the second Flush", files)
+	}
+	rows, err := parquet.ReadFile[ParquetRow](files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Tag != "capture" || rows[0].Fields[TagKey] != "" {
+		t.Errorf("rows = %+v, want the tag of WithTag", rows)
+	}
+}
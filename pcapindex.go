@@ -0,0 +1,163 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FlowKey is the field identifying the flow an entry is about. LookupEntry
+// uses it to find the packets of the flow.
+const FlowKey = "flow"
+
+// PcapRef locates the packets of a flow in a capture file.
+type PcapRef struct {
+	Time time.Time
+	Flow string
+	// File is the capture file and Offset the byte offset of the first
+	// packet record of the flow from Time on.
+	File   string
+	Offset int64
+}
+
+// PcapIndex is an append-only index mapping flows and times to offsets in
+// pcap files, written by the capture code next to its logs so that a log
+// line about a flow leads straight to its packets:
+//
+//	idx.Record(pkt.Time, flowID, ring.Name(), ring.Offset())
+//	...
+//	log.With(log.FlowKey, flowID).Warning("retransmission storm")
+//
+// Records are lines of tab-separated time, flow, file and offset.
+type PcapIndex struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	w    *bufio.Writer
+}
+
+// OpenPcapIndex opens or creates the index file at path for appending.
+func OpenPcapIndex(path string) (*PcapIndex, error) {
+	if err := createLogDir(path); err != nil {
+		return nil, err
+	}
+	f, err := createLogFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	if err != nil {
+		return nil, err
+	}
+	return &PcapIndex{path: path, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Name returns the path of the index.
+func (x *PcapIndex) Name() string {
+	return x.path
+}
+
+// Record notes that the packets of flow from t on start at offset in the
+// capture file. Records are buffered; see Flush.
+func (x *PcapIndex) Record(t time.Time, flow, file string, offset int64) error {
+	if strings.ContainsAny(flow, "\t\n") || strings.ContainsAny(file, "\t\n") {
+		return fmt.Errorf("flow %q or file %q contains a tab or newline", flow, file)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.f == nil {
+		return os.ErrClosed
+	}
+	_, err := fmt.Fprintf(x.w, "%s\t%s\t%s\t%d\n", t.UTC().Format(time.RFC3339Nano), flow, file, offset)
+	return err
+}
+
+// Flush writes the buffered records to the index file.
+func (x *PcapIndex) Flush() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.f == nil {
+		return nil
+	}
+	return x.w.Flush()
+}
+
+// Close flushes and closes the index.
+func (x *PcapIndex) Close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.f == nil {
+		return nil
+	}
+	err := x.w.Flush()
+	if cerr := x.f.Close(); err == nil {
+		err = cerr
+	}
+	x.f = nil
+	return err
+}
+
+// ErrNoPackets is returned by LookupPcap when the index has no record of a
+// flow.
+var ErrNoPackets = errors.New("no packets indexed for flow")
+
+// LookupPcap returns the record of flow in the index file at path that is
+// closest to t: the latest one not after t or, if the flow only started
+// after t, the first one.
+func LookupPcap(path, flow string, t time.Time) (PcapRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return PcapRef{}, err
+	}
+	defer f.Close()
+
+	var before, after PcapRef
+	var haveBefore, haveAfter bool
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		parts := strings.Split(s.Text(), "\t")
+		if len(parts) != 4 || parts[1] != flow {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, parts[0])
+		if err != nil {
+			return PcapRef{}, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		offset, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil {
+			return PcapRef{}, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		ref := PcapRef{Time: ts, Flow: flow, File: parts[2], Offset: offset}
+		switch {
+		case !ts.After(t):
+			if !haveBefore || !ts.Before(before.Time) {
+				before, haveBefore = ref, true
+			}
+		case !haveAfter || ts.Before(after.Time):
+			after, haveAfter = ref, true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return PcapRef{}, err
+	}
+
+	switch {
+	case haveBefore:
+		return before, nil
+	case haveAfter:
+		return after, nil
+	}
+	return PcapRef{}, ErrNoPackets
+}
+
+// LookupEntry returns the packets of the flow e is about, identified by its
+// flow field, around the time of e.
+func LookupEntry(path string, e Entry) (PcapRef, error) {
+	flow, ok := e.Fields[FlowKey]
+	if !ok {
+		return PcapRef{}, fmt.Errorf("entry has no %s field", FlowKey)
+	}
+	return LookupPcap(path, fmt.Sprint(flow), e.Time)
+}
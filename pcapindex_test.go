@@ -0,0 +1,50 @@
+package log
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPcapIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture", "index.tsv")
+	x, err := OpenPcapIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	x.Record(base, "flow-1", "ring-0.pcap", 24)
+	x.Record(base.Add(time.Second), "flow-2", "ring-0.pcap", 1500)
+	x.Record(base.Add(time.Minute), "flow-1", "ring-1.pcap", 24)
+	if err := x.Record(base, "bad\tflow", "ring-0.pcap", 0); err == nil {
+		t.Error("Record accepted a flow containing a tab")
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		flow string
+		at   time.Time
+		want PcapRef
+	}{
+		{"flow-1", base.Add(30 * time.Second), PcapRef{base, "flow-1", "ring-0.pcap", 24}},
+		{"flow-1", base.Add(2 * time.Minute), PcapRef{base.Add(time.Minute), "flow-1", "ring-1.pcap", 24}},
+		{"flow-2", base, PcapRef{base.Add(time.Second), "flow-2", "ring-0.pcap", 1500}},
+	} {
+		got, err := LookupPcap(path, tt.flow, tt.at)
+		if err != nil || !got.Time.Equal(tt.want.Time) || got.File != tt.want.File || got.Offset != tt.want.Offset {
+			t.Errorf("LookupPcap(%s, %s) = %+v, %v, want %+v", tt.flow, tt.at, got, err, tt.want)
+		}
+	}
+
+	if _, err := LookupPcap(path, "flow-3", base); !errors.Is(err, ErrNoPackets) {
+		t.Errorf("LookupPcap(flow-3) error = %v, want ErrNoPackets", err)
+	}
+
+	ref, err := LookupEntry(path, Entry{Time: base.Add(90 * time.Second), Fields: Fields{FlowKey: "flow-1"}})
+	if err != nil || ref.File != "ring-1.pcap" {
+		t.Errorf("LookupEntry() = %+v, %v", ref, err)
+	}
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"io"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// ProductionRotation is the rotation InitProduction applies unless
+// SetRotation was called before: 100 MiB or one day per file, two weeks
+// of compressed backups.
+var ProductionRotation = RotationConfig{
+	MaxSize:    100 << 20,
+	MaxAge:     24 * time.Hour,
+	MaxBackups: 14,
+	Compress:   true,
+}
+
+// InitDevelopment configures the package logger for a developer's
+// machine: every entry, debug included, goes to stderr with the short file,
+// line and function of its caller, in the console format if stderr is a
+// terminal and in the text format if it is piped, its severity in color if
+// stderr is a terminal and NO_COLOR is not set. Like Init, it is meant to
+// be called once at startup; every setting can still be changed
+// afterwards.
+func InitDevelopment() {
+	initOnce("InitDevelopment", "", "debug", func() {
+		SetColor(colorTerminal(os.Stderr))
+		if isTerminal(os.Stderr) {
+			SetFormat(ConsoleFormat)
+		} else {
+			SetFormat(TextFormat)
+		}
+		SetCallerStyle(CallerShort, true)
+		SetReportCaller(true)
+		initStream(os.Stderr, "debug")
+	})
+}
+
+// InitProduction configures the package logger for a service: entries at
+// info and above are written as JSON lines to the file name, rotated as
+// ProductionRotation, and only warnings and errors record their caller,
+// which is the costly part of an entry.
+func InitProduction(name string) {
+	initOnce("InitProduction", name, "info", func() {
+		SetFormat(JSONFormat)
+		SetReportCaller(true)
+		SetCallerLevel("warning")
+		if _, ok := rotationConfig(); !ok {
+			SetRotation(ProductionRotation)
+		}
+		initFile(name, "info")
+	})
+}
+
+// InitCLI configures the package logger for command line tools: entries at
+// info and above go to stderr in the text format, without their caller,
+// their severity in color if stderr is a terminal and NO_COLOR is not set.
+func InitCLI() {
+	initOnce("InitCLI", "", "info", func() {
+		SetFormat(TextFormat)
+		SetColor(colorTerminal(os.Stderr))
+		SetReportCaller(false)
+		initStream(os.Stderr, "info")
+	})
+}
+
+// initStream is Init for an output other than a file.
+func initStream(w io.Writer, level string) {
+	tag = os.Args[0]
+	log.SetFormatter(activeFormatter())
+	SetLevel(level)
+	setOutput(w)
+
+	current.Lock()
+	current.file = ""
+	current.Unlock()
+}
+
+// colorTerminal reports whether f is a terminal whose user did not turn off
+// colors by setting NO_COLOR to a non-empty value, see https://no-color.org.
+func colorTerminal(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
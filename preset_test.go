@@ -0,0 +1,93 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// resetInit lets a test call an Init function again and restores the
+// settings the presets change when it ends.
+func resetInit(t *testing.T) {
+	updateFormatter(func(f *Formatter) { f.once = &sync.Once{} })
+	oldTag := tag
+	t.Cleanup(func() {
+		updateFormatter(func(f *Formatter) { f.once = &sync.Once{} })
+		tag = oldTag
+		rotation.Lock()
+		rotation.cfg = nil
+		rotation.Unlock()
+		SetFormat(TextFormat)
+		SetCallerStyle(CallerFull, false)
+		SetColor(false)
+		SetCallerLevel("debug")
+		SetReportCaller(true)
+		SetLevel("debug")
+		SetOutputs(os.Stderr)
+	})
+}
+
+func TestInitProduction(t *testing.T) {
+	resetInit(t)
+	name := filepath.Join(t.TempDir(), "probe.log")
+	InitProduction(name)
+
+	Debug("hidden")
+	Info("started")
+	Warning("queue high")
+	Flush()
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log file = %q, want the info and warning entries", b)
+	}
+	var info, warn map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &info); err != nil {
+		t.Fatalf("entry %q is not JSON: %v", lines[0], err)
+	}
+	json.Unmarshal([]byte(lines[1]), &warn)
+	if info["file"] != "" {
+		t.Errorf("info entry %q records its caller", lines[0])
+	}
+	if warn["file"] == "" {
+		t.Errorf("warning entry %q does not record its caller", lines[1])
+	}
+	if cfg, ok := rotationConfig(); !ok || cfg != ProductionRotation {
+		t.Errorf("rotation = %+v, %v, want ProductionRotation", cfg, ok)
+	}
+}
+
+func TestInitDevelopment(t *testing.T) {
+	resetInit(t)
+	InitDevelopment()
+
+	if f := packageFormatter(); getLevel().String() != "debug" || !f.CallerFunction || f.CallerStyle != CallerShort {
+		t.Errorf("level %s, formatter %+v, want debug with short callers and functions", getLevel(), f)
+	}
+	var buf syncBuffer
+	SetOutputs(&buf)
+	Debug("detail")
+	if !strings.Contains(buf.String(), "preset_test.go:") {
+		t.Errorf("output = %q, want the short caller", buf.String())
+	}
+}
+
+func TestInitCLI(t *testing.T) {
+	resetInit(t)
+	InitCLI()
+
+	var buf syncBuffer
+	SetOutputs(&buf)
+	Debug("hidden")
+	Info("copying")
+	if strings.Contains(buf.String(), "hidden") || strings.Contains(buf.String(), "preset_test.go") {
+		t.Errorf("output = %q, want info entries without caller", buf.String())
+	}
+}
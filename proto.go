@@ -0,0 +1,106 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultProtoLimit is the number of bytes of a protobuf message written
+// in a field unless SetProtoEncoder sets another limit.
+const DefaultProtoLimit = 4096
+
+// ProtoMessage is implemented by the messages generated by protoc-gen-go,
+// both APIs, and by gogo/protobuf.
+type ProtoMessage interface {
+	ProtoMessage()
+}
+
+// ProtoEncoder renders a protobuf message for a field. Encoders returning
+// JSON, such as protojson.Marshal, write the message as an object in the
+// JSON format.
+type ProtoEncoder func(m ProtoMessage) ([]byte, error)
+
+var protos = struct {
+	sync.RWMutex
+	encode ProtoEncoder
+	limit  int
+}{limit: DefaultProtoLimit}
+
+// SetProtoEncoder sets how fields holding protobuf messages are written,
+// instead of as the dump of their Go struct. The default, or a nil
+// encode, is the compact text format returned by the String method of
+// generated messages. For JSON with the field names of the .proto file:
+//
+//	log.SetProtoEncoder(func(m log.ProtoMessage) ([]byte, error) {
+//		return protojson.Marshal(m.(proto.Message))
+//	}, 0)
+//
+// Encodings longer than limit bytes, DefaultProtoLimit if not positive,
+// are truncated and written as strings.
+func SetProtoEncoder(encode ProtoEncoder, limit int) {
+	if limit <= 0 {
+		limit = DefaultProtoLimit
+	}
+	protos.Lock()
+	protos.encode = encode
+	protos.limit = limit
+	protos.Unlock()
+}
+
+// protoValue is the rendering of a protobuf message in a field: a string
+// in text, an object in JSON if the encoding is JSON.
+type protoValue struct {
+	b []byte
+	// json is set if b is a complete JSON object.
+	json bool
+}
+
+func (p protoValue) String() string {
+	return string(p.b)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p protoValue) MarshalJSON() ([]byte, error) {
+	if p.json {
+		return p.b, nil
+	}
+	return []byte(strconv.Quote(string(p.b))), nil
+}
+
+// encodeProtos replaces the protobuf messages in fields with their
+// rendering.
+func encodeProtos(fields log.Fields) log.Fields {
+	for k, v := range fields {
+		m, ok := v.(ProtoMessage)
+		if !ok {
+			continue
+		}
+		fields[k] = encodeProto(m)
+	}
+	return fields
+}
+
+// encodeProto renders m with the encoder and limit of SetProtoEncoder.
+func encodeProto(m ProtoMessage) protoValue {
+	protos.RLock()
+	encode, limit := protos.encode, protos.limit
+	protos.RUnlock()
+
+	var b []byte
+	if encode == nil {
+		b = []byte(fmt.Sprint(m))
+	} else {
+		var err error
+		if b, err = encode(m); err != nil {
+			return protoValue{b: []byte(fmt.Sprintf("proto: %v", err))}
+		}
+	}
+	if len(b) > limit {
+		return protoValue{b: []byte(fmt.Sprintf("%s...(%d bytes)", b[:limit], len(b)))}
+	}
+	return protoValue{b: b, json: len(b) > 0 && b[0] == '{' && json.Valid(b)}
+}
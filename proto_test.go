@@ -0,0 +1,60 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// probeStatus mimics a generated message.
+type probeStatus struct {
+	Iface   string
+	Dropped int
+}
+
+func (*probeStatus) ProtoMessage() {}
+
+func (s *probeStatus) String() string {
+	return fmt.Sprintf("iface:%q dropped:%d", s.Iface, s.Dropped)
+}
+
+func TestProtoFields(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	WithFields(Fields{"status": &probeStatus{"eth1", 3}}).Info("probe status")
+	if got := buf.String(); !strings.Contains(got, ` status="iface:\"eth1\" dropped:3"`) {
+		t.Errorf("entry = %q, want the text format of the message", got)
+	}
+
+	SetProtoEncoder(func(m ProtoMessage) ([]byte, error) {
+		return json.Marshal(m)
+	}, 0)
+	defer SetProtoEncoder(nil, 0)
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+	buf.Reset()
+	WithFields(Fields{"status": &probeStatus{"eth1", 3}}).Info("probe status")
+	if got := buf.String(); !strings.Contains(got, `"status":{"Iface":"eth1","Dropped":3}`) {
+		t.Errorf("entry = %q, want the message as an object", got)
+	}
+}
+
+func TestEncodeProto(t *testing.T) {
+	m := &probeStatus{strings.Repeat("x", 20), 3}
+	SetProtoEncoder(nil, 10)
+	defer SetProtoEncoder(nil, 0)
+	if got, want := encodeProto(m).String(), `iface:"xxx...(38 bytes)`; got != want {
+		t.Errorf("truncated = %q, want %q", got, want)
+	}
+
+	SetProtoEncoder(func(ProtoMessage) ([]byte, error) { return nil, errors.New("unknown type") }, 0)
+	if got := encodeProto(m).String(); got != "proto: unknown type" {
+		t.Errorf("failed encoding = %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// rateLimitReportInterval is how often the number of entries suppressed by
+// the rate limit is logged.
+const rateLimitReportInterval = time.Second
+
+// rateLimiter is a token bucket capping the number of entries written per
+// second across the whole process.
+type rateLimiter struct {
+	mu         sync.Mutex
+	rate       float64
+	burst      float64
+	tokens     float64
+	last       time.Time
+	suppressed uint64
+	stop       chan struct{}
+}
+
+var limiter = &rateLimiter{}
+
+// SetRateLimit caps the number of entries written to perSecond, allowing
+// bursts of up to burst entries. Entries above the cap are dropped and
+// counted; the count is logged with severity WARNING once per second and is
+// also available in Stats. FATAL entries are never dropped. A non-positive
+// perSecond removes the limit.
+func SetRateLimit(perSecond float64, burst int) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if limiter.stop != nil {
+		close(limiter.stop)
+		limiter.stop = nil
+	}
+
+	limiter.rate = perSecond
+	if perSecond <= 0 {
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	limiter.burst = float64(burst)
+	limiter.tokens = limiter.burst
+	limiter.last = time.Now()
+	limiter.stop = make(chan struct{})
+	go limiter.report(limiter.stop)
+}
+
+// allow reports whether an entry at level may be written now.
+func (l *rateLimiter) allow(level log.Level) bool {
+	if level <= log.FatalLevel {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		l.suppressed++
+		atomic.AddUint64(&stats.RateLimited, 1)
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// report logs the suppressed count every interval until stop is closed.
+func (l *rateLimiter) report(stop chan struct{}) {
+	ticker := time.NewTicker(rateLimitReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.mu.Lock()
+			n := l.suppressed
+			l.suppressed = 0
+			l.mu.Unlock()
+
+			if n > 0 {
+				// The summary bypasses the limit, it is the one
+				// entry that must get through.
+				countWritten(log.WarnLevel)
+				dispatch(log.WarnLevel, captureCaller(log.WarnLevel, 0), "rate limit exceeded", log.Fields{"suppressed": n})
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package log
+
+import (
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestRateLimiter(t *testing.T) {
+	SetRateLimit(1, 3)
+	defer SetRateLimit(0, 0)
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if limiter.allow(log.InfoLevel) {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d entries, want the burst of 3", allowed)
+	}
+	if !limiter.allow(log.FatalLevel) {
+		t.Error("FATAL entry was rate limited")
+	}
+
+	limiter.mu.Lock()
+	suppressed := limiter.suppressed
+	limiter.mu.Unlock()
+	if suppressed != 7 {
+		t.Errorf("suppressed = %d, want 7", suppressed)
+	}
+}
@@ -0,0 +1,163 @@
+package log
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// rateMinutes is the number of minutes of entry counts kept per component.
+const rateMinutes = 60
+
+// ComponentStats is the entry volume of one component, see WithComponent.
+type ComponentStats struct {
+	// Component is empty for the entries not attributed to any.
+	Component string
+	// PerMinute counts the entries of each of the last 60 minutes, oldest
+	// first, the current minute last.
+	PerMinute []uint64
+	// Total counts the entries since the process started.
+	Total uint64
+	// OverBudget counts the entries dropped by the budget of the
+	// component, see SetComponentBudget.
+	OverBudget uint64
+}
+
+// componentRate counts the entries of a component per minute.
+type componentRate struct {
+	mu sync.Mutex
+	// minutes holds the minute, since the Unix epoch, each count is of.
+	minutes [rateMinutes]int64
+	counts  [rateMinutes]uint64
+	total   uint64
+	// reported is the total at the previous component rate summary.
+	reported uint64
+}
+
+// rates maps component names to their *componentRate.
+var rates sync.Map
+
+// countEntry counts an entry of the component named in data that passed the
+// level. Entries are counted before sampling and the rate limit, so the
+// counts show what each component tries to log.
+func countEntry(data log.Fields, now time.Time) {
+	c, _ := data[ComponentKey].(string)
+	v, ok := rates.Load(c)
+	if !ok {
+		v, _ = rates.LoadOrStore(c, &componentRate{})
+	}
+	r := v.(*componentRate)
+
+	m := now.Unix() / 60
+	i := m % rateMinutes
+	if atomic.LoadInt64(&r.minutes[i]) != m {
+		r.mu.Lock()
+		if r.minutes[i] != m {
+			atomic.StoreUint64(&r.counts[i], 0)
+			atomic.StoreInt64(&r.minutes[i], m)
+		}
+		r.mu.Unlock()
+	}
+	atomic.AddUint64(&r.counts[i], 1)
+	atomic.AddUint64(&r.total, 1)
+}
+
+// componentStats returns the entry volume of every component, sorted by
+// name.
+func componentStats(now time.Time) []ComponentStats {
+	var list []ComponentStats
+	m := now.Unix() / 60
+	rates.Range(func(k, v interface{}) bool {
+		r := v.(*componentRate)
+		s := ComponentStats{
+			Component:  k.(string),
+			PerMinute:  make([]uint64, rateMinutes),
+			Total:      atomic.LoadUint64(&r.total),
+			OverBudget: budgetDropped(k.(string)),
+		}
+		for j := range s.PerMinute {
+			minute := m - int64(rateMinutes-1-j)
+			i := minute % rateMinutes
+			if atomic.LoadInt64(&r.minutes[i]) == minute {
+				s.PerMinute[j] = atomic.LoadUint64(&r.counts[i])
+			}
+		}
+		list = append(list, s)
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Component < list[j].Component })
+	return list
+}
+
+var rateSummary struct {
+	sync.Mutex
+	stop chan struct{}
+}
+
+// SetComponentRateSummary logs, every interval, an INFO entry "component
+// rates" with the number of entries each component logged during the
+// interval, e.g. storage=12000 capture=40, and other for the entries not
+// attributed to a component, to find the part of a process responsible for
+// a sudden volume spike. Components that logged nothing are left out. A
+// non-positive interval disables the summary, which is the default.
+func SetComponentRateSummary(interval time.Duration) {
+	rateSummary.Lock()
+	defer rateSummary.Unlock()
+
+	if rateSummary.stop != nil {
+		close(rateSummary.stop)
+		rateSummary.stop = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	// The first summary covers the first interval only.
+	rates.Range(func(_, v interface{}) bool {
+		r := v.(*componentRate)
+		atomic.StoreUint64(&r.reported, atomic.LoadUint64(&r.total))
+		return true
+	})
+	rateSummary.stop = make(chan struct{})
+	go runRateSummary(interval, rateSummary.stop)
+}
+
+func runRateSummary(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			reportRates(interval)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// reportRates logs the entries per component since the previous report.
+func reportRates(interval time.Duration) {
+	fields := log.Fields{}
+	rates.Range(func(k, v interface{}) bool {
+		r := v.(*componentRate)
+		total := atomic.LoadUint64(&r.total)
+		delta := total - atomic.SwapUint64(&r.reported, total)
+		if delta == 0 {
+			return true
+		}
+		name := k.(string)
+		if name == "" {
+			name = "other"
+		}
+		fields[name] = delta
+		return true
+	})
+	if len(fields) == 0 {
+		return
+	}
+	fields["interval"] = interval.String()
+	emit(log.InfoLevel, captureCaller(log.InfoLevel, 0), "component rates", fields)
+}
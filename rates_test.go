@@ -0,0 +1,106 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func componentTotal(name string) (ComponentStats, bool) {
+	for _, c := range Stats().Components {
+		if c.Component == name {
+			return c, true
+		}
+	}
+	return ComponentStats{}, false
+}
+
+func TestComponentStats(t *testing.T) {
+	SetOutputs(&syncBuffer{})
+	defer SetOutputs(os.Stderr)
+	rates.Delete(string(Decode))
+
+	l := WithComponent(Decode)
+	for i := 0; i < 5; i++ {
+		l.Info("decoded")
+	}
+	SetLevel("info")
+	l.Debug("below the level")
+	SetLevel("debug")
+
+	c, ok := componentTotal(string(Decode))
+	if !ok {
+		t.Fatalf("Stats().Components = %+v, want decode", Stats().Components)
+	}
+	if c.Total != 5 {
+		t.Errorf("Total = %d, want 5", c.Total)
+	}
+	if len(c.PerMinute) != rateMinutes {
+		t.Fatalf("PerMinute has %d minutes, want %d", len(c.PerMinute), rateMinutes)
+	}
+	// The entries may straddle a minute boundary.
+	if n := c.PerMinute[rateMinutes-1] + c.PerMinute[rateMinutes-2]; n != 5 {
+		t.Errorf("last minutes = %d, want 5", n)
+	}
+}
+
+func TestComponentStatsMinutes(t *testing.T) {
+	key := "minutes-test"
+	defer rates.Delete(key)
+	data := log.Fields{ComponentKey: key}
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	countEntry(data, start)
+	countEntry(data, start.Add(time.Minute))
+	countEntry(data, start.Add(time.Minute+30*time.Second))
+
+	var got ComponentStats
+	for _, c := range componentStats(start.Add(2 * time.Minute)) {
+		if c.Component == key {
+			got = c
+		}
+	}
+	last := got.PerMinute[rateMinutes-3:]
+	if last[0] != 1 || last[1] != 2 || last[2] != 0 {
+		t.Errorf("last minutes = %v, want [1 2 0]", last)
+	}
+
+	// An hour later the buckets are stale.
+	for _, c := range componentStats(start.Add(time.Hour + 2*time.Minute)) {
+		for _, n := range c.PerMinute {
+			if c.Component == key && n != 0 {
+				t.Errorf("PerMinute = %v an hour later, want zeros", c.PerMinute)
+				break
+			}
+		}
+	}
+}
+
+func TestComponentRateSummary(t *testing.T) {
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	SetComponentRateSummary(time.Hour)
+	defer SetComponentRateSummary(0)
+	for i := 0; i < 3; i++ {
+		WithComponent(Storage).Info("stored")
+	}
+	WithComponent(Capture).Info("captured")
+	reportRates(time.Hour)
+
+	out := buf.String()
+	if !strings.Contains(out, "component rates") || !strings.Contains(out, "capture=1") ||
+		!strings.Contains(out, "storage=3") {
+		t.Errorf("output = %q, want the entries per component", out)
+	}
+	// The second summary only counts the first one, which has no component.
+	reportRates(time.Hour)
+	out = buf.String()
+	last := out[strings.LastIndex(out, "component rates"):]
+	if strings.Contains(last, "storage=") || !strings.Contains(last, "other=1") {
+		t.Errorf("second summary = %q, want only the first summary counted", last)
+	}
+}
@@ -0,0 +1,134 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultReadLimit is the number of entries ReadEntries returns when no
+// limit is given.
+const DefaultReadLimit = 100
+
+// ReadEntries reads up to limit entries, or DefaultReadLimit if limit is
+// not positive, matching filter from the log file name, starting at the
+// byte offset offset, and returns them with the offset to continue from.
+// Offsets stay valid while the file grows, so an admin UI can page through
+// a file, or poll it for new entries, by passing the returned offset back:
+//
+//	entries, next, err := log.ReadEntries("/var/log/probe.log", cursor, 50, nil)
+//
+// A nil filter matches every entry. Both the text and the JSON format are
+// understood; text lines keep their fields in Message. Lines that are not
+// entries, e.g. the continuation of a multi-line message, and lines longer
+// than DefaultMaxLineBytes are skipped. A line still being written is left
+// for the next call. An offset beyond the end of the file, which has been
+// rotated or truncated since, starts over at its beginning.
+func ReadEntries(name string, offset int64, limit int, filter func(Entry) bool) ([]Entry, int64, error) {
+	if limit <= 0 {
+		limit = DefaultReadLimit
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+	if offset < 0 || offset > fi.Size() {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+
+	var entries []Entry
+	r := bufio.NewReaderSize(f, 64*1024)
+	for len(entries) < limit {
+		line, n, err := readLine(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entries, offset, err
+		}
+		offset += n
+		e, ok := parseEntry(line)
+		if ok && (filter == nil || filter(e)) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, offset, nil
+}
+
+// readLine returns the next complete line of r without its newline, and
+// the number of bytes it takes in the file. Lines longer than
+// DefaultMaxLineBytes are returned empty. An incomplete last line is
+// reported as io.EOF.
+func readLine(r *bufio.Reader) ([]byte, int64, error) {
+	var line []byte
+	var n int64
+	for {
+		chunk, err := r.ReadSlice('\n')
+		n += int64(len(chunk))
+		if n <= DefaultMaxLineBytes+1 {
+			line = append(line, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if n > DefaultMaxLineBytes+1 {
+			return nil, n, nil
+		}
+		return bytes.TrimRight(line, "\r\n"), n, nil
+	}
+}
+
+// parseEntry decodes a line in the text or JSON format.
+func parseEntry(line []byte) (Entry, bool) {
+	if len(line) == 0 {
+		return Entry{}, false
+	}
+	if line[0] == '{' {
+		var e Entry
+		if err := json.Unmarshal(line, &e); err == nil && e.Level != "" {
+			return e, true
+		}
+	}
+
+	s := string(line)
+	ts, level, caller, msg := parseLine(s)
+	if level == "unparsed" {
+		return Entry{}, false
+	}
+	e := Entry{Time: ts, Level: level, Message: msg}
+	caller, e.Tag = splitCallerTag(caller)
+	if sp := strings.IndexByte(s, ' '); sp >= 0 {
+		if colon := strings.Index(s, " : "); colon > sp {
+			e.Host = s[sp+1 : colon]
+		}
+	}
+	// The caller is file:line, possibly followed by :function.
+	for k := 0; k < 2; k++ {
+		i := strings.LastIndexByte(caller, ':')
+		if i < 0 {
+			break
+		}
+		if n, err := strconv.Atoi(caller[i+1:]); err == nil {
+			e.File, e.Line = caller[:i], n
+			break
+		}
+		caller = caller[:i]
+	}
+	return e, true
+}
@@ -0,0 +1,98 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestReadEntries(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "probe.log")
+	ts := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	lines := []string{
+		string(formatLine(ts, log.InfoLevel, "probe.go:12", "capture started", log.Fields{hostKey: "probe1", "iface": "eth0"})),
+		"panic: runtime error\n",
+		string(formatLine(ts.Add(time.Second), log.ErrorLevel, "probe.go:40:main.run", "disk full", log.Fields{hostKey: "probe1"})),
+		`{"time":"2026-10-14T10:00:02Z","hostname":"probe2","level":"warning","msg":"queue high","queue":3}` + "\n",
+		strings.Repeat("x", DefaultMaxLineBytes+10) + "\n",
+		string(formatLine(ts.Add(3*time.Second), log.InfoLevel, "", "capture stopped", log.Fields{hostKey: "probe1"})),
+	}
+	if err := os.WriteFile(name, []byte(strings.Join(lines, "")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, next, err := ReadEntries(name, 0, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(entries))
+	}
+	e := entries[0]
+	if e.Level != "info" || e.Host != "probe1" || e.File != "probe.go" || e.Line != 12 ||
+		!e.Time.Equal(ts) || e.Message != "capture started iface=eth0" {
+		t.Errorf("first entry = %+v", e)
+	}
+	if e := entries[1]; e.Level != "error" || e.File != "probe.go" || e.Line != 40 {
+		t.Errorf("second entry = %+v, want the line without the function", e)
+	}
+	if want := int64(len(lines[0]) + len(lines[1]) + len(lines[2])); next != want {
+		t.Errorf("next = %d, want %d", next, want)
+	}
+
+	entries, next, err = ReadEntries(name, next, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Message != "queue high" || entries[0].Fields["queue"] != 3.0 ||
+		entries[1].Message != "capture stopped" {
+		t.Fatalf("second page = %+v", entries)
+	}
+
+	// A line being written is left for the next call.
+	f, _ := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("2026-10-14T10:00:04Z probe1 : INFO\t")
+	entries, again, _ := ReadEntries(name, next, 0, nil)
+	if len(entries) != 0 || again != next {
+		t.Errorf("partial line: %d entries, next %d, want none and %d", len(entries), again, next)
+	}
+	f.WriteString("[1] resumed\n")
+	f.Close()
+	entries, _, _ = ReadEntries(name, next, 0, nil)
+	if len(entries) != 1 || entries[0].Message != "resumed" {
+		t.Errorf("after completion = %+v", entries)
+	}
+}
+
+func TestReadEntriesFilter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "probe.log")
+	var b strings.Builder
+	for i := 0; i < 10; i++ {
+		level := log.InfoLevel
+		if i%3 == 0 {
+			level = log.ErrorLevel
+		}
+		b.Write(formatLine(time.Now(), level, "", "entry", nil))
+	}
+	os.WriteFile(name, []byte(b.String()), 0600)
+
+	errorsOnly := func(e Entry) bool { return e.Level == "error" }
+	entries, next, err := ReadEntries(name, 0, 3, errorsOnly)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("ReadEntries() = %d entries, %v", len(entries), err)
+	}
+	entries, _, _ = ReadEntries(name, next, 3, errorsOnly)
+	if len(entries) != 1 {
+		t.Errorf("second page has %d entries, want 1", len(entries))
+	}
+
+	// An offset past the end, e.g. after a rotation, starts over.
+	entries, _, _ = ReadEntries(name, 1<<30, 0, errorsOnly)
+	if len(entries) != 4 {
+		t.Errorf("after rotation %d entries, want 4", len(entries))
+	}
+}
@@ -0,0 +1,257 @@
+package log
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default limits of a Receiver.
+const (
+	DefaultMaxLineBytes = 64 << 10
+	DefaultMaxBodyBytes = 8 << 20
+	DefaultMaxConns     = 64
+	DefaultIdleTimeout  = 5 * time.Minute
+)
+
+// Receiver accepts newline delimited JSON entries, as written by
+// JSONFormatter, over TCP or HTTP and passes them to Ingest, so small agents
+// can forward their logs to a central process.
+//
+// Over TCP, a client sends one entry per line. If Token is set, the first
+// line must be "AUTH <token>". A line "SYNC" is answered with "OK" once the
+// entries before it are ingested, which is how a Forwarder knows a batch
+// arrived. Over HTTP, a client POSTs a body of entries
+// and, if Token is set, authenticates with "Authorization: Bearer <token>".
+type Receiver struct {
+	// Token is the shared secret clients must present. No authentication
+	// is performed if it is empty.
+	Token string
+
+	// MaxLineBytes limits the size of a single entry, MaxBodyBytes the
+	// size of an HTTP request, MaxConns the number of concurrent TCP
+	// connections and IdleTimeout how long a TCP connection may stay
+	// silent. Zero values select the defaults.
+	MaxLineBytes int
+	MaxBodyBytes int64
+	MaxConns     int
+	IdleTimeout  time.Duration
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	closed    bool
+}
+
+var errUnauthorized = errors.New("unauthorized")
+
+// ServeTCP accepts connections on l until l is closed or Close is called.
+func (r *Receiver) ServeTCP(l net.Listener) error {
+	if !r.track(l, nil) {
+		return net.ErrClosed
+	}
+	defer r.untrack(l, nil)
+
+	sem := make(chan struct{}, r.maxConns())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if r.isClosed() {
+				return nil
+			}
+			return err
+		}
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			reportError(fmt.Errorf("receiver: refusing %s: too many connections", conn.RemoteAddr()))
+			conn.Close()
+			continue
+		}
+		if !r.track(nil, conn) {
+			conn.Close()
+			return nil
+		}
+		go func() {
+			defer func() { <-sem }()
+			defer r.untrack(nil, conn)
+			defer conn.Close()
+			if err := r.serveConn(conn); err != nil && !r.isClosed() {
+				reportError(fmt.Errorf("receiver: %s: %v", conn.RemoteAddr(), err))
+			}
+		}()
+	}
+}
+
+func (r *Receiver) serveConn(conn net.Conn) error {
+	idle := r.IdleTimeout
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+
+	sc := r.scanner(conn)
+	authenticated := r.Token == ""
+	for {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		if !sc.Scan() {
+			return sc.Err()
+		}
+		if !authenticated {
+			line := sc.Text()
+			if !strings.HasPrefix(line, "AUTH ") || !r.validToken(line[len("AUTH "):]) {
+				atomic.AddUint64(&stats.Rejected, 1)
+				return errUnauthorized
+			}
+			authenticated = true
+			continue
+		}
+		if sc.Text()+"\n" == forwardSync {
+			conn.SetWriteDeadline(time.Now().Add(idle))
+			if _, err := io.WriteString(conn, forwardAck); err != nil {
+				return err
+			}
+			continue
+		}
+		r.ingestLine(sc.Bytes())
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Token != "" && !r.validToken(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")) {
+		atomic.AddUint64(&stats.Rejected, 1)
+		http.Error(w, errUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	max := r.MaxBodyBytes
+	if max <= 0 {
+		max = DefaultMaxBodyBytes
+	}
+	sc := r.scanner(http.MaxBytesReader(w, req.Body, max))
+	accepted, rejected := 0, 0
+	for sc.Scan() {
+		if r.ingestLine(sc.Bytes()) {
+			accepted++
+		} else {
+			rejected++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"accepted": accepted, "rejected": rejected})
+}
+
+// Close stops all ServeTCP calls and closes their connections.
+func (r *Receiver) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	for l := range r.listeners {
+		l.Close()
+	}
+	for c := range r.conns {
+		c.Close()
+	}
+	return nil
+}
+
+// ingestLine decodes and ingests one entry, reporting whether it was
+// accepted.
+func (r *Receiver) ingestLine(line []byte) bool {
+	if len(strings.TrimSpace(string(line))) == 0 {
+		return true
+	}
+
+	var e Entry
+	err := json.Unmarshal(line, &e)
+	if err == nil {
+		err = Ingest(e)
+	}
+	if err != nil {
+		atomic.AddUint64(&stats.Rejected, 1)
+		reportError(fmt.Errorf("receiver: rejected entry: %v", err))
+		return false
+	}
+	atomic.AddUint64(&stats.Received, 1)
+	return true
+}
+
+func (r *Receiver) scanner(rd io.Reader) *bufio.Scanner {
+	max := r.MaxLineBytes
+	if max <= 0 {
+		max = DefaultMaxLineBytes
+	}
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 0, 4096), max)
+	return sc
+}
+
+func (r *Receiver) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(r.Token)) == 1
+}
+
+func (r *Receiver) maxConns() int {
+	if r.MaxConns <= 0 {
+		return DefaultMaxConns
+	}
+	return r.MaxConns
+}
+
+// track registers a listener or connection, reporting false once the
+// receiver is closed.
+func (r *Receiver) track(l net.Listener, c net.Conn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return false
+	}
+	if l != nil {
+		if r.listeners == nil {
+			r.listeners = make(map[net.Listener]bool)
+		}
+		r.listeners[l] = true
+	}
+	if c != nil {
+		if r.conns == nil {
+			r.conns = make(map[net.Conn]bool)
+		}
+		r.conns[c] = true
+	}
+	return true
+}
+
+func (r *Receiver) untrack(l net.Listener, c net.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.listeners, l)
+	delete(r.conns, c)
+}
+
+func (r *Receiver) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
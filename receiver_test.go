@@ -0,0 +1,93 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for use as an output while a test
+// goroutine reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestReceiverTCP(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Receiver{Token: "s3cret"}
+	go r.ServeTCP(l)
+	defer r.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(conn, "AUTH s3cret\n")
+	fmt.Fprintf(conn, `{"time":"2017-03-01T12:00:00Z","hostname":"probe-7","level":"warning","msg":"link flap","iface":"eth1"}`+"\n")
+	conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "link flap") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := out.String(); !strings.Contains(got, "probe-7 : WARNING") || !strings.Contains(got, "iface=eth1") {
+		t.Errorf("received entry not logged correctly: %q", got)
+	}
+}
+
+func TestReceiverHTTPAuth(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	r := &Receiver{Token: "s3cret"}
+	body := `{"level":"info","msg":"first"}` + "\n" + `{"level":"bogus","msg":"second"}` + "\n"
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/ingest", strings.NewReader(body)))
+	if rec.Code != 401 {
+		t.Errorf("unauthenticated request got status %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/ingest", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	r.ServeHTTP(rec, req)
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"accepted":1,"rejected":1}` {
+		t.Errorf("response = %s, want one accepted and one rejected entry", got)
+	}
+	if !strings.Contains(out.String(), "first") {
+		t.Errorf("accepted entry was not logged: %q", out.String())
+	}
+}
@@ -0,0 +1,137 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxRecent bounds the number of entries held by the recent buffer so a
+// runaway debug loop cannot exhaust memory.
+const maxRecent = 100000
+
+// recentEntry is a log entry retained by the recent buffer.
+type recentEntry struct {
+	time   time.Time
+	level  log.Level
+	site   callSite
+	msg    string
+	fields log.Fields
+}
+
+// recentBuffer keeps every entry, regardless of the active level, that was
+// logged within the last window, or the last limit entries.
+type recentBuffer struct {
+	mu      sync.Mutex
+	window  time.Duration
+	limit   int
+	entries []recentEntry
+}
+
+var recent = &recentBuffer{}
+
+// KeepRecent keeps all entries logged in the last window in memory,
+// including those below the active level, so they can later be written out
+// with DumpRecent. A zero window disables the buffer and discards its
+// contents.
+func KeepRecent(window time.Duration) {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+
+	recent.window = window
+	if window <= 0 && recent.limit <= 0 {
+		recent.entries = nil
+	}
+}
+
+// KeepLast keeps the last n entries in memory, including those below the
+// active level, so that a post-mortem finds the debug entries that led to
+// a failure without them being written all along; see DumpRecent and
+// SetRecentDump. With KeepRecent too, the entries of the window are kept,
+// n at most. A non-positive n removes the limit, discarding the buffer
+// without a window.
+func KeepLast(n int) {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+
+	recent.limit = n
+	if n <= 0 && recent.window <= 0 {
+		recent.entries = nil
+	} else if n > 0 && len(recent.entries) > n {
+		recent.entries = append(recent.entries[:0], recent.entries[len(recent.entries)-n:]...)
+	}
+}
+
+// DumpRecent writes the entries retained by KeepRecent and KeepLast to w,
+// oldest first.
+func DumpRecent(w io.Writer) error {
+	for _, e := range recent.snapshot() {
+		if _, err := w.Write(e.format()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot returns the retained entries, oldest first.
+func (b *recentBuffer) snapshot() []recentEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prune(time.Now())
+	entries := make([]recentEntry, len(b.entries))
+	copy(entries, b.entries)
+	return entries
+}
+
+// format returns e in the text format.
+func (e recentEntry) format() []byte {
+	ts := e.time
+	if t, ok := e.fields[timeKey].(time.Time); ok {
+		ts = t
+	}
+	return formatLine(ts, e.level, packageFormatter().caller(e.site), e.msg, e.fields)
+}
+
+// enabled reports whether the buffer is retaining entries.
+func (b *recentBuffer) enabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.window > 0 || b.limit > 0
+}
+
+func (b *recentBuffer) add(level log.Level, site callSite, msg string, fields log.Fields) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.window <= 0 && b.limit <= 0 {
+		return
+	}
+
+	now := time.Now()
+	b.prune(now)
+	max := maxRecent
+	if b.limit > 0 && b.limit < max {
+		max = b.limit
+	}
+	if len(b.entries) >= max {
+		b.entries = b.entries[1:]
+	}
+	b.entries = append(b.entries, recentEntry{now, level, site, msg, fields})
+}
+
+// prune drops entries older than the window. The caller must hold b.mu.
+func (b *recentBuffer) prune(now time.Time) {
+	if b.window <= 0 {
+		return
+	}
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(b.entries) && b.entries[i].time.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		b.entries = append(b.entries[:0], b.entries[i:]...)
+	}
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpRecent(t *testing.T) {
+	KeepRecent(time.Minute)
+	defer KeepRecent(0)
+
+	SetLevel("error")
+	defer SetLevel("debug")
+
+	Debug("below the active level")
+	Error("at the active level")
+
+	var buf bytes.Buffer
+	if err := DumpRecent(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"below the active level", "at the active level"} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Errorf("dump is missing %q:\n%s", msg, buf.String())
+		}
+	}
+}
+
+func TestKeepLast(t *testing.T) {
+	KeepLast(2)
+	defer KeepLast(0)
+	SetLevel("info")
+	defer SetLevel("debug")
+
+	for _, msg := range []string{"first", "second", "third"} {
+		Debug(msg)
+	}
+	var buf bytes.Buffer
+	DumpRecent(&buf)
+	if s := buf.String(); strings.Contains(s, "first") || !strings.Contains(s, "second") || !strings.Contains(s, "third") {
+		t.Errorf("dump = %q, want the last two entries", s)
+	}
+
+	KeepLast(1)
+	buf.Reset()
+	DumpRecent(&buf)
+	if n := strings.Count(buf.String(), "\n"); n != 1 {
+		t.Errorf("dump after lowering the limit has %d entries, want 1", n)
+	}
+}
+
+func TestRecentDumpOnFatal(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	KeepLast(10)
+	defer KeepLast(0)
+	SetLevel("info")
+	defer SetLevel("debug")
+	SetFatalPanic(true)
+	defer SetFatalPanic(false)
+
+	file := filepath.Join(t.TempDir(), "crash", "recent.log")
+	if err := SetRecentDump(true, file); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRecentDump(false, "")
+
+	Debug("decoder state reset")
+	func() {
+		defer func() { recover() }()
+		Fatal("ring corrupted")
+	}()
+	b, _ := os.ReadFile(file)
+	for _, want := range []string{"recent entries follow", "reason=fatal", "decoder state reset", "ring corrupted", "end of recent entries"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("dump is missing %q:\n%s", want, b)
+		}
+	}
+	if strings.Contains(out.String(), "decoder state reset") {
+		t.Errorf("debug entry written to the outputs:\n%s", out.String())
+	}
+
+	// Without a file, recovered panics are dumped to the outputs.
+	SetRecentDump(true, "")
+	func() {
+		defer Recover()
+		panic("index out of range")
+	}()
+	if s := out.String(); !strings.Contains(s, "reason=panic") || !strings.Contains(s, "decoder state reset") {
+		t.Errorf("outputs are missing the dump:\n%s", s)
+	}
+}
@@ -0,0 +1,102 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var recentDump struct {
+	sync.Mutex
+	on   bool
+	file string
+	sig  chan os.Signal
+}
+
+// SetRecentDump writes the entries retained by KeepLast and KeepRecent
+// when the process is about to fail: on Fatal, on Panic, on a panic
+// recovered by Recover or RecoverRepanic and on SIGQUIT, after which the
+// Go runtime dumps the goroutines and exits as usual. The entries are
+// appended to file, e.g. next to the crash file of SetCrashFile, or
+// written to the outputs of the package logger if file is empty, between
+// two WARNING entries "recent entries follow" and "end of recent entries"
+// naming the reason. false stops dumping.
+func SetRecentDump(on bool, file string) error {
+	if on && file != "" {
+		if err := createLogDir(file); err != nil {
+			return err
+		}
+	}
+
+	recentDump.Lock()
+	defer recentDump.Unlock()
+	recentDump.on, recentDump.file = on, file
+	if recentDump.sig != nil {
+		signal.Stop(recentDump.sig)
+		close(recentDump.sig)
+		recentDump.sig = nil
+	}
+	if !on {
+		return nil
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGQUIT)
+	recentDump.sig = ch
+	go func() {
+		for range ch {
+			dumpRecentOn("SIGQUIT")
+			// Let the runtime handle the signal again, dumping the
+			// goroutines.
+			signal.Reset(syscall.SIGQUIT)
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(syscall.SIGQUIT)
+			}
+		}
+	}()
+	return nil
+}
+
+// dumpRecentOn writes the retained entries if SetRecentDump asked for it,
+// reason naming the failure.
+func dumpRecentOn(reason string) {
+	recentDump.Lock()
+	on, file := recentDump.on, recentDump.file
+	recentDump.Unlock()
+	if !on {
+		return
+	}
+	entries := recent.snapshot()
+	if len(entries) == 0 {
+		return
+	}
+
+	var b bytes.Buffer
+	fields := log.Fields{"reason": reason, "entries": len(entries)}
+	b.Write(formatLine(time.Now(), log.WarnLevel, "", "recent entries follow", fields))
+	for _, e := range entries {
+		b.Write(e.format())
+	}
+	b.Write(formatLine(time.Now(), log.WarnLevel, "", "end of recent entries", fields))
+
+	if file == "" {
+		// A single write keeps the dump together.
+		writeOutput(nil, b.Bytes())
+		return
+	}
+	f, err := createLogFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	if err != nil {
+		reportError(fmt.Errorf("dump recent entries: %v", err))
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(b.Bytes()); err != nil {
+		reportError(fmt.Errorf("dump recent entries to %s: %v", file, err))
+	}
+	f.Sync()
+}
@@ -0,0 +1,218 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Recorder writes every logging call, whatever its level, to a file as a
+// JSON line holding the time, level, caller, message and fields, so that
+// Replay can later feed the calls through another configuration, e.g. to
+// check a formatter or output change against production traffic. Secrets
+// are recorded masked.
+type Recorder struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	err    error
+	closed bool
+}
+
+var recording atomic.Value // *Recorder
+
+// maxRecordedCall is the size of the largest call Replay accepts.
+const maxRecordedCall = 1 << 20
+
+// StartRecording records every logging call to the file path, closing
+// the recording started before, if any.
+func StartRecording(path string) (*Recorder, error) {
+	if err := createLogDir(path); err != nil {
+		return nil, err
+	}
+	f, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{f: f, w: bufio.NewWriter(f)}
+	addFlusher(r)
+	if prev, _ := recording.Swap(r).(*Recorder); prev != nil {
+		prev.Close()
+	}
+	return r, nil
+}
+
+// Name returns the path of the recording.
+func (r *Recorder) Name() string {
+	return r.f.Name()
+}
+
+// recordingActive reports whether a recording is active.
+func recordingActive() bool {
+	r, _ := recording.Load().(*Recorder)
+	return r != nil
+}
+
+// record writes a call to the active recording, if any.
+func record(level log.Level, site callSite, msg string, fields log.Fields) {
+	if r, _ := recording.Load().(*Recorder); r != nil {
+		r.add(level, site, msg, fields)
+	}
+}
+
+// recordedKeys are the keys of a recorded call that are not fields. Fields
+// of the same name are recorded with the prefix "fields.", like
+// JSONFormatter does.
+var recordedKeys = map[string]bool{
+	"time": true, "hostname": true, "level": true, "tag": true, "file": true, "line": true, "msg": true, "pid": true,
+}
+
+func (r *Recorder) add(level log.Level, site callSite, msg string, fields log.Fields) {
+	call := make(map[string]interface{}, len(fields)+5)
+	for k, v := range fields {
+		if isReserved(k) {
+			continue
+		}
+		if recordedKeys[k] {
+			k = "fields." + k
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		call[k] = v
+	}
+	ts := time.Now()
+	if t, ok := fields[timeKey].(time.Time); ok {
+		ts = t
+	}
+	call["time"] = ts.Format(time.RFC3339Nano)
+	if h, ok := fields[hostKey].(string); ok {
+		call["hostname"] = h
+	}
+	call["level"] = levelName(level)
+	if t, ok := fields[TagKey].(string); ok && t != "" {
+		call["tag"] = t
+	}
+	if site.file != "" {
+		call["file"] = site.file
+		call["line"] = site.line
+	}
+	call["msg"] = msg
+
+	b, err := json.Marshal(call)
+	if err != nil {
+		// A value without a JSON encoding is recorded as its string.
+		for k, v := range call {
+			if _, err := json.Marshal(v); err != nil {
+				call[k] = fmt.Sprint(v)
+			}
+		}
+		b, _ = json.Marshal(call)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.err != nil {
+		return
+	}
+	if _, err := r.w.Write(append(b, '\n')); err != nil {
+		// Reported once, the recording is incomplete from here on.
+		r.err = err
+		reportError(fmt.Errorf("record to %s: %v", r.f.Name(), err))
+	}
+}
+
+// Flush writes the buffered calls to the file.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	return r.w.Flush()
+}
+
+// Close stops the recording and closes the file.
+func (r *Recorder) Close() error {
+	recording.CompareAndSwap(r, (*Recorder)(nil))
+	removeFlusher(r)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.w.Flush()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Replay feeds the calls recorded by a Recorder through the pipeline of
+// the package logger as if they were being logged now, except that they
+// keep their recorded time and caller. Like Ingest, FATAL and PANIC calls
+// are written without terminating the process. Numbers in fields are
+// replayed as float64. Replay returns the number of calls replayed and
+// stops at the first line that is not a recorded call.
+func Replay(r io.Reader) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), maxRecordedCall)
+	n := 0
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return n, fmt.Errorf("call %d: %v", n+1, err)
+		}
+		if err := replay(e); err != nil {
+			return n, fmt.Errorf("call %d: %v", n+1, err)
+		}
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// replay logs a recorded call.
+func replay(e Entry) error {
+	level, err := parseLevel(e.Level)
+	if err != nil {
+		return err
+	}
+	if e.Time.IsZero() {
+		return errors.New("call without time")
+	}
+
+	l := WithFields(e.Fields)
+	if e.Host != "" {
+		l = l.WithHost(e.Host)
+	}
+	if e.Tag != "" {
+		l = l.WithTag(e.Tag)
+	}
+	fields := l.data()
+	// Unlike WithTime, the recorded time does not mark the entry relayed.
+	fields[timeKey] = e.Time
+
+	site := callSite{file: e.File, line: e.Line}
+	if level <= log.FatalLevel {
+		writeEntry(level, site, e.Message, fields)
+		return nil
+	}
+	emit(level, site, e.Message, fields)
+	return nil
+}
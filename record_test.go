@@ -0,0 +1,120 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetLevel("info")
+	defer SetLevel("debug")
+
+	path := filepath.Join(t.TempDir(), "calls.jsonl")
+	r, err := StartRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	With("flow", "10.0.0.1:53", Secret("token", "hunter2")).Info("query")
+	WithTag("dns").Debug("cache miss")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	Info("after the recording")
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 2 {
+		t.Fatalf("recorded %d calls, want 2 including the disabled DEBUG one:\n%s", n, b)
+	}
+	if strings.Contains(string(b), "hunter2") {
+		t.Errorf("recording %q holds the secret", b)
+	}
+
+	// Replayed at debug, the DEBUG call now makes it to the output.
+	SetLevel("debug")
+	buf.Reset()
+	n, err := Replay(bytes.NewReader(b))
+	if err != nil || n != 2 {
+		t.Fatalf("Replay = %d, %v; want 2, nil", n, err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"record_test.go:", "query flow=10.0.0.1:53 token=****", " dns[", "cache miss",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("replayed output %q does not contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, RelayedKey) {
+		t.Errorf("replayed output %q is marked relayed", out)
+	}
+
+	if _, err := Replay(strings.NewReader("not json\n")); err == nil {
+		t.Error("Replay of a malformed line = nil, want error")
+	}
+}
+
+func TestReplayFatalPipeline(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer redactRules.v.Store((*redaction)(nil))
+	RedactFields("password")
+
+	call := `{"time":"2017-03-01T12:00:00Z","level":"fatal","msg":"login failed","password":"hunter2"}` + "\n"
+	if _, err := Replay(strings.NewReader(call)); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(out, "hunter2") || !strings.Contains(out, "login failed") {
+		t.Errorf("replayed FATAL entry not redacted: %q", out)
+	}
+}
+
+func TestRecordCollidingFields(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	dir := t.TempDir()
+	first, err := StartRecording(filepath.Join(dir, "first.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := StartRecording(filepath.Join(dir, "calls.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.closed {
+		t.Errorf("the replaced recording is still open")
+	}
+	With("msg", "from the user", "level", 3).Info("collision")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(r.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"msg":"collision"`, `"fields.msg":"from the user"`, `"level":"info"`, `"fields.level":3`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("recording %s does not contain %s", b, want)
+		}
+	}
+
+	buf.Reset()
+	if _, err := Replay(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "collision") || !strings.Contains(out, `fields.msg="from the user"`) {
+		t.Errorf("replayed output %q lost a colliding field", out)
+	}
+}
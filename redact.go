@@ -0,0 +1,121 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Patterns for RedactPattern matching common personal data.
+var (
+	// CardNumberPattern matches payment card numbers of 13 to 19 digits,
+	// optionally grouped by spaces or dashes.
+	CardNumberPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	// EmailPattern matches e-mail addresses.
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// redaction is the set of rules applied to every entry.
+type redaction struct {
+	names    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+var redactRules = struct {
+	sync.Mutex
+	v atomic.Value // *redaction
+}{}
+
+// RedactFields makes the fields named names, compared case-insensitively,
+// secret in every entry as if logged with Secret, e.g. for password, token
+// and authorization, before the entry reaches any output, hook, recording
+// or buffer.
+func RedactFields(names ...string) {
+	updateRedaction(func(r *redaction) {
+		for _, n := range names {
+			r.names[strings.ToLower(n)] = true
+		}
+	})
+}
+
+// RedactPattern replaces the matches of re with SecretMask in the message
+// and the fields of every entry, e.g. with CardNumberPattern or
+// EmailPattern, before the entry reaches any output. Fields other than
+// numbers, booleans and times are matched as rendered, like fmt.Sprint
+// does; a field with a match is replaced by its redacted rendering, so a
+// struct or map becomes a string. Every pattern is run on every entry:
+// keep them few and simple.
+func RedactPattern(re *regexp.Regexp) {
+	updateRedaction(func(r *redaction) {
+		r.patterns = append(r.patterns, re)
+	})
+}
+
+// updateRedaction replaces the rules with a copy changed by fn.
+func updateRedaction(fn func(*redaction)) {
+	redactRules.Lock()
+	defer redactRules.Unlock()
+
+	r := &redaction{names: make(map[string]bool)}
+	if old, _ := redactRules.v.Load().(*redaction); old != nil {
+		for n := range old.names {
+			r.names[n] = true
+		}
+		r.patterns = append(r.patterns, old.patterns...)
+	}
+	fn(r)
+	redactRules.v.Store(r)
+}
+
+// redact applies the redaction rules to the message and fields of an
+// entry.
+func redact(msg string, fields log.Fields) string {
+	r, _ := redactRules.v.Load().(*redaction)
+	if r == nil {
+		return msg
+	}
+	for _, re := range r.patterns {
+		msg = re.ReplaceAllLiteralString(msg, SecretMask)
+	}
+	for k, v := range fields {
+		if IsSecret(v) || isReserved(k) {
+			continue
+		}
+		if r.names[strings.ToLower(k)] {
+			fields[k] = secretValue{v}
+			continue
+		}
+		if len(r.patterns) == 0 {
+			continue
+		}
+		var s string
+		switch v := v.(type) {
+		case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+			uintptr, float32, float64, complex64, complex128, time.Time, time.Duration:
+			continue
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case error:
+			s = v.Error()
+		case fmt.Stringer:
+			s = v.String()
+		default:
+			s = fmt.Sprint(v)
+		}
+		masked := s
+		for _, re := range r.patterns {
+			masked = re.ReplaceAllLiteralString(masked, SecretMask)
+		}
+		if masked != s {
+			fields[k] = masked
+		}
+	}
+	return msg
+}
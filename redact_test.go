@@ -0,0 +1,79 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer redactRules.v.Store((*redaction)(nil))
+
+	RedactFields("Password", "authorization")
+	RedactPattern(CardNumberPattern)
+	RedactPattern(EmailPattern)
+
+	WithFields(Fields{
+		"password":      "hunter2",
+		"Authorization": "Bearer abc",
+		"card":          "paid with 4111 1111 1111 1111",
+		"err":           errors.New("unknown user bob@example.com"),
+		"port":          443,
+	}).Info("login of alice@example.org")
+
+	got := buf.String()
+	for _, secret := range []string{"hunter2", "Bearer", "4111", "bob@", "alice@"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("output %q contains %q", got, secret)
+		}
+	}
+	for _, want := range []string{
+		"login of ****",
+		"Authorization=****",
+		"password=****",
+		`card="paid with ****"`,
+		`err="unknown user ****"`,
+		"port=443",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+}
+
+// contact is a field value of a type unknown to the redaction.
+type contact struct {
+	Name, Email string
+}
+
+func TestRedactPatternRenderedValues(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer redactRules.v.Store((*redaction)(nil))
+
+	RedactPattern(EmailPattern)
+	With(
+		"owner", contact{"alice", "alice@example.com"},
+		"raw", []byte("from bob@example.com"),
+		"peers", map[string]string{"carol": "carol@example.com"},
+		"addr", net.ParseIP("10.0.0.1"),
+		"port", 25,
+	).Info("mail relayed")
+
+	out := buf.String()
+	if strings.Contains(out, "@example.com") {
+		t.Errorf("output %q leaks an address", out)
+	}
+	for _, want := range []string{"owner=\"{alice ****}\"", "raw=\"from ****\"", "peers=map[carol:****]", "addr=10.0.0.1", "port=25"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q does not contain %q", out, want)
+		}
+	}
+}
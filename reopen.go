@@ -0,0 +1,108 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// reopenCheckInterval is how often the log files are compared with their
+// names, to notice that they were removed or renamed.
+var reopenCheckInterval = time.Second
+
+// fileMoved reports whether the path name no longer leads to f, e.g. after
+// logrotate renamed or removed it. It checks at most once per
+// reopenCheckInterval, checked being the time of the last check.
+func fileMoved(f *os.File, name string, checked *time.Time) bool {
+	now := time.Now()
+	if now.Sub(*checked) < reopenCheckInterval {
+		return false
+	}
+	*checked = now
+	fi, err := f.Stat()
+	if err != nil {
+		return true
+	}
+	named, err := os.Stat(name)
+	return err != nil || !os.SameFile(fi, named)
+}
+
+// reopener is implemented by the log files Reopen reopens.
+type reopener interface {
+	Reopen() error
+}
+
+// Reopen closes and reopens the log files of the package logger, i.e. the
+// file of Init and the one of SetErrorFile, for external log rotation:
+// after logrotate renames the file, call Reopen, e.g. on SIGHUP, to
+// continue in a new file under the old name.
+//
+//	hup := make(chan os.Signal, 1)
+//	signal.Notify(hup, syscall.SIGHUP)
+//	go func() {
+//		for range hup {
+//			log.Reopen()
+//		}
+//	}()
+//
+// Files that were removed or renamed are also reopened on their own within
+// a second, so that logrotate's copytruncate is not needed. Reopen returns
+// the first error.
+func Reopen() error {
+	current.Lock()
+	w := current.output
+	current.Unlock()
+
+	var errs []error
+	if w != nil {
+		errs = reopenWriter(w)
+	}
+	errorFile.mu.Lock()
+	f := errorFile.f
+	errorFile.mu.Unlock()
+	if f != nil {
+		if err := f.Reopen(); err != nil {
+			errs = append(errs, fmt.Errorf("reopen %s: %v", f.Name(), err))
+		}
+	}
+	return firstError(errs)
+}
+
+// reopenWriter reopens w, the outputs of a multiWriter and the output of
+// an AsyncWriter.
+func reopenWriter(w io.Writer) []error {
+	switch w := w.(type) {
+	case *multiWriter:
+		var errs []error
+		for _, o := range w.outputs {
+			errs = append(errs, reopenWriter(o)...)
+		}
+		return errs
+	case *AsyncWriter:
+		return reopenWriter(w.w)
+	case reopener:
+		if err := w.Reopen(); err != nil {
+			return []error{fmt.Errorf("reopen %s: %v", outputName(w), err)}
+		}
+	}
+	return nil
+}
+
+// fallback writes p to stderr in place of the log file w that failed to
+// write it, so that the entries are not lost while the disk is full, and
+// reports whether it did. The writes are counted in Stats. Outputs other
+// than log files have no fallback.
+func fallback(w io.Writer, p []byte) bool {
+	switch w.(type) {
+	case *lazyFile, *rotatingFile:
+	default:
+		return false
+	}
+	if _, err := os.Stderr.Write(p); err != nil {
+		return false
+	}
+	atomic.AddUint64(&stats.Fallback, 1)
+	return true
+}
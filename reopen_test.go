@@ -0,0 +1,99 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReopen(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "probe.log")
+	w, err := openInitFile(name, RotationConfig{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFileOutput(w)
+	defer SetOutputs(os.Stderr)
+
+	Info("before logrotate")
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	Info("after logrotate")
+
+	if b, _ := os.ReadFile(name + ".1"); !strings.Contains(string(b), "before logrotate") || strings.Contains(string(b), "after") {
+		t.Errorf("rotated file = %q", b)
+	}
+	if b, _ := os.ReadFile(name); !strings.Contains(string(b), "after logrotate") {
+		t.Errorf("reopened file = %q", b)
+	}
+}
+
+func TestReopenRemovedFile(t *testing.T) {
+	defer func(d time.Duration) { reopenCheckInterval = d }(reopenCheckInterval)
+	reopenCheckInterval = 0
+	dir := t.TempDir()
+
+	l := &lazyFile{name: filepath.Join(dir, "probe.log")}
+	r := newRotatingFile(filepath.Join(dir, "rotated.log"), RotationConfig{MaxSize: 1 << 20})
+	defer l.Close()
+	defer r.Close()
+	for _, w := range []interface {
+		Write([]byte) (int, error)
+		Name() string
+	}{l, r} {
+		w.Write([]byte("first\n"))
+		if err := os.Remove(w.Name()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("second\n")); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(w.Name()); string(b) != "second\n" {
+			t.Errorf("%s recreated with %q", w.Name(), b)
+		}
+	}
+	if s := r.state(); s.Size != int64(len("second\n")) {
+		t.Errorf("size of the recreated file = %d", s.Size)
+	}
+}
+
+func TestFallbackToStderr(t *testing.T) {
+	dir := t.TempDir()
+	// The directory of the file is a file, it cannot be reopened.
+	os.WriteFile(filepath.Join(dir, "var"), nil, 0644)
+	f, _ := os.CreateTemp(dir, "closed")
+	f.Close()
+	l := &lazyFile{name: filepath.Join(dir, "var", "probe.log"), f: f}
+
+	stderr := os.Stderr
+	defer func() { os.Stderr = stderr }()
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = pw
+	before := Stats()
+
+	m := newMultiWriter(l)
+	_, err = m.Write([]byte("disk full\n"))
+	os.Stderr = stderr
+	pw.Close()
+	if err != nil {
+		t.Errorf("Write = %v, want the entry written to stderr", err)
+	}
+	b := make([]byte, 64)
+	n, _ := pr.Read(b)
+	if string(b[:n]) != "disk full\n" {
+		t.Errorf("stderr = %q", b[:n])
+	}
+	after := Stats()
+	if after.Fallback-before.Fallback != 1 || after.WriteErrors-before.WriteErrors != 1 {
+		t.Errorf("Fallback and WriteErrors increased by %d and %d, want 1", after.Fallback-before.Fallback, after.WriteErrors-before.WriteErrors)
+	}
+}
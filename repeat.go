@@ -0,0 +1,122 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// RepeatedKey is the field counting the repetitions collapsed by
+// SetRepeatCompaction.
+const RepeatedKey = "repeated"
+
+// repeater collapses consecutive identical entries.
+type repeater struct {
+	// active is non-zero while compaction is enabled, sparing entries the
+	// lock otherwise.
+	active int32
+	mu     sync.Mutex
+	window time.Duration
+	// last identifies the previous entry and level its level; count
+	// repetitions of it were dropped since it or the previous report was
+	// written.
+	last  string
+	level log.Level
+	count uint64
+	timer *time.Timer
+	// gen tells the timer of a repetition run apart from those of the runs
+	// before.
+	gen uint64
+}
+
+var repeats = &repeater{}
+
+// SetRepeatCompaction collapses consecutive identical entries, same level,
+// message and fields, like syslogd: the first one is written and the next
+// ones are dropped until a different entry is logged, then a single entry
+// "last message repeated N times" at their level, with the repeated field,
+// reports them. A run of repetitions longer than window is reported every
+// window. Dropped entries are counted in Stats. Entries of Loggers created
+// by New, FATAL and PANIC entries are never dropped. A non-positive window
+// disables compaction, which is the default, reporting the repetitions
+// dropped so far.
+func SetRepeatCompaction(window time.Duration) {
+	r := repeats
+	r.mu.Lock()
+	level, n := r.reset("", 0)
+	r.window = window
+	if window > 0 {
+		atomic.StoreInt32(&r.active, 1)
+	} else {
+		atomic.StoreInt32(&r.active, 0)
+	}
+	r.mu.Unlock()
+	reportRepeats(level, n)
+}
+
+// allow reports whether an entry is to be written, dropping the
+// repetitions of the previous one.
+func (r *repeater) allow(level log.Level, msg string, fields log.Fields) bool {
+	if atomic.LoadInt32(&r.active) == 0 || instanceOf(fields) != nil {
+		return true
+	}
+	key := levelName(level) + " " + msg + formatFields(fields)
+
+	r.mu.Lock()
+	if key == r.last && level > log.FatalLevel {
+		r.count++
+		atomic.AddUint64(&stats.Repeated, 1)
+		if r.count == 1 {
+			gen := r.gen
+			r.timer = time.AfterFunc(r.window, func() { r.flush(gen) })
+		}
+		r.mu.Unlock()
+		return false
+	}
+	prev, n := r.reset(key, level)
+	r.mu.Unlock()
+	reportRepeats(prev, n)
+	return true
+}
+
+// reset starts over with the entry key at level, with r locked, and
+// returns the repetitions of the previous entry to report.
+func (r *repeater) reset(key string, level log.Level) (log.Level, uint64) {
+	prev, n := r.level, r.count
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	r.last, r.level, r.count = key, level, 0
+	r.gen++
+	return prev, n
+}
+
+// flush reports the repetitions of run gen once its window elapsed, the
+// following ones making a new run.
+func (r *repeater) flush(gen uint64) {
+	r.mu.Lock()
+	if gen != r.gen {
+		r.mu.Unlock()
+		return
+	}
+	level, n := r.level, r.count
+	r.count, r.timer = 0, nil
+	r.gen++
+	r.mu.Unlock()
+	reportRepeats(level, n)
+}
+
+// reportRepeats writes the report of n repetitions of an entry at level.
+func reportRepeats(level log.Level, n uint64) {
+	if n == 0 {
+		return
+	}
+	// Like the rate limit report, the report bypasses the stages that
+	// could drop it.
+	countWritten(level)
+	dispatch(level, callSite{}, fmt.Sprintf("last message repeated %d times", n), log.Fields{RepeatedKey: n})
+}
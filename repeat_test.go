@@ -0,0 +1,65 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepeatCompaction(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetRepeatCompaction(time.Hour)
+	defer SetRepeatCompaction(0)
+	before := Stats().Repeated
+
+	for i := 0; i < 5; i++ {
+		Warning("retransmit to 192.0.2.7")
+	}
+	WithFields(Fields{"seq": 1}).Warning("retransmit to 192.0.2.7")
+	Info("link up")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("wrote %q, want 4 lines", out.String())
+	}
+	if !strings.HasSuffix(lines[1], "last message repeated 4 times repeated=4") || !strings.Contains(lines[1], "WARNING") {
+		t.Errorf("report = %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "seq=1") || !strings.HasSuffix(lines[3], "link up") {
+		t.Errorf("entries after the report = %q", lines[2:])
+	}
+	if n := Stats().Repeated - before; n != 4 {
+		t.Errorf("Repeated increased by %d, want 4", n)
+	}
+}
+
+func TestRepeatCompactionWindow(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	SetRepeatCompaction(20 * time.Millisecond)
+	defer SetRepeatCompaction(0)
+
+	Error("rx ring overflow")
+	Error("rx ring overflow")
+	Error("rx ring overflow")
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(out.String(), "repeated 2 times") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no report after the window: %q", out.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	Error("rx ring overflow")
+	SetRepeatCompaction(0)
+	if !strings.Contains(out.String(), "last message repeated 1 times") {
+		t.Errorf("repetitions not reported when compaction is disabled: %q", out.String())
+	}
+	Error("rx ring overflow")
+	if n := strings.Count(out.String(), "ERROR"); n != 4 {
+		t.Errorf("wrote %d ERROR lines, want 4: %q", n, out.String())
+	}
+}
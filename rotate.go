@@ -0,0 +1,781 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RotationConfig configures the rotation of the file opened by Init. A
+// rotated file is renamed to the name of the log file followed by the time
+// of the rotation, e.g. app.log.20170301-120000.000, and a new file is
+// started.
+//
+// The name given to Init with a rotation may contain placeholders, to keep
+// instances sharing a log directory, e.g. on NFS or a Kubernetes hostPath,
+// from writing to the same file:
+//
+//	{hostname}  the host name
+//	{pid}       the process ID
+//	{shard}     Shard
+//	{date}      the current day as 2006-01-02; the file switches to a
+//	            new name at midnight and the files of past days count
+//	            as rotated files
+//
+// e.g. Init("/var/log/probe/probe-{hostname}-{shard}.log", "info"). One
+// file per day, as operations tooling often expects, is
+//
+//	log.SetRotation(log.RotationConfig{Symlink: "/var/log/probe/probe.log", MaxBackups: 30})
+//	log.Init("/var/log/probe/probe-{date}.log", "info")
+type RotationConfig struct {
+	// MaxSize rotates the file before a write would make it larger than
+	// MaxSize bytes. Zero disables rotation by size.
+	MaxSize int64
+	// MaxAge rotates the file once it has been written to for MaxAge.
+	// Zero disables rotation by age.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept, the oldest ones
+	// being removed. Zero keeps all of them.
+	MaxBackups int
+	// Compress gzips rotated files, adding .gz to their names. See also
+	// SetArchiveKey to encrypt them.
+	Compress bool
+	// Shard replaces {shard} in the name of the log file, e.g. the
+	// region or the ordinal of a replica.
+	Shard string
+	// Schedule rotates the file at the times of a cron expression, e.g.
+	// "0 */6 * * *" or @hourly, @daily, @weekly and @monthly, in the local
+	// time zone. Empty disables rotation by schedule.
+	Schedule string
+	// MaxBackupAge removes the rotated files last written more than
+	// MaxBackupAge ago. Zero keeps them regardless of their age.
+	MaxBackupAge time.Duration
+	// Symlink is the path of a symbolic link kept pointing to the file
+	// being written, e.g. app.log for app-{date}.log, so that tail -F and
+	// the tools reading "the" log file need not know about the dates. It
+	// accepts the placeholders of the name other than {date}.
+	Symlink string
+}
+
+// validate reports why cfg is invalid.
+func (cfg RotationConfig) validate() error {
+	if cfg.Schedule != "" {
+		if _, err := parseSchedule(cfg.Schedule); err != nil {
+			return fmt.Errorf("rotation: %v", err)
+		}
+	}
+	return nil
+}
+
+// rotationLayout is the time format in the names of rotated files; it
+// sorts chronologically.
+const rotationLayout = "20060102-150405.000"
+
+// dateLayout is the format of {date} in the name of the log file.
+const dateLayout = "2006-01-02"
+
+// datePattern matches a dateLayout date in filepath.Match patterns.
+const datePattern = "[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]"
+
+var rotation struct {
+	sync.Mutex
+	cfg *RotationConfig
+	// archiveDir is where Archive moves the files it archives.
+	archiveDir string
+}
+
+// SetRotation makes Init rotate the log file as configured by cfg. It must
+// be called before Init. Rotation errors are reported to the self-log, as
+// is an invalid Schedule, which is then ignored.
+func SetRotation(cfg RotationConfig) {
+	if err := cfg.validate(); err != nil {
+		reportError(err)
+		cfg.Schedule = ""
+	}
+	rotation.Lock()
+	rotation.cfg = &cfg
+	rotation.Unlock()
+}
+
+// rotationConfig returns the configuration set with SetRotation, if any.
+func rotationConfig() (RotationConfig, bool) {
+	rotation.Lock()
+	defer rotation.Unlock()
+	if rotation.cfg == nil {
+		return RotationConfig{}, false
+	}
+	return *rotation.cfg, true
+}
+
+// rotatingFile is a log file rotated by size and age. It is opened on the
+// first write unless open is called before.
+type rotatingFile struct {
+	mu sync.Mutex
+	// template is the name given to Init, base the same with the
+	// placeholders other than {date} replaced and name the file written.
+	template string
+	base     string
+	name     string
+	cfg      RotationConfig
+	f        *os.File
+	size     int64
+	opened   time.Time
+	// sched is the parsed Schedule and next the time of the next
+	// rotation by schedule.
+	sched *schedule
+	next  time.Time
+	// symlink is Symlink with the placeholders replaced, and linked the
+	// file it was last pointed to.
+	symlink string
+	linked  string
+	// checked is when the file was last compared with name.
+	checked time.Time
+	// cleanup tracks compression and removal of rotated files, which run
+	// in the background one rotation at a time.
+	cleanup   sync.WaitGroup
+	cleanupMu sync.Mutex
+	now       func() time.Time
+}
+
+func newRotatingFile(name string, cfg RotationConfig) *rotatingFile {
+	r := &rotatingFile{template: name, cfg: cfg, now: time.Now}
+	r.base, r.symlink = name, cfg.Symlink
+	if strings.Contains(name+cfg.Symlink, "{") {
+		hostname, _ := os.Hostname()
+		placeholders := strings.NewReplacer(
+			"{hostname}", hostname,
+			"{pid}", strconv.Itoa(os.Getpid()),
+			"{shard}", cfg.Shard,
+		)
+		r.base, r.symlink = placeholders.Replace(name), placeholders.Replace(cfg.Symlink)
+	}
+	if cfg.Schedule != "" {
+		var err error
+		if r.sched, err = parseSchedule(cfg.Schedule); err != nil {
+			reportError(fmt.Errorf("rotation: %v", err))
+		}
+	}
+	r.name = r.nameAt(time.Now())
+	return r
+}
+
+// Name returns the path of the file, placeholders included.
+func (r *rotatingFile) Name() string {
+	return r.template
+}
+
+// nameAt returns the name of the file written at now.
+func (r *rotatingFile) nameAt(now time.Time) string {
+	if !strings.Contains(r.base, "{date}") {
+		return r.base
+	}
+	return strings.Replace(r.base, "{date}", now.Format(dateLayout), -1)
+}
+
+// open opens the file, continuing an existing one.
+func (r *rotatingFile) open() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.openLocked()
+}
+
+func (r *rotatingFile) openLocked() error {
+	r.name = r.nameAt(r.now())
+	if err := createLogDir(r.name); err != nil {
+		return err
+	}
+	f, err := openLogFile(r.name)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size, r.opened = f, fi.Size(), r.now()
+	if r.sched != nil {
+		r.next = r.sched.next(r.opened)
+	}
+	if r.symlink != "" && r.linked != r.name {
+		if err := r.link(); err != nil {
+			reportError(fmt.Errorf("link %s to %s: %v", r.symlink, r.name, err))
+		}
+	}
+	return nil
+}
+
+// link points the symlink to the file written, replacing it atomically so
+// that readers never miss it. The target is relative if both are in the
+// same directory, for the link to survive moving the directory.
+func (r *rotatingFile) link() error {
+	target := r.name
+	if filepath.Dir(target) == filepath.Dir(r.symlink) {
+		target = filepath.Base(target)
+	}
+	tmp := r.symlink + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.symlink); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	r.linked = r.name
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p would exceed
+// MaxSize or the file is older than MaxAge. An entry larger than MaxSize
+// is written to a file of its own. The file is reopened if it was removed
+// or renamed behind the package's back, and once if the write fails.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f != nil && fileMoved(r.f, r.name, &r.checked) {
+		r.f.Close()
+		r.f = nil
+	}
+	if r.f == nil {
+		if err := r.openLocked(); err != nil {
+			return 0, err
+		}
+	}
+	if r.name != r.nameAt(r.now()) {
+		// The day in the name changed, the file of the previous day is
+		// archived under its name.
+		previous := r.name
+		if err := r.f.Close(); err != nil {
+			reportError(fmt.Errorf("close %s: %v", r.name, err))
+		}
+		r.f = nil
+		if err := r.openLocked(); err != nil {
+			return 0, err
+		}
+		atomic.AddUint64(&stats.Rotations, 1)
+		r.startCleanup(previous)
+	} else if r.size == 0 && !r.next.IsZero() && !r.now().Before(r.next) {
+		// Nothing to rotate, the file is empty.
+		r.next = r.sched.next(r.now())
+	} else if r.size > 0 && r.due(int64(len(p))) {
+		if _, err := r.rotate(); err != nil {
+			// Keep writing to the current file rather than lose entries.
+			reportError(fmt.Errorf("rotate %s: %v", r.name, err))
+		}
+	}
+	n, err := r.f.Write(p)
+	if err != nil {
+		// Retry once in a new file.
+		r.f.Close()
+		r.f = nil
+		if r.openLocked() == nil {
+			var m int
+			m, err = r.f.Write(p[n:])
+			n += m
+		}
+	}
+	if r.f != nil {
+		r.size += int64(n)
+	}
+	return n, err
+}
+
+// Reopen closes the file and opens it again, continuing the file under its
+// name, unless it was not opened yet.
+func (r *rotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	r.f.Close()
+	r.f = nil
+	return r.openLocked()
+}
+
+// due reports whether the file is to be rotated before writing n bytes.
+func (r *rotatingFile) due(n int64) bool {
+	if r.cfg.MaxSize > 0 && r.size+n > r.cfg.MaxSize {
+		return true
+	}
+	if !r.next.IsZero() && !r.now().Before(r.next) {
+		return true
+	}
+	return r.cfg.MaxAge > 0 && r.now().Sub(r.opened) >= r.cfg.MaxAge
+}
+
+// rotate renames the current file and starts a new one. It returns the
+// name of the rotated file.
+func (r *rotatingFile) rotate() (string, error) {
+	backup := r.backupName()
+	if err := r.f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(r.name, backup); err != nil {
+		// The closed file is reopened for appending.
+		if err := r.openLocked(); err != nil {
+			return "", err
+		}
+		return "", err
+	}
+	if err := r.openLocked(); err != nil {
+		return backup, err
+	}
+
+	atomic.AddUint64(&stats.Rotations, 1)
+	r.startCleanup(backup)
+	return backup, nil
+}
+
+// backupName returns the name the current file is rotated to: the time of
+// the rotation, followed by a counter if a backup of the same millisecond
+// exists already, compressed or encrypted or not, so that it is never
+// replaced.
+func (r *rotatingFile) backupName() string {
+	stamp := r.name + "." + r.now().Format(rotationLayout)
+	backup := stamp
+	for i := 1; backupExists(backup); i++ {
+		backup = stamp + "-" + strconv.Itoa(i)
+	}
+	return backup
+}
+
+// backupExists reports whether the backup name exists in any of the forms
+// the cleanup leaves.
+func backupExists(name string) bool {
+	for _, suffix := range []string{"", ".gz", ".enc", ".gz.enc"} {
+		if _, err := os.Lstat(name + suffix); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// startCleanup compresses and encrypts the rotated file backup, if any,
+// and prunes the backups in the background.
+func (r *rotatingFile) startCleanup(backup string) {
+	r.cleanup.Add(1)
+	go func() {
+		defer r.cleanup.Done()
+		r.cleanupMu.Lock()
+		defer r.cleanupMu.Unlock()
+		if r.cfg.Compress && backup != "" {
+			if err := compressFile(backup); err != nil {
+				reportError(fmt.Errorf("compress %s: %v", backup, err))
+			} else {
+				backup += ".gz"
+			}
+		}
+		if id, aead := currentArchiveKey(); aead != nil && backup != "" {
+			if err := encryptFile(backup, id, aead); err != nil {
+				reportError(fmt.Errorf("encrypt %s: %v", backup, err))
+			}
+		}
+		if err := r.prune(); err != nil {
+			reportError(fmt.Errorf("remove old backups of %s: %v", r.template, err))
+		}
+	}()
+}
+
+// prune removes the oldest rotated files beyond MaxBackups and those older
+// than MaxBackupAge.
+func (r *rotatingFile) prune() error {
+	if r.cfg.MaxBackups <= 0 && r.cfg.MaxBackupAge <= 0 {
+		return nil
+	}
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+	var remove []string
+	if r.cfg.MaxBackups > 0 && len(backups) > r.cfg.MaxBackups {
+		remove, backups = backups[:len(backups)-r.cfg.MaxBackups], backups[len(backups)-r.cfg.MaxBackups:]
+	}
+	if r.cfg.MaxBackupAge > 0 {
+		cutoff := r.now().Add(-r.cfg.MaxBackupAge)
+		for _, name := range backups {
+			if fi, err := os.Stat(name); err == nil && fi.ModTime().Before(cutoff) {
+				remove = append(remove, name)
+			}
+		}
+	}
+	for _, name := range remove {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// backups returns the rotated files of r, oldest first, and with {date}
+// in the name the files of past days. A file being compressed or encrypted
+// is counted once.
+func (r *rotatingFile) backups() ([]string, error) {
+	r.mu.Lock()
+	current := r.name
+	r.mu.Unlock()
+	pattern := strings.Replace(r.base, "{date}", datePattern, -1)
+	dated := pattern != r.base
+
+	matches, err := filepath.Glob(pattern + "*")
+	if err != nil {
+		return nil, err
+	}
+	type backup struct {
+		name, file, stamp string
+		// seq is the counter of backups of the same millisecond.
+		seq int
+	}
+	seen := make(map[string]bool)
+	var found []backup
+	for _, m := range matches {
+		b := backup{name: m, file: strings.TrimSuffix(strings.TrimSuffix(m, ".enc"), ".gz")}
+		if i := strings.LastIndexByte(b.file, '-'); i > len(rotationLayout) {
+			if seq, err := strconv.Atoi(b.file[i+1:]); err == nil && seq > 0 {
+				if _, err := time.Parse(rotationLayout, b.file[i-len(rotationLayout):i]); err == nil {
+					b.file, b.seq = b.file[:i], seq
+				}
+			}
+		}
+		if n := len(b.file) - len(rotationLayout); n > 0 && b.file[n-1] == '.' {
+			if _, err := time.Parse(rotationLayout, b.file[n:]); err == nil {
+				b.file, b.stamp = b.file[:n-1], b.file[n:]
+			}
+		}
+		if ok, _ := filepath.Match(pattern, b.file); !ok || m == r.symlink || m == r.symlink+".tmp" {
+			continue
+		}
+		if b.stamp == "" && (!dated || b.file == current) {
+			continue
+		}
+		id := b.file + "." + b.stamp + "-" + strconv.Itoa(b.seq)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		found = append(found, b)
+	}
+	// The file of a day is continued after its rotations.
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].file != found[j].file {
+			return found[i].file < found[j].file
+		}
+		if found[i].stamp == "" || found[j].stamp == "" {
+			return found[i].stamp != ""
+		}
+		if found[i].stamp != found[j].stamp {
+			return found[i].stamp < found[j].stamp
+		}
+		return found[i].seq < found[j].seq
+	})
+	backups := make([]string, len(found))
+	for i, b := range found {
+		backups[i] = b.name
+	}
+	return backups, nil
+}
+
+// Flush syncs the file and waits for rotated files to be compressed and
+// pruned.
+func (r *rotatingFile) Flush() error {
+	r.cleanup.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	return r.f.Sync()
+}
+
+// Close waits for the compression and pruning of backups, then syncs and
+// closes the current file. A later Write opens it again.
+func (r *rotatingFile) Close() error {
+	r.cleanup.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Sync()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	r.f = nil
+	return err
+}
+
+// RotationState describes the file opened by Init with a rotation, so
+// that fleet tooling can predict the disk usage of the logs.
+type RotationState struct {
+	// File is the file being written and Size its size.
+	File string
+	Size int64
+	// MaxSize and MaxBackups are those of the RotationConfig.
+	MaxSize    int64
+	MaxBackups int
+	// Opened is when the file was started, and NextRotation when it is
+	// rotated by age, by schedule or by the day in its name at the latest,
+	// zero without any. The rotation happens with the first entry after it.
+	Opened       time.Time
+	NextRotation time.Time
+	// Backups are the rotated files on disk, oldest first.
+	Backups []BackupFile
+	// BackupSize is the total size of the backups.
+	BackupSize int64
+}
+
+// BackupFile is a rotated log file.
+type BackupFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// state returns the rotation state of r.
+func (r *rotatingFile) state() RotationState {
+	r.mu.Lock()
+	s := RotationState{
+		File:       r.name,
+		Size:       r.size,
+		MaxSize:    r.cfg.MaxSize,
+		MaxBackups: r.cfg.MaxBackups,
+		Opened:     r.opened,
+	}
+	if r.f != nil {
+		if r.cfg.MaxAge > 0 {
+			s.NextRotation = r.opened.Add(r.cfg.MaxAge)
+		}
+		if !r.next.IsZero() && (s.NextRotation.IsZero() || r.next.Before(s.NextRotation)) {
+			s.NextRotation = r.next
+		}
+		if strings.Contains(r.base, "{date}") {
+			y, m, d := r.now().Date()
+			midnight := time.Date(y, m, d+1, 0, 0, 0, 0, time.Local)
+			if s.NextRotation.IsZero() || midnight.Before(s.NextRotation) {
+				s.NextRotation = midnight
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	names, err := r.backups()
+	if err != nil {
+		reportError(fmt.Errorf("list backups of %s: %v", r.template, err))
+	}
+	for _, name := range names {
+		fi, err := os.Stat(name)
+		if err != nil {
+			// Removed by pruning meanwhile.
+			continue
+		}
+		s.Backups = append(s.Backups, BackupFile{Name: name, Size: fi.Size(), ModTime: fi.ModTime()})
+		s.BackupSize += fi.Size()
+	}
+	return s
+}
+
+// forceRotate rotates the file now unless it is empty, and returns the
+// name of the rotated file.
+func (r *rotatingFile) forceRotate() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		if err := r.openLocked(); err != nil {
+			return "", err
+		}
+	}
+	if r.size == 0 {
+		return "", nil
+	}
+	return r.rotate()
+}
+
+// archive rotates the file, waits for the rotated file to be compressed
+// and encrypted and moves it to dir, if not empty. It returns the name of
+// the archived file, empty if the file was empty.
+func (r *rotatingFile) archive(dir string) (string, error) {
+	backup, err := r.forceRotate()
+	if backup == "" {
+		return "", err
+	}
+	r.cleanup.Wait()
+
+	name := backup
+	for _, suffix := range []string{".gz", ".enc"} {
+		if _, err := os.Stat(name + suffix); err == nil {
+			name += suffix
+		}
+	}
+	if dir == "" {
+		return name, err
+	}
+	if err := mkdirLog(dir); err != nil {
+		return name, err
+	}
+	dest := filepath.Join(dir, filepath.Base(name))
+	if err := os.Rename(name, dest); err != nil {
+		return name, err
+	}
+	return dest, err
+}
+
+// activeRotatingFile returns the file opened by Init with a rotation, nil
+// without one.
+func activeRotatingFile() *rotatingFile {
+	current.Lock()
+	mw := current.output
+	current.Unlock()
+	if mw == nil {
+		return nil
+	}
+	for _, w := range mw.outputs {
+		if a, ok := w.(*AsyncWriter); ok {
+			w = a.w
+		}
+		if r, ok := w.(*rotatingFile); ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// Rotate rotates the file opened by Init with a rotation right away, e.g.
+// ahead of a backup or when the disk fills up, as if it had reached
+// MaxSize. An empty file is not rotated. Rotate fails without a rotation.
+func Rotate() error {
+	r := activeRotatingFile()
+	if r == nil {
+		return errNotRotated
+	}
+	_, err := r.forceRotate()
+	return err
+}
+
+var errNotRotated = errors.New("log file not rotated, see SetRotation")
+
+// SetArchiveDir sets the directory Archive moves the files it archives to,
+// e.g. one collected with the incident reports. It should be on the file
+// system of the log. An empty dir, the default, leaves them with the
+// rotated files, subject to MaxBackups.
+func SetArchiveDir(dir string) {
+	rotation.Lock()
+	rotation.archiveDir = dir
+	rotation.Unlock()
+}
+
+// Archive rotates the file opened by Init with a rotation like Rotate,
+// then waits for the rotated file to be compressed and encrypted, as
+// configured, and moves it to the directory set with SetArchiveDir, e.g.
+// right after capturing an incident, so that the entries of the incident
+// are kept in a file of their own. It returns the name of the archived
+// file, empty if the log file was empty. Archive fails without a
+// rotation.
+func Archive() (string, error) {
+	r := activeRotatingFile()
+	if r == nil {
+		return "", errNotRotated
+	}
+	rotation.Lock()
+	dir := rotation.archiveDir
+	rotation.Unlock()
+	return r.archive(dir)
+}
+
+// RotationHandler returns an HTTP handler serving the RotationState of the
+// log file as JSON on GET, and calling Rotate on POST before answering the
+// new state:
+//
+//	curl -X POST http://localhost:8080/debug/log/rotation
+//
+// POST with action=archive calls Archive instead and answers the name of
+// the archived file as {"archived":"..."}. It answers 404 Not Found
+// without a rotation.
+func RotationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r := activeRotatingFile()
+		if r == nil {
+			http.Error(w, "log file not rotated", http.StatusNotFound)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			switch action := req.FormValue("action"); action {
+			case "", "rotate":
+				if _, err := r.forceRotate(); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				WithFields(Fields{"remote": req.RemoteAddr}).Info("log file rotated on request")
+			case "archive":
+				name, err := Archive()
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				WithFields(Fields{"remote": req.RemoteAddr, "archived": name}).Info("log file archived on request")
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]string{"archived": name})
+				return
+			default:
+				http.Error(w, fmt.Sprintf("unknown action %q", action), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(r.state())
+	})
+}
+
+// compressFile replaces name with a gzipped copy named name.gz.
+func compressFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := name + ".gz.tmp"
+	out, err := createLogFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(name)
+}
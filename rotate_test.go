@@ -0,0 +1,358 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileBySize(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "probe.log")
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := newRotatingFile(name, RotationConfig{MaxSize: 20, MaxBackups: 2})
+	r.now = func() time.Time { return now }
+	rotations := Stats().Rotations
+
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Second)
+		if _, err := r.Write([]byte("entry of 15 b.\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := Stats().Rotations - rotations; n != 3 {
+		t.Errorf("%d rotations counted, want 3", n)
+	}
+
+	backups, err := r.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{name + ".20170301-120003.000", name + ".20170301-120004.000"}
+	if strings.Join(backups, " ") != strings.Join(want, " ") {
+		t.Errorf("backups = %q, want the newest two rotations %q", backups, want)
+	}
+	if b, _ := os.ReadFile(name); string(b) != "entry of 15 b.\n" {
+		t.Errorf("current file = %q, want the last entry only", b)
+	}
+}
+
+func TestRotatingFileSameMillisecond(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "probe.log")
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := newRotatingFile(name, RotationConfig{MaxSize: 10})
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		if _, err := fmt.Fprintf(r, "entry %d larger than 10\n", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := r.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all string
+	for _, b := range append(backups, name) {
+		content, _ := os.ReadFile(b)
+		all += string(content)
+	}
+	for i := 0; i < 5; i++ {
+		if !strings.Contains(all, fmt.Sprintf("entry %d ", i)) {
+			t.Errorf("entry %d lost by rotations in the same millisecond, files %q hold %q", i, backups, all)
+		}
+	}
+	if len(backups) > 1 && backups[1] != name+".20170301-120000.000-1" {
+		t.Errorf("backups = %q, want a counter after the first", backups)
+	}
+}
+
+func TestRotatingFileByAge(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "probe.log")
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := newRotatingFile(name, RotationConfig{MaxAge: time.Hour, Compress: true})
+	r.now = func() time.Time { return now }
+
+	r.Write([]byte("old\n"))
+	now = now.Add(30 * time.Minute)
+	r.Write([]byte("still young\n"))
+	now = now.Add(30 * time.Minute)
+	r.Write([]byte("new\n"))
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name + ".20170301-130000.000.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old\nstill young\n" {
+		t.Errorf("rotated file = %q, want the entries of the first hour", b)
+	}
+	if _, err := os.Stat(name + ".20170301-130000.000"); !os.IsNotExist(err) {
+		t.Errorf("uncompressed backup was not removed: %v", err)
+	}
+}
+
+func TestRotatingFileClose(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "probe.log")
+	r := newRotatingFile(name, RotationConfig{MaxSize: 1 << 20})
+
+	r.Write([]byte("one\n"))
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("second Close() = %v, want nil", err)
+	}
+	if _, err := r.Write([]byte("two\n")); err != nil {
+		t.Fatalf("Write after Close = %v, want the file reopened", err)
+	}
+	r.Close()
+	if b, _ := os.ReadFile(name); string(b) != "one\ntwo\n" {
+		t.Errorf("file = %q", b)
+	}
+}
+
+func TestRotatingFileTemplate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2017, 3, 1, 23, 0, 0, 0, time.UTC)
+	r := newRotatingFile(filepath.Join(dir, "probe-{hostname}-{pid}-{shard}-{date}.log"),
+		RotationConfig{MaxSize: 20, MaxBackups: 2, Shard: "eu1"})
+	r.now = func() time.Time { return now }
+	defer r.Close()
+
+	hostname, _ := os.Hostname()
+	prefix := filepath.Join(dir, fmt.Sprintf("probe-%s-%d-eu1-", hostname, os.Getpid()))
+	r.Write([]byte("entry of 15 b.\n"))
+	r.Write([]byte("entry of 15 b.\n"))
+	now = now.Add(2 * time.Hour)
+	r.Write([]byte("next day\n"))
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, _ := os.ReadFile(prefix + "2017-03-02.log"); string(b) != "next day\n" {
+		t.Errorf("file of the day = %q", b)
+	}
+	if b, _ := os.ReadFile(prefix + "2017-03-01.log"); string(b) != "entry of 15 b.\n" {
+		t.Errorf("file of the previous day = %q", b)
+	}
+	backups, err := r.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{prefix + "2017-03-01.log.20170301-230000.000", prefix + "2017-03-01.log"}
+	if strings.Join(backups, " ") != strings.Join(want, " ") {
+		t.Errorf("backups = %q, want %q", backups, want)
+	}
+	if r.Name() != filepath.Join(dir, "probe-{hostname}-{pid}-{shard}-{date}.log") {
+		t.Errorf("Name() = %q, want the template", r.Name())
+	}
+}
+
+func TestRotatingFileSchedule(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "probe.log")
+	now := time.Date(2017, 3, 1, 11, 50, 0, 0, time.UTC)
+	r := newRotatingFile(name, RotationConfig{Schedule: "0 */6 * * *"})
+	r.now = func() time.Time { return now }
+	defer r.Close()
+
+	r.Write([]byte("morning\n"))
+	if s := r.state(); !s.NextRotation.Equal(time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("NextRotation = %v, want noon", s.NextRotation)
+	}
+	now = now.Add(20 * time.Minute)
+	r.Write([]byte("afternoon\n"))
+	now = now.Add(time.Hour)
+	r.Write([]byte("still afternoon\n"))
+	r.Flush()
+
+	if b, _ := os.ReadFile(name + ".20170301-121000.000"); string(b) != "morning\n" {
+		t.Errorf("file rotated at noon = %q", b)
+	}
+	if b, _ := os.ReadFile(name); string(b) != "afternoon\nstill afternoon\n" {
+		t.Errorf("current file = %q", b)
+	}
+}
+
+func TestRotatingFileSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need a privilege")
+	}
+	dir := t.TempDir()
+	link := filepath.Join(dir, "probe.log")
+	now := time.Date(2017, 3, 1, 23, 0, 0, 0, time.UTC)
+	// A file of the previous week, expired.
+	old := filepath.Join(dir, "probe-2017-02-20.log")
+	os.WriteFile(old, []byte("last week\n"), 0644)
+	os.Chtimes(old, now.AddDate(0, 0, -8), now.AddDate(0, 0, -8))
+
+	r := newRotatingFile(filepath.Join(dir, "probe-{date}.log"),
+		RotationConfig{Symlink: link, MaxBackupAge: 7 * 24 * time.Hour})
+	r.now = func() time.Time { return now }
+	defer r.Close()
+
+	r.Write([]byte("day one\n"))
+	if target, err := os.Readlink(link); err != nil || target != "probe-2017-03-01.log" {
+		t.Errorf("link points to %q (%v), want the file of the day", target, err)
+	}
+	now = now.Add(2 * time.Hour)
+	r.Write([]byte("day two\n"))
+	r.Flush()
+
+	if b, _ := os.ReadFile(link); string(b) != "day two\n" {
+		t.Errorf("file read through the link = %q", b)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expired file kept: %v", err)
+	}
+	backups, _ := r.backups()
+	if want := filepath.Join(dir, "probe-2017-03-01.log"); len(backups) != 1 || backups[0] != want {
+		t.Errorf("backups = %q, want %q only", backups, want)
+	}
+}
+
+func TestRotatingFileState(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "probe-{date}.log")
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.Local)
+	r := newRotatingFile(name, RotationConfig{MaxSize: 100, MaxAge: time.Hour, MaxBackups: 3})
+	r.now = func() time.Time { return now }
+
+	r.Write([]byte("first file\n"))
+	now = now.Add(time.Minute)
+	if _, err := r.forceRotate(); err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("second\n"))
+	r.Flush()
+
+	s := r.state()
+	if s.File != filepath.Join(dir, "probe-2017-03-01.log") || s.Size != 7 || s.MaxSize != 100 || s.MaxBackups != 3 {
+		t.Errorf("state = %+v", s)
+	}
+	if want := now.Add(time.Hour); !s.NextRotation.Equal(want) {
+		t.Errorf("next rotation = %v, want %v", s.NextRotation, want)
+	}
+	if len(s.Backups) != 1 || s.Backups[0].Size != 11 || s.BackupSize != 11 {
+		t.Errorf("backups = %+v, size %d, want the first file", s.Backups, s.BackupSize)
+	}
+
+	// Midnight comes before the age limit.
+	now = time.Date(2017, 3, 1, 23, 30, 0, 0, time.Local)
+	r.forceRotate()
+	if s := r.state(); !s.NextRotation.Equal(time.Date(2017, 3, 2, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("next rotation = %v, want midnight", s.NextRotation)
+	}
+}
+
+func TestRotationHandler(t *testing.T) {
+	srv := httptest.NewServer(RotationHandler())
+	defer srv.Close()
+	SetOutputs(io.Discard)
+	if resp, err := http.Get(srv.URL); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET without rotation = %v, %v, want 404", resp, err)
+	}
+	if err := Rotate(); err == nil {
+		t.Error("Rotate without rotation = nil, want error")
+	}
+
+	name := filepath.Join(t.TempDir(), "probe.log")
+	r := newRotatingFile(name, RotationConfig{MaxSize: 1 << 20})
+	setOutput(newMultiWriter(r, io.Discard))
+	defer SetOutputs(os.Stderr)
+	r.Write([]byte("entry\n"))
+
+	resp, err := http.Post(srv.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var s RotationState
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.File != name || len(s.Backups) != 1 {
+		t.Errorf("state after POST = %+v, want one backup", s)
+	}
+	if st := Stats().Rotation; st == nil || st.File != name {
+		t.Errorf("Stats().Rotation = %+v", st)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "probe.log")
+	r := newRotatingFile(name, RotationConfig{MaxSize: 1 << 20, Compress: true})
+	setOutput(newMultiWriter(r, io.Discard))
+	defer SetOutputs(os.Stderr)
+	incidents := filepath.Join(dir, "incidents")
+	SetArchiveDir(incidents)
+	defer SetArchiveDir("")
+
+	if archived, err := Archive(); err != nil || archived != "" {
+		t.Errorf("Archive of an empty file = %q, %v, want nothing archived", archived, err)
+	}
+	r.Write([]byte("incident\n"))
+	archived, err := Archive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(archived) != incidents || !strings.HasSuffix(archived, ".gz") {
+		t.Errorf("archived %q, want a compressed file in %s", archived, incidents)
+	}
+	f, err := os.Open(archived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "incident\n" {
+		t.Errorf("archived content = %q", b)
+	}
+
+	srv := httptest.NewServer(RotationHandler())
+	defer srv.Close()
+	r.Write([]byte("second incident\n"))
+	resp, err := http.Post(srv.URL+"?action=archive", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if filepath.Dir(body["archived"]) != incidents {
+		t.Errorf("POST action=archive = %v", body)
+	}
+	if resp, _ := http.Post(srv.URL+"?action=shred", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown action answered %s", resp.Status)
+	}
+}
@@ -0,0 +1,146 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule formats of ExportParsingRules.
+const (
+	// GrokRules is a grok pattern, e.g. for Logstash or an Elasticsearch
+	// ingest pipeline.
+	GrokRules = "grok"
+	// PromtailRules is a list of Promtail pipeline stages.
+	PromtailRules = "promtail"
+	// VectorRules is a Vector remap (VRL) program for the message field.
+	VectorRules = "vector"
+)
+
+// ExportParsingRules returns the rules parsing the lines written with the
+// current format and settings, e.g. the level labels, into the time,
+// hostname, level, tag, pid and msg fields, as well as caller for the text
+// format, where the tag is only set by WithTag, and file and line for
+// JSON. Generating the ingestion
+// configuration from the code keeps it in step with what the code writes:
+//
+//	rules, _ := log.ExportParsingRules(log.PromtailRules)
+//	os.WriteFile("pipeline_stages.yaml", []byte(rules), 0644)
+//
+// The fields of text lines follow the message as key=value pairs, which a
+// logfmt stage or parser can extract. JSON lines have no grok pattern.
+func ExportParsingRules(rules string) (string, error) {
+	isJSON := format == JSONFormat || format == FlatJSONFormat
+	switch format {
+	case CustomFormat:
+		return "", errors.New("no parsing rules for a custom format")
+	case CEFFormat, LEEFFormat, RFC5424Format:
+		return "", fmt.Errorf("no parsing rules for the %s format, parsed natively by SIEMs", format)
+	case ConsoleFormat:
+		return "", errors.New("no parsing rules for the console format, meant for terminals")
+	}
+	switch rules {
+	case GrokRules:
+		if isJSON {
+			return "", fmt.Errorf("no grok pattern for the %s format, use a JSON parser", format)
+		}
+		return textPattern(true), nil
+	case PromtailRules:
+		if isJSON {
+			return promtailJSON, nil
+		}
+		return fmt.Sprintf(promtailText, strings.Replace(textPattern(false), "'", "''", -1)), nil
+	case VectorRules:
+		if isJSON {
+			return vectorJSON, nil
+		}
+		return fmt.Sprintf(vectorText, textPattern(false)), nil
+	}
+	return "", fmt.Errorf("not a valid rule format: %q", rules)
+}
+
+// textPattern returns the regular expression matching a line of the text
+// format, with grok named captures and patterns if grok is set.
+func textPattern(grok bool) string {
+	group := func(name, re string) string {
+		if grok {
+			return "(?<" + name + ">" + re + ")"
+		}
+		return "(?P<" + name + ">" + re + ")"
+	}
+
+	var alts []string
+	seen := make(map[string]bool)
+	for _, lvl := range allLevels {
+		l := regexp.QuoteMeta(levelLabel(lvl))
+		if !seen[l] {
+			seen[l] = true
+			alts = append(alts, l)
+		}
+	}
+	level := group("level", strings.Join(alts, "|"))
+	if packageFormatter().Color {
+		level = `(?:\x1b\[[0-9;]*m)?` + level + `(?:\x1b\[0m)?`
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if grok {
+		b.WriteString("%{TIMESTAMP_ISO8601:time} %{NOTSPACE:hostname} : ")
+	} else {
+		b.WriteString(group("time", `\S+`) + " " + group("hostname", `\S+`) + " : ")
+	}
+	b.WriteString(level + `\t` + group("caller", `(?:[^\[\s:]*:[^\[\s]*)?`) + `(?: ?` + group("tag", `[^\[\s:]+`) + `)?\[`)
+	if grok {
+		b.WriteString(`%{POSINT:pid}\] %{GREEDYDATA:msg}`)
+	} else {
+		b.WriteString(group("pid", `\d+`) + `\] ` + group("msg", `.*`))
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+const promtailText = `pipeline_stages:
+  - regex:
+      expression: '%s'
+  - labels:
+      level:
+  - timestamp:
+      source: time
+      format: RFC3339
+  - output:
+      source: msg
+`
+
+const promtailJSON = `pipeline_stages:
+  - json:
+      expressions:
+        time: time
+        hostname: hostname
+        level: level
+        tag: tag
+        pid: pid
+        file: file
+        line: line
+        msg: msg
+  - labels:
+      level:
+  - timestamp:
+      source: time
+      format: RFC3339Nano
+  - output:
+      source: msg
+`
+
+const vectorText = `. |= parse_regex!(.message, r'%s')
+.timestamp = parse_timestamp!(.time, "%%+")
+.message = .msg
+del(.msg)
+`
+
+const vectorJSON = `. |= object!(parse_json!(.message))
+.timestamp = parse_timestamp!(.time, "%+")
+.message = .msg
+del(.msg)
+`
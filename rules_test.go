@@ -0,0 +1,67 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestExportParsingRules(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetLevelLabels(FourLetterLevelLabels)
+	defer SetLevelLabels(nil)
+	SetColor(true)
+	defer SetColor(false)
+
+	With("port", 443).Warning("handshake failed")
+	rules, err := ExportParsingRules(PromtailRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`expression: '(.*)'`).FindStringSubmatch(rules)
+	if m == nil {
+		t.Fatalf("promtail rules lack the regex:\n%s", rules)
+	}
+	re := regexp.MustCompile(m[1])
+	sub := re.FindStringSubmatch(strings.TrimSuffix(buf.String(), "\n"))
+	if sub == nil {
+		t.Fatalf("expression %s does not match %q", m[1], buf.String())
+	}
+	got := map[string]string{}
+	for i, name := range re.SubexpNames() {
+		got[name] = sub[i]
+	}
+	if got["level"] != "WARN" || got["msg"] != "handshake failed port=443" || !strings.HasPrefix(got["caller"], "/") || got["pid"] == "" {
+		t.Errorf("parsed %v", got)
+	}
+	for _, tagged := range []string{
+		"2017-03-01T12:00:00Z probe : WARN\t/src/dhcp.go:10 dhcp-server/lease[42] renewed",
+		"2017-03-01T12:00:00Z probe : WARN\tdhcp-server[42] renewed",
+	} {
+		sub := re.FindStringSubmatch(tagged)
+		if sub == nil || sub[re.SubexpIndex("tag")] == "" || sub[re.SubexpIndex("msg")] != "renewed" {
+			t.Errorf("expression %s parses %q as %q", m[1], tagged, sub)
+		}
+	}
+
+	grok, err := ExportParsingRules(GrokRules)
+	if err != nil || !strings.Contains(grok, "%{TIMESTAMP_ISO8601:time}") || !strings.Contains(grok, "(?<level>PANC|FATL|EROR|WARN|INFO|DEBG|TRCE)") {
+		t.Errorf("grok = %s, %v", grok, err)
+	}
+
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+	if _, err := ExportParsingRules(GrokRules); err == nil {
+		t.Error("grok pattern exported for JSON")
+	}
+	if v, _ := ExportParsingRules(VectorRules); !strings.Contains(v, "parse_json!") {
+		t.Errorf("vector rules for JSON = %s", v)
+	}
+	if _, err := ExportParsingRules("fluentd"); err == nil {
+		t.Error("unknown rule format accepted")
+	}
+}
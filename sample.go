@@ -0,0 +1,108 @@
+package log
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// sampler keeps a fraction of the DEBUG and INFO entries.
+type sampler struct {
+	mu   sync.Mutex
+	rate float64
+	rng  *rand.Rand
+	key  string
+}
+
+var sampling = &sampler{rate: 1}
+
+// SetSampling keeps only the fraction rate of DEBUG and INFO entries,
+// dropping the others before they are written; dropped entries are counted
+// in Stats. WARNING and more severe entries are always kept. A rate of 1 or
+// more disables sampling.
+func SetSampling(rate float64) {
+	sampling.mu.Lock()
+	sampling.rate = rate
+	sampling.mu.Unlock()
+}
+
+// SetSampleSource sets the randomness behind sampling decisions, e.g.
+// rand.NewSource(1) for reproducible tests. nil restores the default
+// source, seeded from crypto/rand.
+func SetSampleSource(src rand.Source) {
+	sampling.mu.Lock()
+	if src == nil {
+		sampling.rng = nil
+	} else {
+		sampling.rng = rand.New(src)
+	}
+	sampling.mu.Unlock()
+}
+
+// SetSampleKey samples deterministically by the value of the field key:
+// entries whose field has the same value, e.g. the same request ID, are
+// either all kept or all dropped, so a sampled request keeps its whole
+// history. Entries without the field are sampled randomly. An empty key
+// restores random sampling.
+func SetSampleKey(key string) {
+	sampling.mu.Lock()
+	sampling.key = key
+	sampling.mu.Unlock()
+}
+
+// keep reports whether an entry is kept by sampling.
+func (s *sampler) keep(level log.Level, fields log.Fields) bool {
+	if level <= log.WarnLevel {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rate >= 1 {
+		return true
+	}
+	var x float64
+	if v, ok := fields[s.key]; ok && s.key != "" {
+		h := fnv.New64a()
+		fmt.Fprint(h, v)
+		x = float64(mix64(h.Sum64())) / math.MaxUint64
+	} else {
+		if s.rng == nil {
+			s.rng = rand.New(rand.NewSource(cryptoSeed()))
+		}
+		x = s.rng.Float64()
+	}
+	if x < s.rate {
+		return true
+	}
+	atomic.AddUint64(&stats.Sampled, 1)
+	return false
+}
+
+// mix64 spreads the bits of FNV hashes of short, similar values such as
+// sequential IDs, whose high bits barely differ (the murmur3 finalizer).
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// cryptoSeed returns a seed from crypto/rand.
+func cryptoSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		reportError(fmt.Errorf("seed sampling: %v", err))
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
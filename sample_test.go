@@ -0,0 +1,80 @@
+package log
+
+import (
+	"math/rand"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func sampleRun(n int) []bool {
+	kept := make([]bool, n)
+	for i := range kept {
+		kept[i] = sampling.keep(log.InfoLevel, nil)
+	}
+	return kept
+}
+
+func TestSampleSourceDeterministic(t *testing.T) {
+	SetSampling(0.5)
+	defer SetSampling(1)
+	defer SetSampleSource(nil)
+
+	SetSampleSource(rand.NewSource(42))
+	a := sampleRun(100)
+	SetSampleSource(rand.NewSource(42))
+	b := sampleRun(100)
+
+	n := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("decision %d differs with the same seed", i)
+		}
+		if a[i] {
+			n++
+		}
+	}
+	if n < 30 || n > 70 {
+		t.Errorf("kept %d of 100 entries at rate 0.5", n)
+	}
+}
+
+func TestSampleKey(t *testing.T) {
+	SetSampling(0.5)
+	defer SetSampling(1)
+	SetSampleKey("request_id")
+	defer SetSampleKey("")
+
+	kept := 0
+	for id := 0; id < 100; id++ {
+		fields := log.Fields{"request_id": id}
+		first := sampling.keep(log.DebugLevel, fields)
+		for i := 0; i < 5; i++ {
+			if sampling.keep(log.InfoLevel, fields) != first {
+				t.Fatalf("entries of request %d sampled differently", id)
+			}
+		}
+		if first {
+			kept++
+		}
+	}
+	if kept < 30 || kept > 70 {
+		t.Errorf("kept %d of 100 requests at rate 0.5", kept)
+	}
+}
+
+func TestSamplingKeepsWarnings(t *testing.T) {
+	SetSampling(0)
+	defer SetSampling(1)
+
+	before := Stats().Sampled
+	if !sampling.keep(log.WarnLevel, nil) || !sampling.keep(log.ErrorLevel, nil) {
+		t.Error("WARNING or ERROR entry sampled out")
+	}
+	if sampling.keep(log.InfoLevel, nil) {
+		t.Error("INFO entry kept at rate 0")
+	}
+	if Stats().Sampled != before+1 {
+		t.Errorf("Sampled = %d, want %d", Stats().Sampled, before+1)
+	}
+}
@@ -0,0 +1,42 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2024, 5, 31, 22, 30, 0, 0, time.UTC) // a Friday
+	for spec, want := range map[string]time.Time{
+		"@daily":         time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		"@hourly":        time.Date(2024, 5, 31, 23, 0, 0, 0, time.UTC),
+		"*/20 * * * *":   time.Date(2024, 5, 31, 22, 40, 0, 0, time.UTC),
+		"15 3 * * 1-5":   time.Date(2024, 6, 3, 3, 15, 0, 0, time.UTC),
+		"0 0 * * 7":      time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+		"0 12 15 * 6":    time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		"0 0 1 1,7 *":    time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		"30 22 31 5 *":   time.Date(2025, 5, 31, 22, 30, 0, 0, time.UTC),
+		"0 0 29 2 *":     time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 0-4/2 * * * ": time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		s, err := parseSchedule(spec)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", spec, err)
+			continue
+		}
+		if got := s.next(from); !got.Equal(want) {
+			t.Errorf("next of %q = %v, want %v", spec, got, want)
+		}
+	}
+	if s, _ := parseSchedule("0 0 30 2 *"); !s.next(from).IsZero() {
+		t.Errorf("February 30 scheduled at %v", s.next(from))
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "@yearly", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("parseSchedule(%q) = nil error", spec)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package log
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SchemaErrorsKey is the field listing the fields that could not be
+// coerced to their declared type.
+const SchemaErrorsKey = "schema_errors"
+
+// FieldType is the type declared for a field with DeclareField.
+type FieldType int
+
+const (
+	// TypeString renders values with fmt.Sprint, errors with Error.
+	TypeString FieldType = iota + 1
+	// TypeInt converts to int64. Strings are parsed, floats must be whole.
+	TypeInt
+	// TypeFloat converts to float64. Strings are parsed.
+	TypeFloat
+	// TypeBool converts to bool. Strings are parsed with strconv.ParseBool.
+	TypeBool
+	// TypeSeconds converts durations to float64 seconds. Numbers are taken
+	// as seconds, strings are parsed with time.ParseDuration.
+	TypeSeconds
+)
+
+var schemaTypeNames = map[FieldType]string{
+	TypeString:  "string",
+	TypeInt:     "int",
+	TypeFloat:   "float",
+	TypeBool:    "bool",
+	TypeSeconds: "seconds",
+}
+
+func (t FieldType) String() string {
+	if s, ok := schemaTypeNames[t]; ok {
+		return s
+	}
+	return "FieldType(" + strconv.Itoa(int(t)) + ")"
+}
+
+var schema = struct {
+	sync.RWMutex
+	types map[string]FieldType
+}{}
+
+// DeclareField declares the type of the field key. Every entry carrying the
+// field has its value coerced to that type before it reaches the outputs,
+// so that sinks with a fixed schema, like Parquet files or Elasticsearch
+// indices, never see a field with mixed types:
+//
+//	log.DeclareField("port", log.TypeInt)
+//	log.DeclareField("elapsed", log.TypeSeconds)
+//
+// A value that cannot be coerced is removed from the entry and described
+// in the schema_errors field instead. A zero t removes the declaration.
+func DeclareField(key string, t FieldType) {
+	schema.Lock()
+	defer schema.Unlock()
+
+	if t == 0 {
+		delete(schema.types, key)
+		return
+	}
+	if schema.types == nil {
+		schema.types = make(map[string]FieldType)
+	}
+	schema.types[key] = t
+}
+
+// coerceFields converts the declared fields of an entry in place.
+func coerceFields(fields log.Fields) log.Fields {
+	if len(fields) == 0 {
+		return fields
+	}
+
+	schema.RLock()
+	defer schema.RUnlock()
+	if len(schema.types) == 0 {
+		return fields
+	}
+
+	var errs []string
+	for k, v := range fields {
+		t, ok := schema.types[k]
+		if !ok {
+			continue
+		}
+		c, err := coerce(v, t)
+		if err != nil {
+			delete(fields, k)
+			errs = append(errs, k+": "+err.Error())
+			continue
+		}
+		fields[k] = c
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		fields[SchemaErrorsKey] = strings.Join(errs, "; ")
+	}
+	return fields
+}
+
+// coerce converts v to t.
+func coerce(v interface{}, t FieldType) (interface{}, error) {
+	fail := func() (interface{}, error) {
+		return nil, fmt.Errorf("cannot convert %v (%T) to %s", v, v, t)
+	}
+
+	switch t {
+	case TypeString:
+		if err, ok := v.(error); ok {
+			return err.Error(), nil
+		}
+		return fmt.Sprint(v), nil
+
+	case TypeInt:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if rv.Uint() > math.MaxInt64 {
+				return fail()
+			}
+			return int64(rv.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			if f := rv.Float(); f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+				return int64(f), nil
+			}
+		case reflect.String:
+			if n, err := strconv.ParseInt(strings.TrimSpace(rv.String()), 10, 64); err == nil {
+				return n, nil
+			}
+		}
+		return fail()
+
+	case TypeFloat:
+		if f, ok := toFloat(v); ok {
+			return f, nil
+		}
+		if s, ok := v.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return f, nil
+			}
+		}
+		return fail()
+
+	case TypeBool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+		return fail()
+
+	case TypeSeconds:
+		switch d := v.(type) {
+		case time.Duration:
+			return d.Seconds(), nil
+		case string:
+			if parsed, err := time.ParseDuration(strings.TrimSpace(d)); err == nil {
+				return parsed.Seconds(), nil
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(d), 64); err == nil {
+				return f, nil
+			}
+			return fail()
+		}
+		if f, ok := toFloat(v); ok {
+			return f, nil
+		}
+		return fail()
+	}
+	return fail()
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoerce(t *testing.T) {
+	for _, tt := range []struct {
+		v    interface{}
+		t    FieldType
+		want interface{}
+	}{
+		{8080, TypeInt, int64(8080)},
+		{"8080", TypeInt, int64(8080)},
+		{uint16(53), TypeInt, int64(53)},
+		{443.0, TypeInt, int64(443)},
+		{"0.5", TypeFloat, 0.5},
+		{int32(2), TypeFloat, 2.0},
+		{"true", TypeBool, true},
+		{1500 * time.Millisecond, TypeSeconds, 1.5},
+		{"250ms", TypeSeconds, 0.25},
+		{3, TypeSeconds, 3.0},
+		{errors.New("refused"), TypeString, "refused"},
+		{42, TypeString, "42"},
+	} {
+		got, err := coerce(tt.v, tt.t)
+		if err != nil || got != tt.want {
+			t.Errorf("coerce(%#v, %s) = %#v, %v, want %#v", tt.v, tt.t, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		v interface{}
+		t FieldType
+	}{
+		{"http", TypeInt},
+		{1.5, TypeInt},
+		{uint64(1 << 63), TypeInt},
+		{"fast", TypeFloat},
+		{"yes please", TypeBool},
+		{"soon", TypeSeconds},
+	} {
+		if got, err := coerce(tt.v, tt.t); err == nil {
+			t.Errorf("coerce(%#v, %s) = %#v, want error", tt.v, tt.t, got)
+		}
+	}
+}
+
+func TestDeclareField(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	DeclareField("port", TypeInt)
+	DeclareField("elapsed", TypeSeconds)
+	defer DeclareField("port", 0)
+	defer DeclareField("elapsed", 0)
+
+	With("port", "8080", "elapsed", 2*time.Second).Info("listening")
+	With("port", "http").Info("bad port")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	if !strings.HasSuffix(lines[0], "listening elapsed=2 port=8080") {
+		t.Errorf("line %q does not carry the coerced fields", lines[0])
+	}
+	want := `bad port schema_errors="port: cannot convert http (string) to int"`
+	if !strings.HasSuffix(lines[1], want) {
+		t.Errorf("line %q does not end with %q", lines[1], want)
+	}
+}
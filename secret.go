@@ -0,0 +1,99 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// SecretMask is how a secret field is rendered with MaskSecrets.
+const SecretMask = "****"
+
+// SecretMode selects how secret fields are rendered.
+type SecretMode int32
+
+const (
+	// MaskSecrets replaces secrets with SecretMask.
+	MaskSecrets SecretMode = iota
+	// HashSecrets replaces secrets with "sha256:" and the first 16 hex
+	// digits of their SHA-256, so audit logs can tell whether two entries
+	// carried the same secret without revealing it. Secrets with little
+	// entropy, like PINs, can be recovered from their hash.
+	HashSecrets
+)
+
+var secretMode int32
+
+// SetSecretMode sets how every output renders secret fields. The default is
+// MaskSecrets. Audit records hash secrets whatever the mode.
+func SetSecretMode(m SecretMode) {
+	atomic.StoreInt32(&secretMode, int32(m))
+}
+
+// Secret returns a field whose value is sensitive, such as a password or an
+// API key. Formatters never write the value itself but SecretMask or, with
+// HashSecrets, its hash:
+//
+//	log.WithTyped(log.Secret("api_key", key)).Info("authenticated")
+func Secret(key string, val interface{}) Field {
+	return Field{Key: key, kind: anyKind, any: secretValue{val}}
+}
+
+// secretValue holds the value of a secret field. Every way of rendering it
+// yields the masked form.
+type secretValue struct {
+	v interface{}
+}
+
+func (s secretValue) String() string {
+	if SecretMode(atomic.LoadInt32(&secretMode)) == HashSecrets {
+		return s.hash()
+	}
+	return SecretMask
+}
+
+// hash returns the form of s under HashSecrets.
+func (s secretValue) hash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(s.v)))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// hashSecrets returns fields with the secret values replaced by their hash,
+// fields itself if it holds none.
+func hashSecrets(fields Fields) Fields {
+	var hashed Fields
+	for k, v := range fields {
+		s, ok := v.(secretValue)
+		if !ok {
+			continue
+		}
+		if hashed == nil {
+			hashed = make(Fields, len(fields))
+			for k, v := range fields {
+				hashed[k] = v
+			}
+		}
+		hashed[k] = s.hash()
+	}
+	if hashed == nil {
+		return fields
+	}
+	return hashed
+}
+
+func (s secretValue) GoString() string {
+	return s.String()
+}
+
+func (s secretValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// IsSecret reports whether v, a field value as seen by hooks and test
+// outputs, was logged with Secret.
+func IsSecret(v interface{}) bool {
+	_, ok := v.(secretValue)
+	return ok
+}
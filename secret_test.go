@@ -0,0 +1,64 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestSecret(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	WithTyped(Secret("api_key", "hunter2")).Info("authenticated")
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("output %q reveals the secret", buf.String())
+	}
+	if !strings.HasSuffix(buf.String(), "authenticated api_key=****\n") {
+		t.Errorf("output %q does not mask the secret", buf.String())
+	}
+
+	v := Secret("api_key", "hunter2").Value()
+	if !IsSecret(v) || IsSecret("hunter2") {
+		t.Error("IsSecret does not recognize secret values")
+	}
+	for _, s := range []string{fmt.Sprint(v), fmt.Sprintf("%#v", v), fmt.Sprintf("%+v", v)} {
+		if s != SecretMask {
+			t.Errorf("secret rendered as %q, want %q", s, SecretMask)
+		}
+	}
+}
+
+func TestSecretJSON(t *testing.T) {
+	entry := log.NewEntry(log.StandardLogger()).WithField("token", Secret("token", "hunter2").Value())
+	b, err := (&JSONFormatter{}).Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(b, &got)
+	if got["token"] != SecretMask {
+		t.Errorf("JSON token = %v, want %q", got["token"], SecretMask)
+	}
+}
+
+func TestHashSecrets(t *testing.T) {
+	SetSecretMode(HashSecrets)
+	defer SetSecretMode(MaskSecrets)
+
+	a := fmt.Sprint(Secret("k", "hunter2").Value())
+	b := fmt.Sprint(Secret("k", "hunter2").Value())
+	c := fmt.Sprint(Secret("k", "hunter3").Value())
+	if !strings.HasPrefix(a, "sha256:") || len(a) != len("sha256:")+16 {
+		t.Errorf("hashed secret = %q", a)
+	}
+	if a != b || a == c {
+		t.Errorf("hashes %q, %q, %q do not identify equal secrets", a, b, c)
+	}
+}
@@ -0,0 +1,33 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// selfLog receives errors of the logging pipeline itself, which cannot be
+// reported through the pipeline.
+var selfLog = struct {
+	sync.Mutex
+	w io.Writer
+}{w: os.Stderr}
+
+// SetSelfLog sets the writer that receives errors of the logger itself, such
+// as a failing output. The default is os.Stderr; nil discards them.
+func SetSelfLog(w io.Writer) {
+	selfLog.Lock()
+	selfLog.w = w
+	selfLog.Unlock()
+}
+
+// reportError writes err to the self-log.
+func reportError(err error) {
+	selfLog.Lock()
+	defer selfLog.Unlock()
+
+	if selfLog.w != nil {
+		fmt.Fprintf(selfLog.w, "go-log: %v\n", err)
+	}
+}
@@ -0,0 +1,283 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// staticFields holds the fields of StaticFields given to Setup.
+var (
+	staticFields     atomic.Value // Fields
+	staticFieldsOnce sync.Once
+)
+
+// asyncOptions are the arguments of Async.
+type asyncOptions struct {
+	size     int
+	interval time.Duration
+	policy   FullPolicy
+}
+
+// Format writes the entries in the format name, as accepted by SetFormat.
+func Format(name string) Option {
+	return func(o *options) {
+		f, err := parseFormat(name)
+		if err != nil {
+			o.errs = append(o.errs, err)
+			return
+		}
+		o.format = f
+	}
+}
+
+// Rotation rotates the files of OutputFile as cfg, see SetRotation.
+func Rotation(cfg RotationConfig) Option {
+	return func(o *options) {
+		if err := cfg.validate(); err != nil {
+			o.errs = append(o.errs, err)
+			return
+		}
+		o.rotation = &cfg
+	}
+}
+
+// Async writes to each output from a queue, see NewAsyncWriter.
+func Async(size int, interval time.Duration, policy FullPolicy) Option {
+	return func(o *options) {
+		o.async = &asyncOptions{size, interval, policy}
+	}
+}
+
+// StaticFields adds fields to every entry, e.g. the service and its
+// version.
+func StaticFields(fields Fields) Option {
+	return func(o *options) {
+		merged := make(Fields, len(o.fields)+len(fields))
+		for k, v := range o.fields {
+			merged[k] = v
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		o.fields = merged
+	}
+}
+
+// wrap returns w queued as given by Async.
+func (o *options) wrap(w io.Writer) io.Writer {
+	if o.async == nil {
+		return w
+	}
+	return NewAsyncWriter(w, o.async.size, o.async.interval, o.async.policy)
+}
+
+// Setup initializes the package logger with the options of New, the
+// counterpart of Init for the settings it has no parameters for:
+//
+//	err := log.Setup(
+//		log.OutputFile("/var/log/probe/probe.log"),
+//		log.Rotation(log.ProductionRotation),
+//		log.Format(log.JSONFormat),
+//		log.Async(0, 0, log.BlockWhenFull),
+//		log.StaticFields(log.Fields{"service": "probe"}),
+//	)
+//
+// Entries at info and above, unless MinLevel is given, are written to
+// stderr unless OutputFile or OutputWriters are given. Like Init, only the
+// first initialization has an effect. Nothing is initialized if an option
+// is invalid; if a file cannot be opened, the initialization is over and
+// entries still go to stderr. Setup fails if the package logger was
+// initialized already, see Reinit to change its settings.
+func Setup(opts ...Option) error {
+	o := newOptions(opts)
+	if len(o.errs) > 0 {
+		return o.errs[0]
+	}
+	var err error
+	file := o.file()
+	if !initOnce("Setup", file, levelName(o.level), func() { err = o.init(file) }) {
+		return errInitialized
+	}
+	return err
+}
+
+var errInitialized = errors.New("logger already initialized, see Reinit")
+
+// reinitMu serializes the calls to Reinit.
+var reinitMu sync.Mutex
+
+// Reinit replaces the settings of the live package logger with those of
+// the options, initialized or not, e.g. to move the log file or change the
+// format after startup:
+//
+//	err := log.Reinit(log.OutputFile("/var/log/probe/probe.log"), log.MinLevel("debug"))
+//
+// The options are applied as by Setup: settings without an option are
+// reset, e.g. the level to info and the output to stderr, except the
+// format and the rotation, which are kept. The new outputs are
+// opened before anything changes, so that on error the logger keeps its
+// settings; then the outputs are swapped, entries logged concurrently
+// going to either the old or the new ones, and the old files are flushed
+// and closed. Writers given with OutputWriters are flushed, not closed.
+// Later calls to Init and Setup are ignored.
+func Reinit(opts ...Option) error {
+	o := newOptions(opts)
+	if len(o.errs) > 0 {
+		return o.errs[0]
+	}
+	setup := func() error {
+		reinitMu.Lock()
+		defer reinitMu.Unlock()
+		current.Lock()
+		old := current.output
+		current.Unlock()
+		file := o.file()
+		if err := o.init(file); err != nil {
+			return err
+		}
+		if len(o.fields) == 0 {
+			staticFields.Store(Fields(nil))
+		}
+		initState.Lock()
+		initState.by, initState.file, initState.level = "Reinit", file, levelName(o.level)
+		initState.Unlock()
+		if old != nil {
+			for _, err := range flushWriter(old) {
+				reportError(err)
+			}
+			for _, err := range closeFiles(old) {
+				reportError(err)
+			}
+		}
+		return nil
+	}
+	var err error
+	if initFirst("Reinit", o.file(), levelName(o.level), 2, func() { err = setup() }) {
+		return err
+	}
+	return setup()
+}
+
+// closeFiles closes the log files opened by the package among w, the
+// outputs of a multiWriter and the AsyncWriters.
+func closeFiles(w io.Writer) []error {
+	switch c := w.(type) {
+	case *multiWriter:
+		// Wait for a write in progress.
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		var errs []error
+		for _, o := range c.outputs {
+			errs = append(errs, closeFiles(o)...)
+		}
+		return errs
+	case *AsyncWriter:
+		if _, ok := c.w.(reopener); ok {
+			return closeWriter(c)
+		}
+	case *lazyFile, *rotatingFile:
+		return closeWriter(c)
+	}
+	return nil
+}
+
+// file returns the first file of OutputFile, empty if there is none.
+func (o *options) file() string {
+	for _, out := range o.outputs {
+		if out.w == nil {
+			return out.file
+		}
+	}
+	return ""
+}
+
+// init initializes the package logger from o, file being its first file.
+// Nothing changes if a file cannot be opened.
+func (o *options) init(file string) error {
+	rotation, rotated := rotationConfig()
+	if o.rotation != nil {
+		rotation, rotated = *o.rotation, true
+	}
+	var outputs []io.Writer
+	for _, out := range o.outputs {
+		w := out.w
+		if w == nil {
+			var err error
+			if w, err = openInitFile(out.file, rotation, rotated); err != nil {
+				// Close what was opened, no output is installed.
+				closeWriter(newMultiWriter(outputs...))
+				return err
+			}
+		}
+		outputs = append(outputs, o.wrap(w))
+	}
+
+	if o.rotation != nil {
+		SetRotation(*o.rotation)
+	}
+	if o.format != "" {
+		format = o.format
+	}
+	log.SetFormatter(activeFormatter())
+
+	tag = os.Args[0]
+	if o.tag != "" {
+		tag = o.tag
+	}
+	setLevel(o.level)
+	switch {
+	case len(outputs) == 0:
+		setOutput(o.wrap(os.Stderr))
+	case len(outputs) == 1 && file != "":
+		setFileOutput(outputs[0])
+	default:
+		setOutput(newMultiWriter(outputs...))
+	}
+	if len(o.fields) > 0 {
+		staticFields.Store(o.fields)
+		staticFieldsOnce.Do(func() {
+			AddFieldExtractor(func() Fields {
+				f, _ := staticFields.Load().(Fields)
+				return f
+			})
+		})
+	}
+
+	current.Lock()
+	current.file = file
+	current.Unlock()
+	return nil
+}
+
+// openInitFile opens the log file of an initialization, rotated with cfg if
+// rotated is set and on the first entry after SetLazyOpen.
+func openInitFile(name string, cfg RotationConfig, rotated bool) (io.Writer, error) {
+	if rotated {
+		r := newRotatingFile(name, cfg)
+		if !lazyOpen() {
+			if err := r.open(); err != nil {
+				return nil, fmt.Errorf(`can not open log file: "%s".`, name)
+			}
+		}
+		return r, nil
+	}
+	if lazyOpen() {
+		return &lazyFile{name: name}, nil
+	}
+	if err := createLogDir(name); err != nil {
+		return nil, fmt.Errorf(`create log file dir error: "%s".`, filepath.Dir(name))
+	}
+	f, err := openLogFile(name)
+	if err != nil {
+		return nil, fmt.Errorf(`can not open log file: "%s".`, name)
+	}
+	return &lazyFile{name: name, f: f}, nil
+}
@@ -0,0 +1,151 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetup(t *testing.T) {
+	resetInit(t)
+	defer SetFormat(TextFormat)
+	defer staticFields.Store(Fields(nil))
+	defer func() {
+		rotation.Lock()
+		rotation.cfg = nil
+		rotation.Unlock()
+	}()
+	name := filepath.Join(t.TempDir(), "probe.log")
+	err := Setup(
+		OutputFile(name),
+		Rotation(RotationConfig{MaxSize: 1 << 20, MaxBackups: 2}),
+		Format(JSONFormat),
+		Async(0, time.Hour, BlockWhenFull),
+		StaticFields(Fields{"service": "probe"}),
+		Tag("probe"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Debug("hidden")
+	Info("started")
+	if b, _ := os.ReadFile(name); len(b) != 0 {
+		t.Errorf("log file before Flush = %q, want the entries queued", b)
+	}
+	Flush()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("log file = %q, want the info entry", b)
+	}
+	var e map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatalf("entry %q is not JSON: %v", lines[0], err)
+	}
+	if e["msg"] != "started" || e["service"] != "probe" || e["tag"] != "probe" {
+		t.Errorf("entry = %v, want the static fields and the tag", e)
+	}
+	if c := CurrentConfig(); c.File != name || c.Level != "info" {
+		t.Errorf("CurrentConfig() = %+v, want the file at info", c)
+	}
+	if r := activeRotatingFile(); r == nil {
+		t.Error("the log file does not rotate")
+	}
+}
+
+func TestSetupInvalid(t *testing.T) {
+	resetInit(t)
+	for _, opt := range []Option{MinLevel("loud"), Format("xml")} {
+		if err := Setup(opt); err == nil {
+			t.Errorf("Setup with an invalid option succeeded")
+		}
+	}
+	if err := Setup(OutputFile(filepath.Join(t.TempDir(), "missing", "\x00"))); err == nil {
+		t.Error("Setup with a file that cannot be created succeeded")
+	}
+}
+
+func TestSetupTwice(t *testing.T) {
+	resetInit(t)
+	var out bytes.Buffer
+	if err := Setup(OutputWriters(&out)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Setup(MinLevel("debug")); err == nil {
+		t.Error("second Setup succeeded")
+	}
+}
+
+func TestReinit(t *testing.T) {
+	resetInit(t)
+	defer staticFields.Store(Fields(nil))
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	if err := Setup(OutputFile(first), StaticFields(Fields{"service": "probe"})); err != nil {
+		t.Fatal(err)
+	}
+	Info("one")
+
+	// Nothing changes if the new file cannot be opened.
+	if err := Reinit(OutputFile(filepath.Join(dir, "missing", "\x00"))); err == nil {
+		t.Error("Reinit with a file that cannot be created succeeded")
+	}
+	Info("two")
+	if err := Reinit(OutputFile(second), MinLevel("debug"), Format(JSONFormat)); err != nil {
+		t.Fatal(err)
+	}
+	Debug("three")
+	Flush()
+
+	b, _ := os.ReadFile(first)
+	if s := string(b); !strings.Contains(s, "service=probe") || !strings.Contains(s, "two") || strings.Contains(s, "three") {
+		t.Errorf("first file = %q, want the entries before Reinit", s)
+	}
+	b, _ = os.ReadFile(second)
+	var e map[string]interface{}
+	if err := json.Unmarshal(b, &e); err != nil || e["msg"] != "three" || e["service"] != nil {
+		t.Errorf("second file = %q, want the debug entry as JSON without static fields", b)
+	}
+	if c := CurrentConfig(); c.File != second || c.Level != "debug" {
+		t.Errorf("CurrentConfig() = %+v after Reinit", c)
+	}
+	if err := Setup(); err == nil {
+		t.Error("Setup after Reinit succeeded")
+	}
+}
+
+func TestReinitUninitialized(t *testing.T) {
+	resetInit(t)
+	var out bytes.Buffer
+	if err := Reinit(OutputWriters(&out)); err != nil {
+		t.Fatal(err)
+	}
+	Info("started")
+	if !strings.Contains(out.String(), "started") {
+		t.Errorf("output = %q", out.String())
+	}
+	if err := Setup(); err == nil {
+		t.Error("Setup after Reinit succeeded")
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	var out bytes.Buffer
+	l := New(OutputWriters(&out), Format(JSONFormat), StaticFields(Fields{"service": "probe"}))
+	l.Info("started")
+	var e map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+		t.Fatalf("entry %q is not JSON: %v", out.String(), err)
+	}
+	if e["service"] != "probe" {
+		t.Errorf("entry = %v, want the static fields", e)
+	}
+}
@@ -0,0 +1,173 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// EventIDKey is the field identifying the kind of an event for SIEMs: the
+// Signature ID of CEF, the Event ID of LEEF and the MSGID of RFC 5424.
+const EventIDKey = "event_id"
+
+// DefaultVendor is the vendor written by CEFFormatter and LEEFFormatter
+// without Vendor.
+const DefaultVendor = "net-sniper"
+
+// CEFFormatter formats entries as ArcSight Common Event Format events,
+//
+//	CEF:0|Vendor|Product|Version|Signature ID|Name|Severity|Extension
+//
+// where the product is the tag unless set, the signature ID the event_id
+// field or the level name, the name the message and the severity 0 to 10
+// by level. The extension has the keys rt, the time in milliseconds since
+// the epoch, dvchost, dvcpid and msg, followed by the fields.
+type CEFFormatter struct {
+	// Vendor, Product and Version identify the device, DefaultVendor and
+	// the tag if empty.
+	Vendor, Product, Version string
+}
+
+// cefSeverity maps levels to CEF severities.
+var cefSeverity = map[log.Level]int{
+	log.PanicLevel: 10,
+	log.FatalLevel: 10,
+	log.ErrorLevel: 7,
+	log.WarnLevel:  5,
+	log.InfoLevel:  3,
+	log.DebugLevel: 1,
+	traceLevel:     0,
+}
+
+func (c *CEFFormatter) Format(entry *log.Entry) ([]byte, error) {
+	vendor, product, id := siemHeader(c.Vendor, c.Product, entry)
+	var b strings.Builder
+	b.WriteString("CEF:0")
+	for _, s := range []string{vendor, product, c.Version, id, entry.Message} {
+		b.WriteString("|")
+		b.WriteString(cefHeaderEscaper.Replace(s))
+	}
+	fmt.Fprintf(&b, "|%d|rt=%d dvchost=%s dvcpid=%d msg=%s",
+		cefSeverity[severityOf(entry.Level)],
+		entry.Time.UnixNano()/1e6,
+		cefEscaper.Replace(entryHost(entry.Data)),
+		pid,
+		cefEscaper.Replace(entry.Message))
+	for _, k := range siemKeys(entry.Data) {
+		b.WriteString(" ")
+		b.WriteString(siemKey(k))
+		b.WriteString("=")
+		b.WriteString(cefEscaper.Replace(siemValue(entry.Data, k)))
+	}
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
+
+// cefHeaderEscaper and cefEscaper escape the characters CEF requires to be
+// escaped in the prefix and in extension values, the event being a single
+// line.
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefEscaper       = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// LEEFFormatter formats entries as IBM QRadar Log Event Extended Format 1.0
+// events,
+//
+//	LEEF:1.0|Vendor|Product|Version|Event ID|attributes
+//
+// the tab separated attributes being devTime, sev, 1 to 10 by level, cat,
+// the level name, identHostName, pid and msg, followed by the fields. The
+// product and the event ID are as for CEFFormatter.
+type LEEFFormatter struct {
+	// Vendor, Product and Version identify the device, DefaultVendor and
+	// the tag if empty.
+	Vendor, Product, Version string
+}
+
+// leefTime is the layout of devTime, LEEF's default devTimeFormat
+// MMM dd yyyy HH:mm:ss.SSS zzz.
+const leefTime = "Jan 02 2006 15:04:05.000 MST"
+
+func (c *LEEFFormatter) Format(entry *log.Entry) ([]byte, error) {
+	vendor, product, id := siemHeader(c.Vendor, c.Product, entry)
+	var b strings.Builder
+	b.WriteString("LEEF:1.0")
+	for _, s := range []string{vendor, product, c.Version, id} {
+		b.WriteString("|")
+		b.WriteString(cefHeaderEscaper.Replace(s))
+	}
+	severity := cefSeverity[severityOf(entry.Level)]
+	if severity == 0 {
+		severity = 1
+	}
+	fmt.Fprintf(&b, "|devTime=%s\tsev=%d\tcat=%s\tidentHostName=%s\tpid=%d\tmsg=%s",
+		entry.Time.Format(leefTime),
+		severity,
+		severityName(entry.Level),
+		leefEscaper.Replace(entryHost(entry.Data)),
+		pid,
+		leefEscaper.Replace(entry.Message))
+	for _, k := range siemKeys(entry.Data) {
+		b.WriteString("\t")
+		b.WriteString(siemKey(k))
+		b.WriteString("=")
+		b.WriteString(leefEscaper.Replace(siemValue(entry.Data, k)))
+	}
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
+
+// leefEscaper escapes the attribute delimiter and line breaks in LEEF
+// attribute values.
+var leefEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// siemHeader returns the vendor, the product and the event ID of entry.
+func siemHeader(vendor, product string, entry *log.Entry) (string, string, string) {
+	if vendor == "" {
+		vendor = DefaultVendor
+	}
+	if product == "" {
+		product = appName(entry.Data)
+	}
+	id, _ := entry.Data[EventIDKey].(string)
+	if id == "" {
+		id = severityName(entry.Level)
+	}
+	return vendor, product, id
+}
+
+// siemKeys returns the sorted fields of an event, without the event ID of
+// the header.
+func siemKeys(fields log.Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if !isReserved(k) && k != EventIDKey {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// siemKey makes k a valid extension key, of letters, digits and
+// underscores.
+func siemKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, k)
+}
+
+// siemValue renders the field k of an event.
+func siemValue(fields log.Fields, k string) string {
+	value := fields[k]
+	if s, ok := value.(string); ok && k == LayersKey {
+		value = parseLayers(s)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCEFFormatter(t *testing.T) {
+	defer SetTag(tag)
+	SetTag("/usr/bin/sniper")
+
+	entry := syslogEntry()
+	entry.Data[EventIDKey] = "link-101"
+	entry.Data["dst ip"] = "10.0.0.1"
+	entry.Message = "link|flap"
+	b, err := (&CEFFormatter{Version: "2.1"}).Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `CEF:0|net-sniper|sniper|2.1|link-101|link\|flap|5|rt=1488369600000 dvchost=probe-7 dvcpid=`
+	if !strings.HasPrefix(string(b), want) {
+		t.Errorf("event %q does not start with %q", b, want)
+	}
+	want = ` msg=link|flap dst_ip=10.0.0.1 iface=eth1 reason=carrier "lost"]` + "\n"
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("event %q does not end with %q", b, want)
+	}
+
+	entry = syslogEntry()
+	entry.Data["query"] = "a=b\nc\\d"
+	b, _ = (&CEFFormatter{Vendor: "acme", Product: "probe"}).Format(entry)
+	if !strings.HasPrefix(string(b), "CEF:0|acme|probe||warning|link flap|5|") {
+		t.Errorf("event %q does not have the vendor, product and level", b)
+	}
+	if !strings.Contains(string(b), ` query=a\=b\nc\\d `) {
+		t.Errorf("event %q does not escape the extension value", b)
+	}
+}
+
+func TestLEEFFormatter(t *testing.T) {
+	defer SetTag(tag)
+	SetTag("/usr/bin/sniper")
+
+	entry := syslogEntry()
+	entry.Data["note"] = "a\tb"
+	b, err := (&LEEFFormatter{Version: "2.1"}).Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "LEEF:1.0|net-sniper|sniper|2.1|warning|devTime=Mar 01 2017 12:00:00.000 UTC\tsev=5\tcat=warning\tidentHostName=probe-7\tpid="
+	if !strings.HasPrefix(string(b), want) {
+		t.Errorf("event %q does not start with %q", b, want)
+	}
+	want = "\tmsg=link flap\tiface=eth1\tnote=a\\tb\treason=carrier \"lost\"]\n"
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("event %q does not end with %q", b, want)
+	}
+}
+
+func TestSIEMFormats(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer SetFormat(TextFormat)
+
+	for format, prefix := range map[string]string{
+		CEFFormat:     "CEF:0|",
+		LEEFFormat:    "LEEF:1.0|",
+		RFC5424Format: "<12>1 ",
+	} {
+		buf.Reset()
+		if err := SetFormat(format); err != nil {
+			t.Fatal(err)
+		}
+		WithFields(Fields{"iface": "eth1"}).Warning("link flap")
+		if !strings.HasPrefix(buf.String(), prefix) || !strings.Contains(buf.String(), "eth1") {
+			t.Errorf("%s format wrote %q", format, buf.String())
+		}
+		if _, err := ExportParsingRules(GrokRules); err == nil {
+			t.Errorf("ExportParsingRules for the %s format = nil error", format)
+		}
+	}
+}
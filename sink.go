@@ -0,0 +1,239 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultSinkQueue is the number of entries queued for a Sink while it is
+// slow or its destination unreachable.
+const DefaultSinkQueue = 10000
+
+// Sink delivers entries to a destination the package has no output for,
+// such as a log pipeline reached without a sidecar tailer. KafkaSink and
+// FluentSink are sinks; others are plugged in with AddSink. Write is
+// called from a single goroutine at a time and may block; an error counts
+// the entry as failed in Stats and in the delivery reports.
+type Sink interface {
+	Write(e *Entry) error
+	Close() error
+}
+
+// ContextSink is implemented by sinks whose writes can be cancelled. The
+// context passed to WriteContext is cancelled when the sink is removed or
+// the package logger closed, so that a write hanging on a slow broker does
+// not hold up the exit of the process.
+type ContextSink interface {
+	Sink
+	WriteContext(ctx context.Context, e *Entry) error
+}
+
+// AddSink delivers every entry of the package logger to s until remove is
+// called, which closes s. Entries are queued and written by a background
+// goroutine, so a slow sink never blocks logging; when the queue is full,
+// new entries are dropped. Flush waits for the queue to drain, and Close
+// closes s like every output. Entries still queued when s is removed are
+// counted as dropped.
+func AddSink(s Sink) (remove func() error) {
+	q := &sinkQueue{
+		sink:  s,
+		queue: make(chan Entry, DefaultSinkQueue),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	if q.sync = synchronous(); !q.sync {
+		go q.run()
+	}
+	addHook(q)
+	addFlusher(q)
+	return q.Close
+}
+
+// sinkQueue feeds a Sink from a logrus hook.
+type sinkQueue struct {
+	sink    Sink
+	queue   chan Entry
+	pending int64
+	dropped uint64
+
+	// ctx is cancelled by Close, aborting the write in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// sync is set in the test mode: entries are written by Fire, without
+	// the queue and its goroutine.
+	sync    bool
+	failing bool
+
+	// mu orders the entries queued by Fire before Close.
+	mu     sync.Mutex
+	closed bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Name returns the name of the sink.
+func (q *sinkQueue) Name() string {
+	return outputName(q.sink)
+}
+
+// Levels implements logrus.Hook.
+func (q *sinkQueue) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook. It never blocks.
+func (q *sinkQueue) Fire(entry *log.Entry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	if q.sync {
+		e := toEntry(entry)
+		q.deliver(&e)
+		return nil
+	}
+	atomic.AddInt64(&q.pending, 1)
+	select {
+	case q.queue <- toEntry(entry):
+	default:
+		atomic.AddInt64(&q.pending, -1)
+		atomic.AddUint64(&q.dropped, 1)
+	}
+	return nil
+}
+
+// QueueDepth implements QueueReporter.
+func (q *sinkQueue) QueueDepth() int {
+	return len(q.queue)
+}
+
+// QueueLatency implements QueueReporter; the queue does not measure it.
+func (q *sinkQueue) QueueLatency() time.Duration {
+	return 0
+}
+
+// Flush waits until every queued entry has been written or given up on.
+func (q *sinkQueue) Flush() error {
+	return q.FlushContext(context.Background())
+}
+
+// FlushContext is Flush giving up when ctx is done.
+func (q *sinkQueue) FlushContext(ctx context.Context) error {
+	return waitPending(ctx, &q.pending, q.done, "sink closed with entries pending")
+}
+
+// Close stops the queue, cancelling the write in progress of a
+// ContextSink, removes it from the package logger and closes the sink.
+// Entries still queued are discarded and counted as dropped; call Flush
+// first to write them.
+func (q *sinkQueue) Close() error {
+	q.closeOnce.Do(func() {
+		removeHook(q)
+		removeFlusher(q)
+		q.mu.Lock()
+		q.closed = true
+		q.mu.Unlock()
+
+		q.cancel()
+		close(q.stop)
+		if q.sync {
+			close(q.done)
+		}
+		<-q.done
+		for len(q.queue) > 0 {
+			<-q.queue
+			atomic.AddInt64(&q.pending, -1)
+			atomic.AddUint64(&q.dropped, 1)
+		}
+		q.closeErr = q.sink.Close()
+	})
+	return q.closeErr
+}
+
+func (q *sinkQueue) run() {
+	defer close(q.done)
+
+	for {
+		var e Entry
+		select {
+		case e = <-q.queue:
+		case <-q.stop:
+			return
+		}
+		q.deliver(&e)
+		atomic.AddInt64(&q.pending, -1)
+	}
+}
+
+// deliver writes e to the sink and reports the outcome.
+func (q *sinkQueue) deliver(e *Entry) {
+	if err := q.write(e); err != nil {
+		// Only report the transition to failing, a dead sink would
+		// otherwise flood the self-log.
+		if !q.failing {
+			q.failing = true
+			reportError(fmt.Errorf("output %s: %v", q.Name(), err))
+		}
+		reportDelivery(DeliveryReport{Output: q.Name(), Failed: 1, Err: err})
+	} else {
+		if q.failing {
+			q.failing = false
+			reportError(fmt.Errorf("output %s recovered", q.Name()))
+		}
+		reportDelivery(DeliveryReport{Output: q.Name(), Delivered: 1})
+	}
+}
+
+// write writes e to the sink, cancellably if it is a ContextSink.
+func (q *sinkQueue) write(e *Entry) error {
+	if s, ok := q.sink.(ContextSink); ok {
+		return s.WriteContext(q.ctx, e)
+	}
+	return q.sink.Write(e)
+}
+
+// entryRecord returns e with the keys of JSONFormatter, for sinks shipping
+// entries as documents.
+func entryRecord(e *Entry) map[string]interface{} {
+	r := make(map[string]interface{}, len(e.Fields)+len(jsonKeys))
+	for k, v := range e.Fields {
+		if jsonKeys[k] {
+			k = "fields." + k
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		r[k] = v
+	}
+	r["time"] = jsonTime(e.Time)
+	r["hostname"] = e.Host
+	r["level"] = e.Level
+	r["tag"] = e.Tag
+	r["pid"] = pid
+	r["file"] = e.File
+	r["line"] = e.Line
+	r["msg"] = e.Message
+	return r
+}
+
+// entryJSON returns e as a JSON object in the format of JSONFormatter,
+// without the trailing newline.
+func entryJSON(e *Entry) ([]byte, error) {
+	b, err := json.Marshal(entryRecord(e))
+	if err != nil {
+		return nil, fmt.Errorf("marshal entry to JSON: %v", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,216 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink is a Sink keeping the entries written to it.
+type recordSink struct {
+	mu      sync.Mutex
+	entries []Entry
+	err     error
+	closed  bool
+}
+
+func (s *recordSink) Write(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.entries = append(s.entries, *e)
+	return nil
+}
+
+func (s *recordSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+func TestAddSink(t *testing.T) {
+	SetOutputs(nopWriter{})
+	defer SetOutputs(os.Stderr)
+
+	s := &recordSink{}
+	remove := AddSink(s)
+	With("iface", "eth1").Warning("link flap")
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	if len(s.entries) != 1 || s.entries[0].Message != "link flap" || s.entries[0].Level != "warning" || s.entries[0].Fields["iface"] != "eth1" {
+		t.Errorf("sink received %+v", s.entries)
+	}
+	s.mu.Unlock()
+
+	if err := remove(); err != nil {
+		t.Fatal(err)
+	}
+	Warning("after removal")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed || len(s.entries) != 1 {
+		t.Errorf("removed sink closed = %v with %d entries, want closed with 1", s.closed, len(s.entries))
+	}
+}
+
+func TestAddSinkFailing(t *testing.T) {
+	SetOutputs(nopWriter{})
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	var reports []DeliveryReport
+	var mu sync.Mutex
+	SetDeliveryCallback(func(r DeliveryReport) {
+		mu.Lock()
+		reports = append(reports, r)
+		mu.Unlock()
+	})
+	defer SetDeliveryCallback(nil)
+
+	s := &recordSink{err: errors.New("broker down")}
+	remove := AddSink(s)
+	defer remove()
+	Error("lost")
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) != 1 || reports[0].Failed != 1 || reports[0].Err == nil {
+		t.Errorf("delivery reports = %+v, want one failure", reports)
+	}
+}
+
+// blockingSink is a ContextSink whose writes last until their context is
+// cancelled.
+type blockingSink struct {
+	started chan struct{}
+	err     chan error
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{started: make(chan struct{}, 1), err: make(chan error, 1)}
+}
+
+func (s *blockingSink) Write(e *Entry) error {
+	return s.WriteContext(context.Background(), e)
+}
+
+func (s *blockingSink) WriteContext(ctx context.Context, e *Entry) error {
+	s.started <- struct{}{}
+	<-ctx.Done()
+	s.err <- ctx.Err()
+	return ctx.Err()
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+func TestAddSinkRemoveCancels(t *testing.T) {
+	SetOutputs(nopWriter{})
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	s := newBlockingSink()
+	remove := AddSink(s)
+	Info("hangs")
+	<-s.started
+	removed := make(chan struct{})
+	go func() {
+		remove()
+		close(removed)
+	}()
+	select {
+	case <-removed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("remove waited for the write in progress")
+	}
+	if err := <-s.err; err != context.Canceled {
+		t.Errorf("write ended with %v, want %v", err, context.Canceled)
+	}
+}
+
+// stuckSink is a ContextSink whose writes last until their context is
+// cancelled, closing started on the first one.
+type stuckSink struct {
+	once    sync.Once
+	started chan struct{}
+}
+
+func (s *stuckSink) Write(e *Entry) error {
+	return s.WriteContext(context.Background(), e)
+}
+
+func (s *stuckSink) WriteContext(ctx context.Context, e *Entry) error {
+	s.once.Do(func() { close(s.started) })
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *stuckSink) Close() error {
+	return nil
+}
+
+func TestAddSinkRemoveBacklog(t *testing.T) {
+	SetOutputs(nopWriter{})
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	s := &stuckSink{started: make(chan struct{})}
+	remove := AddSink(s)
+	Info("hangs")
+	<-s.started
+	Info("queued")
+	Info("queued")
+	remove()
+
+	if err := Flush(); err != nil {
+		t.Errorf("Flush after removing a sink with a backlog: %v", err)
+	}
+	list, _ := hooks.list.Load().([]registeredHook)
+	for _, r := range list {
+		if q, ok := r.h.(*sinkQueue); ok && q.sink == Sink(s) {
+			t.Error("the hook of the removed sink is still registered")
+		}
+	}
+}
+
+func TestCloseContext(t *testing.T) {
+	SetOutputs(nopWriter{})
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	s := newBlockingSink()
+	AddSink(s)
+	Info("hangs")
+	<-s.started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := CloseContext(ctx); err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("CloseContext() = %v, want the deadline", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("CloseContext took %s", d)
+	}
+	select {
+	case <-s.err:
+	case <-time.After(5 * time.Second):
+		t.Error("the write in progress was not cancelled")
+	}
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// ClockSkewKey is the field holding the estimated clock skew of the host an
+// ingested entry comes from.
+const ClockSkewKey = "clock_skew"
+
+// skewSamples is the number of recent entries per host the estimate is
+// based on.
+const skewSamples = 64
+
+// maxSkewHosts bounds the number of hosts tracked; a new host beyond it
+// replaces the one idle the longest. Hosts idle for skewIdle are forgotten.
+const (
+	maxSkewHosts = 4096
+	skewIdle     = time.Hour
+)
+
+// skewEstimator tracks the difference between the receive time and the
+// entry time of each source. Transport and queueing only ever add delay,
+// so the minimum over recent entries is the best estimate of the skew.
+type skewEstimator struct {
+	mu    sync.Mutex
+	hosts map[string]*skewWindow
+}
+
+type skewWindow struct {
+	samples [skewSamples]time.Duration
+	n, next int
+	// seen is the receive time of the last entry.
+	seen time.Time
+}
+
+var skews = &skewEstimator{hosts: make(map[string]*skewWindow)}
+
+// observe records an entry of host timestamped t and received at now and
+// returns the current skew estimate of host.
+func (s *skewEstimator) observe(host string, t, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.hosts[host]
+	if !ok {
+		if len(s.hosts) >= maxSkewHosts {
+			s.evict(now)
+		}
+		w = &skewWindow{}
+		s.hosts[host] = w
+	}
+	w.seen = now
+	w.samples[w.next] = now.Sub(t)
+	w.next = (w.next + 1) % skewSamples
+	if w.n < skewSamples {
+		w.n++
+	}
+	return w.min()
+}
+
+// evict forgets the hosts idle for skewIdle at now or, if there are none,
+// the host idle the longest.
+func (s *skewEstimator) evict(now time.Time) {
+	var idlest string
+	var seen time.Time
+	for host, w := range s.hosts {
+		if now.Sub(w.seen) >= skewIdle {
+			delete(s.hosts, host)
+		} else if idlest == "" || w.seen.Before(seen) {
+			idlest, seen = host, w.seen
+		}
+	}
+	if len(s.hosts) >= maxSkewHosts {
+		delete(s.hosts, idlest)
+	}
+}
+
+func (w *skewWindow) min() time.Duration {
+	m := w.samples[0]
+	for _, d := range w.samples[1:w.n] {
+		if d < m {
+			m = d
+		}
+	}
+	return m
+}
+
+// ClockSkews returns the estimated clock skew of every host entries were
+// ingested from: how far the host's clock is behind the local one. Adding
+// it to the time of an entry places the entry on the local timeline.
+// Ingest attaches the estimate to every entry carrying a time and a host as
+// the clock_skew field. Hosts no entry was ingested from for an hour are
+// left out.
+func ClockSkews() map[string]time.Duration {
+	skews.mu.Lock()
+	defer skews.mu.Unlock()
+
+	now := time.Now()
+	m := make(map[string]time.Duration, len(skews.hosts))
+	for host, w := range skews.hosts {
+		if now.Sub(w.seen) >= skewIdle {
+			delete(skews.hosts, host)
+			continue
+		}
+		m[host] = w.min()
+	}
+	return m
+}
@@ -0,0 +1,76 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSkewEstimator(t *testing.T) {
+	s := &skewEstimator{hosts: make(map[string]*skewWindow)}
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// The host's clock is 2s behind; entries arrive after 10 to 300ms.
+	for i, delay := range []time.Duration{300, 10, 120} {
+		got := s.observe("probe-7", now.Add(-2*time.Second), now.Add(delay*time.Millisecond))
+		want := []time.Duration{2300, 2010, 2010}[i] * time.Millisecond
+		if got != want {
+			t.Errorf("estimate after sample %d = %s, want %s", i, got, want)
+		}
+	}
+
+	// Old samples age out of the window.
+	for i := 0; i < skewSamples; i++ {
+		s.observe("probe-7", now, now.Add(50*time.Millisecond))
+	}
+	if got := s.observe("probe-7", now, now.Add(50*time.Millisecond)); got != 50*time.Millisecond {
+		t.Errorf("estimate after the window = %s, want 50ms", got)
+	}
+}
+
+func TestSkewEstimatorBound(t *testing.T) {
+	s := &skewEstimator{hosts: make(map[string]*skewWindow)}
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < maxSkewHosts+10; i++ {
+		s.observe(fmt.Sprint("probe-", i), now, now.Add(time.Duration(i)*time.Millisecond))
+	}
+	if len(s.hosts) != maxSkewHosts {
+		t.Errorf("%d hosts tracked, want %d", len(s.hosts), maxSkewHosts)
+	}
+	if _, ok := s.hosts["probe-0"]; ok {
+		t.Error("the host idle the longest was kept")
+	}
+
+	// Once idle, every host is forgotten to make room.
+	later := now.Add(2 * skewIdle)
+	s.observe("probe-new", later, later)
+	if len(s.hosts) != 1 {
+		t.Errorf("%d hosts tracked after an idle hour, want 1", len(s.hosts))
+	}
+}
+
+func TestIngestClockSkew(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	err := Ingest(Entry{
+		Time:    time.Now().Add(-time.Hour),
+		Level:   "info",
+		Host:    "skewed-host",
+		Message: "hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "clock_skew=1h0m0.") {
+		t.Errorf("output %q does not carry the skew", buf.String())
+	}
+	if d := ClockSkews()["skewed-host"]; d < time.Hour || d > time.Hour+time.Second {
+		t.Errorf("ClockSkews()[skewed-host] = %s, want about 1h", d)
+	}
+}
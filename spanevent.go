@@ -0,0 +1,85 @@
+package log
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ctxKey is the reserved key carrying the context of an entry logged
+// through InfoCtx and the like while span events are enabled.
+const ctxKey = "_ctx"
+
+// SpanEventRecorder attaches e to the span active in ctx, if any. It is
+// called with the logger locked and must be fast. For OpenTelemetry:
+//
+//	log.SetSpanEvents("warning", func(ctx context.Context, e log.Entry) {
+//		span := trace.SpanFromContext(ctx)
+//		if !span.IsRecording() {
+//			return
+//		}
+//		attrs := []attribute.KeyValue{attribute.String("level", e.Level)}
+//		for k, v := range e.Fields {
+//			attrs = append(attrs, attribute.String(k, fmt.Sprint(v)))
+//		}
+//		span.AddEvent(e.Message, trace.WithAttributes(attrs...))
+//	})
+type SpanEventRecorder func(ctx context.Context, e Entry)
+
+var spanEvents = struct {
+	sync.Mutex
+	level log.Level
+	fn    SpanEventRecorder
+}{}
+
+func init() {
+	addHook(spanEventHook{})
+}
+
+// SetSpanEvents passes the entries at level or more severe logged with a
+// context, through WarningCtx and the like, to fn in addition to writing
+// them, so that a trace shows the errors of its spans inline. Entries of
+// Loggers created by New are not passed. A nil fn disables span events.
+func SetSpanEvents(level string, fn SpanEventRecorder) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	spanEvents.Lock()
+	spanEvents.level, spanEvents.fn = lvl, fn
+	spanEvents.Unlock()
+	return nil
+}
+
+// ctxData returns the fields of an entry logged with ctx.
+func ctxData(ctx context.Context) log.Fields {
+	data := FromContext(ctx).data()
+	spanEvents.Lock()
+	on := spanEvents.fn != nil
+	spanEvents.Unlock()
+	if on {
+		data[ctxKey] = ctx
+	}
+	return data
+}
+
+type spanEventHook struct{}
+
+func (spanEventHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (spanEventHook) Fire(entry *log.Entry) error {
+	ctx, ok := entry.Data[ctxKey].(context.Context)
+	if !ok {
+		return nil
+	}
+	spanEvents.Lock()
+	level, fn := spanEvents.level, spanEvents.fn
+	spanEvents.Unlock()
+	if fn != nil && entry.Level <= level {
+		fn(ctx, toEntry(entry))
+	}
+	return nil
+}
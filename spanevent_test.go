@@ -0,0 +1,43 @@
+package log
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+type spanKey struct{}
+
+func TestSpanEvents(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+
+	var events []string
+	err := SetSpanEvents("warning", func(ctx context.Context, e Entry) {
+		if span, ok := ctx.Value(spanKey{}).(string); ok {
+			events = append(events, span+" "+e.Level+" "+e.Message+" "+e.Fields["request_id"].(string))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetSpanEvents("warning", nil)
+
+	ctx := context.WithValue(NewContext(context.Background(), Fields{"request_id": "r1"}), spanKey{}, "s1")
+	InfoCtx(ctx, "started")
+	WarningCtx(ctx, "slow backend")
+	ErrorCtx(ctx, "backend down")
+	Error("no context")
+
+	if got := strings.Join(events, "; "); got != "s1 warning slow backend r1; s1 error backend down r1" {
+		t.Errorf("events = %q", got)
+	}
+	if !strings.Contains(out.String(), "started") || strings.Contains(out.String(), ctxKey) {
+		t.Errorf("output = %q, want every entry without the context", out.String())
+	}
+	if err := SetSpanEvents("loud", nil); err == nil {
+		t.Error("SetSpanEvents accepted an invalid level")
+	}
+}
@@ -0,0 +1,124 @@
+package log
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// StackKey is the field holding the stack of the goroutine that logged an
+// entry, innermost call first.
+const StackKey = "stack"
+
+// DefaultStackDepth is the number of frames of a stack trace unless
+// SetStackTraces sets another depth.
+const DefaultStackDepth = 32
+
+// stackLevel is the least severe level whose entries carry a stack trace,
+// stackDepth the frames recorded and stackTraces zero while disabled.
+var (
+	stackLevel  = uint32(log.ErrorLevel)
+	stackDepth  = int32(DefaultStackDepth)
+	stackTraces int32
+)
+
+// SetStackTraces attaches the stack of the logging goroutine, at most depth
+// frames or DefaultStackDepth for a non-positive depth, as the stack field
+// of the entries at level or more severe, e.g. "error" for errors and
+// fatal entries. An empty level disables stack traces, which is the
+// default. Entries already carrying a stack field keep theirs.
+func SetStackTraces(level string, depth int) error {
+	if level == "" {
+		atomic.StoreInt32(&stackTraces, 0)
+		return nil
+	}
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	if depth <= 0 {
+		depth = DefaultStackDepth
+	}
+	atomic.StoreUint32(&stackLevel, uint32(lvl))
+	atomic.StoreInt32(&stackDepth, int32(depth))
+	atomic.StoreInt32(&stackTraces, 1)
+	return nil
+}
+
+// ErrorStack logs msg with severity ERROR, the error in the error field,
+// the errors it wraps in the error_chain field and the stack of the caller
+// in the stack field, whether SetStackTraces is enabled or not:
+//
+//	if err := db.Ping(); err != nil {
+//		log.ErrorStack(err, "database unreachable")
+//	}
+func ErrorStack(err error, msg string) {
+	emit(log.ErrorLevel, captureCaller(log.ErrorLevel, 1), msg, errorStackFields(nil, err, stack(1, stackFrames())))
+}
+
+// ErrorStack is like the package-level ErrorStack but adds the fields of l.
+func (l *Logger) ErrorStack(err error, msg string) {
+	if l.enabled(log.ErrorLevel) {
+		emit(log.ErrorLevel, captureCaller(log.ErrorLevel, 1), msg, errorStackFields(l.data(), err, stack(1, stackFrames())))
+	}
+}
+
+// errorStackFields adds an error, its chain and a stack to fields.
+func errorStackFields(fields log.Fields, err error, st string) log.Fields {
+	if fields == nil {
+		fields = make(log.Fields, 3)
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		if chain := panicDetails(err); chain != "" {
+			fields["error_chain"] = chain
+		}
+	}
+	fields[StackKey] = st
+	return fields
+}
+
+// withStack adds the stack trace of the caller skip frames above the
+// caller of withStack to fields if entries at level carry one.
+func withStack(level log.Level, skip int, fields log.Fields) log.Fields {
+	if atomic.LoadInt32(&stackTraces) == 0 || uint32(level) > atomic.LoadUint32(&stackLevel) {
+		return fields
+	}
+	if _, ok := fields[StackKey]; ok {
+		return fields
+	}
+	if fields == nil {
+		fields = make(log.Fields, 1)
+	}
+	fields[StackKey] = stack(skip+1, stackFrames())
+	return fields
+}
+
+// stackFrames returns the depth set with SetStackTraces.
+func stackFrames() int {
+	return int(atomic.LoadInt32(&stackDepth))
+}
+
+// stack formats at most depth frames of the stack of the calling
+// goroutine, starting skip frames above the caller of stack, like
+// runtime/debug.Stack: the function, then the file and line indented.
+func stack(skip, depth int) string {
+	pcs := make([]uintptr, depth)
+	n := runtime.Callers(skip+2, pcs)
+	if n == 0 {
+		return ""
+	}
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetStackTraces(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+	if err := SetStackTraces("error", 2); err != nil {
+		t.Fatal(err)
+	}
+	defer SetStackTraces("", 0)
+
+	Warning("no stack")
+	Error("with stack")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("output = %q", buf.String())
+	}
+	if strings.Contains(lines[0], `"stack"`) {
+		t.Errorf("warning %s carries a stack", lines[0])
+	}
+	var e Entry
+	if err := e.UnmarshalJSON([]byte(lines[1])); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := e.Fields[StackKey].(string)
+	if !strings.HasPrefix(st, "github.com/net-sniper/go-log.TestSetStackTraces\n\t") || !strings.Contains(st, "stack_test.go:") {
+		t.Errorf("stack = %q, want the test function first", st)
+	}
+	if n := strings.Count(st, "\n\t"); n != 2 {
+		t.Errorf("stack has %d frames, want the depth of 2:\n%s", n, st)
+	}
+
+	if err := SetStackTraces("loud", 0); err == nil {
+		t.Error("SetStackTraces accepted an invalid level")
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetSelfLog(io.Discard)
+	defer SetSelfLog(os.Stderr)
+
+	err := fmt.Errorf("load config: %w", os.ErrNotExist)
+	With("path", "/etc/probe.conf").ErrorStack(err, "startup failed")
+	got := buf.String()
+	for _, want := range []string{
+		"startup failed",
+		`error="load config: file does not exist"`,
+		`error_chain="[\"*errors.errorString: file does not exist\"]"`,
+		"path=/etc/probe.conf",
+		"stack=\"github.com/net-sniper/go-log.TestErrorStack\\n\\t",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+
+	buf.Reset()
+	ErrorStack(errors.New("boom"), "plain")
+	if !strings.Contains(buf.String(), "stack_test.go:") {
+		t.Errorf("output %q lacks the caller", buf.String())
+	}
+}
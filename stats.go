@@ -0,0 +1,168 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Statistics are counters describing the health of the logging pipeline.
+type Statistics struct {
+	// Entries counts the entries written by the package logger and the
+	// Loggers created by New, by level name.
+	Entries map[string]uint64
+	// WriteErrors is the number of failed writes to an output.
+	WriteErrors uint64
+	// Fallback is the number of failed writes to a log file written to
+	// stderr instead.
+	Fallback uint64
+	// RateLimited is the number of entries dropped by SetRateLimit.
+	RateLimited uint64
+	// Sampled is the number of entries dropped by SetSampling.
+	Sampled uint64
+	// Filtered is the number of entries dropped by AddFilter.
+	Filtered uint64
+	// Deduplicated is the number of duplicate entries dropped by
+	// SetDedup.
+	Deduplicated uint64
+	// Repeated is the number of repetitions collapsed by
+	// SetRepeatCompaction.
+	Repeated uint64
+	// OverBudget is the number of entries dropped by
+	// SetComponentBudget.
+	OverBudget uint64
+	// Truncated is the number of messages truncated by SetMessagePolicy.
+	Truncated uint64
+	// Delivered and DeliveryFailed count the entries acknowledged by and
+	// given up on by remote outputs, see SetDeliveryCallback.
+	Delivered      uint64
+	DeliveryFailed uint64
+	// Received and Rejected count the entries accepted and refused by
+	// Receivers.
+	Received uint64
+	Rejected uint64
+	// SubscriberDropped counts the entries not delivered to a Subscribe
+	// channel because it was full.
+	SubscriberDropped uint64
+	// Rotations is the number of log files rotated, see SetRotation.
+	Rotations uint64
+	// Rotation describes the file opened by Init with a rotation, nil
+	// without one.
+	Rotation *RotationState
+	// Outputs holds the metrics of every output of the package logger.
+	Outputs []OutputStats
+	// Components holds the entry volume of every component.
+	Components []ComponentStats
+}
+
+// OutputStats are the metrics of a single output.
+type OutputStats struct {
+	// Name describes the output, e.g. the path of a file.
+	Name string
+	// Writes and Errors count the successful and failed writes.
+	Writes uint64
+	Errors uint64
+	// Bytes is the number of bytes written.
+	Bytes uint64
+	// WriteTime is the total and MaxWriteTime the longest time spent in
+	// the output's Write method.
+	WriteTime    time.Duration
+	MaxWriteTime time.Duration
+	// QueueDepth and QueueLatency are reported by outputs implementing
+	// QueueReporter and are zero for synchronous outputs.
+	QueueDepth   int
+	QueueLatency time.Duration
+}
+
+// QueueReporter is implemented by outputs that queue entries before
+// writing them.
+type QueueReporter interface {
+	// QueueDepth returns the number of entries waiting to be written.
+	QueueDepth() int
+	// QueueLatency returns the recent average time from enqueueing an
+	// entry to writing it.
+	QueueLatency() time.Duration
+}
+
+var stats Statistics
+
+// entryCounts are the entries written by severity, indexed by log.Level.
+var entryCounts [traceLevel + 1]uint64
+
+// countWritten counts an entry that passed the pipeline, whichever path
+// writes it: logrus, the reorder buffer or a batch.
+func countWritten(level log.Level) {
+	atomic.AddUint64(&entryCounts[severityOf(level)], 1)
+}
+
+// Stats returns a snapshot of the pipeline counters.
+func Stats() Statistics {
+	s := Statistics{
+		WriteErrors: atomic.LoadUint64(&stats.WriteErrors),
+		Fallback:    atomic.LoadUint64(&stats.Fallback),
+		RateLimited: atomic.LoadUint64(&stats.RateLimited),
+		Sampled:     atomic.LoadUint64(&stats.Sampled),
+		Filtered:    atomic.LoadUint64(&stats.Filtered),
+
+		Deduplicated: atomic.LoadUint64(&stats.Deduplicated),
+		Repeated:     atomic.LoadUint64(&stats.Repeated),
+		OverBudget:   atomic.LoadUint64(&stats.OverBudget),
+		Truncated:    atomic.LoadUint64(&stats.Truncated),
+
+		Delivered:      atomic.LoadUint64(&stats.Delivered),
+		DeliveryFailed: atomic.LoadUint64(&stats.DeliveryFailed),
+
+		Received: atomic.LoadUint64(&stats.Received),
+		Rejected: atomic.LoadUint64(&stats.Rejected),
+
+		SubscriberDropped: atomic.LoadUint64(&stats.SubscriberDropped),
+
+		Rotations: atomic.LoadUint64(&stats.Rotations),
+	}
+	s.Entries = make(map[string]uint64, len(entryCounts))
+	for _, lvl := range allLevels {
+		s.Entries[levelName(lvl)] = atomic.LoadUint64(&entryCounts[lvl])
+	}
+
+	current.Lock()
+	mw := current.output
+	current.Unlock()
+	if mw != nil {
+		s.Outputs = mw.stats()
+	}
+	if r := activeRotatingFile(); r != nil {
+		st := r.state()
+		s.Rotation = &st
+	}
+	s.Components = componentStats(time.Now())
+	return s
+}
+
+// outputMetrics are the counters kept by multiWriter for one output.
+type outputMetrics struct {
+	writes       uint64
+	errors       uint64
+	bytes        uint64
+	writeTime    int64
+	maxWriteTime int64
+	// started is the start, in Unix nanoseconds, of the write in progress
+	// or zero. It is watched by SetWatchdog.
+	started int64
+}
+
+func (m *outputMetrics) record(n int, err error, elapsed time.Duration) {
+	if err != nil {
+		atomic.AddUint64(&m.errors, 1)
+	} else {
+		atomic.AddUint64(&m.writes, 1)
+	}
+	atomic.AddUint64(&m.bytes, uint64(n))
+	atomic.AddInt64(&m.writeTime, int64(elapsed))
+	for {
+		max := atomic.LoadInt64(&m.maxWriteTime)
+		if int64(elapsed) <= max || atomic.CompareAndSwapInt64(&m.maxWriteTime, max, int64(elapsed)) {
+			break
+		}
+	}
+}
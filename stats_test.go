@@ -0,0 +1,34 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+type queuedWriter struct{ bytes.Buffer }
+
+func (*queuedWriter) QueueDepth() int             { return 3 }
+func (*queuedWriter) QueueLatency() time.Duration { return time.Millisecond }
+
+func TestStatsOutputs(t *testing.T) {
+	var plain bytes.Buffer
+	queued := &queuedWriter{}
+	SetOutputs(&plain, queued)
+	defer SetOutputs(os.Stderr)
+
+	Info("counted")
+	Info("counted")
+
+	outputs := Stats().Outputs
+	if len(outputs) != 2 {
+		t.Fatalf("Stats().Outputs has %d entries, want 2", len(outputs))
+	}
+	if outputs[0].Writes != 2 || outputs[0].Bytes != uint64(plain.Len()) {
+		t.Errorf("plain output stats = %+v, want 2 writes of %d bytes", outputs[0], plain.Len())
+	}
+	if outputs[1].QueueDepth != 3 || outputs[1].QueueLatency != time.Millisecond {
+		t.Errorf("queued output stats = %+v, want depth 3 and latency 1ms", outputs[1])
+	}
+}
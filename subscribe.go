@@ -0,0 +1,111 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SubscribeBuffer is the capacity of the channel returned by Subscribe.
+const SubscribeBuffer = 1000
+
+type subscriber struct {
+	filter func(Entry) bool
+	ch     chan Entry
+}
+
+var subscribers = struct {
+	sync.RWMutex
+	list []*subscriber
+}{}
+
+func init() {
+	addHook(subscribeHook{})
+}
+
+// Subscribe returns a channel receiving every entry written from now on for
+// which filter returns true, or every entry if filter is nil, so that
+// in-process components like dashboards, tests or adaptive controllers can
+// follow the live stream. The channel is buffered; entries that do not fit
+// are dropped and counted in Stats rather than slowing down logging. cancel
+// ends the subscription and closes the channel.
+//
+// filter is called with the logger locked and must not log.
+func Subscribe(filter func(Entry) bool) (entries <-chan Entry, cancel func()) {
+	s := &subscriber{filter: filter, ch: make(chan Entry, SubscribeBuffer)}
+
+	subscribers.Lock()
+	subscribers.list = append(subscribers.list, s)
+	subscribers.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			subscribers.Lock()
+			defer subscribers.Unlock()
+			for i, o := range subscribers.list {
+				if o == s {
+					subscribers.list = append(subscribers.list[:i:i], subscribers.list[i+1:]...)
+					break
+				}
+			}
+			close(s.ch)
+		})
+	}
+}
+
+// subscribeHook delivers entries to the subscribers.
+type subscribeHook struct{}
+
+func (subscribeHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (subscribeHook) Fire(entry *log.Entry) error {
+	subscribers.RLock()
+	defer subscribers.RUnlock()
+	if len(subscribers.list) == 0 {
+		return nil
+	}
+
+	e := toEntry(entry)
+	for _, s := range subscribers.list {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			atomic.AddUint64(&stats.SubscriberDropped, 1)
+		}
+	}
+	return nil
+}
+
+// toEntry converts a logrus entry to an Entry.
+func toEntry(entry *log.Entry) Entry {
+	site := entryCaller(entry.Data)
+	e := Entry{
+		Time:    entry.Time,
+		Level:   severityName(entry.Level),
+		Host:    entryHost(entry.Data),
+		Tag:     tag,
+		File:    site.file,
+		Line:    site.line,
+		Message: entry.Message,
+	}
+	if t, ok := entry.Data[TagKey].(string); ok {
+		e.Tag = t
+	}
+	for k, v := range entry.Data {
+		if isReserved(k) {
+			continue
+		}
+		if e.Fields == nil {
+			e.Fields = make(Fields, len(entry.Data))
+		}
+		e.Fields[k] = v
+	}
+	return e
+}
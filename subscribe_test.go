@@ -0,0 +1,60 @@
+package log
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	SetOutputs(io.Discard)
+	defer SetOutputs(os.Stderr)
+
+	errs, cancel := Subscribe(func(e Entry) bool { return e.Level == "error" })
+	all, cancelAll := Subscribe(nil)
+	defer cancelAll()
+
+	Info("started")
+	WithTag("db").With("table", "flows").Error("write failed")
+
+	e := <-errs
+	if e.Message != "write failed" || e.Tag != "db" || e.Fields["table"] != "flows" {
+		t.Errorf("entry = %+v", e)
+	}
+	if _, ok := e.Fields[TagKey]; ok {
+		t.Error("tag repeated in the fields")
+	}
+	if e.File == "" || e.Line == 0 {
+		t.Errorf("entry has no caller: %+v", e)
+	}
+	if got := (<-all).Message; got != "started" {
+		t.Errorf("first entry = %q, want started", got)
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-errs; ok {
+		t.Error("channel not closed by cancel")
+	}
+	Error("after cancel")
+	<-all
+	if got := (<-all).Message; got != "after cancel" {
+		t.Errorf("remaining subscription got %q", got)
+	}
+}
+
+func TestSubscribeFull(t *testing.T) {
+	SetOutputs(io.Discard)
+	defer SetOutputs(os.Stderr)
+
+	_, cancel := Subscribe(nil)
+	defer cancel()
+
+	before := Stats().SubscriberDropped
+	for i := 0; i < SubscribeBuffer+5; i++ {
+		Info("flood")
+	}
+	if got := Stats().SubscriberDropped - before; got != 5 {
+		t.Errorf("SubscriberDropped grew by %d, want 5", got)
+	}
+}
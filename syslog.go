@@ -0,0 +1,192 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultSDID is the SD-ID of the structured data element written by
+// SyslogFormatter. 32473 is the enterprise number reserved for examples and
+// documentation.
+const DefaultSDID = "fields@32473"
+
+// syslogUser is the user-level facility.
+const syslogUser = 1
+
+// SyslogFormatter formats entries as RFC 5424 syslog messages,
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [fields@32473 k="v"] MSG
+//
+// where APP-NAME is the tag and MSGID the event_id field, "-" without.
+// With StructuredData set, fields are written as parameters of a single
+// SD-ELEMENT that rsyslog and other daemons can index; otherwise the
+// structured data is "-" and fields are appended to the message as in the
+// text format.
+type SyslogFormatter struct {
+	// Facility is the syslog facility code, LOG_USER (1) if zero.
+	Facility int
+	// StructuredData writes fields as an SD-ELEMENT.
+	StructuredData bool
+	// SDID is the SD-ID of the element, DefaultSDID if empty.
+	SDID string
+	// RFC3164 writes the older BSD format understood by every local
+	// syslog daemon, <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG, with
+	// fields appended to the message.
+	RFC3164 bool
+}
+
+// syslogSeverity maps levels to syslog severities.
+var syslogSeverity = map[log.Level]int{
+	log.PanicLevel: 0, // emerg
+	log.FatalLevel: 2, // crit
+	log.ErrorLevel: 3, // err
+	log.WarnLevel:  4, // warning
+	log.InfoLevel:  6, // info
+	log.DebugLevel: 7, // debug
+	traceLevel:     7, // debug
+}
+
+func (c *SyslogFormatter) Format(entry *log.Entry) ([]byte, error) {
+	facility := c.Facility
+	if facility == 0 {
+		facility = syslogUser
+	}
+	if facility < 0 || facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d", facility)
+	}
+
+	appName := appName(entry.Data)
+	if c.RFC3164 {
+		return []byte(fmt.Sprintf("<%d>%s %s %s[%d]: %s%s\n",
+			facility*8+syslogSeverity[severityOf(entry.Level)],
+			entry.Time.Format(time.Stamp),
+			syslogHeader(entryHost(entry.Data), 255),
+			syslogHeader(appName, 32),
+			pid, entry.Message, appendFields(nil, entry.Data))), nil
+	}
+
+	msgID, _ := entry.Data[EventIDKey].(string)
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s ",
+		facility*8+syslogSeverity[severityOf(entry.Level)],
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeader(entryHost(entry.Data), 255),
+		syslogHeader(appName, 48),
+		pid,
+		syslogHeader(msgID, 32))
+
+	msg := entry.Message
+	if c.StructuredData {
+		b.WriteString(c.structuredData(entry.Data))
+	} else {
+		b.WriteString("-")
+		msg += string(appendFields(nil, entry.Data))
+	}
+	if msg != "" {
+		b.WriteString(" ")
+		b.WriteString(msg)
+	}
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
+
+// appName returns the application name of an entry: its tag or the base
+// name of the process-wide tag, usually os.Args[0].
+func appName(fields log.Fields) string {
+	if t, ok := fields[TagKey].(string); ok && t != "" {
+		return t
+	}
+	return filepath.Base(tag)
+}
+
+// structuredData renders fields as an SD-ELEMENT, or "-" if there are none.
+func (c *SyslogFormatter) structuredData(fields log.Fields) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if !isReserved(k) && k != EventIDKey {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return "-"
+	}
+	sort.Strings(keys)
+
+	id := c.SDID
+	if id == "" {
+		id = DefaultSDID
+	}
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(sdID(id))
+	for _, k := range keys {
+		value := fields[k]
+		if s, ok := value.(string); ok && k == LayersKey {
+			value = parseLayers(s)
+		}
+		b.WriteString(" ")
+		b.WriteString(sdName(k))
+		b.WriteString(`="`)
+		b.WriteString(sdEscaper.Replace(fmt.Sprint(value)))
+		b.WriteString(`"`)
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// sdEscaper escapes the characters RFC 5424 requires to be escaped in
+// parameter values.
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// sdName makes s a valid SD-NAME, e.g. a PARAM-NAME: at most 32 printable
+// ASCII characters other than '=', ' ', ']', '"' and '@'.
+func sdName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '@' {
+			return '_'
+		}
+		return r
+	}, sdID(s))
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	return s
+}
+
+// sdID makes s a valid SD-ID: an SD-NAME, or one with '@' and a private
+// enterprise number.
+func sdID(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > 32 && !strings.Contains(s, "@") {
+		s = s[:32]
+	}
+	return s
+}
+
+// syslogHeader makes s a valid header field of at most max printable ASCII
+// characters, "-" standing for an empty value.
+func syslogHeader(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func syslogEntry() *log.Entry {
+	entry := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
+		"iface":  "eth1",
+		"reason": `carrier "lost"]`,
+		hostKey:  "probe-7",
+	})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = log.WarnLevel
+	entry.Message = "link flap"
+	return entry
+}
+
+func TestSyslogFormatterStructuredData(t *testing.T) {
+	defer SetTag(tag)
+	SetTag("/usr/bin/sniper")
+
+	b, err := (&SyslogFormatter{StructuredData: true}).Format(syslogEntry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<12>1 2017-03-01T12:00:00.000000Z probe-7 sniper `
+	if !strings.HasPrefix(string(b), want) {
+		t.Errorf("message %q does not start with %q", b, want)
+	}
+	want = ` - [fields@32473 iface="eth1" reason="carrier \"lost\"\]"] link flap` + "\n"
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("message %q does not end with %q", b, want)
+	}
+}
+
+func TestSyslogFormatterMsgID(t *testing.T) {
+	entry := syslogEntry()
+	entry.Data[EventIDKey] = "link-101"
+	b, err := (&SyslogFormatter{StructuredData: true}).Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ` link-101 [fields@32473 iface="eth1" reason="carrier \"lost\"\]"] link flap` + "\n"
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("message %q does not end with %q", b, want)
+	}
+}
+
+func TestSyslogFormatterFlat(t *testing.T) {
+	b, err := (&SyslogFormatter{Facility: 16}).Format(syslogEntry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "<132>1 ") {
+		t.Errorf("message %q does not have PRI 132 (local0.warning)", b)
+	}
+	want := ` - - link flap iface=eth1 reason="carrier \"lost\"]"` + "\n"
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("message %q does not end with %q", b, want)
+	}
+}
+
+func TestSyslogFormatterRFC3164(t *testing.T) {
+	defer SetTag(tag)
+	SetTag("/usr/bin/sniper")
+
+	b, err := (&SyslogFormatter{RFC3164: true}).Format(syslogEntry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<12>Mar  1 12:00:00 probe-7 sniper["
+	if !strings.HasPrefix(string(b), want) {
+		t.Errorf("message %q does not start with %q", b, want)
+	}
+	want = `]: link flap iface=eth1 reason="carrier \"lost\"]"` + "\n"
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("message %q does not end with %q", b, want)
+	}
+}
+
+func TestSDName(t *testing.T) {
+	for in, want := range map[string]string{
+		"user_id":               "user_id",
+		"a b=c]d\"e":            "a_b_c_d_e",
+		"user@host":             "user_host",
+		strings.Repeat("k", 40): strings.Repeat("k", 32),
+	} {
+		if got := sdName(in); got != want {
+			t.Errorf("sdName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := sdID("fields@32473"); got != "fields@32473" {
+		t.Errorf("sdID(%q) = %q, want it unchanged", "fields@32473", got)
+	}
+}
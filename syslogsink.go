@@ -0,0 +1,253 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultSyslogQueue is the number of entries a SyslogSink buffers while
+// the daemon is slow or unreachable.
+const DefaultSyslogQueue = 10000
+
+// syslogSockets are the local syslog sockets tried in turn by
+// AddSyslogSink without a network.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// journaldSocket is the native protocol socket of systemd-journald.
+var journaldSocket = "/run/systemd/journal/socket"
+
+// SyslogSink sends entries to a syslog daemon or to systemd-journald. It
+// is a logrus hook, so entries reach it whatever the outputs of the
+// package logger; they are queued and sent by a background goroutine,
+// and dropped when the queue is full.
+type SyslogSink struct {
+	name    string
+	dial    func(ctx context.Context) (net.Conn, error)
+	encode  func(*log.Entry) ([]byte, error)
+	stream  bool
+	queue   chan []byte
+	pending int64
+	dropped uint64
+
+	// ctx is cancelled by Close, aborting the connection in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// AddSyslogSink sends every entry of the package logger to the syslog
+// daemon at addr, with the given facility (LOG_USER, 1, if zero). network
+// is "udp", "tcp" or "unixgram" for a socket path; the messages are RFC
+// 5424 ones with the fields as structured data, framed by octet counting
+// over tcp. With an empty network the local daemon is used through
+// /dev/log or its BSD and macOS equivalents, in the RFC 3164 format every
+// local daemon understands.
+func AddSyslogSink(network, addr string, facility int) (*SyslogSink, error) {
+	if facility < 0 || facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d", facility)
+	}
+
+	format := &SyslogFormatter{Facility: facility, StructuredData: true}
+	s := &SyslogSink{name: "syslog:" + network + ":" + addr}
+	s.encode = func(entry *log.Entry) ([]byte, error) {
+		msg, err := format.Format(entry)
+		// Messages are framed by the datagram or their length, not by
+		// a terminator.
+		return bytes.TrimSuffix(msg, []byte("\n")), err
+	}
+	switch network {
+	case "":
+		format.StructuredData, format.RFC3164 = false, true
+		s.name = "syslog"
+		s.dial = dialLocalSyslog
+	case "tcp", "tcp4", "tcp6":
+		s.stream = true
+		fallthrough
+	default:
+		s.dial = func(ctx context.Context) (net.Conn, error) {
+			d := &net.Dialer{Timeout: forwardDialTimeout}
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+	s.start()
+	return s, nil
+}
+
+// AddJournaldSink sends every entry of the package logger to the local
+// systemd-journald in its native protocol: the message, priority, tag,
+// pid and caller go to the standard journal fields and every field to a
+// journal field of its own, its name upper-cased, so journalctl can
+// filter on it, e.g. journalctl IFACE=eth1.
+func AddJournaldSink() (*SyslogSink, error) {
+	if _, err := os.Stat(journaldSocket); err != nil {
+		return nil, fmt.Errorf("journald is not running: %v", err)
+	}
+	s := &SyslogSink{
+		name:   "journald",
+		encode: encodeJournal,
+		dial: func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unixgram", journaldSocket)
+		},
+	}
+	s.start()
+	return s, nil
+}
+
+// dialLocalSyslog connects to the first local syslog socket accepting a
+// connection.
+func dialLocalSyslog(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	var err error
+	for _, path := range syslogSockets {
+		var conn net.Conn
+		if conn, err = d.DialContext(ctx, "unixgram", path); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, fmt.Errorf("no local syslog daemon: %v", err)
+}
+
+// start registers the sink and starts sending.
+func (s *SyslogSink) start() {
+	s.queue = make(chan []byte, DefaultSyslogQueue)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	addHook(s)
+	addFlusher(s)
+}
+
+// Name returns the destination of the sink.
+func (s *SyslogSink) Name() string {
+	return s.name
+}
+
+// Levels implements logrus.Hook.
+func (s *SyslogSink) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook. It never blocks.
+func (s *SyslogSink) Fire(entry *log.Entry) error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	msg, err := s.encode(entry)
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.pending, 1)
+	select {
+	case s.queue <- msg:
+	default:
+		atomic.AddInt64(&s.pending, -1)
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return nil
+}
+
+// QueueDepth implements QueueReporter.
+func (s *SyslogSink) QueueDepth() int {
+	return len(s.queue)
+}
+
+// QueueLatency implements QueueReporter; the sink does not measure it.
+func (s *SyslogSink) QueueLatency() time.Duration {
+	return 0
+}
+
+// Dropped returns the number of entries dropped because the queue was full.
+func (s *SyslogSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Flush waits until every queued entry has been sent or given up on.
+func (s *SyslogSink) Flush() error {
+	return s.FlushContext(context.Background())
+}
+
+// FlushContext is Flush giving up when ctx is done.
+func (s *SyslogSink) FlushContext(ctx context.Context) error {
+	return waitPending(ctx, &s.pending, s.done, "syslog sink closed with entries pending")
+}
+
+// Close stops the sink, aborting a connection or a message in flight.
+// Entries still queued are discarded; call Flush first to send them.
+func (s *SyslogSink) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		close(s.stop)
+	})
+	<-s.done
+	return nil
+}
+
+func (s *SyslogSink) run() {
+	defer close(s.done)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	for {
+		var msg []byte
+		select {
+		case msg = <-s.queue:
+		case <-s.stop:
+			return
+		}
+
+		// A message that cannot be sent is retried once on a new
+		// connection, the daemon may have been restarted.
+		var err error
+		for attempt := 0; attempt < 2; attempt++ {
+			if conn == nil {
+				if conn, err = s.dial(s.ctx); err != nil {
+					conn = nil
+					continue
+				}
+			}
+			if err = s.send(conn, msg); err == nil {
+				break
+			}
+			conn.Close()
+			conn = nil
+		}
+		if err != nil {
+			reportDelivery(DeliveryReport{Output: s.name, Failed: 1, Err: err})
+		} else {
+			reportDelivery(DeliveryReport{Output: s.name, Delivered: 1})
+		}
+		atomic.AddInt64(&s.pending, -1)
+	}
+}
+
+// send writes msg to conn, prefixed with its length on stream connections
+// (RFC 6587 octet counting).
+func (s *SyslogSink) send(conn net.Conn, msg []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(forwardDialTimeout))
+	defer interruptible(s.ctx, conn)()
+	if s.stream {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_, err := conn.Write(msg)
+	return err
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	SetOutputs(nopWriter{})
+	defer SetOutputs(os.Stderr)
+
+	s, err := AddSyslogSink("udp", pc.LocalAddr().String(), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	With("iface", "eth1").Warning("link flap")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<132>1 ") || !strings.HasSuffix(msg, `[fields@32473 iface="eth1"] link flap`) {
+		t.Errorf("datagram = %q, want an RFC 5424 local0.warning message", msg)
+	}
+}
+
+func TestSyslogSinkInvalidFacility(t *testing.T) {
+	if _, err := AddSyslogSink("udp", "127.0.0.1:514", 24); err == nil {
+		t.Error("AddSyslogSink with facility 24 = nil, want error")
+	}
+}
+
+// nopWriter discards entries without being stderr, which would make the
+// stderr mirror step aside.
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
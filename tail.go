@@ -0,0 +1,161 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// tailKeepAlive is how often TailHandler writes a comment to idle streams,
+// so that proxies do not close them.
+const tailKeepAlive = 15 * time.Second
+
+// TailHandler returns an HTTP handler streaming the entries of the process
+// as Server-Sent Events, one JSON object in the format of JSONFormatter
+// per event, so that operators can follow a production process from a
+// browser or curl without shell access:
+//
+//	curl -N 'http://localhost:8080/debug/tail?level=warning&field=iface:eth1'
+//
+// The query selects the entries: level their minimum severity, every field
+// parameter a key:value its fields must match, and recent=true starts the
+// stream with the entries retained by KeepRecent, below the active level
+// included. Entries the client is too slow for are dropped and counted in
+// Stats, like for Subscribe.
+func TailHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match, err := tailFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		// Subscribe first, the recent entries must not leave a gap.
+		entries, cancel := Subscribe(match)
+		defer cancel()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		if r.URL.Query().Get("recent") == "true" {
+			for _, e := range recentEntries() {
+				if match(e) {
+					if err := writeEvent(w, &e); err != nil {
+						return
+					}
+				}
+			}
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(tailKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case e, ok := <-entries:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, &e); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+// tailFilter returns the filter of the entries selected by the query of r.
+func tailFilter(r *http.Request) (func(Entry) bool, error) {
+	q := r.URL.Query()
+	min := log.DebugLevel
+	if name := q.Get("level"); name != "" {
+		var err error
+		if min, err = parseLevel(name); err != nil {
+			return nil, err
+		}
+	}
+	want := make(map[string]string)
+	for _, f := range q["field"] {
+		i := strings.IndexByte(f, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("field %q is not key:value", f)
+		}
+		want[f[:i]] = f[i+1:]
+	}
+
+	return func(e Entry) bool {
+		if lvl, err := parseLevel(e.Level); err != nil || lvl > min {
+			return false
+		}
+		for k, v := range want {
+			got, ok := e.Fields[k]
+			if !ok || fmt.Sprint(got) != v {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// writeEvent writes e as an event.
+func writeEvent(w http.ResponseWriter, e *Entry) error {
+	b, err := entryJSON(e)
+	if err != nil {
+		reportError(err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", b)
+	return err
+}
+
+// recentEntries returns the entries retained by KeepRecent and KeepLast,
+// oldest first.
+func recentEntries() []Entry {
+	retained := recent.snapshot()
+
+	entries := make([]Entry, len(retained))
+	for i, r := range retained {
+		e := Entry{
+			Time:    r.time,
+			Level:   severityName(r.level),
+			Host:    entryHost(r.fields),
+			Tag:     tag,
+			File:    r.site.file,
+			Line:    r.site.line,
+			Message: r.msg,
+		}
+		if t, ok := r.fields[timeKey].(time.Time); ok {
+			e.Time = t
+		}
+		if t, ok := r.fields[TagKey].(string); ok {
+			e.Tag = t
+		}
+		for k, v := range r.fields {
+			if isReserved(k) {
+				continue
+			}
+			if e.Fields == nil {
+				e.Fields = make(Fields, len(r.fields))
+			}
+			e.Fields[k] = v
+		}
+		entries[i] = e
+	}
+	return entries
+}
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the data of the next event read from sc.
+func nextEvent(t *testing.T, sc *bufio.Scanner) string {
+	t.Helper()
+	data := make(chan string, 1)
+	go func() {
+		for sc.Scan() {
+			if line := sc.Text(); strings.HasPrefix(line, "data: ") {
+				data <- strings.TrimPrefix(line, "data: ")
+				return
+			}
+		}
+		close(data)
+	}()
+	select {
+	case d, ok := <-data:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return ""
+}
+
+func TestTailHandler(t *testing.T) {
+	SetOutputs(nopWriter{})
+	defer SetOutputs(os.Stderr)
+	srv := httptest.NewServer(TailHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?level=warning&field=iface:eth1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	With("iface", "eth1").Info("too verbose")
+	With("iface", "eth2").Warning("other interface")
+	With("iface", "eth1").Warning("link flap")
+	got := nextEvent(t, bufio.NewScanner(resp.Body))
+	if !strings.Contains(got, `"msg":"link flap"`) || !strings.Contains(got, `"level":"warning"`) {
+		t.Errorf("first event = %s, want the eth1 warning", got)
+	}
+}
+
+func TestTailHandlerRecent(t *testing.T) {
+	SetOutputs(nopWriter{})
+	defer SetOutputs(os.Stderr)
+	KeepRecent(time.Minute)
+	defer KeepRecent(0)
+	SetLevel("info")
+	defer SetLevel("debug")
+	With("flow", 7).Debug("below the level")
+
+	srv := httptest.NewServer(TailHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "?recent=true&field=flow:7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := nextEvent(t, bufio.NewScanner(resp.Body)); !strings.Contains(got, `"msg":"below the level"`) || !strings.Contains(got, `"flow":7`) {
+		t.Errorf("first event = %s, want the retained debug entry", got)
+	}
+}
+
+func TestTailHandlerBadQuery(t *testing.T) {
+	for _, q := range []string{"?level=loud", "?field=iface"} {
+		w := httptest.NewRecorder()
+		TailHandler().ServeHTTP(w, httptest.NewRequest("GET", "/"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, w.Code)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxTalkers bounds the number of distinct values counted per field and
+// interval; further values are only counted in the total.
+const maxTalkers = 10000
+
+// topTalkers counts the entries per value of designated fields over a
+// sliding window made of one bucket per report interval.
+type topTalkers struct {
+	mu       sync.Mutex
+	n        int
+	window   time.Duration
+	interval time.Duration
+	// buckets maps, per interval of the window, fields to their values
+	// to the number of entries carrying them, the current one last.
+	buckets []map[string]map[string]uint64
+	totals  []map[string]uint64
+	stop    chan struct{}
+}
+
+var talkers = &topTalkers{}
+
+// talkerFields holds the []string of fields SetTopTalkers counts, read
+// for every entry.
+var talkerFields atomic.Value
+
+// SetTopTalkers logs, every interval, an INFO entry "top talkers" for each
+// of fields, e.g. src_ip or dst_port in capture logs, with the n values
+// most of the entries of the last window were logged with, as top_1 to
+// top_n holding the count and the value, e.g. top_1="5234 10.0.0.7", and
+// the entries carrying the field. The window is rounded up to a multiple
+// of the interval. Entries are counted when they pass the level, before
+// sampling and the rate limit. A non-positive interval disables the
+// leaderboards, which is the default.
+func SetTopTalkers(fields []string, n int, window, interval time.Duration) {
+	talkers.mu.Lock()
+	defer talkers.mu.Unlock()
+
+	if talkers.stop != nil {
+		close(talkers.stop)
+		talkers.stop = nil
+	}
+	talkers.buckets, talkers.totals = nil, nil
+	if interval <= 0 || len(fields) == 0 {
+		talkerFields.Store([]string(nil))
+		return
+	}
+	if window < interval {
+		window = interval
+	}
+	buckets := int((window + interval - 1) / interval)
+	talkers.n = n
+	talkers.window = time.Duration(buckets) * interval
+	talkers.interval = interval
+	talkers.buckets = make([]map[string]map[string]uint64, buckets)
+	talkers.totals = make([]map[string]uint64, buckets)
+	for i := range talkers.buckets {
+		talkers.buckets[i] = make(map[string]map[string]uint64)
+		talkers.totals[i] = make(map[string]uint64)
+	}
+	talkerFields.Store(append([]string(nil), fields...))
+	talkers.stop = make(chan struct{})
+	go talkers.run(interval, talkers.stop)
+}
+
+// add counts an entry with data under the values of the fields it carries.
+func (t *topTalkers) add(data log.Fields) {
+	fields, _ := talkerFields.Load().([]string)
+	if len(fields) == 0 || len(data) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.buckets) == 0 {
+		return
+	}
+	counts, totals := t.buckets[len(t.buckets)-1], t.totals[len(t.totals)-1]
+	for _, f := range fields {
+		v, ok := data[f]
+		if !ok {
+			continue
+		}
+		totals[f]++
+		values := counts[f]
+		if values == nil {
+			values = make(map[string]uint64)
+			counts[f] = values
+		}
+		key := talkerValue(v)
+		if _, ok := values[key]; !ok && len(values) >= maxTalkers {
+			continue
+		}
+		values[key]++
+	}
+}
+
+// talkerValue returns the value v is counted under.
+func talkerValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func (t *topTalkers) run(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.report()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// report logs the leaderboards of the window and starts a new interval.
+func (t *topTalkers) report() {
+	fields, _ := talkerFields.Load().([]string)
+
+	t.mu.Lock()
+	if len(t.buckets) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	n, window := t.n, t.window
+	sums := make(map[string]map[string]uint64)
+	totals := make(map[string]uint64)
+	for i, b := range t.buckets {
+		for f, values := range b {
+			sum := sums[f]
+			if sum == nil {
+				sum = make(map[string]uint64, len(values))
+				sums[f] = sum
+			}
+			for v, c := range values {
+				sum[v] += c
+			}
+		}
+		for f, c := range t.totals[i] {
+			totals[f] += c
+		}
+	}
+	t.buckets = append(t.buckets[1:], make(map[string]map[string]uint64))
+	t.totals = append(t.totals[1:], make(map[string]uint64))
+	t.mu.Unlock()
+
+	for _, f := range fields {
+		if totals[f] == 0 {
+			continue
+		}
+		type talker struct {
+			value string
+			count uint64
+		}
+		sorted := make([]talker, 0, len(sums[f]))
+		for v, c := range sums[f] {
+			sorted = append(sorted, talker{v, c})
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].count != sorted[j].count {
+				return sorted[i].count > sorted[j].count
+			}
+			return sorted[i].value < sorted[j].value
+		})
+		if n > 0 && len(sorted) > n {
+			sorted = sorted[:n]
+		}
+
+		entry := log.Fields{
+			"field":   f,
+			"entries": totals[f],
+			"window":  window.String(),
+		}
+		for i, tk := range sorted {
+			entry[fmt.Sprintf("top_%d", i+1)] = fmt.Sprintf("%d %s", tk.count, tk.value)
+		}
+		emit(log.InfoLevel, captureCaller(log.InfoLevel, 0), "top talkers", entry)
+	}
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopTalkers(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetTopTalkers([]string{"src_ip", "dst_port"}, 2, 2*time.Hour, time.Hour)
+	defer SetTopTalkers(nil, 0, 0, 0)
+
+	for i := 0; i < 3; i++ {
+		WithFields(Fields{"src_ip": "10.0.0.7", "dst_port": 443}).Info("syn")
+	}
+	WithFields(Fields{"src_ip": "10.0.0.9"}).Info("syn")
+	WithFields(Fields{"src_ip": "10.0.0.1"}).Info("syn")
+	Debug("below the level")
+
+	buf.Reset()
+	talkers.report()
+	got := buf.String()
+	for _, want := range []string{
+		`top talkers entries=5 field=src_ip top_1="3 10.0.0.7" top_2="1 10.0.0.1" window=2h0m0s`,
+		`top talkers entries=3 field=dst_port top_1="3 443" window`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report %q does not contain %q", got, want)
+		}
+	}
+
+	// The first interval is still in the window, then slides out.
+	WithFields(Fields{"src_ip": "10.0.0.9"}).Info("syn")
+	buf.Reset()
+	talkers.report()
+	if !strings.Contains(buf.String(), "entries=6 field=src_ip") {
+		t.Errorf("second report %q does not cover the window", buf.String())
+	}
+	buf.Reset()
+	talkers.report()
+	if !strings.Contains(buf.String(), `entries=1 field=src_ip top_1="1 10.0.0.9" window`) {
+		t.Errorf("third report %q still counts the expired interval", buf.String())
+	}
+	buf.Reset()
+	talkers.report()
+	if buf.Len() != 0 {
+		t.Errorf("empty window logged %q", buf.String())
+	}
+}
@@ -0,0 +1,107 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TemplateData is what the template of a TemplateFormatter is executed
+// with for every entry.
+type TemplateData struct {
+	// Time is the timestamp in the layout selected with SetTimeFormat,
+	// RFC3339 by default.
+	Time string
+	// Level is the severity label, as printed by the text format.
+	Level string
+	Host  string
+	Tag   string
+	PID   int
+	File  string
+	Line  int
+	// Caller is file:line in the style selected with SetCallerStyle.
+	Caller string
+	Msg    string
+	// Fields renders the fields as key=value pairs sorted by key, as
+	// the text format does; Data holds them for templates picking
+	// single ones, e.g. {{.Data.flow}}.
+	Fields string
+	Data   Fields
+}
+
+// TemplateFormatter is an EntryFormatter writing entries with a
+// text/template, for line formats that differ from the text format:
+//
+//	f, err := log.NewTemplateFormatter("{{.Time}} {{.Level}} {{.Tag}} {{.Msg}} {{.Fields}}")
+type TemplateFormatter struct {
+	tmpl *template.Template
+}
+
+// NewTemplateFormatter returns a TemplateFormatter executing text with a
+// TemplateData. A newline is appended to lines that do not end with one.
+func NewTemplateFormatter(text string) (*TemplateFormatter, error) {
+	tmpl, err := template.New("entry").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("entry template: %v", err)
+	}
+	return &TemplateFormatter{tmpl: tmpl}, nil
+}
+
+// SetTemplate selects a TemplateFormatter executing text as the format of
+// every entry written from now on, see SetFormatter.
+func SetTemplate(text string) error {
+	f, err := NewTemplateFormatter(text)
+	if err != nil {
+		return err
+	}
+	SetFormatter(f)
+	return nil
+}
+
+// FormatEntry implements EntryFormatter.
+func (t *TemplateFormatter) FormatEntry(e *Entry) ([]byte, error) {
+	level, err := parseLevel(e.Level)
+	if err != nil {
+		return nil, err
+	}
+	return t.execute(templateData(*e, packageFormatter().caller(callSite{file: e.File, line: e.Line}), level))
+}
+
+func (t *TemplateFormatter) execute(data TemplateData) ([]byte, error) {
+	var b bytes.Buffer
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("entry template: %v", err)
+	}
+	if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// templateData returns the TemplateData of e.
+func templateData(e Entry, caller string, level log.Level) TemplateData {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	label := levelLabel(level)
+	if packageFormatter().Color {
+		label = colorLabel(level, label)
+	}
+	return TemplateData{
+		Time:   formatTime(e.Time, time.RFC3339),
+		Level:  label,
+		Host:   e.Host,
+		Tag:    e.Tag,
+		PID:    pid,
+		File:   e.File,
+		Line:   e.Line,
+		Caller: caller,
+		Msg:    e.Message,
+		Fields: strings.TrimPrefix(formatFields(log.Fields(e.Fields)), " "),
+		Data:   e.Fields,
+	}
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestSetTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetCallerStyle(CallerShort, false)
+	defer SetCallerStyle(CallerFull, false)
+
+	if err := SetTemplate("{{.Time}} {{.Level}} {{.Tag}} {{.Caller}} {{.Msg}} {{.Data.iface}} [{{.Fields}}]"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFormatter(nil)
+	old := tag
+	tag = "sniper"
+	defer func() { tag = old }()
+
+	WithFields(Fields{"iface": "eth1", "speed": 100}).Warning("link flap")
+	want := regexp.MustCompile(`^\S+ WARNING sniper template_test\.go:\d+ link flap eth1 \[iface=eth1 speed=100\]\n$`)
+	if got := buf.String(); !want.MatchString(got) {
+		t.Errorf("output = %q, want a match of %s", got, want)
+	}
+}
+
+func TestTemplateFormatterFormatEntry(t *testing.T) {
+	f, err := NewTemplateFormatter("{{.Time}} {{.Level}} {{.Msg}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := Entry{Time: time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC), Level: "info", Message: "started"}
+	b, err := f.FormatEntry(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "2017-03-01T12:00:00Z "+levelLabel(log.InfoLevel)+" started\n"; got != want {
+		t.Errorf("FormatEntry() = %q, want %q", got, want)
+	}
+
+	if _, err := NewTemplateFormatter("{{.Msg"); err == nil {
+		t.Error("NewTemplateFormatter accepted an unterminated action")
+	}
+}
@@ -0,0 +1,40 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TemporarilySetLevel sets the log level like SetLevel and reverts to the
+// previous level after d, or earlier when the returned function is called:
+//
+//	restore, err := log.TemporarilySetLevel("debug", 5*time.Minute)
+//	if err != nil {
+//		return err
+//	}
+//	defer restore()
+//
+// The previous level is only restored if the level has not been changed
+// again in the meantime, so a later SetLevel always wins. An invalid level
+// is an error and leaves the level unchanged.
+func TemporarilySetLevel(level string, d time.Duration) (restore func(), err error) {
+	set, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	previous := getLevel()
+	setLevel(set)
+
+	var once sync.Once
+	revert := func() {
+		once.Do(func() {
+			atomic.CompareAndSwapUint32(stdLevel(), uint32(set), uint32(previous))
+		})
+	}
+	timer := time.AfterFunc(d, revert)
+	return func() {
+		timer.Stop()
+		revert()
+	}, nil
+}
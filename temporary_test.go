@@ -0,0 +1,45 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestTemporarilySetLevel(t *testing.T) {
+	SetLevel("info")
+	defer SetLevel("debug")
+
+	restore, err := TemporarilySetLevel("debug", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := log.GetLevel(); got != log.DebugLevel {
+		t.Fatalf("level = %s, want debug", got)
+	}
+	restore()
+	if got := log.GetLevel(); got != log.InfoLevel {
+		t.Errorf("level after restore = %s, want info", got)
+	}
+
+	TemporarilySetLevel("debug", 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if got := log.GetLevel(); got != log.InfoLevel {
+		t.Errorf("level after expiry = %s, want info", got)
+	}
+
+	restore, _ = TemporarilySetLevel("debug", time.Hour)
+	SetLevel("warn")
+	restore()
+	if got := log.GetLevel(); got != log.WarnLevel {
+		t.Errorf("restore overrode a later SetLevel: level = %s, want warning", got)
+	}
+
+	if restore, err := TemporarilySetLevel("loud", time.Hour); err == nil || restore != nil {
+		t.Error("TemporarilySetLevel accepted an invalid level")
+	}
+	if got := log.GetLevel(); got != log.WarnLevel {
+		t.Errorf("level after an invalid level = %s, want warning", got)
+	}
+}
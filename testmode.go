@@ -0,0 +1,125 @@
+package log
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TestMode configures SetTestMode.
+type TestMode struct {
+	// Clock returns the time of every entry; nil keeps the real time.
+	Clock func() time.Time
+	// Host and Pid replace the host name and the process ID written in
+	// entries, unless empty or zero.
+	Host string
+	Pid  int
+	// Deterministic makes the output the same on every run and machine:
+	// entries have the time of the Unix epoch and TimeTrack measures no
+	// duration unless Clock is set, the host is localhost and the PID 1
+	// unless Host and Pid are set, and the console format writes no time
+	// since the start of the process.
+	Deterministic bool
+}
+
+// Host and PID of the entries in the deterministic test mode.
+const (
+	deterministicHost = "localhost"
+	deterministicPid  = 1
+)
+
+var testMode struct {
+	active        int32
+	deterministic int32
+	mu            sync.Mutex
+	clock         func() time.Time
+}
+
+// SetTestMode makes the package logger synchronous and deterministic, for
+// tests comparing the output with golden files or running under the race
+// detector whatever the asynchronous settings of the code under test:
+// every entry is written to the outputs and passed to the sinks of
+// AddSink before the logging call returns, AsyncWriter writes in the
+// calling goroutine, and entries are stamped by m.Clock with the host and
+// PID of m. For golden files, set Deterministic:
+//
+//	func TestMain(m *testing.M) {
+//		log.SetTestMode(&log.TestMode{Deterministic: true})
+//		os.Exit(m.Run())
+//	}
+//
+// Outputs and sinks are synchronous if created after the call,
+// so SetTestMode is best called from TestMain, before any logging. A nil
+// m ends the test mode.
+func SetTestMode(m *TestMode) {
+	localHost.once.Do(func() { localHost.name, _ = os.Hostname() })
+
+	testMode.mu.Lock()
+	defer testMode.mu.Unlock()
+	if m == nil {
+		atomic.StoreInt32(&testMode.active, 0)
+		atomic.StoreInt32(&testMode.deterministic, 0)
+		testMode.clock = nil
+		localHost.name, _ = os.Hostname()
+		pid = os.Getpid()
+		pidText = strconv.Itoa(pid)
+		return
+	}
+	testMode.clock = m.Clock
+	host, p := m.Host, m.Pid
+	var d int32
+	if m.Deterministic {
+		d = 1
+		// logrus replaces the zero time with the current one.
+		if testMode.clock == nil {
+			epoch := time.Unix(0, 0).UTC()
+			testMode.clock = func() time.Time { return epoch }
+		}
+		if host == "" {
+			host = deterministicHost
+		}
+		if p == 0 {
+			p = deterministicPid
+		}
+	}
+	if host != "" {
+		localHost.name = host
+	}
+	if p != 0 {
+		pid = p
+		pidText = strconv.Itoa(pid)
+	}
+	atomic.StoreInt32(&testMode.deterministic, d)
+	atomic.StoreInt32(&testMode.active, 1)
+}
+
+// synchronous reports whether the test mode is on.
+func synchronous() bool {
+	return atomic.LoadInt32(&testMode.active) != 0
+}
+
+// deterministic reports whether the deterministic test mode is on.
+func deterministic() bool {
+	return atomic.LoadInt32(&testMode.deterministic) != 0
+}
+
+// entryTime stamps an entry with the clock of the test mode or of
+// SetClock, unless it has a time of its own.
+func entryTime(fields log.Fields) log.Fields {
+	stamp := activeClock()
+	if stamp == nil {
+		return fields
+	}
+	if _, ok := fields[timeKey]; ok {
+		return fields
+	}
+	if fields == nil {
+		fields = make(log.Fields, 1)
+	}
+	fields[timeKey] = stamp()
+	return fields
+}
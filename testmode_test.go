@@ -0,0 +1,80 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTestMode(t *testing.T) {
+	now := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	SetTestMode(&TestMode{
+		Clock: func() time.Time { now = now.Add(time.Second); return now },
+		Host:  "golden",
+		Pid:   42,
+	})
+	defer SetTestMode(nil)
+	if err := SetFormat(JSONFormat); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFormat(TextFormat)
+
+	var out syncBuffer
+	a := NewAsyncWriter(&out, 0, time.Hour, BlockWhenFull)
+	defer a.Close()
+	SetOutputs(a)
+	defer SetOutputs(os.Stderr)
+	s := &recordSink{}
+	remove := AddSink(s)
+	defer remove()
+
+	Info("first")
+	WithFields(Fields{"iface": "eth0"}).Warning("second")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("output = %q, want both entries written synchronously", out.String())
+	}
+	for i, want := range []string{
+		`"time":"2017-03-01T12:00:01`,
+		`"time":"2017-03-01T12:00:02`,
+	} {
+		if !strings.Contains(lines[i], want) || !strings.Contains(lines[i], `"hostname":"golden"`) || !strings.Contains(lines[i], `"pid":42`) {
+			t.Errorf("line %d = %s, want %s, the host and the pid of the test mode", i, lines[i], want)
+		}
+	}
+	s.mu.Lock()
+	e := s.entries
+	s.mu.Unlock()
+	if len(e) != 2 || e[1].Message != "second" || !e[1].Time.Equal(now) {
+		t.Errorf("sink entries = %+v, want both, stamped by the clock", e)
+	}
+
+	SetTestMode(nil)
+	if pid != os.Getpid() {
+		t.Errorf("pid after the test mode = %d, want %d", pid, os.Getpid())
+	}
+}
+
+func TestTestModeDeterministic(t *testing.T) {
+	SetTestMode(&TestMode{Deterministic: true})
+	defer SetTestMode(nil)
+	SetReportCaller(false)
+	defer SetReportCaller(true)
+
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	Info("first")
+	if err := SetFormat(ConsoleFormat); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFormat(TextFormat)
+	Info("second")
+
+	want := "1970-01-01T00:00:00Z localhost : INFO\t[1] first\n" +
+		"    0.000s INFO    second\n"
+	if got := out.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package log
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TimeEpochMillis is the layout of SetTimeFormat writing timestamps as the
+// number of milliseconds since the Unix epoch.
+const TimeEpochMillis = "epoch_ms"
+
+// UptimeKey is the field holding the time since the start of the process
+// once SetUptime enabled it.
+const UptimeKey = "uptime"
+
+// timeFormat is the timestamp layout and zone selected with SetTimeFormat.
+type timeFormat struct {
+	layout string
+	utc    bool
+}
+
+var (
+	timestamps atomic.Value // timeFormat
+	uptime     int32
+
+	// processStart carries a monotonic clock reading, uptimes are not
+	// affected by changes of the wall clock.
+	processStart = time.Now()
+)
+
+// SetTimeFormat selects the layout of the timestamps of the text and JSON
+// formats: a layout of the time package such as time.RFC3339Nano, or
+// TimeEpochMillis. The empty layout restores the defaults, RFC3339 with
+// second precision for text and time.RFC3339Nano for JSON. utc writes the
+// timestamps in UTC rather than the local time zone. Compact parses text
+// lines back with the selected layout. It may be called before or after
+// Init.
+func SetTimeFormat(layout string, utc bool) {
+	timestamps.Store(timeFormat{layout: layout, utc: utc})
+}
+
+// activeTimeFormat returns the format selected with SetTimeFormat.
+func activeTimeFormat() timeFormat {
+	tf, _ := timestamps.Load().(timeFormat)
+	return tf
+}
+
+// formatTime renders t in the selected format, def being the layout of the
+// calling format if none was selected.
+func formatTime(t time.Time, def string) string {
+	return string(appendTime(nil, t, def))
+}
+
+// appendTime appends formatTime(t, def) to b.
+func appendTime(b []byte, t time.Time, def string) []byte {
+	tf := activeTimeFormat()
+	if tf.utc {
+		t = t.UTC()
+	}
+	switch tf.layout {
+	case "":
+		return t.AppendFormat(b, def)
+	case TimeEpochMillis:
+		return strconv.AppendInt(b, t.UnixMilli(), 10)
+	}
+	return t.AppendFormat(b, tf.layout)
+}
+
+// jsonTime is formatTime for JSON documents, in which epoch milliseconds are
+// a number.
+func jsonTime(t time.Time) interface{} {
+	if activeTimeFormat().layout == TimeEpochMillis {
+		return t.UnixMilli()
+	}
+	return formatTime(t, time.RFC3339Nano)
+}
+
+// parseJSONTime parses the time of a JSON entry, RFC3339 or in the selected
+// layout.
+func parseJSONTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if layout := activeTimeFormat().layout; err != nil && layout != "" && layout != TimeEpochMillis {
+		if lt, lerr := time.Parse(layout, s); lerr == nil {
+			return lt, nil
+		}
+	}
+	return t, err
+}
+
+// parseTimestamp parses the timestamp starting a text line and returns the
+// remainder of s after it and its separating space.
+func parseTimestamp(s string) (time.Time, string, bool) {
+	layout := activeTimeFormat().layout
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	// The timestamp spans as many spaces as its layout.
+	end := -1
+	for n := strings.Count(layout, " "); n >= 0; n-- {
+		sp := strings.IndexByte(s[end+1:], ' ')
+		if sp < 0 {
+			return time.Time{}, "", false
+		}
+		end += sp + 1
+	}
+
+	if layout == TimeEpochMillis {
+		ms, err := strconv.ParseInt(s[:end], 10, 64)
+		if err != nil {
+			return time.Time{}, "", false
+		}
+		return time.UnixMilli(ms), s[end+1:], true
+	}
+	ts, err := time.Parse(layout, s[:end])
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return ts, s[end+1:], true
+}
+
+// SetUptime enables or disables adding the uptime field to entries, the
+// time since the start of the process measured with the monotonic clock,
+// for ordering and timing entries regardless of clock steps and of the
+// precision of the timestamps. Entries with a timestamp of their own, such
+// as relayed ones, are left alone.
+func SetUptime(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&uptime, v)
+}
+
+// addUptime adds the uptime field to fields if enabled.
+func addUptime(fields log.Fields) log.Fields {
+	if atomic.LoadInt32(&uptime) == 0 {
+		return fields
+	}
+	if _, ok := fields[timeKey]; ok {
+		return fields
+	}
+	if fields == nil {
+		fields = make(log.Fields, 1)
+	}
+	fields[UptimeKey] = time.Since(processStart)
+	return fields
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestSetTimeFormat(t *testing.T) {
+	defer SetTimeFormat("", false)
+	ts := time.Date(2017, 3, 1, 12, 0, 0, 123456789, time.FixedZone("CET", 3600))
+
+	for _, tt := range []struct {
+		layout    string
+		utc       bool
+		want      string
+		precision time.Duration
+	}{
+		{"", false, "2017-03-01T12:00:00+01:00 ", time.Second},
+		{time.RFC3339Nano, false, "2017-03-01T12:00:00.123456789+01:00 ", 1},
+		{time.RFC3339Nano, true, "2017-03-01T11:00:00.123456789Z ", 1},
+		{TimeEpochMillis, false, "1488366000123 ", time.Millisecond},
+		{"2006-01-02 15:04:05.000", true, "2017-03-01 11:00:00.123 ", time.Millisecond},
+	} {
+		SetTimeFormat(tt.layout, tt.utc)
+		line := string(formatLine(ts, log.InfoLevel, "main.go:10", "started", nil))
+		if !strings.HasPrefix(line, tt.want) {
+			t.Errorf("layout %q, utc %v: line = %q, want it to start with %q", tt.layout, tt.utc, line, tt.want)
+		}
+
+		got, rest, ok := parseTimestamp(line)
+		if !ok || !got.Equal(ts.Truncate(tt.precision)) || !strings.HasPrefix(rest, entryHost(nil)+" : INFO") {
+			t.Errorf("layout %q: parseTimestamp() = %v, %q, %v", tt.layout, got, rest, ok)
+		}
+	}
+}
+
+func TestSetTimeFormatJSON(t *testing.T) {
+	defer SetTimeFormat("", false)
+	f := &JSONFormatter{}
+	entry := log.WithFields(log.Fields{})
+	entry.Time = time.Date(2017, 3, 1, 12, 0, 0, 123000000, time.UTC)
+	entry.Message = "started"
+
+	SetTimeFormat(TimeEpochMillis, false)
+	b, err := f.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"time":1488369600123`) {
+		t.Errorf("Format() = %s, want the time as a number", b)
+	}
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.Fatal(err)
+	}
+	if !e.Time.Equal(entry.Time) {
+		t.Errorf("decoded time = %v, want %v", e.Time, entry.Time)
+	}
+
+	SetTimeFormat("2006-01-02 15:04:05.000", false)
+	if b, err = f.Format(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &e); err != nil || !e.Time.Equal(entry.Time) {
+		t.Errorf("decoded %s as %v, %v", b, e.Time, err)
+	}
+}
+
+func TestSetUptime(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetUptime(true)
+	defer SetUptime(false)
+
+	Info("started")
+	WithTime(time.Now()).Info("relayed")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %q", buf.String())
+	}
+	if !strings.Contains(lines[0], " uptime=") {
+		t.Errorf("entry = %q, want an uptime field", lines[0])
+	}
+	if strings.Contains(lines[1], "uptime=") {
+		t.Errorf("entry with its own time = %q, want no uptime field", lines[1])
+	}
+	if c := CurrentConfig(); !c.Uptime {
+		t.Error("CurrentConfig().Uptime = false")
+	}
+}
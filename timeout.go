@@ -0,0 +1,92 @@
+package log
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrWriteTimeout is returned by a TimeoutWriter whose output did not accept
+// an entry in time.
+var ErrWriteTimeout = errors.New("write timed out")
+
+// TimeoutWriter bounds the time an output may block a write, so that a hung
+// NFS mount or a stalled TCP peer fails the write instead of blocking the
+// logging goroutines:
+//
+//	log.SetOutputs(log.NewTimeoutWriter(conn, time.Second))
+//
+// Outputs supporting write deadlines, such as net.Conn, get one before every
+// write. Other outputs are written from a separate goroutine; when that
+// write times out it is abandoned, may still complete later, and further
+// writes fail immediately until it returns.
+type TimeoutWriter struct {
+	w       io.Writer
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending chan struct{}
+}
+
+// NewTimeoutWriter returns a TimeoutWriter failing writes to w that take
+// longer than timeout.
+func NewTimeoutWriter(w io.Writer, timeout time.Duration) *TimeoutWriter {
+	return &TimeoutWriter{w: w, timeout: timeout}
+}
+
+// Name describes the wrapped output.
+func (t *TimeoutWriter) Name() string {
+	return outputName(t.w)
+}
+
+// Flush flushes the wrapped output if it buffers entries.
+func (t *TimeoutWriter) Flush() error {
+	if f, ok := t.w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Write writes p to the wrapped output, failing with ErrWriteTimeout if
+// that takes longer than the timeout.
+func (t *TimeoutWriter) Write(p []byte) (int, error) {
+	if d, ok := t.w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		// Regular files refuse deadlines and take the slow path below.
+		if err := d.SetWriteDeadline(time.Now().Add(t.timeout)); err == nil {
+			return t.w.Write(p)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pending != nil {
+		select {
+		case <-t.pending:
+			t.pending = nil
+		default:
+			return 0, ErrWriteTimeout
+		}
+	}
+
+	// The write may outlive this call, which must not keep p.
+	buf := append([]byte(nil), p...)
+	done := make(chan struct{})
+	var n int
+	var err error
+	go func() {
+		n, err = t.w.Write(buf)
+		close(done)
+	}()
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return n, err
+	case <-timer.C:
+		t.pending = done
+		return 0, ErrWriteTimeout
+	}
+}
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTimeoutWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewTimeoutWriter(&buf, time.Second)
+	if _, err := w.Write([]byte("fast\n")); err != nil {
+		t.Fatalf("Write() = %v, want nil", err)
+	}
+	if buf.String() != "fast\n" {
+		t.Errorf("output = %q, want %q", buf.String(), "fast\n")
+	}
+}
+
+func TestTimeoutWriterBlocked(t *testing.T) {
+	b := &blockingWriter{release: make(chan struct{})}
+	w := NewTimeoutWriter(b, 20*time.Millisecond)
+
+	start := time.Now()
+	if _, err := w.Write([]byte("stuck\n")); !errors.Is(err, ErrWriteTimeout) {
+		t.Fatalf("Write() = %v, want ErrWriteTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Write() blocked for %s", elapsed)
+	}
+	// The abandoned write is still running: fail without waiting again.
+	start = time.Now()
+	if _, err := w.Write([]byte("next\n")); !errors.Is(err, ErrWriteTimeout) {
+		t.Fatalf("second Write() = %v, want ErrWriteTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 20*time.Millisecond {
+		t.Errorf("second Write() waited %s, want immediate failure", elapsed)
+	}
+	if w.Name() != "nfs" {
+		t.Errorf("Name() = %q, want the wrapped output's name", w.Name())
+	}
+
+	close(b.release)
+	time.Sleep(10 * time.Millisecond)
+	if _, err := w.Write([]byte("again\n")); err != nil {
+		t.Errorf("Write() after recovery = %v, want nil", err)
+	}
+}
+
+func TestTimeoutWriterConn(t *testing.T) {
+	// Nobody reads the other end of the pipe: writes block.
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	w := NewTimeoutWriter(client, 20*time.Millisecond)
+	_, err := w.Write([]byte("stalled peer\n"))
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("Write() = %v, want a timeout", err)
+	}
+}
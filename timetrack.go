@@ -0,0 +1,62 @@
+package log
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Fields of TimeTrack.
+const (
+	// DurationKey is the time the operation took.
+	DurationKey = "duration"
+	// ThresholdKey is the threshold a slow operation exceeded.
+	ThresholdKey = "threshold"
+	// ContextErrorKey is why the context of the operation is done.
+	ContextErrorKey = "ctx_error"
+)
+
+// TimeTrack times an operation until the returned function is called,
+// typically deferred:
+//
+//	func rebuild(ctx context.Context) error {
+//		defer log.TimeTrack(ctx, "rebuild routing table", 200*time.Millisecond)()
+//		...
+//	}
+//
+// The operation is logged with its duration and the fields of ctx, with
+// severity DEBUG, or WARNING if it took longer than a positive threshold,
+// adding the field threshold, or if ctx was cancelled or its deadline
+// exceeded meanwhile, adding the field ctx_error. The entry is attributed
+// to the caller of TimeTrack.
+func TimeTrack(ctx context.Context, op string, threshold time.Duration) func() {
+	start := now()
+	site := captureCaller(log.WarnLevel, 1)
+	return func() {
+		d := now().Sub(start)
+		level := log.DebugLevel
+		fields := log.Fields{DurationKey: d}
+		if threshold > 0 && d > threshold {
+			level = log.WarnLevel
+			fields[ThresholdKey] = threshold
+		}
+		if err := ctx.Err(); err != nil {
+			level = log.WarnLevel
+			fields[ContextErrorKey] = err.Error()
+		}
+		if !enabled(level) {
+			return
+		}
+		if uint32(level) > atomic.LoadUint32(&callerLevel) {
+			site = callSite{}
+		}
+		for k, v := range ctxData(ctx) {
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+		emit(level, site, op, fields)
+	}
+}
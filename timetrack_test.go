@@ -0,0 +1,45 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeTrack(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer SetLevel(getLevel().String())
+	SetLevel("debug")
+
+	ctx := NewContext(context.Background(), Fields{"table": "main"})
+	TimeTrack(ctx, "fast", time.Hour)()
+	done := TimeTrack(ctx, "slow", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	done()
+	cancelled, cancel := context.WithCancel(ctx)
+	done = TimeTrack(cancelled, "cancelled", 0)
+	cancel()
+	done()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("wrote %q, want 3 entries", buf.String())
+	}
+	for i, want := range []struct{ level, text string }{
+		{"DEBUG", "fast duration="},
+		{"WARNING", "slow duration="},
+		{"WARNING", "cancelled ctx_error=\"context canceled\" duration="},
+	} {
+		if !strings.Contains(lines[i], want.level) || !strings.Contains(lines[i], "timetrack_test.go:") || !strings.Contains(lines[i], want.text) || !strings.Contains(lines[i], "table=main") {
+			t.Errorf("entry %d = %q, want %s %q", i, lines[i], want.level, want.text)
+		}
+	}
+	if !strings.Contains(lines[1], "threshold=1ms") {
+		t.Errorf("slow entry %q lacks the threshold", lines[1])
+	}
+}
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"crypto/tls"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TLSFields describes a TLS connection: tls_version, tls_cipher, tls_sni,
+// tls_alpn and tls_resumed and, if the peer presented a certificate,
+// tls_peer_subject, tls_peer_issuer, tls_peer_not_after and
+// tls_peer_expires_in for the leaf certificate.
+func TLSFields(cs tls.ConnectionState) Fields {
+	fields := Fields{
+		"tls_version": tls.VersionName(cs.Version),
+		"tls_cipher":  tls.CipherSuiteName(cs.CipherSuite),
+		"tls_sni":     cs.ServerName,
+		"tls_alpn":    cs.NegotiatedProtocol,
+		"tls_resumed": cs.DidResume,
+	}
+	if len(cs.PeerCertificates) > 0 {
+		leaf := cs.PeerCertificates[0]
+		fields["tls_peer_subject"] = leaf.Subject.String()
+		fields["tls_peer_issuer"] = leaf.Issuer.String()
+		fields["tls_peer_not_after"] = leaf.NotAfter.UTC().Format(time.RFC3339)
+		fields["tls_peer_expires_in"] = time.Until(leaf.NotAfter).Round(time.Second)
+	}
+	return fields
+}
+
+// DumpTLS logs cs with severity DEBUG, see TLSFields.
+func DumpTLS(cs tls.ConnectionState) {
+	if enabled(log.DebugLevel) {
+		output(log.DebugLevel, "tls connection", log.Fields(TLSFields(cs)))
+	}
+}
+
+// LogTLSHandshake logs every completed handshake with severity DEBUG. It
+// has the signature of tls.Config.VerifyConnection and always accepts the
+// connection:
+//
+//	cfg := &tls.Config{VerifyConnection: log.LogTLSHandshake}
+func LogTLSHandshake(cs tls.ConnectionState) error {
+	if enabled(log.DebugLevel) {
+		output(log.DebugLevel, "tls handshake", log.Fields(TLSFields(cs)))
+	}
+	return nil
+}
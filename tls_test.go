@@ -0,0 +1,35 @@
+package log
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+)
+
+func TestTLSFields(t *testing.T) {
+	cs := tls.ConnectionState{
+		Version:     tls.VersionTLS13,
+		CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+		ServerName:  "probe.example.net",
+		PeerCertificates: []*x509.Certificate{{
+			Subject:  pkix.Name{CommonName: "probe.example.net"},
+			NotAfter: time.Now().Add(48 * time.Hour),
+		}},
+	}
+
+	fields := TLSFields(cs)
+	if fields["tls_version"] != "TLS 1.3" {
+		t.Errorf("tls_version = %v, want TLS 1.3", fields["tls_version"])
+	}
+	if fields["tls_cipher"] != "TLS_AES_128_GCM_SHA256" {
+		t.Errorf("tls_cipher = %v", fields["tls_cipher"])
+	}
+	if fields["tls_peer_subject"] != "CN=probe.example.net" {
+		t.Errorf("tls_peer_subject = %v", fields["tls_peer_subject"])
+	}
+	if d, _ := fields["tls_peer_expires_in"].(time.Duration); d < 47*time.Hour {
+		t.Errorf("tls_peer_expires_in = %v, want about 48h", fields["tls_peer_expires_in"])
+	}
+}
@@ -0,0 +1,75 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Field keys of the trace of an entry, see AddTraceExtractor.
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// TraceExtractor returns the IDs of the trace and span active in ctx, or
+// empty strings if there is none. Extractors adapt a tracing system to the
+// logger without it depending on that system, e.g. for OpenTelemetry:
+//
+//	log.AddTraceExtractor(func(ctx context.Context) (string, string) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		if !sc.IsValid() {
+//			return "", ""
+//		}
+//		return sc.TraceID().String(), sc.SpanID().String()
+//	})
+//
+// Zipkin B3 or AWS X-Ray adapters return the IDs their propagators stored
+// in the context the same way.
+type TraceExtractor func(ctx context.Context) (traceID, spanID string)
+
+var traceExtractors = struct {
+	sync.Mutex
+	list atomic.Value // []TraceExtractor
+}{}
+
+// AddTraceExtractor registers fn to add the trace_id and span_id fields to
+// the entries logged with a context, through InfoCtx and the like or
+// FromContext. The first extractor finding a trace wins; fields stored
+// with NewContext under the same keys take precedence.
+func AddTraceExtractor(fn TraceExtractor) {
+	traceExtractors.Lock()
+	defer traceExtractors.Unlock()
+
+	list, _ := traceExtractors.list.Load().([]TraceExtractor)
+	traceExtractors.list.Store(append(list[:len(list):len(list)], fn))
+}
+
+// traceFields adds the trace active in ctx to fields, returning a copy of
+// fields if they change.
+func traceFields(ctx context.Context, fields Fields) Fields {
+	list, _ := traceExtractors.list.Load().([]TraceExtractor)
+	for _, fn := range list {
+		traceID, spanID := fn(ctx)
+		if traceID == "" {
+			continue
+		}
+		_, hasTrace := fields[TraceIDKey]
+		_, hasSpan := fields[SpanIDKey]
+		if hasTrace && (hasSpan || spanID == "") {
+			return fields
+		}
+		merged := make(Fields, len(fields)+2)
+		for k, v := range fields {
+			merged[k] = v
+		}
+		if !hasTrace {
+			merged[TraceIDKey] = traceID
+		}
+		if !hasSpan && spanID != "" {
+			merged[SpanIDKey] = spanID
+		}
+		return merged
+	}
+	return fields
+}
@@ -0,0 +1,44 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testSpanKey struct{}
+
+type testSpan struct{ trace, span string }
+
+func TestTraceExtractor(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	AddTraceExtractor(func(ctx context.Context) (string, string) {
+		if s, ok := ctx.Value(testSpanKey{}).(testSpan); ok {
+			return s.trace, s.span
+		}
+		return "", ""
+	})
+
+	ctx := context.WithValue(context.Background(), testSpanKey{}, testSpan{"4bf92f3577b34da6", "00f067aa0ba902b7"})
+	InfoCtx(NewContext(ctx, Fields{"request_id": "r1"}), "handled")
+	if !strings.Contains(buf.String(), "handled request_id=r1 span_id=00f067aa0ba902b7 trace_id=4bf92f3577b34da6\n") {
+		t.Errorf("output %q lacks the trace", buf.String())
+	}
+
+	buf.Reset()
+	InfoCtx(context.Background(), "untraced")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Errorf("output %q has a trace without a span in the context", buf.String())
+	}
+
+	buf.Reset()
+	FromContext(NewContext(ctx, Fields{TraceIDKey: "explicit"})).Info("override")
+	if !strings.Contains(buf.String(), "span_id=00f067aa0ba902b7 trace_id=explicit\n") {
+		t.Errorf("output = %q, want the trace_id of the context fields", buf.String())
+	}
+}
@@ -0,0 +1,284 @@
+package log
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// fieldKind identifies the type of the value held by a Field.
+type fieldKind uint8
+
+const (
+	anyKind fieldKind = iota
+	stringKind
+	intKind
+	uintKind
+	floatKind
+	boolKind
+	durationKind
+	timeKind
+	errorKind
+)
+
+// Field is a strongly typed key/value pair. Unlike Fields, constructing a
+// Field does not box its value into an interface, except for Time and Any,
+// so up to four typed fields of WithTyped only cost an allocation once the
+// entry is actually written.
+type Field struct {
+	Key string
+
+	kind fieldKind
+	num  int64
+	str  string
+	any  interface{}
+}
+
+// String returns a string field.
+func String(key, val string) Field {
+	return Field{Key: key, kind: stringKind, str: val}
+}
+
+// Int returns an integer field.
+func Int(key string, val int) Field {
+	return Field{Key: key, kind: intKind, num: int64(val)}
+}
+
+// Int64 returns a 64-bit integer field.
+func Int64(key string, val int64) Field {
+	return Field{Key: key, kind: intKind, num: val}
+}
+
+// Uint64 returns an unsigned 64-bit integer field.
+func Uint64(key string, val uint64) Field {
+	return Field{Key: key, kind: uintKind, num: int64(val)}
+}
+
+// Float64 returns a floating point field.
+func Float64(key string, val float64) Field {
+	return Field{Key: key, kind: floatKind, num: int64(math.Float64bits(val))}
+}
+
+// Bool returns a boolean field.
+func Bool(key string, val bool) Field {
+	f := Field{Key: key, kind: boolKind}
+	if val {
+		f.num = 1
+	}
+	return f
+}
+
+// Duration returns a duration field.
+func Duration(key string, val time.Duration) Field {
+	return Field{Key: key, kind: durationKind, num: int64(val)}
+}
+
+// Time returns a time field.
+func Time(key string, val time.Time) Field {
+	return Field{Key: key, kind: timeKind, any: val}
+}
+
+// Err returns a field named error holding err.
+func Err(err error) Field {
+	return Field{Key: "error", kind: errorKind, any: err}
+}
+
+// Any returns a field holding an arbitrary value.
+func Any(key string, val interface{}) Field {
+	return Field{Key: key, kind: anyKind, any: val}
+}
+
+// Value returns the value of f.
+func (f Field) Value() interface{} {
+	switch f.kind {
+	case stringKind:
+		return f.str
+	case intKind:
+		return f.num
+	case uintKind:
+		return uint64(f.num)
+	case floatKind:
+		return math.Float64frombits(uint64(f.num))
+	case boolKind:
+		return f.num == 1
+	case durationKind:
+		return time.Duration(f.num)
+	}
+	return f.any
+}
+
+// WithTyped returns a TypedLogger adding typed fields to every entry:
+//
+//	log.WithTyped(log.String("peer", addr), log.Duration("rtt", rtt)).Debug("probe")
+func WithTyped(fields ...Field) TypedLogger {
+	return typedLogger(nil, fields)
+}
+
+// WithTyped returns a TypedLogger adding typed fields to those of l.
+func (l *Logger) WithTyped(fields ...Field) TypedLogger {
+	return typedLogger(l, fields)
+}
+
+// maxFewTyped is the number of typed fields a TypedLogger holds by value.
+const maxFewTyped = 4
+
+// TypedLogger is the Logger of WithTyped. Up to four fields are held by
+// value rather than in a *Logger, so that they stay on the stack of the
+// caller and cost nothing if nothing is logged, without every Logger
+// carrying the room for them. Logger returns a *Logger with the fields for
+// the methods of Logger a TypedLogger lacks.
+type TypedLogger struct {
+	parent *Logger
+	few    [maxFewTyped]Field
+	n      int
+	// more holds the fields of a call with more than maxFewTyped.
+	more []Field
+}
+
+// typedLogger returns a child of parent adding fields. It copies fields
+// rather than keeping the slice, which would otherwise escape: the
+// variadic array of WithTyped stays on the stack of the caller.
+func typedLogger(parent *Logger, fields []Field) TypedLogger {
+	t := TypedLogger{parent: parent}
+	if len(fields) <= maxFewTyped {
+		t.n = copy(t.few[:], fields)
+	} else {
+		t.more = append([]Field(nil), fields...)
+	}
+	return t
+}
+
+// Logger returns a *Logger adding the fields of t.
+func (t TypedLogger) Logger() *Logger {
+	if t.more != nil {
+		return &Logger{parent: t.parent, typed: t.more}
+	}
+	return &Logger{parent: t.parent, typed: append([]Field(nil), t.few[:t.n]...)}
+}
+
+// With returns a Logger adding the alternating keys and values to the
+// fields of t, like Logger.With.
+func (t TypedLogger) With(keysAndValues ...interface{}) *Logger {
+	return t.Logger().With(keysAndValues...)
+}
+
+// WithTyped returns a TypedLogger adding typed fields to those of t.
+func (t TypedLogger) WithTyped(fields ...Field) TypedLogger {
+	return typedLogger(t.Logger(), fields)
+}
+
+// data returns the fields of an entry of t.
+func (t TypedLogger) data() log.Fields {
+	var data log.Fields
+	if t.parent != nil {
+		data = t.parent.data()
+	} else {
+		data = make(log.Fields, t.n+len(t.more))
+	}
+	for _, f := range t.few[:t.n] {
+		data[f.Key] = f.Value()
+	}
+	for _, f := range t.more {
+		data[f.Key] = f.Value()
+	}
+	return data
+}
+
+// Trace logs a message with severity TRACE.
+func (t TypedLogger) Trace(v ...interface{}) {
+	if t.parent.enabled(traceLevel) {
+		output(traceLevel, fmt.Sprint(v...), t.data())
+	}
+}
+
+// Debug logs a message with severity DEBUG.
+func (t TypedLogger) Debug(v ...interface{}) {
+	if t.parent.enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprint(v...), t.data())
+	}
+}
+
+// Info logs a message with severity INFO.
+func (t TypedLogger) Info(v ...interface{}) {
+	if t.parent.enabled(log.InfoLevel) {
+		output(log.InfoLevel, fmt.Sprint(v...), t.data())
+	}
+}
+
+// Warning logs a message with severity WARNING.
+func (t TypedLogger) Warning(v ...interface{}) {
+	if t.parent.enabled(log.WarnLevel) {
+		output(log.WarnLevel, fmt.Sprint(v...), t.data())
+	}
+}
+
+// Error logs a message with severity ERROR.
+func (t TypedLogger) Error(v ...interface{}) {
+	if t.parent.enabled(log.ErrorLevel) {
+		output(log.ErrorLevel, fmt.Sprint(v...), t.data())
+	}
+}
+
+// Fatal logs a message with severity ERROR followed by a call to os.Exit().
+func (t TypedLogger) Fatal(v ...interface{}) {
+	output(log.FatalLevel, fmt.Sprint(v...), t.data())
+}
+
+// Panic logs a message with severity PANIC followed by a call to panic with
+// the message.
+func (t TypedLogger) Panic(v ...interface{}) {
+	msg := fmt.Sprint(v...)
+	output(log.PanicLevel, msg, t.data())
+	panic(msg)
+}
+
+// Tracef logs a formatted message with severity TRACE.
+func (t TypedLogger) Tracef(format string, v ...interface{}) {
+	if t.parent.enabled(traceLevel) {
+		output(traceLevel, fmt.Sprintf(format, v...), t.data())
+	}
+}
+
+// Debugf logs a formatted message with severity DEBUG.
+func (t TypedLogger) Debugf(format string, v ...interface{}) {
+	if t.parent.enabled(log.DebugLevel) {
+		output(log.DebugLevel, fmt.Sprintf(format, v...), t.data())
+	}
+}
+
+// Infof logs a formatted message with severity INFO.
+func (t TypedLogger) Infof(format string, v ...interface{}) {
+	if t.parent.enabled(log.InfoLevel) {
+		output(log.InfoLevel, fmt.Sprintf(format, v...), t.data())
+	}
+}
+
+// Warningf logs a formatted message with severity WARNING.
+func (t TypedLogger) Warningf(format string, v ...interface{}) {
+	if t.parent.enabled(log.WarnLevel) {
+		output(log.WarnLevel, fmt.Sprintf(format, v...), t.data())
+	}
+}
+
+// Errorf logs a formatted message with severity ERROR.
+func (t TypedLogger) Errorf(format string, v ...interface{}) {
+	if t.parent.enabled(log.ErrorLevel) {
+		output(log.ErrorLevel, fmt.Sprintf(format, v...), t.data())
+	}
+}
+
+// Fatalf logs a formatted message with severity ERROR followed by a call to
+// os.Exit().
+func (t TypedLogger) Fatalf(format string, v ...interface{}) {
+	output(log.FatalLevel, fmt.Sprintf(format, v...), t.data())
+}
+
+// Panicf logs a formatted message with severity PANIC followed by a call to
+// panic with the message.
+func (t TypedLogger) Panicf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	output(log.PanicLevel, msg, t.data())
+	panic(msg)
+}
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypedFields(t *testing.T) {
+	err := errors.New("refused")
+	data := WithTyped(
+		String("peer", "192.0.2.7"),
+		Int("port", 443),
+		Uint64("bytes", 1<<63),
+		Float64("loss", 0.25),
+		Bool("tls", true),
+		Duration("rtt", 3*time.Millisecond),
+		Err(err),
+	).With("legacy", 1, Int("mixed", 2)).data()
+
+	want := map[string]interface{}{
+		"peer":   "192.0.2.7",
+		"port":   int64(443),
+		"bytes":  uint64(1 << 63),
+		"loss":   0.25,
+		"tls":    true,
+		"rtt":    3 * time.Millisecond,
+		"error":  err,
+		"legacy": 1,
+		"mixed":  int64(2),
+	}
+	for k, v := range want {
+		if data[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, data[k], v)
+		}
+	}
+}
+
+func TestWithTypedDisabled(t *testing.T) {
+	defer SetLevel(getLevel().String())
+	SetLevel("info")
+	port := 443
+	if n := testing.AllocsPerRun(100, func() {
+		WithTyped(String("peer", "192.0.2.7"), Int("port", port), Bool("tls", true)).Debug("probe")
+	}); n != 0 {
+		t.Errorf("disabled typed entry allocates %v times", n)
+	}
+
+	data := WithTyped(Int("a", 1), Int("b", 2), Int("c", 3), Int("d", 4), Int("e", 5)).WithTyped(Int("f", 6)).data()
+	for i, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		if data[k] != int64(i+1) {
+			t.Errorf("%s = %#v, want %d", k, data[k], i+1)
+		}
+	}
+}
+
+func TestTypedLoggerCaller(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	WithFields(Fields{"iface": "eth1"}).WithTyped(Int("port", 443)).Warningf("probe %d failed", 3)
+	if out := buf.String(); !regexp.MustCompile(`\ttyped_test\.go:\d+\[|/typed_test\.go:\d+\[`).MatchString(out) ||
+		!strings.HasSuffix(out, "probe 3 failed iface=eth1 port=443\n") {
+		t.Errorf("output = %q, want the caller and the fields", out)
+	}
+}
+
+func BenchmarkWithTypedDisabled(b *testing.B) {
+	SetLevel("info")
+	defer SetLevel("debug")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		WithTyped(String("peer", "192.0.2.7"), Int("port", i)).Debug("probe")
+	}
+}
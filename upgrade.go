@@ -0,0 +1,126 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// UpgradedFromKey is the field recording the original severity of entries
+// escalated by an upgrade rule.
+const UpgradedFromKey = "upgraded_from"
+
+// Matcher selects entries by message and fields.
+type Matcher func(msg string, fields Fields) bool
+
+// MessageContains matches entries whose message contains s.
+func MessageContains(s string) Matcher {
+	return func(msg string, _ Fields) bool {
+		return strings.Contains(msg, s)
+	}
+}
+
+// FieldAbove matches entries whose field key holds a number greater than n.
+func FieldAbove(key string, n float64) Matcher {
+	return func(_ string, fields Fields) bool {
+		v, ok := toFloat(fields[key])
+		return ok && v > n
+	}
+}
+
+// FieldEquals matches entries whose field key holds value.
+func FieldEquals(key string, value interface{}) Matcher {
+	return func(_ string, fields Fields) bool {
+		v, ok := fields[key]
+		return ok && v == value
+	}
+}
+
+type upgradeRule struct {
+	level log.Level
+	match Matcher
+}
+
+var upgrades = struct {
+	sync.RWMutex
+	rules []upgradeRule
+}{}
+
+// AddUpgradeRule escalates entries selected by match to level before they
+// are filtered and routed, so that known-critical events reach outputs that
+// only receive errors:
+//
+//	log.AddUpgradeRule("error", log.MessageContains("corrupt"))
+//	log.AddUpgradeRule("warn", log.FieldAbove("retries", 5))
+//
+// Rules never lower the severity of an entry. Level is one of error, warn,
+// info and debug; escalating to fatal or panic is refused as it would end
+// the process.
+func AddUpgradeRule(level string, match Matcher) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	if lvl <= log.FatalLevel {
+		return fmt.Errorf("cannot upgrade entries to %s", lvl)
+	}
+
+	upgrades.Lock()
+	upgrades.rules = append(upgrades.rules, upgradeRule{lvl, match})
+	upgrades.Unlock()
+	return nil
+}
+
+// ClearUpgradeRules removes every upgrade rule.
+func ClearUpgradeRules() {
+	upgrades.Lock()
+	upgrades.rules = nil
+	upgrades.Unlock()
+}
+
+// upgradesActive reports whether any upgrade rule is set.
+func upgradesActive() bool {
+	upgrades.RLock()
+	defer upgrades.RUnlock()
+	return len(upgrades.rules) > 0
+}
+
+// upgrade returns the severity of an entry after applying the rules. The
+// original severity is recorded in fields when it changes.
+func upgrade(level log.Level, msg string, fields log.Fields) (log.Level, log.Fields) {
+	upgrades.RLock()
+	defer upgrades.RUnlock()
+
+	to := level
+	for _, r := range upgrades.rules {
+		if r.level < to && r.match(msg, Fields(fields)) {
+			to = r.level
+		}
+	}
+	if to == level {
+		return level, fields
+	}
+	if fields == nil {
+		fields = make(log.Fields, 1)
+	}
+	fields[UpgradedFromKey] = severityName(level)
+	return to, fields
+}
+
+// toFloat converts numeric field values, including those of named types
+// such as time.Duration.
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUpgradeRules(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	SetLevel("info")
+	defer SetLevel("debug")
+	defer ClearUpgradeRules()
+
+	if err := AddUpgradeRule("error", MessageContains("corrupt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddUpgradeRule("warn", FieldAbove("retries", 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	Debug("page checksum corrupt")
+	With("retries", 7).Info("reconnected")
+	With("retries", 2).Info("reconnected quickly")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	for i, want := range []string{
+		": ERROR\t",
+		": WARNING\t",
+		": INFO\t",
+	} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+	if !strings.Contains(lines[0], "upgraded_from=debug") {
+		t.Errorf("line %q does not record the original severity", lines[0])
+	}
+	if strings.Contains(lines[2], UpgradedFromKey) {
+		t.Errorf("line %q marked as upgraded", lines[2])
+	}
+}
+
+func TestUpgradeRuleNeverDowngrades(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+	defer ClearUpgradeRules()
+
+	AddUpgradeRule("info", MessageContains("disk"))
+	Error("disk full")
+	if !strings.Contains(buf.String(), ": ERROR\t") {
+		t.Errorf("output = %q, want severity ERROR kept", buf.String())
+	}
+}
+
+func TestAddUpgradeRuleInvalid(t *testing.T) {
+	defer ClearUpgradeRules()
+	for _, level := range []string{"fatal", "panic", "loud"} {
+		if err := AddUpgradeRule(level, MessageContains("x")); err == nil {
+			t.Errorf("AddUpgradeRule(%q) = nil, want error", level)
+		}
+	}
+}
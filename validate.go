@@ -0,0 +1,106 @@
+package log
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ConfigProblem is a combination of settings of the package logger that
+// does not work as configured, see CheckConfig.
+type ConfigProblem struct {
+	// Settings names the settings involved, e.g. color and outputs.
+	Settings []string
+	// Problem describes what goes wrong.
+	Problem string
+}
+
+// Error returns the settings and the problem, so that p can be returned as
+// an error.
+func (p ConfigProblem) Error() string {
+	return strings.Join(p.Settings, ", ") + ": " + p.Problem
+}
+
+// CheckConfig returns the combinations of settings of the package logger
+// that contradict each other, such as color on a log file or sampling in
+// front of an audit file. Init, its presets and Reconfigure log each of
+// them as a WARNING entry "configuration mismatch" once the configuration
+// is applied, before the entries of the application; a program refusing to
+// start with them calls CheckConfig after its setup.
+func CheckConfig() []ConfigProblem {
+	var problems []ConfigProblem
+	add := func(problem string, settings ...string) {
+		problems = append(problems, ConfigProblem{Settings: settings, Problem: problem})
+	}
+
+	current.Lock()
+	var outputs []io.Writer
+	if current.output != nil {
+		outputs = current.output.outputs
+	}
+	current.Unlock()
+
+	f := packageFormatter()
+	if f.Color {
+		if format != TextFormat && format != CustomFormat {
+			add("colors only apply to the text format", "color", "format")
+		} else {
+			for _, w := range outputs {
+				if name, ok := fileOutput(w); ok {
+					add("ANSI color codes are written to the file "+name, "color", "outputs")
+				}
+			}
+		}
+	}
+	if atomic.LoadInt32(&reportCaller) == 0 && f.CallerFunction {
+		add("the caller function is not shown since callers are not recorded", "caller_function", "caller_level")
+	}
+
+	for _, w := range outputs {
+		a, ok := w.(*AuditFile)
+		if !ok {
+			continue
+		}
+		sampling.mu.Lock()
+		sampled := sampling.rate < 1
+		sampling.mu.Unlock()
+		if sampled {
+			add("the audit file "+a.path+" misses the entries dropped by sampling", "sampling", "outputs")
+		}
+		limiter.mu.Lock()
+		limited := limiter.rate > 0
+		limiter.mu.Unlock()
+		if limited {
+			add("the audit file "+a.path+" misses the entries dropped by the rate limit", "rate_limit", "outputs")
+		}
+		if atomic.LoadInt32(&dedup.active) != 0 {
+			add("the audit file "+a.path+" misses the duplicates dropped by deduplication", "dedup", "outputs")
+		}
+	}
+	return problems
+}
+
+// fileOutput returns the name of w if it is a file, a terminal excepted.
+func fileOutput(w io.Writer) (string, bool) {
+	switch f := w.(type) {
+	case *os.File:
+		return f.Name(), !isTerminal(f)
+	case *rotatingFile, *lazyFile:
+		return outputName(w), true
+	}
+	return "", false
+}
+
+// reportConfigProblems logs the problems found by CheckConfig as coming
+// from site.
+func reportConfigProblems(site callSite) {
+	for _, p := range CheckConfig() {
+		emit(log.WarnLevel, site, "configuration mismatch", log.Fields{
+			"settings": strings.Join(p.Settings, ","),
+			"problem":  p.Problem,
+		})
+	}
+}
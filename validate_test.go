@@ -0,0 +1,113 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hasProblem reports whether problems involve setting with a problem
+// containing text.
+func hasProblem(problems []ConfigProblem, setting, text string) bool {
+	for _, p := range problems {
+		for _, s := range p.Settings {
+			if s == setting && strings.Contains(p.Problem, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestCheckConfig(t *testing.T) {
+	defer SetOutputs(os.Stderr)
+	defer SetColor(false)
+	defer SetFormat(TextFormat)
+	dir := t.TempDir()
+
+	SetOutputs(&bytes.Buffer{})
+	if problems := CheckConfig(); len(problems) != 0 {
+		t.Errorf("default configuration has problems %v", problems)
+	}
+
+	SetColor(true)
+	SetFormat(JSONFormat)
+	if p := CheckConfig(); !hasProblem(p, "format", "text format") {
+		t.Errorf("color with JSON: problems %v", p)
+	}
+	SetFormat(TextFormat)
+	f, err := os.Create(filepath.Join(dir, "probe.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	SetOutputs(f)
+	if p := CheckConfig(); !hasProblem(p, "color", f.Name()) {
+		t.Errorf("color on a file: problems %v", p)
+	}
+	SetColor(false)
+
+	a, err := OpenAuditFile(filepath.Join(dir, "audit.log"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	SetOutputs(a)
+	SetSampling(0.5)
+	defer SetSampling(1)
+	SetRateLimit(100, 10)
+	defer SetRateLimit(0, 0)
+	p := CheckConfig()
+	if !hasProblem(p, "sampling", "audit.log") || !hasProblem(p, "rate_limit", "audit.log") {
+		t.Errorf("sampled audit file: problems %v", p)
+	}
+	if len(p) > 0 && !strings.HasPrefix(p[0].Error(), "sampling, outputs: the audit file") {
+		t.Errorf("Error() = %q", p[0].Error())
+	}
+}
+
+func TestInitReportsConfigProblems(t *testing.T) {
+	resetInit(t)
+	name := filepath.Join(t.TempDir(), "probe.log")
+	SetColor(true)
+	Init(name, "info")
+	Info("started")
+	Flush()
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "configuration mismatch") || !strings.Contains(lines[0], "settings=color,outputs") || !strings.Contains(lines[1], "started") {
+		t.Errorf("log file = %q, want the mismatch reported first", b)
+	}
+	if !strings.Contains(lines[0], "validate_test.go:") {
+		t.Errorf("mismatch %q is not reported at the Init call", lines[0])
+	}
+}
+
+func TestReconfigureReportsConfigProblems(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "probe.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	SetOutputs(f)
+	defer SetOutputs(os.Stderr)
+	SetColor(true)
+	defer SetColor(false)
+	defer SetLevel("debug")
+
+	c := CurrentConfig()
+	c.Level = "info"
+	if err := Reconfigure(c); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(f.Name())
+	if !strings.Contains(string(b), "configuration changed") || !strings.Contains(string(b), "configuration mismatch") {
+		t.Errorf("log file = %q, want the change and the mismatch", b)
+	}
+}
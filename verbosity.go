@@ -0,0 +1,155 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// traceLevel is the level of Trace, below debug, which logrus does not
+// have. V(n) logs n levels below debug, traceLevel itself for V(1), from
+// the verbosity names v1, v2 and so on of SetLevel. All these levels are
+// written with the trace severity.
+const traceLevel = log.DebugLevel + 1
+
+// VerbosityKey is the field holding the verbosity of the entries of V.
+const VerbosityKey = "v"
+
+// allLevels are the levels of log.AllLevels and trace.
+var allLevels = append(log.AllLevels[:len(log.AllLevels):len(log.AllLevels)], traceLevel)
+
+// verbosityLevel returns the level of the entries of V(n).
+func verbosityLevel(n int) log.Level {
+	if n < 0 {
+		n = 0
+	}
+	return log.DebugLevel + log.Level(n)
+}
+
+// parseVerbosity parses a verbosity name such as v3.
+func parseVerbosity(s string) (log.Level, bool) {
+	if !strings.HasPrefix(s, "v") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n < 0 || s[1] == '+' {
+		return 0, false
+	}
+	return verbosityLevel(n), true
+}
+
+// levelName returns the name of a level as accepted by SetLevel: trace for
+// V(1) and v2, v3 and so on below.
+func levelName(lvl log.Level) string {
+	switch {
+	case lvl <= log.DebugLevel:
+		return lvl.String()
+	case lvl == traceLevel:
+		return "trace"
+	}
+	return "v" + strconv.Itoa(int(lvl-log.DebugLevel))
+}
+
+// severityOf returns the severity entries at level are written with.
+func severityOf(level log.Level) log.Level {
+	if level > traceLevel {
+		return traceLevel
+	}
+	return level
+}
+
+// severityName returns the level name entries at level are written with.
+func severityName(level log.Level) string {
+	return levelName(severityOf(level))
+}
+
+// Trace logs a message with severity TRACE, below DEBUG, written only at
+// the trace level or more verbose, e.g. for packet-level diagnostics too
+// chatty for debug. Logrus has no trace level: trace entries go to the
+// outputs but only to the hooks registered for trace explicitly.
+func Trace(v ...interface{}) {
+	if enabled(traceLevel) {
+		output(traceLevel, fmt.Sprint(v...), nil)
+	}
+}
+
+// Tracef logs a formatted message with severity TRACE.
+func Tracef(format string, v ...interface{}) {
+	if enabled(traceLevel) {
+		output(traceLevel, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+// Trace logs a message with severity TRACE.
+func (l *Logger) Trace(v ...interface{}) {
+	if l.enabled(traceLevel) {
+		output(traceLevel, fmt.Sprint(v...), l.data())
+	}
+}
+
+// Tracef logs a formatted message with severity TRACE.
+func (l *Logger) Tracef(format string, v ...interface{}) {
+	if l.enabled(traceLevel) {
+		output(traceLevel, fmt.Sprintf(format, v...), l.data())
+	}
+}
+
+// Verbose logs the entries of a verbosity, see V.
+type Verbose struct {
+	l *Logger
+	n int
+}
+
+// V returns the logger of verbosity n, whose entries are written with
+// severity TRACE and the field v=n once the level is vn or more verbose,
+// e.g. with SetLevel("v3") for
+//
+//	log.V(3).Info("rx ", pkt)
+//
+// The level trace is v1 and debug is v0. Check Enabled before building
+// expensive messages.
+func V(n int) Verbose {
+	return Verbose{n: n}
+}
+
+// V returns the logger of verbosity n of l, see the V function.
+func (l *Logger) V(n int) Verbose {
+	return Verbose{l: l, n: n}
+}
+
+// Enabled reports whether the entries of v may be written.
+func (v Verbose) Enabled() bool {
+	if v.l == nil {
+		return enabled(verbosityLevel(v.n))
+	}
+	return v.l.enabled(verbosityLevel(v.n))
+}
+
+// Info logs a message at the verbosity of v.
+func (v Verbose) Info(args ...interface{}) {
+	if v.Enabled() {
+		output(verbosityLevel(v.n), fmt.Sprint(args...), v.data())
+	}
+}
+
+// Infof logs a formatted message at the verbosity of v.
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if v.Enabled() {
+		output(verbosityLevel(v.n), fmt.Sprintf(format, args...), v.data())
+	}
+}
+
+// data returns the entry data of v.
+func (v Verbose) data() log.Fields {
+	var data log.Fields
+	if v.l != nil {
+		data = v.l.data()
+	}
+	if data == nil {
+		data = make(log.Fields, 1)
+	}
+	data[VerbosityKey] = v.n
+	return data
+}
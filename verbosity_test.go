@@ -0,0 +1,100 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	defer SetLevel("debug")
+
+	SetLevel("debug")
+	Trace("rx frame")
+	if V(1).Enabled() {
+		t.Error("V(1) enabled at debug")
+	}
+	if out.String() != "" {
+		t.Fatalf("trace entry written at debug: %q", out.String())
+	}
+
+	SetLevel("trace")
+	Tracef("rx frame %d", 1)
+	V(1).Info("rx frame 2")
+	V(2).Info("rx payload")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %q, want 2 lines", out.String())
+	}
+	if !strings.Contains(lines[0], ": TRACE\t") || !strings.Contains(lines[0], "verbosity_test.go:") || !strings.HasSuffix(lines[0], "rx frame 1") {
+		t.Errorf("Tracef wrote %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "rx frame 2 v=1") {
+		t.Errorf("V(1).Info wrote %q", lines[1])
+	}
+	if got := CurrentConfig().Level; got != "trace" {
+		t.Errorf("CurrentConfig().Level = %q, want trace", got)
+	}
+	if n := Stats().Entries["trace"]; n < 2 {
+		t.Errorf(`Stats().Entries["trace"] = %d, want at least 2`, n)
+	}
+}
+
+func TestVerbosity(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	defer SetLevel("debug")
+	SetFormat(JSONFormat)
+	defer SetFormat(TextFormat)
+
+	if err := SetLevel("v3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := CurrentConfig().Level; got != "v3" {
+		t.Errorf("CurrentConfig().Level = %q, want v3", got)
+	}
+	V(3).Infof("rx %d bytes", 64)
+	V(4).Info("rx payload")
+	WithFields(Fields{"iface": "eth1"}).V(2).Info("rx frame")
+
+	var got []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		got = append(got, m)
+	}
+	if len(got) != 2 {
+		t.Fatalf("wrote %v, want 2 entries", got)
+	}
+	if got[0]["level"] != "trace" || got[0]["v"] != 3.0 || got[0]["msg"] != "rx 64 bytes" {
+		t.Errorf("V(3).Infof wrote %v", got[0])
+	}
+	if got[1]["level"] != "trace" || got[1]["v"] != 2.0 || got[1]["iface"] != "eth1" {
+		t.Errorf("Logger.V(2).Info wrote %v", got[1])
+	}
+}
+
+func TestComponentTrace(t *testing.T) {
+	var out syncBuffer
+	SetOutputs(&out)
+	defer SetOutputs(os.Stderr)
+	defer SetLevel("debug")
+	c := RegisterComponent("capture")
+	if err := SetComponentLevels("*=info,capture=trace"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetComponentLevels("")
+
+	WithComponent(c).Trace("rx frame")
+	Trace("elsewhere")
+	if got := out.String(); !strings.Contains(got, "rx frame") || strings.Contains(got, "elsewhere") {
+		t.Errorf("wrote %q, want only the trace entry of capture", got)
+	}
+}
@@ -0,0 +1,156 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// busyReporter is implemented by components writing entries in the
+// background. busySince returns when the write in progress started, or the
+// zero time when idle.
+type busyReporter interface {
+	busySince() time.Time
+}
+
+var watchdog = struct {
+	sync.Mutex
+	stall  time.Duration
+	stop   chan struct{}
+	wedged map[string]bool
+}{}
+
+// SetWatchdog starts watching the outputs and forwarders of the package
+// logger. A write that has not returned after stall, e.g. to a file on a hung
+// NFS mount, is reported to the self-log and makes Healthy fail until it
+// completes. A non-positive stall stops the watchdog.
+func SetWatchdog(stall time.Duration) {
+	watchdog.Lock()
+	defer watchdog.Unlock()
+
+	if watchdog.stop != nil {
+		close(watchdog.stop)
+		watchdog.stop = nil
+	}
+	watchdog.stall = stall
+	watchdog.wedged = nil
+	if stall <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	watchdog.stop = stop
+	go func() {
+		t := time.NewTicker(stall / 2)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				checkWedged(stall)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// checkWedged reports components that became wedged or recovered since the
+// last check.
+func checkWedged(stall time.Duration) {
+	now := wedged(time.Now(), stall)
+
+	watchdog.Lock()
+	prev := watchdog.wedged
+	watchdog.wedged = make(map[string]bool, len(now))
+	for name, d := range now {
+		watchdog.wedged[name] = true
+		if !prev[name] {
+			reportError(fmt.Errorf("output %s has made no progress for %s", name, d.Round(time.Millisecond)))
+		}
+	}
+	for name := range prev {
+		if _, ok := now[name]; !ok {
+			reportError(fmt.Errorf("output %s made progress again", name))
+		}
+	}
+	watchdog.Unlock()
+}
+
+// wedged returns the components whose current write started more than
+// stall before now, with the time they have been stuck. It must not take
+// the locks held while writing.
+func wedged(now time.Time, stall time.Duration) map[string]time.Duration {
+	current.Lock()
+	mw := current.output
+	flushers := current.flushers
+	current.Unlock()
+
+	stuck := make(map[string]time.Duration)
+	check := func(name string, since time.Time) {
+		if !since.IsZero() && now.Sub(since) > stall {
+			stuck[name] = now.Sub(since)
+		}
+	}
+	if mw != nil {
+		for i, w := range mw.outputs {
+			check(outputName(w), unixNano(atomic.LoadInt64(&mw.metrics[i].started)))
+			if b, ok := w.(busyReporter); ok {
+				check(outputName(w), b.busySince())
+			}
+		}
+	}
+	for _, f := range flushers {
+		if b, ok := f.(busyReporter); ok {
+			check(outputName(f), b.busySince())
+		}
+	}
+	return stuck
+}
+
+// Healthy returns an error naming the outputs that the watchdog considers
+// wedged, or nil. It always returns nil when SetWatchdog is not active.
+func Healthy() error {
+	watchdog.Lock()
+	stall := watchdog.stall
+	watchdog.Unlock()
+	if stall <= 0 {
+		return nil
+	}
+
+	stuck := wedged(time.Now(), stall)
+	if len(stuck) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(stuck))
+	for name, d := range stuck {
+		names = append(names, fmt.Sprintf("%s (%s)", name, d.Round(time.Millisecond)))
+	}
+	sort.Strings(names)
+	return fmt.Errorf("outputs not making progress: %s", strings.Join(names, ", "))
+}
+
+// HealthHandler returns an HTTP handler answering 200 while Healthy returns
+// nil and 503 with the error otherwise, for use as a health check.
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := Healthy(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// unixNano converts Unix nanoseconds to a time, zero staying zero.
+func unixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
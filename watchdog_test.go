@@ -0,0 +1,67 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks every write until release is closed.
+type blockingWriter struct{ release chan struct{} }
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func (*blockingWriter) Name() string { return "nfs" }
+
+func TestWatchdog(t *testing.T) {
+	var selfOut syncBuffer
+	SetSelfLog(&selfOut)
+	defer SetSelfLog(os.Stderr)
+
+	w := &blockingWriter{release: make(chan struct{})}
+	SetOutputs(w)
+	defer SetOutputs(os.Stderr)
+	SetWatchdog(50 * time.Millisecond)
+	defer SetWatchdog(0)
+
+	done := make(chan struct{})
+	go func() {
+		Info("stuck")
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(selfOut.String(), "no progress") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(selfOut.String(), "output nfs has made no progress") {
+		t.Errorf("self-log = %q, want wedged output reported", selfOut.String())
+	}
+	if err := Healthy(); err == nil || !strings.Contains(err.Error(), "nfs") {
+		t.Errorf("Healthy() = %v, want error naming nfs", err)
+	}
+	rec := httptest.NewRecorder()
+	HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("HealthHandler status = %d, want 503", rec.Code)
+	}
+
+	close(w.release)
+	<-done
+	if err := Healthy(); err != nil {
+		t.Errorf("Healthy() after release = %v, want nil", err)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for !strings.Contains(selfOut.String(), "progress again") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(selfOut.String(), "output nfs made progress again") {
+		t.Errorf("self-log = %q, want recovery reported", selfOut.String())
+	}
+}
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Restart delays of SafeGo.
+const (
+	minRestartDelay = 100 * time.Millisecond
+	maxRestartDelay = time.Minute
+)
+
+// Go runs fn in a new goroutine. If fn panics, the panic is recovered and
+// logged with severity ERROR, like LogPanic, together with the stack of the
+// goroutine; the entry is attributed to fn.
+func Go(fn func()) {
+	(*Logger)(nil).Go(fn)
+}
+
+// SafeGo is like Go but restarts fn after a panic, waiting between 100ms and
+// a minute, doubling with every consecutive panic. It stops once fn returns
+// normally. The restarts field of the entry counts the restarts so far.
+func SafeGo(fn func()) {
+	(*Logger)(nil).SafeGo(fn)
+}
+
+// Go is like the package-level Go but adds the fields of l, e.g. the
+// identity of a worker, to the entry logged on panic.
+func (l *Logger) Go(fn func()) {
+	go l.runWorker(fn, 0)
+}
+
+// SafeGo is like the package-level SafeGo but adds the fields of l to the
+// entries logged on panic.
+func (l *Logger) SafeGo(fn func()) {
+	go func() {
+		delay := minRestartDelay
+		for restarts := 0; ; restarts++ {
+			start := time.Now()
+			if !l.runWorker(fn, restarts) {
+				return
+			}
+			// A worker that ran for a while before panicking is not
+			// crash looping.
+			if time.Since(start) > maxRestartDelay {
+				delay = minRestartDelay
+			}
+			time.Sleep(delay)
+			if delay *= 2; delay > maxRestartDelay {
+				delay = maxRestartDelay
+			}
+		}
+	}()
+}
+
+// runWorker calls fn and reports whether it panicked.
+func (l *Logger) runWorker(fn func(), restarts int) (panicked bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		panicked = true
+
+		var fields log.Fields
+		if l != nil {
+			fields = l.data()
+		} else {
+			fields = make(log.Fields, 5)
+		}
+		for k, v := range panicFields(r) {
+			fields[k] = v
+		}
+		fields[StackKey] = string(debug.Stack())
+		if restarts > 0 {
+			fields["restarts"] = restarts
+		}
+
+		site := callSite{pc: reflect.ValueOf(fn).Pointer()}
+		site.file, site.line = runtime.FuncForPC(site.pc).FileLine(site.pc)
+		emit(log.ErrorLevel, site, "recovered panic", fields)
+	}()
+
+	fn()
+	return false
+}
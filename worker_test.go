@@ -0,0 +1,55 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGo(t *testing.T) {
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	With("worker", 3).Go(func() { panic("queue closed") })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(buf.String(), "recovered panic") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := buf.String()
+	for _, want := range []string{": ERROR\t", "worker_test.go:", "worker=3", "panic_message=\"queue closed\"", "stack=", "TestGo"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+}
+
+func TestSafeGo(t *testing.T) {
+	var buf syncBuffer
+	SetOutputs(&buf)
+	defer SetOutputs(os.Stderr)
+
+	var runs int32
+	done := make(chan struct{})
+	SafeGo(func() {
+		if atomic.AddInt32(&runs, 1) < 3 {
+			panic("flaky")
+		}
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker was not restarted")
+	}
+	if n := strings.Count(buf.String(), "recovered panic"); n != 2 {
+		t.Errorf("logged %d panics, want 2", n)
+	}
+	if !strings.Contains(buf.String(), "restarts=1") {
+		t.Errorf("output %q does not count the restarts", buf.String())
+	}
+}